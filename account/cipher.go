@@ -0,0 +1,24 @@
+package account
+
+import (
+	"fmt"
+	"github.com/hacash/core/crypto/btcec"
+)
+
+// ECIES 加密，只有公钥对应的私钥持有者可以解密
+func EncryptByPublicKey(publicKeyBytes33 []byte, msg []byte) ([]byte, error) {
+	pubKey, e1 := btcec.ParsePubKey(publicKeyBytes33, btcec.S256())
+	if e1 != nil {
+		return nil, e1
+	}
+	return btcec.Encrypt(pubKey, msg)
+}
+
+// ECIES 解密
+func DecryptByPrivateKey(privateKeyBytes32 []byte, data []byte) ([]byte, error) {
+	if len(privateKeyBytes32) != 32 {
+		return nil, fmt.Errorf("Private key length is not 32")
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), privateKeyBytes32)
+	return btcec.Decrypt(privKey, data)
+}
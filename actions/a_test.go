@@ -1,9 +1,15 @@
 package actions
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/hacash/core/account"
 	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
 	"testing"
 	"time"
 )
@@ -39,3 +45,1004 @@ func Test3(t *testing.T) {
 	fmt.Println(moveBtcLockWeekByIdx(2048))
 
 }
+
+// 只实现余额读写的链状态
+type testBalanceState struct {
+	interfaces.ChainStateOperation
+	balances map[string]*stores.Balance
+}
+
+func (s *testBalanceState) IsDatabaseVersionRebuildMode() bool { return false }
+func (s *testBalanceState) GetPendingBlockHeight() uint64      { return 500000 }
+func (s *testBalanceState) Balance(addr fields.Address) *stores.Balance {
+	if b, ok := s.balances[string(addr)]; ok {
+		cp := *b
+		return &cp
+	}
+	return nil
+}
+func (s *testBalanceState) BalanceSet(addr fields.Address, b *stores.Balance) error {
+	s.balances[string(addr)] = b
+	return nil
+}
+func (s *testBalanceState) BalanceDel(addr fields.Address) error {
+	delete(s.balances, string(addr))
+	return nil
+}
+
+type testMainAddressTx struct {
+	interfaces.Transaction
+	address   fields.Address
+	feepurity uint64
+}
+
+func (t *testMainAddressTx) GetAddress() fields.Address { return t.address }
+func (t *testMainAddressTx) FeePurity() uint64          { return t.feepurity }
+
+// 只实现钻石和元数据读写的链状态
+type testDiamondState struct {
+	interfaces.ChainStateOperation
+	diamonds map[string]*stores.Diamond
+	metadata map[string]*stores.DiamondMetadata
+	archives map[string]*stores.DiamondMetadataRecord
+}
+
+func (s *testDiamondState) GetPendingBlockHeight() uint64 { return 500000 }
+func (s *testDiamondState) Diamond(name fields.DiamondName) *stores.Diamond {
+	return s.diamonds[string(name)]
+}
+func (s *testDiamondState) DiamondMetadata(name fields.DiamondName) *stores.DiamondMetadata {
+	if m, ok := s.metadata[string(name)]; ok {
+		buf, _ := m.Serialize()
+		cp := stores.NewDiamondMetadata()
+		cp.Parse(buf, 0)
+		return cp
+	}
+	return nil
+}
+func (s *testDiamondState) DiamondMetadataSet(name fields.DiamondName, m *stores.DiamondMetadata) error {
+	s.metadata[string(name)] = m
+	return nil
+}
+func (s *testDiamondState) DiamondMetadataDel(name fields.DiamondName) error {
+	delete(s.metadata, string(name))
+	return nil
+}
+func (s *testDiamondState) DiamondMetadataArchive(name fields.DiamondName, version uint32) *stores.DiamondMetadataRecord {
+	return s.archives[string(stores.DiamondMetadataArchiveKey(name, version))]
+}
+func (s *testDiamondState) DiamondMetadataArchiveSet(name fields.DiamondName, rcd *stores.DiamondMetadataRecord) error {
+	s.archives[string(stores.DiamondMetadataArchiveKey(name, uint32(rcd.Version)))] = rcd
+	return nil
+}
+func (s *testDiamondState) DiamondMetadataArchiveDel(name fields.DiamondName, version uint32) error {
+	delete(s.archives, string(stores.DiamondMetadataArchiveKey(name, version)))
+	return nil
+}
+
+// 批量付款
+func Test_batch_payout(t *testing.T) {
+
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	payaddr := fields.Address(account.CreateAccountByPassword("batch payer").Address)
+	addr1 := fields.Address(account.CreateAccountByPassword("batch 1").Address)
+	addr2 := fields.Address(account.CreateAccountByPassword("batch 2").Address)
+	paybls := stores.NewBalanceWithAmount(fields.NewAmountByUnit248(10))
+	paybls.Satoshi = 100
+	state := &testBalanceState{balances: map[string]*stores.Balance{string(payaddr): paybls}}
+	trs := &testMainAddressTx{address: payaddr}
+
+	// HAC 总额不足，整体失败
+	act := NewAction_30_BatchPayoutTransfer()
+	act.AppendRecipient(addr1, fields.NewAmountByUnit248(6), 0)
+	act.AppendRecipient(addr2, fields.NewAmountByUnit248(5), 0)
+	act.SetBelongTransaction(trs)
+	if act.WriteinChainState(state) == nil || state.Balance(addr1) != nil {
+		t.Fatal("total amount must not enough")
+	}
+
+	// SAT 总额不足，整体失败
+	act = NewAction_30_BatchPayoutTransfer()
+	act.AppendRecipient(addr1, nil, 60)
+	act.AppendRecipient(addr2, nil, 60)
+	act.SetBelongTransaction(trs)
+	if act.WriteinChainState(state) == nil || state.Balance(addr1) != nil {
+		t.Fatal("total satoshi must not enough")
+	}
+
+	// 执行和回退
+	act = NewAction_30_BatchPayoutTransfer()
+	act.AppendRecipient(addr1, fields.NewAmountByUnit248(4), 30)
+	act.AppendRecipient(addr2, fields.NewAmountByUnit248(6), 70)
+	act.SetBelongTransaction(trs)
+	e := act.WriteinChainState(state)
+	if e != nil {
+		t.Fatal(e)
+	}
+	bls1, bls2, bls3 := state.Balance(addr1), state.Balance(addr2), state.Balance(payaddr)
+	fmt.Println(bls1.Hacash.ToFinString(), bls1.Satoshi, bls2.Hacash.ToFinString(), bls2.Satoshi)
+	if bls1.Satoshi != 30 || bls2.Hacash.ToFinString() != "ㄜ6:248" || bls3 != nil && (bls3.Hacash.IsPositive() || bls3.Satoshi != 0) {
+		t.Fatal("batch payout balance error")
+	}
+	e = act.RecoverChainState(state)
+	if e != nil {
+		t.Fatal(e)
+	}
+	bls3 = state.Balance(payaddr)
+	if bls3 == nil || bls3.Hacash.NotEqual(fields.NewAmountByUnit248(10)) || bls3.Satoshi != 100 {
+		t.Fatal("batch payout recover error")
+	}
+	if b := state.Balance(addr1); b != nil && (b.Hacash.IsPositive() || b.Satoshi != 0) {
+		t.Fatal("recipient balance must be recovered")
+	}
+
+	// 收款地址数量上限
+	act = NewAction_30_BatchPayoutTransfer()
+	for i := 0; i < BatchPayoutTransferMaxRecipients; i++ {
+		act.AppendRecipient(addr1, nil, 1)
+	}
+	if act.AppendRecipient(addr1, nil, 1) == nil {
+		t.Fatal("recipients quantity must be limited")
+	}
+	act.Recipients = append(act.Recipients, act.Recipients[0])
+	act.RecipientCount += 1
+	act.SetBelongTransaction(trs)
+	if act.WriteinChainState(state) == nil {
+		t.Fatal("recipients quantity must be limited")
+	}
+	body, _ := act.Serialize()
+	_, e = NewAction_30_BatchPayoutTransfer().Parse(body, 2)
+	if e == nil {
+		t.Fatal("recipients quantity must be limited")
+	}
+
+	// 主网暂未启用
+	sys.TestDebugLocalDevelopmentMark = false
+	if act.WriteinChainState(state) == nil || act.RecoverChainState(state) == nil {
+		t.Fatal("mainnet not yet")
+	}
+}
+
+// 钻石附加元数据
+func Test_diamond_metadata(t *testing.T) {
+
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	owner := fields.Address(account.CreateAccountByPassword("metadata owner").Address)
+	other := fields.Address(account.CreateAccountByPassword("metadata other").Address)
+	diamond := fields.DiamondName("WTYUIA")
+	state := &testDiamondState{
+		diamonds: map[string]*stores.Diamond{
+			string(diamond): {Status: stores.DiamondStatusNormal, Address: owner},
+			"NHMYYM":        {Status: stores.DiamondStatusListing, Address: owner},
+		},
+		metadata: map[string]*stores.DiamondMetadata{},
+		archives: map[string]*stores.DiamondMetadataRecord{},
+	}
+	trs := &testMainAddressTx{address: owner, feepurity: DiamondMetadataMinFeePurity}
+
+	// 0.0001 枚 = ㄜ1:244 ，按 unit 232 计含量为 10^12
+	fee := fields.NewAmountSmall(1, 244)
+	fee.Unit -= 232
+	if fee.GetValue().Uint64() != DiamondMetadataMinFeePurity {
+		t.Fatal("min fee purity unit error")
+	}
+
+	// 序列化
+	act, _ := NewAction_44_DiamondMetadataSet(diamond, owner, 1, stores.DiamondMetadataTypeURI, []byte("ipfs://hacd"))
+	bts, _ := act.Serialize()
+	act2, _, e := ParseAction(bts, 0)
+	bts2, _ := act2.Serialize()
+	if e != nil || uint32(len(bts)) != act.Size() || !bytes.Equal(bts, bts2) {
+		t.Fatal("metadata action serialize error", e)
+	}
+
+	// 写入检查
+	checks := []struct {
+		name      string
+		diamond   string
+		owner     fields.Address
+		version   uint32
+		datatype  fields.VarUint1
+		data      []byte
+		feepurity uint64
+		code      errs.Code
+	}{
+		{"low fee purity", "WTYUIA", owner, 1, stores.DiamondMetadataTypeText, []byte("hello"), DiamondMetadataMinFeePurity - 1, errs.CodeInvalidParameter},
+		{"not exist", "ZZZZZZ", owner, 1, stores.DiamondMetadataTypeText, []byte("hello"), DiamondMetadataMinFeePurity, errs.CodeNotFound},
+		{"not owner", "WTYUIA", other, 1, stores.DiamondMetadataTypeText, []byte("hello"), DiamondMetadataMinFeePurity, errs.CodeNotPermitted},
+		{"listing", "NHMYYM", owner, 1, stores.DiamondMetadataTypeText, []byte("hello"), DiamondMetadataMinFeePurity, errs.CodeStatusConflict},
+		{"version skip", "WTYUIA", owner, 2, stores.DiamondMetadataTypeText, []byte("hello"), DiamondMetadataMinFeePurity, errs.CodeStatusConflict},
+		{"empty text", "WTYUIA", owner, 1, stores.DiamondMetadataTypeText, []byte{}, DiamondMetadataMinFeePurity, errs.CodeInvalidParameter},
+		{"invalid utf8", "WTYUIA", owner, 1, stores.DiamondMetadataTypeURI, []byte{0xff, 0xfe}, DiamondMetadataMinFeePurity, errs.CodeInvalidParameter},
+		{"hash size", "WTYUIA", owner, 1, stores.DiamondMetadataTypeContentHash, bytes.Repeat([]byte{1}, 31), DiamondMetadataMinFeePurity, errs.CodeInvalidParameter},
+		{"clear with data", "WTYUIA", owner, 1, stores.DiamondMetadataTypeClear, []byte("x"), DiamondMetadataMinFeePurity, errs.CodeInvalidParameter},
+		{"unknown type", "WTYUIA", owner, 1, 9, []byte("hello"), DiamondMetadataMinFeePurity, errs.CodeUnsupportedKind},
+	}
+	for _, c := range checks {
+		act, _ := NewAction_44_DiamondMetadataSet([]byte(c.diamond), c.owner, c.version, c.datatype, c.data)
+		act.SetBelongTransaction(&testMainAddressTx{address: c.owner, feepurity: c.feepurity})
+		if e := act.WriteinChainState(state); !errs.Is(e, c.code) {
+			t.Fatal(c.name, "must be error code", c.code, "but got", e)
+		}
+	}
+	if len(state.metadata) != 0 {
+		t.Fatal("rejected metadata must not be saved")
+	}
+
+	// 写满之后移出最早的版本
+	newact := func(v int) *Action_44_DiamondMetadataSet {
+		act, _ := NewAction_44_DiamondMetadataSet(diamond, owner, uint32(v), stores.DiamondMetadataTypeText, []byte(fmt.Sprintf("v%d", v)))
+		act.SetBelongTransaction(trs)
+		return act
+	}
+	total := stores.DiamondMetadataMaxVersions + 2
+	for v := 1; v <= total; v++ {
+		if e := newact(v).WriteinChainState(state); e != nil {
+			t.Fatal(e)
+		}
+	}
+	meta := state.DiamondMetadata(diamond)
+	if int(meta.Count) != stores.DiamondMetadataMaxVersions || meta.Records[0].Version != 3 || meta.CurrentVersion() != uint32(total) {
+		t.Fatal("metadata evict error")
+	}
+	if len(state.archives) != 2 || string(state.DiamondMetadataArchive(diamond, 1).Data.Message) != "v1" {
+		t.Fatal("metadata archive error")
+	}
+
+	// 回退，移出的版本放回
+	for v := total; v >= 1; v-- {
+		if e := newact(v).RecoverChainState(state); e != nil {
+			t.Fatal(e)
+		}
+		if v == total {
+			meta = state.DiamondMetadata(diamond)
+			if meta.Records[0].Version != 2 || int(meta.Count) != stores.DiamondMetadataMaxVersions || len(state.archives) != 1 {
+				t.Fatal("metadata restore error")
+			}
+		}
+	}
+	if state.DiamondMetadata(diamond) != nil || len(state.archives) != 0 {
+		t.Fatal("metadata must be deleted")
+	}
+}
+
+// 锁仓和归属锁仓扩展分别读写的链状态
+type testLockblsState struct {
+	*testBalanceState
+	lockbls     map[string][]byte
+	vestings    map[string]*stores.LockblsVesting
+	totalsupply *stores.TotalSupply
+}
+
+func (s *testLockblsState) Lockbls(id fields.LockblsId) *stores.Lockbls {
+	if bts, ok := s.lockbls[string(id)]; ok {
+		lock := &stores.Lockbls{}
+		lock.Parse(bts, 0)
+		return lock
+	}
+	return nil
+}
+func (s *testLockblsState) LockblsCreate(id fields.LockblsId, lock *stores.Lockbls) error {
+	s.lockbls[string(id)], _ = lock.Serialize()
+	return nil
+}
+func (s *testLockblsState) LockblsUpdate(id fields.LockblsId, lock *stores.Lockbls) error {
+	return s.LockblsCreate(id, lock)
+}
+func (s *testLockblsState) LockblsDelete(id fields.LockblsId) error {
+	delete(s.lockbls, string(id))
+	return nil
+}
+func (s *testLockblsState) LockblsVesting(id fields.LockblsId) *stores.LockblsVesting {
+	if v, ok := s.vestings[string(id)]; ok {
+		cp := *v
+		return &cp
+	}
+	return nil
+}
+func (s *testLockblsState) LockblsVestingSet(id fields.LockblsId, v *stores.LockblsVesting) error {
+	cp := *v
+	s.vestings[string(id)] = &cp
+	return nil
+}
+func (s *testLockblsState) LockblsVestingDel(id fields.LockblsId) error {
+	delete(s.vestings, string(id))
+	return nil
+}
+func (s *testLockblsState) ReadTotalSupply() (*stores.TotalSupply, error) {
+	return s.totalsupply, nil
+}
+func (s *testLockblsState) UpdateSetTotalSupply(ts *stores.TotalSupply) error {
+	s.totalsupply = ts
+	return nil
+}
+
+// 归属锁仓扩展单独保存，非标准版本号的主地址不会被误读为归属锁仓
+func Test_lockbls_vesting_store(t *testing.T) {
+
+	payaddr := fields.Address(account.CreateAccountByPassword("lockbls payer").Address)
+	oddaddr := fields.Address(append([]byte{255}, make([]byte, 20)...))
+	state := &testLockblsState{
+		testBalanceState: &testBalanceState{balances: map[string]*stores.Balance{
+			string(payaddr): stores.NewBalanceWithAmount(fields.NewAmountByUnit248(100)),
+		}},
+		lockbls:     map[string][]byte{},
+		vestings:    map[string]*stores.LockblsVesting{},
+		totalsupply: stores.NewTotalSupplyStoreData(),
+	}
+	trs := &testMainAddressTx{address: payaddr}
+
+	// 分叉高度之前保持原有规则，普通锁仓读出后不是归属锁仓
+	act := NewAction_9_LockblsCreate()
+	act.LockblsId = fields.LockblsId(bytes.Repeat([]byte{9}, stores.LockblsIdLength))
+	act.PaymentAddress = payaddr
+	act.MasterAddress = oddaddr
+	act.LinearBlockNumber = 288
+	act.TotalStockAmount = *fields.NewAmountByUnit248(10)
+	act.LinearReleaseAmount = *fields.NewAmountByUnit248(1)
+	act.SetBelongTransaction(trs)
+	if e := act.WriteinChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	if lock := readLockbls(state, act.LockblsId); lock == nil || lock.IsVesting.Check() || lock.MasterAddress.NotEqual(oddaddr) {
+		t.Fatal("plain lockbls must not be vesting")
+	}
+	act.RecoverChainState(state)
+
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	// 分叉之后拒绝非标准版本号
+	if e := act.WriteinChainState(state); !errs.Is(e, errs.CodeInvalidParameter) {
+		t.Fatal("master address version must be checked", e)
+	}
+
+	// 归属锁仓创建、撤销和回退
+	vact := &Action_39_LockblsVestingCreate{
+		LockblsId:         fields.LockblsId(bytes.Repeat([]byte{8}, stores.LockblsIdLength)),
+		PaymentAddress:    payaddr,
+		MasterAddress:     fields.Address(make([]byte, 21)),
+		EffectBlockHeight: 100000,
+		CliffBlockHeight:  400000,
+		EndBlockHeight:    900000,
+		LinearBlockNumber: 1000,
+		TotalStockAmount:  *fields.NewAmountByUnit248(80),
+		IsRevocable:       fields.CreateBool(true),
+	}
+	vact.SetBelongTransaction(trs)
+	if e := vact.WriteinChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	plain, _ := stores.NewEmptyLockbls(vact.MasterAddress).Serialize()
+	if len(state.lockbls[string(vact.LockblsId)]) < len(plain) || state.vestings[string(vact.LockblsId)] == nil {
+		t.Fatal("vesting must be saved separately")
+	}
+	ract := &Action_40_LockblsVestingRevoke{LockblsId: vact.LockblsId, RevokerAddress: payaddr}
+	ract.SetBelongTransaction(trs)
+	if e := ract.WriteinChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	lock := readLockbls(state, vact.LockblsId)
+	if !lock.IsVesting.Check() || !lock.Vesting.IsRevoked() || !lock.TotalLockAmount.LessThan(&vact.TotalStockAmount) {
+		t.Fatal("vesting revoke error")
+	}
+	if e := ract.RecoverChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	lock = readLockbls(state, vact.LockblsId)
+	if lock.Vesting.IsRevoked() || lock.TotalLockAmount.NotEqual(&vact.TotalStockAmount) {
+		t.Fatal("vesting revoke recover error")
+	}
+	if e := vact.RecoverChainState(state); e != nil || len(state.lockbls) != 0 || len(state.vestings) != 0 {
+		t.Fatal("vesting create recover error", e)
+	}
+}
+
+// 余额和持有索引读写的链状态，持有索引按 stores.DiamondOwnerKey 逐条保存
+type testOwnerIndexState struct {
+	*testBalanceState
+	height  uint64
+	owners  map[string]bool
+	history map[string][]*stores.DiamondHistoryRecord
+}
+
+func (s *testOwnerIndexState) GetPendingBlockHeight() uint64 { return s.height }
+func (s *testOwnerIndexState) DiamondOwnerAdd(addr fields.Address, name fields.DiamondName) error {
+	s.owners[string(stores.DiamondOwnerKey(addr, name))] = true
+	return nil
+}
+func (s *testOwnerIndexState) DiamondOwnerDel(addr fields.Address, name fields.DiamondName) error {
+	delete(s.owners, string(stores.DiamondOwnerKey(addr, name)))
+	return nil
+}
+func (s *testOwnerIndexState) DiamondHistoryList(name fields.DiamondName, offset, limit uint32) ([]*stores.DiamondHistoryRecord, uint32, error) {
+	list := s.history[string(name)]
+	total := uint32(len(list))
+	if offset >= total {
+		return nil, total, nil
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return list[offset:end], total, nil
+}
+func (s *testOwnerIndexState) DiamondHistoryPush(name fields.DiamondName, r *stores.DiamondHistoryRecord) error {
+	s.history[string(name)] = append(s.history[string(name)], r)
+	return nil
+}
+func (s *testOwnerIndexState) DiamondHistoryPop(name fields.DiamondName) error {
+	list := s.history[string(name)]
+	s.history[string(name)] = list[:len(list)-1]
+	return nil
+}
+func (s *testOwnerIndexState) has(addr fields.Address, name string) bool {
+	return s.owners[string(stores.DiamondOwnerKey(addr, []byte(name)))]
+}
+
+// 持有索引：启用高度之前不写入，之后每个钻石一条记录
+func Test_diamond_owner_index(t *testing.T) {
+
+	addr1 := fields.Address(bytes.Repeat([]byte{1}, 21))
+	addr2 := fields.Address(bytes.Repeat([]byte{2}, 21))
+	state := &testOwnerIndexState{
+		testBalanceState: &testBalanceState{balances: map[string]*stores.Balance{
+			string(addr1): {Diamond: 3},
+		}},
+		height:  DiamondIndexActiveBlockHeight - 1,
+		owners:  map[string]bool{},
+		history: map[string][]*stores.DiamondHistoryRecord{},
+	}
+	names := []fields.DiamondName{[]byte("WTYUIA"), []byte("NHMYYM"), []byte("AAAAAA")}
+	if e := DoSimpleDiamondTransferFromChainState(state, addr1, addr2, names[:1]); e != nil {
+		t.Fatal(e)
+	}
+	if len(state.owners) != 0 {
+		t.Fatal("owner index must not write before active height")
+	}
+	// 启用之后
+	state.height = DiamondIndexActiveBlockHeight
+	state.owners[string(stores.DiamondOwnerKey(addr1, names[1]))] = true // 启用区块已补建
+	state.owners[string(stores.DiamondOwnerKey(addr1, names[2]))] = true
+	if e := DoSimpleDiamondTransferFromChainState(state, addr1, addr2, names[1:2]); e != nil {
+		t.Fatal(e)
+	}
+	if state.has(addr1, "NHMYYM") || !state.has(addr2, "NHMYYM") || !state.has(addr1, "AAAAAA") {
+		t.Fatal("owner index transfer error")
+	}
+	if e := DoSubDiamondFromChainState(state, addr1, names[2:]); e != nil || state.has(addr1, "AAAAAA") {
+		t.Fatal("owner index sub error", e)
+	}
+	if e := DoAddDiamondFromChainState(state, addr1, names[2:]); e != nil || !state.has(addr1, "AAAAAA") {
+		t.Fatal("owner index add error", e)
+	}
+	// 没有遍历能力的状态不能启用
+	if e := DoActivateDiamondIndex(state); !errs.Is(e, errs.CodeUnsupportedKind) {
+		t.Fatal("activate without iterator must be error")
+	}
+}
+
+type testHashTx struct {
+	interfaces.Transaction
+	hash fields.Hash
+}
+
+func (t *testHashTx) Hash() fields.Hash { return t.hash }
+
+// 流转历史：启用高度之前不写入，回退只移除尾部属于该交易的记录
+func Test_diamond_history(t *testing.T) {
+
+	mortgagor := fields.Address(bytes.Repeat([]byte{1}, 21))
+	lender := fields.Address(bytes.Repeat([]byte{2}, 21))
+	state := &testOwnerIndexState{
+		testBalanceState: &testBalanceState{balances: map[string]*stores.Balance{}},
+		height:           DiamondIndexActiveBlockHeight - 1,
+		history:          map[string][]*stores.DiamondHistoryRecord{},
+	}
+	names := []fields.DiamondName{[]byte("WTYUIA")}
+	tx1 := &testHashTx{hash: bytes.Repeat([]byte{1}, 32)}
+	tx2 := &testHashTx{hash: bytes.Repeat([]byte{2}, 32)}
+	if e := doAppendDiamondHistory(state, tx1, 19, mortgagor, lender, names); e != nil || len(state.history) != 0 {
+		t.Fatal("history must not write before active height", e)
+	}
+	state.height = DiamondIndexActiveBlockHeight
+	// 抵押给出借人，之后由出借人释放给赎回人
+	doAppendDiamondHistory(state, tx1, 19, mortgagor, lender, names)
+	doAppendDiamondHistory(state, tx2, 20, lender, mortgagor, names)
+	list, total, _ := state.DiamondHistoryList(names[0], 0, 0)
+	if total != 2 || list[1].FromAddress.NotEqual(lender) || list[1].ToAddress.NotEqual(mortgagor) {
+		t.Fatal("history append error")
+	}
+	// 不是尾部的交易不移除
+	if e := doDropDiamondHistory(state, tx1, 19, names); e != nil || len(state.history["WTYUIA"]) != 2 {
+		t.Fatal("history drop must only match last record", e)
+	}
+	doDropDiamondHistory(state, tx2, 20, names)
+	doDropDiamondHistory(state, tx1, 19, names)
+	if e := doDropDiamondHistory(state, tx1, 19, names); e != nil || len(state.history["WTYUIA"]) != 0 {
+		t.Fatal("history drop error", e)
+	}
+}
+
+// 余额、钻石和用户借贷读写的链状态
+type testUserLendingState struct {
+	*testOwnerIndexState
+	diamonds    map[string]*stores.Diamond
+	lendings    map[string]*stores.UserLending
+	totalsupply *stores.TotalSupply
+}
+
+func (s *testUserLendingState) Diamond(name fields.DiamondName) *stores.Diamond {
+	if d, ok := s.diamonds[string(name)]; ok {
+		cp := *d
+		return &cp
+	}
+	return nil
+}
+func (s *testUserLendingState) DiamondSet(name fields.DiamondName, d *stores.Diamond) error {
+	s.diamonds[string(name)] = d
+	return nil
+}
+func (s *testUserLendingState) UserLending(id fields.UserLendingId) *stores.UserLending {
+	if l, ok := s.lendings[string(id)]; ok {
+		buf, _ := l.Serialize()
+		cp := &stores.UserLending{}
+		cp.Parse(buf, 0)
+		return cp
+	}
+	return nil
+}
+func (s *testUserLendingState) UserLendingUpdate(id fields.UserLendingId, l *stores.UserLending) error {
+	s.lendings[string(id)] = l
+	return nil
+}
+func (s *testUserLendingState) ReadTotalSupply() (*stores.TotalSupply, error) {
+	return s.totalsupply, nil
+}
+func (s *testUserLendingState) UpdateSetTotalSupply(ts *stores.TotalSupply) error {
+	s.totalsupply = ts
+	return nil
+}
+
+type testAddressHashTx struct {
+	interfaces.Transaction
+	address fields.Address
+	hash    fields.Hash
+}
+
+func (t *testAddressHashTx) GetAddress() fields.Address { return t.address }
+func (t *testAddressHashTx) Hash() fields.Hash          { return t.hash }
+
+// 三枚钻石和 999 SAT 抵押，约定赎回 10 HAC
+func newTestUserLendingState(mortgagor, lender fields.Address) (*testUserLendingState, fields.UserLendingId) {
+	lendid := fields.UserLendingId(bytes.Repeat([]byte{7}, stores.UserLendingIdLength))
+	lend := &stores.UserLending{
+		CreateBlockHeight:      400000,
+		ExpireBlockHeight:      600000,
+		MortgagorAddress:       mortgagor,
+		LenderAddress:          lender,
+		MortgageBitcoin:        fields.SatoshiVariation{NotEmpty: fields.CreateBool(true), ValueSAT: 999},
+		LoanTotalAmount:        *fields.NewAmountByUnit248(8),
+		AgreedRedemptionAmount: *fields.NewAmountByUnit248(10),
+	}
+	lend.MortgageDiamondList.Diamonds = []fields.DiamondName{[]byte("AAAAAA"), []byte("BBBBBB"), []byte("CCCCCC")}
+	lend.MortgageDiamondList.Count = 3
+	state := &testUserLendingState{
+		testOwnerIndexState: &testOwnerIndexState{
+			testBalanceState: &testBalanceState{balances: map[string]*stores.Balance{
+				string(mortgagor): {Hacash: *fields.NewAmountByUnit248(10)},
+			}},
+			height:  500000,
+			owners:  map[string]bool{},
+			history: map[string][]*stores.DiamondHistoryRecord{},
+		},
+		diamonds:    map[string]*stores.Diamond{},
+		lendings:    map[string]*stores.UserLending{string(lendid): lend},
+		totalsupply: stores.NewTotalSupplyStoreData(),
+	}
+	for _, name := range lend.MortgageDiamondList.Diamonds {
+		state.diamonds[string(name)] = &stores.Diamond{Status: stores.DiamondStatusLendingOtherUser, Address: mortgagor}
+	}
+	return state, lendid
+}
+
+// 部分还款：按比例向下取整释放末尾的钻石和比特币，回退后合约与余额完全恢复
+func Test_user_lending_partial_repay(t *testing.T) {
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	mortgagor := fields.Address(bytes.Repeat([]byte{1}, 21))
+	lender := fields.Address(bytes.Repeat([]byte{2}, 21))
+	state, lendid := newTestUserLendingState(mortgagor, lender)
+	trs := &testAddressHashTx{address: mortgagor, hash: bytes.Repeat([]byte{9}, 32)}
+	lendbts, _ := state.lendings[string(lendid)].Serialize()
+
+	newact := func(repay int, sat uint64, names ...string) *Action_41_UsersLendingPartialRepay {
+		act := &Action_41_UsersLendingPartialRepay{
+			LendingID:      lendid,
+			RepayAmount:    *fields.NewAmountByUnit248(int64(repay)),
+			ReleaseBitcoin: fields.NewEmptySatoshiVariation(),
+		}
+		if sat > 0 {
+			act.ReleaseBitcoin = fields.SatoshiVariation{NotEmpty: fields.CreateBool(true), ValueSAT: fields.Satoshi(sat)}
+		}
+		for _, n := range names {
+			act.ReleaseDiamondList.Diamonds = append(act.ReleaseDiamondList.Diamonds, []byte(n))
+		}
+		act.ReleaseDiamondList.Count = fields.VarUint1(len(names))
+		act.SetBelongTransaction(trs)
+		return act
+	}
+	// 还款 4/10：钻石 floor(3*0.4)=1 ，比特币 floor(999*0.4)=399
+	checks := []struct {
+		act  *Action_41_UsersLendingPartialRepay
+		code errs.Code
+	}{
+		{newact(10, 999, "AAAAAA", "BBBBBB", "CCCCCC"), errs.CodeInvalidParameter}, // 全额请使用赎回
+		{newact(4, 399, "BBBBBB", "CCCCCC"), errs.CodeInvalidParameter},            // 数量向下取整
+		{newact(4, 399, "AAAAAA"), errs.CodeInvalidParameter},                      // 必须为末尾
+		{newact(4, 400), errs.CodeInvalidParameter},                                // 比特币不能向上取整
+		{newact(4, 400, "CCCCCC"), errs.CodeInvalidParameter},
+	}
+	for i, c := range checks {
+		if e := c.act.WriteinChainState(state); !errs.Is(e, c.code) {
+			t.Fatal("partial repay check", i, "error", e)
+		}
+	}
+	// 非抵押人不能还款
+	other := newact(4, 399, "CCCCCC")
+	other.SetBelongTransaction(&testAddressHashTx{address: lender, hash: trs.hash})
+	if e := other.WriteinChainState(state); !errs.Is(e, errs.CodeNotPermitted) {
+		t.Fatal("only mortgagor can partial repay", e)
+	}
+
+	act := newact(4, 399, "CCCCCC")
+	if e := act.WriteinChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	lend := state.UserLending(lendid)
+	if lend.AgreedRedemptionAmount.ToFinString() != "ㄜ6:248" || lend.MortgageDiamondList.Count != 2 ||
+		lend.MortgageBitcoin.ValueSAT != 600 || !lend.MortgageBitcoin.NotEmpty.Check() {
+		t.Fatal("lending after partial repay error", lend.AgreedRedemptionAmount.ToFinString())
+	}
+	bls1, bls2 := state.Balance(mortgagor), state.Balance(lender)
+	if bls1.Hacash.ToFinString() != "ㄜ6:248" || bls2.Hacash.ToFinString() != "ㄜ4:248" || bls1.Diamond != 1 || bls1.Satoshi != 399 {
+		t.Fatal("balance after partial repay error")
+	}
+	if state.diamonds["CCCCCC"].Status != stores.DiamondStatusNormal || state.diamonds["BBBBBB"].Status != stores.DiamondStatusLendingOtherUser {
+		t.Fatal("diamond status after partial repay error")
+	}
+	if hist := state.history["CCCCCC"]; len(hist) != 1 || hist[0].FromAddress.NotEqual(lender) || hist[0].ToAddress.NotEqual(mortgagor) {
+		t.Fatal("partial repay history error")
+	}
+
+	// 回退
+	if e := act.RecoverChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	lendbts2, _ := state.lendings[string(lendid)].Serialize()
+	if !bytes.Equal(lendbts, lendbts2) {
+		t.Fatal("lending recover error")
+	}
+	bls1, bls2 = state.Balance(mortgagor), state.Balance(lender)
+	if bls1.Hacash.ToMeiString() != "10" || !bls2.Hacash.IsEmpty() || bls1.Diamond != 0 || bls1.Satoshi != 0 {
+		t.Fatal("balance recover error")
+	}
+	if state.diamonds["CCCCCC"].Status != stores.DiamondStatusLendingOtherUser || len(state.history["CCCCCC"]) != 0 || state.has(mortgagor, "CCCCCC") {
+		t.Fatal("diamond recover error")
+	}
+	if state.totalsupply.Get(stores.TotalSupplyStoreTypeOfUsersLendingPartialRepayHacAmount) != 0 {
+		t.Fatal("total supply recover error")
+	}
+
+	// 比特币释放比例不足 1 SAT 时不释放
+	lend = state.UserLending(lendid)
+	lend.MortgageBitcoin.ValueSAT = 2
+	state.lendings[string(lendid)] = lend
+	if e := newact(4, 0, "CCCCCC").WriteinChainState(state); e != nil || state.UserLending(lendid).MortgageBitcoin.ValueSAT != 2 {
+		t.Fatal("small satoshi release error", e)
+	}
+}
+
+// 协商展期：双方签名，原值必须与合约一致，回退恢复原值
+func Test_user_lending_extend(t *testing.T) {
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	mortgagor := fields.Address(bytes.Repeat([]byte{1}, 21))
+	lender := fields.Address(bytes.Repeat([]byte{2}, 21))
+	state, lendid := newTestUserLendingState(mortgagor, lender)
+	trs := &testAddressHashTx{address: mortgagor, hash: bytes.Repeat([]byte{9}, 32)}
+	lendbts, _ := state.lendings[string(lendid)].Serialize()
+
+	newact := func() *Action_42_UsersLendingExtend {
+		act := &Action_42_UsersLendingExtend{
+			LendingID:                  lendid,
+			MortgagorAddress:           mortgagor,
+			LenderAddress:              lender,
+			PrevExpireBlockHeight:      600000,
+			NewExpireBlockHeight:       700000,
+			PrevAgreedRedemptionAmount: *fields.NewAmountByUnit248(10),
+			NewAgreedRedemptionAmount:  *fields.NewAmountByUnit248(12),
+		}
+		act.SetBelongTransaction(trs)
+		return act
+	}
+	signs := newact().RequestSignAddresses()
+	if len(signs) != 2 || signs[0].NotEqual(mortgagor) || signs[1].NotEqual(lender) {
+		t.Fatal("extend must request both signatures")
+	}
+	checks := []struct {
+		modify func(*Action_42_UsersLendingExtend)
+		code   errs.Code
+	}{
+		{func(a *Action_42_UsersLendingExtend) { a.LenderAddress = mortgagor }, errs.CodeNotPermitted},
+		{func(a *Action_42_UsersLendingExtend) { a.PrevExpireBlockHeight = 599999 }, errs.CodeInvalidParameter},
+		{func(a *Action_42_UsersLendingExtend) { a.PrevAgreedRedemptionAmount = *fields.NewAmountByUnit248(11) }, errs.CodeInvalidParameter},
+		{func(a *Action_42_UsersLendingExtend) { a.NewExpireBlockHeight = 600000 }, errs.CodeInvalidParameter},
+		{func(a *Action_42_UsersLendingExtend) {
+			a.PrevExpireBlockHeight, a.NewExpireBlockHeight = 400000, 500005
+		}, errs.CodeInvalidParameter},
+		{func(a *Action_42_UsersLendingExtend) { a.NewAgreedRedemptionAmount = *fields.NewEmptyAmount() }, errs.CodeInvalidParameter},
+	}
+	for i, c := range checks {
+		act := newact()
+		c.modify(act)
+		if e := act.WriteinChainState(state); !errs.Is(e, c.code) {
+			t.Fatal("extend check", i, "error", e)
+		}
+	}
+
+	act := newact()
+	if e := act.WriteinChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	lend := state.UserLending(lendid)
+	if lend.ExpireBlockHeight != 700000 || lend.AgreedRedemptionAmount.ToFinString() != "ㄜ12:248" ||
+		state.totalsupply.Get(stores.TotalSupplyStoreTypeOfUsersLendingExtendCount) != 1 {
+		t.Fatal("lending after extend error")
+	}
+	// 同一签名不能重放
+	if e := newact().WriteinChainState(state); !errs.Is(e, errs.CodeInvalidParameter) {
+		t.Fatal("extend replay must be error", e)
+	}
+	if e := act.RecoverChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	lendbts2, _ := state.lendings[string(lendid)].Serialize()
+	if !bytes.Equal(lendbts, lendbts2) || state.totalsupply.Get(stores.TotalSupplyStoreTypeOfUsersLendingExtendCount) != 0 {
+		t.Fatal("extend recover error")
+	}
+}
+
+// 钻石多地址转账：编码与分组
+func Test_diamond_multi_recipient_transfer(t *testing.T) {
+
+	from := fields.Address(bytes.Repeat([]byte{1}, 21))
+	to1 := fields.Address(bytes.Repeat([]byte{2}, 21))
+	to2 := fields.Address(bytes.Repeat([]byte{3}, 21))
+	act := NewAction_43_DiamondMultiRecipientTransfer(from)
+	act.AppendDiamond([]byte("WTYUIA"), to1)
+	act.AppendDiamond([]byte("NHMYYM"), to2)
+	act.AppendDiamond([]byte("AAAAAA"), to1)
+	if act.GroupCount != 2 || act.DiamondCount() != 3 {
+		t.Fatal("group error")
+	}
+	bts, e := act.Serialize()
+	if e != nil || uint32(len(bts)) != act.Size() {
+		t.Fatal("serialize error")
+	}
+	act2, _, e := ParseAction(bts, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	bts2, _ := act2.Serialize()
+	if !bytes.Equal(bts, bts2) || act2.Kind() != 43 {
+		t.Fatal("parse error")
+	}
+	// 同一地址超过 200 枚分为多组
+	act3 := NewAction_43_DiamondMultiRecipientTransfer(from)
+	for i := 0; i < 201; i++ {
+		act3.AppendDiamond([]byte(fmt.Sprintf("A%05d", i)), to1)
+	}
+	if act3.GroupCount != 2 || len(act3.Groups[1].DiamondList.Diamonds) != 1 {
+		t.Fatal("split group error")
+	}
+}
+
+// 钻石多地址转账：整体检查后依次转移，回退后余额、所属、索引和历史完全恢复
+func Test_diamond_multi_recipient_transfer_state(t *testing.T) {
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	from := fields.Address(bytes.Repeat([]byte{1}, 21))
+	to1 := fields.Address(bytes.Repeat([]byte{2}, 21))
+	to2 := fields.Address(bytes.Repeat([]byte{3}, 21))
+	state, _ := newTestUserLendingState(from, to1)
+	state.balances = map[string]*stores.Balance{string(from): {Diamond: 3}}
+	names := []string{"WTYUIA", "NHMYYM", "AAAAAA"}
+	for _, n := range names {
+		state.diamonds[n] = &stores.Diamond{Status: stores.DiamondStatusNormal, Address: from}
+		state.owners[string(stores.DiamondOwnerKey(from, []byte(n)))] = true
+	}
+	trs := &testAddressHashTx{address: from, hash: bytes.Repeat([]byte{9}, 32)}
+
+	// 任何一枚不满足则整体失败
+	bad := NewAction_43_DiamondMultiRecipientTransfer(from)
+	bad.AppendDiamond([]byte("WTYUIA"), to1)
+	bad.AppendDiamond([]byte("AAAAAA"), from)
+	bad.SetBelongTransaction(trs)
+	if e := bad.WriteinChainState(state); !errs.Is(e, errs.CodeInvalidParameter) || state.diamonds["WTYUIA"].Address.NotEqual(from) {
+		t.Fatal("transfer to self must be rejected before any change", e)
+	}
+	lent := NewAction_43_DiamondMultiRecipientTransfer(from)
+	lent.AppendDiamond([]byte("CCCCCC"), to1) // 抵押中
+	lent.SetBelongTransaction(trs)
+	if e := lent.WriteinChainState(state); !errs.Is(e, errs.CodeStatusConflict) {
+		t.Fatal("mortgaged diamond must be rejected", e)
+	}
+
+	act := NewAction_43_DiamondMultiRecipientTransfer(from)
+	act.AppendDiamond([]byte("WTYUIA"), to1)
+	act.AppendDiamond([]byte("NHMYYM"), to2)
+	act.AppendDiamond([]byte("AAAAAA"), to1)
+	act.SetBelongTransaction(trs)
+	if e := act.WriteinChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	if state.Balance(from).Diamond != 0 || state.Balance(to1).Diamond != 2 || state.Balance(to2).Diamond != 1 {
+		t.Fatal("diamond balance error")
+	}
+	if state.diamonds["NHMYYM"].Address.NotEqual(to2) || !state.has(to1, "AAAAAA") || state.has(from, "WTYUIA") {
+		t.Fatal("diamond owner error")
+	}
+	if h := state.history["NHMYYM"]; len(h) != 1 || h[0].ActionKind != 43 || h[0].ToAddress.NotEqual(to2) {
+		t.Fatal("diamond history error")
+	}
+
+	if e := act.RecoverChainState(state); e != nil {
+		t.Fatal(e)
+	}
+	if state.Balance(from).Diamond != 3 || state.Balance(to1).Diamond != 0 || state.Balance(to2).Diamond != 0 {
+		t.Fatal("diamond balance recover error")
+	}
+	for _, n := range names {
+		if state.diamonds[n].Address.NotEqual(from) || !state.has(from, n) || len(state.history[n]) != 0 {
+			t.Fatal("diamond recover error", n)
+		}
+	}
+}
+
+// 归属锁仓的 json api
+func Test_lockbls_vesting_describe(t *testing.T) {
+
+	payaddr := fields.Address(account.CreateAccountByPassword("lockbls payer").Address)
+	vact := &Action_39_LockblsVestingCreate{
+		LockblsId:         fields.LockblsId(bytes.Repeat([]byte{8}, stores.LockblsIdLength)),
+		PaymentAddress:    payaddr,
+		MasterAddress:     payaddr,
+		CliffBlockHeight:  400000,
+		EndBlockHeight:    900000,
+		LinearBlockNumber: 1000,
+		TotalStockAmount:  *fields.NewAmountByUnit248(80),
+		IsRevocable:       fields.CreateBool(true),
+	}
+	desc := vact.Describe()
+	fmt.Println(desc)
+	if desc["lockbls_id"] != vact.LockblsId.ToHex() || desc["cliff_block_height"] != uint64(400000) || desc["is_revocable"] != true ||
+		desc["total_stock_amount"] != "ㄜ8:249" || desc["master_address"] != payaddr.ToReadable() {
+		t.Fatal("vesting create describe error")
+	}
+	ract := &Action_40_LockblsVestingRevoke{LockblsId: vact.LockblsId, RevokerAddress: payaddr}
+	if desc = ract.Describe(); desc["lockbls_id"] != vact.LockblsId.ToHex() || desc["revoker_address"] != payaddr.ToReadable() {
+		t.Fatal("vesting revoke describe error")
+	}
+}
+
+// 哈希时间锁定的 json api
+func Test_htlc_describe(t *testing.T) {
+
+	sender := fields.Address(bytes.Repeat([]byte{1}, 21))
+	receiver := fields.Address(bytes.Repeat([]byte{2}, 21))
+	act := &Action_31_HtlcCreate{
+		HtlcId:             fields.HtlcId(bytes.Repeat([]byte{5}, 16)),
+		SenderAddress:      sender,
+		ReceiverAddress:    receiver,
+		Hashlock:           fields.Hash(bytes.Repeat([]byte{6}, 32)),
+		TimeoutBlockHeight: 600000,
+		LockAmount:         *fields.NewAmountByUnit248(3),
+		LockSatoshi:        fields.NewEmptySatoshiVariation(),
+	}
+	act.LockDiamondList.Diamonds = []fields.DiamondName{[]byte("WTYUIA")}
+	act.LockDiamondList.Count = 1
+	desc := act.Describe()
+	fmt.Println(desc)
+	if desc["hashlock"] != act.Hashlock.ToHex() || desc["timeout_block_height"] != uint64(600000) || desc["lock_amount"] != "ㄜ3:248" ||
+		desc["sender_address"] != sender.ToReadable() || desc["receiver_address"] != receiver.ToReadable() || desc["lock_diamond"] != "WTYUIA" {
+		t.Fatal("htlc create describe error")
+	}
+	unlock := &Action_32_HtlcUnlock{HtlcId: act.HtlcId, IsRefund: fields.CreateBool(true)}
+	if desc = unlock.Describe(); desc["htlc_id"] != act.HtlcId.ToHex() || desc["is_refund"] != true || desc["preimage"] != nil {
+		t.Fatal("htlc refund describe error")
+	}
+	unlock = &Action_32_HtlcUnlock{HtlcId: act.HtlcId, IsRefund: fields.CreateBool(false), Preimage: bytes.Repeat([]byte{7}, 32)}
+	if desc = unlock.Describe(); desc["preimage"] != unlock.Preimage.ToHex() {
+		t.Fatal("htlc claim describe error")
+	}
+}
+
+// 担保交易的 json api
+func Test_escrow_describe(t *testing.T) {
+
+	buyer := fields.Address(bytes.Repeat([]byte{1}, 21))
+	seller := fields.Address(bytes.Repeat([]byte{2}, 21))
+	arbiter := fields.Address(bytes.Repeat([]byte{3}, 21))
+	act := &Action_36_EscrowCreate{
+		EscrowId:           fields.EscrowId(bytes.Repeat([]byte{5}, 16)),
+		BuyerAddress:       buyer,
+		SellerAddress:      seller,
+		ArbiterAddress:     arbiter,
+		LockAmount:         *fields.NewAmountByUnit248(3),
+		TimeoutBlockHeight: 600000,
+	}
+	desc := act.Describe()
+	fmt.Println(desc)
+	if desc["escrow_id"] != act.EscrowId.ToHex() || desc["arbiter_address"] != arbiter.ToReadable() || desc["lock_amount"] != "ㄜ3:248" || desc["timeout_block_height"] != uint64(600000) {
+		t.Fatal("escrow create describe error")
+	}
+	auths := EscrowAuthorizers{Count: 2, Addresses: []fields.Address{buyer, arbiter}}
+	release := &Action_37_EscrowRelease{EscrowId: act.EscrowId, Authorizers: auths}
+	refund := &Action_38_EscrowRefund{EscrowId: act.EscrowId}
+	if list, ok := release.Describe()["authorizers"].([]string); !ok || len(list) != 2 || list[1] != arbiter.ToReadable() {
+		t.Fatal("escrow release describe error")
+	}
+	if list, ok := refund.Describe()["authorizers"].([]string); !ok || len(list) != 0 {
+		t.Fatal("escrow refund describe error")
+	}
+}
+
+// 普通线性锁仓计入锁定统计，提取和回退同步更新
+func Test_lockbls_total_supply(t *testing.T) {
+
+	payaddr := fields.Address(account.CreateAccountByPassword("lockbls payer").Address)
+	state := &testLockblsState{
+		testBalanceState: &testBalanceState{balances: map[string]*stores.Balance{
+			string(payaddr): stores.NewBalanceWithAmount(fields.NewAmountByUnit248(100)),
+		}},
+		lockbls:     map[string][]byte{},
+		vestings:    map[string]*stores.LockblsVesting{},
+		totalsupply: stores.NewTotalSupplyStoreData(),
+	}
+	trs := &testMainAddressTx{address: payaddr}
+	locked := func() string {
+		amt, _ := state.totalsupply.GetAmount(stores.TotalSupplyStoreTypeOfLocatedHACInLockbls)
+		return amt.ToMeiString()
+	}
+
+	act := NewAction_9_LockblsCreate()
+	act.LockblsId = fields.LockblsId(bytes.Repeat([]byte{9}, stores.LockblsIdLength))
+	act.PaymentAddress = payaddr
+	act.MasterAddress = payaddr
+	act.LinearBlockNumber = 288
+	act.TotalStockAmount = *fields.NewAmountByUnit248(10)
+	act.LinearReleaseAmount = *fields.NewAmountByUnit248(1)
+	act.SetBelongTransaction(trs)
+	if e := act.WriteinChainState(state); e != nil || locked() != "10" {
+		t.Fatal("lockbls create total supply error", e)
+	}
+	ract := &Action_10_LockblsRelease{LockblsId: act.LockblsId, ReleaseAmount: *fields.NewAmountByUnit248(1)}
+	ract.SetBelongTransaction(trs)
+	if e := ract.WriteinChainState(state); e != nil || locked() != "9" {
+		t.Fatal("lockbls release total supply error", e)
+	}
+	if e := ract.RecoverChainState(state); e != nil || locked() != "10" {
+		t.Fatal("lockbls release recover total supply error", e)
+	}
+	if e := act.RecoverChainState(state); e != nil || locked() != "0" {
+		t.Fatal("lockbls create recover total supply error", e)
+	}
+}
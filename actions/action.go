@@ -2,7 +2,7 @@ package actions
 
 import (
 	"encoding/binary"
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/interfaces"
 )
 
@@ -67,15 +67,47 @@ func NewActionByKind(kind uint16) (interfaces.Action, error) {
 		return new(Action_27_ClosePaymentChannelByClaimDistribution), nil
 	case 28:
 		return new(Action_28_FromSatoshiTransfer), nil
+	case 29:
+		return new(Action_29_TransactionMemo), nil
+	case 30:
+		return new(Action_30_BatchPayoutTransfer), nil
+	case 31:
+		return new(Action_31_HtlcCreate), nil
+	case 32:
+		return new(Action_32_HtlcUnlock), nil
+	case 33:
+		return new(Action_33_DiamondListingCreate), nil
+	case 34:
+		return new(Action_34_DiamondListingFill), nil
+	case 35:
+		return new(Action_35_DiamondListingCancel), nil
+	case 36:
+		return new(Action_36_EscrowCreate), nil
+	case 37:
+		return new(Action_37_EscrowRelease), nil
+	case 38:
+		return new(Action_38_EscrowRefund), nil
+	case 39:
+		return new(Action_39_LockblsVestingCreate), nil
+	case 40:
+		return new(Action_40_LockblsVestingRevoke), nil
+	case 41:
+		return new(Action_41_UsersLendingPartialRepay), nil
+	case 42:
+		return new(Action_42_UsersLendingExtend), nil
+	case 43:
+		return new(Action_43_DiamondMultiRecipientTransfer), nil
+	case 44:
+		return new(Action_44_DiamondMetadataSet), nil
 
 	}
 	////////////////////    END      ////////////////////
-	return nil, fmt.Errorf("Cannot find Action kind of " + string(kind))
+	return nil, errs.New(errs.CodeUnsupportedKind, "Cannot find Action kind of %d", kind)
 }
 
 func ParseAction(buf []byte, seek uint32) (interfaces.Action, uint32, error) {
 	if seek+2 >= uint32(len(buf)) {
-		return nil, 0, fmt.Errorf("[ParseAction] seek out of buf len.")
+		return nil, 0, errs.New(errs.CodeMalformed, "[ParseAction] seek out of buf len.")
 	}
 	var kind = binary.BigEndian.Uint16(buf[seek : seek+2])
 	var act, e1 = NewActionByKind(kind)
@@ -83,5 +115,8 @@ func ParseAction(buf []byte, seek uint32) (interfaces.Action, uint32, error) {
 		return nil, 0, e1
 	}
 	var mv, err = act.Parse(buf, seek+2)
-	return act, mv, err
+	if err != nil {
+		return act, mv, errs.FromOr(errs.CodeMalformed, err)
+	}
+	return act, mv, nil
 }
@@ -4,10 +4,11 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/lending"
 	"github.com/hacash/core/stores"
 	"github.com/hacash/core/sys"
 	"math/big"
@@ -117,7 +118,7 @@ func (*Action_17_BitcoinsSystemLendingCreate) RequestSignAddresses() []fields.Ad
 func (act *Action_17_BitcoinsSystemLendingCreate) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -128,23 +129,23 @@ func (act *Action_17_BitcoinsSystemLendingCreate) WriteinChainState(state interf
 
 	// 检查数量上限
 	if act.MortgageBitcoinPortion <= 0 {
-		return fmt.Errorf("Bitcoin system lending mortgage bitcoin portion cannot empty.")
+		return errs.New(errs.CodeInvalidParameter, "Bitcoin system lending mortgage bitcoin portion cannot empty.")
 	}
 	if act.MortgageBitcoinPortion > 10000 {
-		return fmt.Errorf("Bitcoin system lending mortgage bitcoin portion max is 10000 (100BTC).")
+		return errs.New(errs.CodeInvalidParameter, "Bitcoin system lending mortgage bitcoin portion max is 10000 (100BTC).")
 	}
 
 	// 检查id格式
 	if len(act.LendingID) != stores.BitcoinSyslendIdLength ||
 		act.LendingID[0] == 0 ||
 		act.LendingID[stores.BitcoinSyslendIdLength-1] == 0 {
-		return fmt.Errorf("Bitcoin Lending Id format error.")
+		return errs.New(errs.CodeMalformed, "Bitcoin Lending Id format error.")
 	}
 
 	// 查询id是否存在
 	btclendObj := state.BitcoinSystemLending(act.LendingID)
 	if btclendObj != nil {
-		return fmt.Errorf("Bitcoin Lending <%s> already exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeAlreadyExists, "Bitcoin Lending already exist.").WithID(act.LendingID)
 	}
 
 	// 检查扣除比特币余额
@@ -159,40 +160,54 @@ func (act *Action_17_BitcoinsSystemLendingCreate) WriteinChainState(state interf
 		return e1
 	}
 	// 当前实时借出的比特币份数
-	btcpartcurnum := totalsupply.Get(stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCurrentMortgageCount)
+	btcpartcurnum := float64(totalsupply.GetCount(stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCurrentMortgageCount))
 	// 总的比特币份数
-	totalbtcpart := totalsupply.Get(stores.TotalSupplyStoreTypeOfTransferBitcoin) * 100
+	totalbtcpart := float64(totalsupply.GetCount(stores.TotalSupplyStoreTypeOfTransferBitcoin)) * 100
 	// 借贷比例，必须算上本次抵押的BTC份数
 	alllendper := (btcpartcurnum + float64(act.MortgageBitcoinPortion)) / totalbtcpart * 100
-
-	// 计算可借数量和预付利息，参数单位： %
-	canLoanHacPart, predeshac := coinbase.CalculationOfInterestBitcoinMortgageLoanAmount(alllendper)
-	canLoanHacPart *= float64(act.MortgageBitcoinPortion)
-	predeshac *= float64(act.MortgageBitcoinPortion) // 真实预付利息份数
+	// 系统实时算上自己后的总抵押比例，单位：万分之，取值范围 0 ~ 10000
+	realtimeMortgageRatio := fields.VarUint2(alllendper * 100)
+
+	// 计算可借数量和预付利息（单位：百万分之一枚）
+	var canLoanHacMicro, predeshacMicro *big.Int
+	if coinbase.IsFixedPointMathActive(state.GetPendingBlockHeight()) {
+		// 分叉高度之后使用定点计算
+		lendportion := uint64(btcpartcurnum) + uint64(act.MortgageBitcoinPortion)
+		totalportion := uint64(totalbtcpart)
+		loanmicro, predesmicro, e := coinbase.CalculationOfInterestBitcoinMortgageLoanAmountFixedPoint(lendportion, totalportion)
+		if e != nil {
+			return e
+		}
+		portion := new(big.Int).SetUint64(uint64(act.MortgageBitcoinPortion))
+		canLoanHacMicro = new(big.Int).Mul(new(big.Int).SetUint64(loanmicro), portion)
+		predeshacMicro = new(big.Int).Mul(new(big.Int).SetUint64(predesmicro), portion)
+		realtimeMortgageRatio = fields.VarUint2(lendportion * 10000 / totalportion)
+	} else {
+		// 计算可借数量和预付利息，参数单位： %
+		canLoanHacPart, predeshac := coinbase.CalculationOfInterestBitcoinMortgageLoanAmount(alllendper)
+		canLoanHacPart *= float64(act.MortgageBitcoinPortion)
+		predeshac *= float64(act.MortgageBitcoinPortion) // 真实预付利息份数
+		canLoanHacMicro = big.NewInt(int64(canLoanHacPart * 100 * 10000))
+		predeshacMicro = big.NewInt(int64(predeshac * 100 * 10000))
+	}
 
 	// 真实数额，计算时忽略单位 240 后的小数部分
-	realMaxLoanAmt, e3 := fields.NewAmountByBigIntWithUnit(
-		big.NewInt(int64(canLoanHacPart*100*10000)),
-		240,
-	)
+	realMaxLoanAmt, e3 := fields.NewAmountByBigIntWithUnit(canLoanHacMicro, 240)
 	if e3 != nil {
 		return e3
 	}
-	realLowPreDes, e4 := fields.NewAmountByBigIntWithUnit(
-		big.NewInt(int64(predeshac*100*10000)),
-		240,
-	)
+	realLowPreDes, e4 := fields.NewAmountByBigIntWithUnit(predeshacMicro, 240)
 	if e4 != nil {
 		return e4
 	}
 
 	// 判断可借数量是否满足
 	if act.LoanTotalAmount.MoreThan(realMaxLoanAmt) {
-		return fmt.Errorf("Loan total amount %s can not more than real time effective amount %s.", act.LoanTotalAmount.ToFinString(), realMaxLoanAmt.ToFinString())
+		return errs.New(errs.CodeInvalidParameter, "Loan total amount %s can not more than real time effective amount %s.", act.LoanTotalAmount.ToFinString(), realMaxLoanAmt.ToFinString())
 	}
 	// 判断预付利息是否满足
 	if act.PreBurningInterestAmount.LessThan(realLowPreDes) {
-		return fmt.Errorf("Pre burning interest amount %s can not less than amount %s.", act.PreBurningInterestAmount.ToFinString(), realLowPreDes.ToFinString())
+		return errs.New(errs.CodeInvalidParameter, "Pre burning interest amount %s can not less than amount %s.", act.PreBurningInterestAmount.ToFinString(), realLowPreDes.ToFinString())
 	}
 
 	// 扣除预付利息
@@ -216,7 +231,7 @@ func (act *Action_17_BitcoinsSystemLendingCreate) WriteinChainState(state interf
 		MortgageBitcoinPortion:     act.MortgageBitcoinPortion,
 		LoanTotalAmount:            act.LoanTotalAmount,
 		PreBurningInterestAmount:   act.PreBurningInterestAmount,
-		RealtimeTotalMortgageRatio: fields.VarUint2(alllendper * 100), // 系统实时算上自己后的总抵押比例，单位：万分之，取值范围 0 ~ 10000
+		RealtimeTotalMortgageRatio: realtimeMortgageRatio,
 	}
 	e11 := state.BitcoinLendingCreate(act.LendingID, dlsto)
 	if e11 != nil {
@@ -229,19 +244,19 @@ func (act *Action_17_BitcoinsSystemLendingCreate) WriteinChainState(state interf
 		return e20
 	}
 	// 增加实时比特币系统抵押份数统计
-	totalsupply.DoAdd(
+	totalsupply.DoAddCount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCurrentMortgageCount,
-		float64(act.MortgageBitcoinPortion),
+		uint64(act.MortgageBitcoinPortion),
 	)
 	// 比特币系统抵押累计预先销毁利息
-	totalsupply.DoAdd(
+	totalsupply.DoAddAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionBurningInterestHacAmount,
-		act.PreBurningInterestAmount.ToMei(),
+		&act.PreBurningInterestAmount,
 	)
 	// 比特币系统抵押数量统计 累计借出流水
-	totalsupply.DoAdd(
+	totalsupply.DoAddAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCumulationLoanHacAmount,
-		act.LoanTotalAmount.ToMei(),
+		&act.LoanTotalAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -258,7 +273,7 @@ func (act *Action_17_BitcoinsSystemLendingCreate) RecoverChainState(state interf
 	var e error = nil
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -270,7 +285,7 @@ func (act *Action_17_BitcoinsSystemLendingCreate) RecoverChainState(state interf
 	// 查询id是否存在
 	btclendObj := state.BitcoinSystemLending(act.LendingID)
 	if btclendObj == nil {
-		return fmt.Errorf("Bitcoin Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeNotFound, "Bitcoin Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 回退比特币余额
@@ -310,19 +325,19 @@ func (act *Action_17_BitcoinsSystemLendingCreate) RecoverChainState(state interf
 		return e20
 	}
 	// 增加实时比特币系统抵押份数统计  回退减少
-	totalsupply.DoSub(
+	totalsupply.DoSubCount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCurrentMortgageCount,
-		float64(act.MortgageBitcoinPortion),
+		uint64(act.MortgageBitcoinPortion),
 	)
 	// 比特币系统抵押累计预先销毁利息     回退减少
-	totalsupply.DoSub(
+	totalsupply.DoSubAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionBurningInterestHacAmount,
-		act.PreBurningInterestAmount.ToMei(),
+		&act.PreBurningInterestAmount,
 	)
 	// 比特币系统抵押数量统计 累计借出流水   回退减少
-	totalsupply.DoSub(
+	totalsupply.DoSubAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCumulationLoanHacAmount,
-		act.LoanTotalAmount.ToMei(),
+		&act.LoanTotalAmount,
 	)
 	// 更新统计
 	e = state.UpdateSetTotalSupply(totalsupply)
@@ -423,7 +438,7 @@ func (*Action_18_BitcoinsSystemLendingRansom) RequestSignAddresses() []fields.Ad
 func (act *Action_18_BitcoinsSystemLendingRansom) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -437,26 +452,23 @@ func (act *Action_18_BitcoinsSystemLendingRansom) WriteinChainState(state interf
 	if len(act.LendingID) != stores.BitcoinSyslendIdLength ||
 		act.LendingID[0] == 0 ||
 		act.LendingID[stores.BitcoinSyslendIdLength-1] == 0 {
-		return fmt.Errorf("Bitcoin Lending Id format error.")
+		return errs.New(errs.CodeMalformed, "Bitcoin Lending Id format error.")
 	}
 
 	// 查询id是否存在
 	btclendObj := state.BitcoinSystemLending(act.LendingID)
 	if btclendObj == nil {
-		return fmt.Errorf("Bitcoin Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeNotFound, "Bitcoin Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 检查是否赎回状态
 	if btclendObj.IsRansomed.Check() {
 		// 已经赎回。不可再次赎回
-		return fmt.Errorf("Bitcoin Lending <%s> has been redeemed.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeStatusConflict, "Bitcoin Lending <%s> has been redeemed.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 赎回期阶段区块数
-	ransomBlockNumberBase := uint64(100000) // 十万个区块约一年
-	if sys.TestDebugLocalDevelopmentMark {
-		ransomBlockNumberBase = 10 // 测试环境 10 个区块为周期
-	}
+	ransomBlockNumberBase := lending.BitcoinSystemLendingRansomBlockNumber() // 十万个区块约一年，测试环境 10 个区块
 
 	// 计算比特币赎回金额
 	_, realRansomAmt, e4 := coinbase.CalculationBitcoinSystemLendingRedeemAmount(
@@ -470,7 +482,7 @@ func (act *Action_18_BitcoinsSystemLendingRansom) WriteinChainState(state interf
 
 	// 检查赎回金额是否有效（赎回金额真的大于实时计算的可赎回金额）检查赎回金额是否满足要求
 	if act.RansomAmount.LessThan(realRansomAmt) {
-		return fmt.Errorf("Ransom amount %s can not less than real time ransom amount %s.", act.RansomAmount.ToFinString(), realRansomAmt.ToFinString())
+		return errs.New(errs.CodeInvalidParameter, "Ransom amount %s can not less than real time ransom amount %s.", act.RansomAmount.ToFinString(), realRansomAmt.ToFinString())
 	}
 
 	// 赎回操作，扣除HAC余额（以便首先检查余额是否充足）
@@ -502,14 +514,14 @@ func (act *Action_18_BitcoinsSystemLendingRansom) WriteinChainState(state interf
 		return e20
 	}
 	// 减少实时比特币抵押份数统计
-	totalsupply.DoSub(
+	totalsupply.DoSubCount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCurrentMortgageCount,
-		float64(btclendObj.MortgageBitcoinPortion),
+		uint64(btclendObj.MortgageBitcoinPortion),
 	)
 	// 比特币系统抵押累计赎回销毁  流水
-	totalsupply.DoAdd(
+	totalsupply.DoAddAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCumulationRansomHacAmount,
-		act.RansomAmount.ToMei(),
+		&act.RansomAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -524,7 +536,7 @@ func (act *Action_18_BitcoinsSystemLendingRansom) WriteinChainState(state interf
 func (act *Action_18_BitcoinsSystemLendingRansom) RecoverChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -536,7 +548,7 @@ func (act *Action_18_BitcoinsSystemLendingRansom) RecoverChainState(state interf
 	// 查询id是否存在
 	btclendObj := state.BitcoinSystemLending(act.LendingID)
 	if btclendObj == nil {
-		return fmt.Errorf("Bitcoin Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeNotFound, "Bitcoin Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 回退赎回操作，增加HAC余额
@@ -568,14 +580,14 @@ func (act *Action_18_BitcoinsSystemLendingRansom) RecoverChainState(state interf
 		return e20
 	}
 	// 回退  增加实时比特币抵押份数统计
-	totalsupply.DoAdd(
+	totalsupply.DoAddCount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCurrentMortgageCount,
-		float64(btclendObj.MortgageBitcoinPortion),
+		uint64(btclendObj.MortgageBitcoinPortion),
 	)
 	// 比特币系统抵押累计赎回销毁  流水  回退减少
-	totalsupply.DoSub(
+	totalsupply.DoSubAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCumulationRansomHacAmount,
-		act.RansomAmount.ToMei(),
+		&act.RansomAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -3,7 +3,7 @@ package actions
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -129,7 +129,7 @@ func (act *Action_7_SatoshiGenesis) WriteinChainState(state interfaces.ChainStat
 	// 交易只能包含唯一一个action
 	belongactionnum := len(act.belong_trs.GetActions())
 	if 1 != belongactionnum {
-		return fmt.Errorf("Satoshi Genesis tx need only one action but got %d actions.", belongactionnum)
+		return errs.New(errs.CodeInvalidParameter, "Satoshi Genesis tx need only one action but got %d actions.", belongactionnum)
 	}
 	// 检查已经记录的增发（避免已完成的增发重复执行）
 	belongtxhx, berr2 := state.ReadMoveBTCTxHashByNumber(uint32(act.TransferNo))
@@ -138,7 +138,7 @@ func (act *Action_7_SatoshiGenesis) WriteinChainState(state interfaces.ChainStat
 	}
 	if belongtxhx != nil {
 		// 增发已经完成
-		return fmt.Errorf("Satoshi act TransferNo<%d> has been executed.", act.TransferNo)
+		return errs.New(errs.CodeAlreadyExists, "Satoshi act TransferNo<%d> has been executed.", act.TransferNo)
 	}
 
 	// 请求验证数据
@@ -147,7 +147,7 @@ func (act *Action_7_SatoshiGenesis) WriteinChainState(state interfaces.ChainStat
 		// 交易位于交易池时 和 设置了check url时， 必须验证
 		if checkact == nil {
 			// URL 未返回数据
-			return fmt.Errorf("SatoshiGenesis btc move logs url return invalid.")
+			return errs.New(errs.CodeInvalidParameter, "SatoshiGenesis btc move logs url return invalid.")
 		}
 		// 比较
 		if act.TransferNo != checkact.TransferNo ||
@@ -158,11 +158,11 @@ func (act *Action_7_SatoshiGenesis) WriteinChainState(state interfaces.ChainStat
 			act.AdditionalTotalHacAmount != checkact.AdditionalTotalHacAmount ||
 			bytes.Compare(act.OriginAddress, checkact.OriginAddress) != 0 ||
 			bytes.Compare(act.BitcoinTransferHash, checkact.BitcoinTransferHash) != 0 {
-			return fmt.Errorf("Action_7_SatoshiGenesis act and check act is mismatch.")
+			return errs.New(errs.CodeInvalidParameter, "Action_7_SatoshiGenesis act and check act is mismatch.")
 		}
 		// 验证数据 （转移比特币数量 1 ～ 1万枚）
 		if act.BitcoinQuantity < 1 && act.BitcoinQuantity > 10000 {
-			return fmt.Errorf("SatoshiGenesis act BitcoinQuantity number is error (right is 1 ~ 10000).")
+			return errs.New(errs.CodeInvalidParameter, "SatoshiGenesis act BitcoinQuantity number is error (right is 1 ~ 10000).")
 		}
 		var ttHac int64 = 0
 		for i := act.BitcoinEffectiveGenesis + 1; i <= act.BitcoinEffectiveGenesis+act.BitcoinQuantity; i++ {
@@ -170,12 +170,12 @@ func (act *Action_7_SatoshiGenesis) WriteinChainState(state interfaces.ChainStat
 		}
 		if ttHac != int64(act.AdditionalTotalHacAmount) {
 			// 增发的 HAC 数量不对
-			return fmt.Errorf("SatoshiGenesis act AdditionalTotalHacAmount need %d but got %d.", ttHac, act.AdditionalTotalHacAmount)
+			return errs.New(errs.CodeInvalidParameter, "SatoshiGenesis act AdditionalTotalHacAmount need %d but got %d.", ttHac, act.AdditionalTotalHacAmount)
 		}
 		// 检查时间（延迟28天才能领取）
 		targettime := time.Unix(int64(act.BitcoinBlockTimestamp), 0).AddDate(0, 0, 28)
 		if time.Now().Before(targettime) {
-			return fmt.Errorf("SatoshiGenesis submit tx time must over %s", targettime.Format("2006/01/02 15:04:05"))
+			return errs.New(errs.CodeInvalidParameter, "SatoshiGenesis submit tx time must over %s", targettime.Format("2006/01/02 15:04:05"))
 		}
 		// 检查成功！！！
 	}
@@ -186,7 +186,7 @@ func (act *Action_7_SatoshiGenesis) WriteinChainState(state interfaces.ChainStat
 	}
 
 	// 统计比特币转移数量
-	totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfTransferBitcoin, float64(act.BitcoinQuantity))
+	totalsupply.DoAddCount(stores.TotalSupplyStoreTypeOfTransferBitcoin, uint64(act.BitcoinQuantity))
 
 	// 记录 标记 已完成的 转移增发
 	stoerr := state.SaveMoveBTCBelongTxHash(uint32(act.TransferNo), act.belong_trs.Hash())
@@ -213,7 +213,7 @@ func (act *Action_7_SatoshiGenesis) WriteinChainState(state interfaces.ChainStat
 	}
 
 	if weekhei > 17000000 {
-		return fmt.Errorf("SatoshiGenesis moveBtcLockWeekByIdx weekhei overflow.")
+		return errs.New(errs.CodeMalformed, "SatoshiGenesis moveBtcLockWeekByIdx weekhei overflow.")
 	}
 	if lockweek > 0 {
 
@@ -241,8 +241,7 @@ func (act *Action_7_SatoshiGenesis) WriteinChainState(state interfaces.ChainStat
 		}
 
 		// 累加解锁的HAC
-		addamt := totaladdhacamt.ToMei()
-		totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, addamt)
+		totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, totaladdhacamt)
 
 	}
 
@@ -269,14 +268,14 @@ func (act *Action_7_SatoshiGenesis) RecoverChainState(state interfaces.ChainStat
 	}
 
 	// 回退比特币转移数量
-	totalsupply.DoSub(stores.TotalSupplyStoreTypeOfTransferBitcoin, float64(act.BitcoinQuantity))
+	totalsupply.DoSubCount(stores.TotalSupplyStoreTypeOfTransferBitcoin, uint64(act.BitcoinQuantity))
 
 	// 回退 hac
 	// 锁仓时间按最先一枚计算
 	// 判断是否线性锁仓至 lockbls
 	lockweek, weekhei := moveBtcLockWeekByIdx(int64(act.BitcoinEffectiveGenesis) + 1)
 	if weekhei > 17000000 {
-		return fmt.Errorf("moveBtcLockWeekByIdx weekhei overflow.")
+		return errs.New(errs.CodeMalformed, "moveBtcLockWeekByIdx weekhei overflow.")
 	}
 	if lockweek > 0 {
 
@@ -300,8 +299,7 @@ func (act *Action_7_SatoshiGenesis) RecoverChainState(state interfaces.ChainStat
 			return e1
 		}
 		// 减去解锁的HAC
-		addamt := addhacamt.ToMei()
-		totalsupply.DoSub(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, addamt)
+		totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, addhacamt)
 
 	}
 
@@ -354,6 +352,25 @@ func moveBtcCoinRewardByIdx(btcidx int64) int64 {
 	return powf2(lvn - tarlv)
 }
 
+// 前 n 枚BTC转移总共增发的HAC数量（单位：枚）
+// 每一层级共增发 2^20 枚，第 2^21 枚之后每枚增发一枚
+func MoveBtcCoinRewardTotalByCount(btcnum int64) int64 {
+	var lvn = 21
+	var total int64 = 0
+	for i := 0; i < lvn && btcnum > 0; i++ {
+		lvnum := powf2(i)
+		if btcnum < lvnum {
+			lvnum = btcnum
+		}
+		total += lvnum * powf2(lvn-1-i)
+		btcnum -= lvnum
+	}
+	if btcnum > 0 {
+		total += btcnum // 最后始终增发一枚
+	}
+	return total
+}
+
 // 计算第几枚BTC锁仓信息
 func moveBtcLockWeekByIdx(btcidx int64) (int64, int64) {
 	var oneweekhei int64 = 2000   // 2000 / 288 = 6.9444天
@@ -379,6 +396,12 @@ func moveBtcLockWeekByIdx(btcidx int64) (int64, int64) {
 
 ///////////////////////////
 
+// 查询比特币转移的线性锁仓，不存在（未锁仓）则返回 nil
+// 可提取额度和释放计划见 stores.Lockbls 的 ReleasableAt 和 FullSchedule
+func GetLockblsByBtcMove(state interfaces.ChainStateOperation, btcTransferNo uint32) *stores.Lockbls {
+	return state.Lockbls(GainLockblsIdByBtcMove(btcTransferNo))
+}
+
 func GainLockblsIdByBtcMove(btcTransferNo uint32) []byte {
 
 	// 自己创建的 lockbls key 不允许创建这样的的前面全为0的key!!!
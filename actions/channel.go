@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -105,47 +105,47 @@ func (act *Action_2_OpenPaymentChannel) WriteinChainState(state interfaces.Chain
 	var isIdCanUse = (sto == nil) ||
 		(sto.IsAgreementClosed() && sto.LeftAddress.Equal(act.LeftAddress) && sto.RightAddress.Equal(act.RightAddress))
 	if isIdCanUse == false {
-		return fmt.Errorf("Payment Channel Id <%s> already exist.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeAlreadyExists, "Payment Channel Id already exist.").WithID(act.ChannelId)
 	}
 	if sto != nil {
 		reuseVersion = sto.ReuseVersion + 1 // 重用版本号增长
 	}
 	// 通道id合法性
 	if len(act.ChannelId) != stores.ChannelIdLength || act.ChannelId[0] == 0 || act.ChannelId[stores.ChannelIdLength-1] == 0 {
-		return fmt.Errorf("Payment Channel Id <%s> format error.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeMalformed, "Payment Channel Id <%s> format error.", hex.EncodeToString(act.ChannelId))
 	}
 	// 两个地址不能相同
 	if act.LeftAddress.Equal(act.RightAddress) {
-		return fmt.Errorf("Left address cannot equal with right address.")
+		return errs.New(errs.CodeInvalidParameter, "Left address cannot equal with right address.")
 	}
 	// 检查金额储存的位数
 	labt, _ := act.LeftAmount.Serialize()
 	rabt, _ := act.RightAmount.Serialize()
 	if len(labt) > 6 || len(rabt) > 6 {
 		// 避免锁定资金的储存位数过长，导致的复利计算后的值存储位数超过最大范围
-		return fmt.Errorf("Payment Channel create error: left or right Amount bytes too long.")
+		return errs.New(errs.CodeMalformed, "Payment Channel create error: left or right Amount bytes too long.")
 	}
 	// 不能为负数，或者两个通道同时为零（可以一个为正数另一个为零）
 	if (!act.LeftAmount.IsPositive() || !act.RightAmount.IsPositive()) ||
 		(act.LeftAmount.IsEmpty() && act.RightAmount.IsEmpty()) {
-		return fmt.Errorf("Action_2_OpenPaymentChannel Payment Channel create error: left or right Amount is not positive.")
+		return errs.New(errs.CodeInvalidParameter, "Action_2_OpenPaymentChannel Payment Channel create error: left or right Amount is not positive.")
 	}
 	// 检查余额是否充足
 	bls1 := state.Balance(act.LeftAddress)
 	if bls1 == nil {
-		return fmt.Errorf("Action_2_OpenPaymentChannel Address %s Balance cannot empty.", act.LeftAddress.ToReadable())
+		return errs.New(errs.CodeInsufficientBalance, "Action_2_OpenPaymentChannel Address %s Balance cannot empty.", act.LeftAddress.ToReadable())
 	}
 	amt1 := bls1.Hacash
 	if amt1.LessThan(&act.LeftAmount) {
-		return fmt.Errorf("Action_2_OpenPaymentChannel Address %s Balance is not enough. need %s but got %s", act.LeftAddress.ToReadable(), act.LeftAmount.ToFinString(), amt1.ToFinString())
+		return errs.New(errs.CodeInsufficientBalance, "Action_2_OpenPaymentChannel Address %s Balance is not enough. need %s but got %s", act.LeftAddress.ToReadable(), act.LeftAmount.ToFinString(), amt1.ToFinString())
 	}
 	bls2 := state.Balance(act.RightAddress)
 	if bls2 == nil {
-		return fmt.Errorf("Address %s Balance is not enough.", act.RightAddress.ToReadable())
+		return errs.New(errs.CodeInsufficientBalance, "Address %s Balance is not enough.", act.RightAddress.ToReadable())
 	}
 	amt2 := bls2.Hacash
 	if amt2.LessThan(&act.RightAmount) {
-		return fmt.Errorf("Action_2_OpenPaymentChannel Address %s Balance is not enough. need %s but got %s", act.RightAddress.ToReadable(), act.RightAmount.ToFinString(), amt2.ToFinString())
+		return errs.New(errs.CodeInsufficientBalance, "Action_2_OpenPaymentChannel Address %s Balance is not enough. need %s but got %s", act.RightAddress.ToReadable(), act.RightAmount.ToFinString(), amt2.ToFinString())
 	}
 	curheight := state.GetPendingBlockHeight()
 	// 创建 channel
@@ -183,9 +183,12 @@ func (act *Action_2_OpenPaymentChannel) WriteinChainState(state interfaces.Chain
 		return e
 	}
 	// 累加锁入的HAC
-	addamt := act.LeftAmount.ToMei() + act.RightAmount.ToMei()
-	totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, addamt)
-	totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfChannelOfOpening, 1)
+	lockamt, e := act.LeftAmount.Add(&act.RightAmount)
+	if e != nil {
+		return e
+	}
+	totalsupply.DoAddAmountWithFloat(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, lockamt, act.LeftAmount.ToMei()+act.RightAmount.ToMei())
+	totalsupply.DoAddCount(stores.TotalSupplyStoreTypeOfChannelOfOpening, 1)
 	// update total supply
 	e3 := state.UpdateSetTotalSupply(totalsupply)
 	if e3 != nil {
@@ -216,8 +219,11 @@ func (act *Action_2_OpenPaymentChannel) RecoverChainState(state interfaces.Chain
 		return e2
 	}
 	// 回退解锁的HAC
-	addamt := act.LeftAmount.ToMei() + act.RightAmount.ToMei()
-	totalsupply.DoSub(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, addamt)
+	lockamt, e := act.LeftAmount.Add(&act.RightAmount)
+	if e != nil {
+		return e
+	}
+	totalsupply.DoSubAmountWithFloat(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, lockamt, act.LeftAmount.ToMei()+act.RightAmount.ToMei())
 	// update total supply
 	e3 := state.UpdateSetTotalSupply(totalsupply)
 	if e3 != nil {
@@ -286,11 +292,11 @@ func (act *Action_3_ClosePaymentChannel) WriteinChainState(state interfaces.Chai
 	// 查询通道
 	paychan := state.Channel(act.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
 	}
 	// 判断通道已经关闭
 	if paychan.IsClosed() {
-		return fmt.Errorf("Payment Channel <%s> is be closed.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeStatusConflict, "Payment Channel <%s> is be closed.", hex.EncodeToString(act.ChannelId))
 	}
 	// 检查两个账户的签名 // 仅仅验证这两个地址
 	signok, e1 := act.belong_trs.VerifyTargetSigns([]fields.Address{paychan.LeftAddress, paychan.RightAddress})
@@ -298,7 +304,7 @@ func (act *Action_3_ClosePaymentChannel) WriteinChainState(state interfaces.Chai
 		return e1
 	}
 	if !signok { // 签名检查失败
-		return fmt.Errorf("Payment Channel <%s> address signature verify fail.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeBadSignature, "Payment Channel address signature verify fail.").WithID(act.ChannelId)
 	}
 
 	// 写入状态
@@ -413,13 +419,13 @@ func (act *Action_12_ClosePaymentChannelBySetupAmount) WriteinChainState(state i
 	// 查询通道
 	paychan := state.Channel(act.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
 	}
 	// 检查两个账户是否匹配
 	if paychan.LeftAddress.NotEqual(act.LeftAddress) ||
 		paychan.RightAddress.NotEqual(act.RightAddress) {
 		// 地址检查失败
-		return fmt.Errorf("Payment Channel <%s> address not match.", act.RightAddress.ToReadable())
+		return errs.New(errs.CodeNotPermitted, "Payment Channel <%s> address not match.", act.RightAddress.ToReadable())
 	}
 	// 写入状态
 	leftSAT := paychan.LeftSatoshi.GetRealSatoshi()
@@ -510,7 +516,7 @@ func (act *Action_21_ClosePaymentChannelBySetupOnlyLeftAmount) WriteinChainState
 	// 查询通道
 	paychan := state.Channel(act.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
 	}
 	// 检查两个账户的签名，仅仅验证这两个地址
 	signok, e0 := act.belong_trs.VerifyTargetSigns([]fields.Address{paychan.LeftAddress, paychan.RightAddress})
@@ -518,11 +524,11 @@ func (act *Action_21_ClosePaymentChannelBySetupOnlyLeftAmount) WriteinChainState
 		return e0
 	}
 	if !signok { // 签名检查失败
-		return fmt.Errorf("Payment Channel <%s> address signature verify fail.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeBadSignature, "Payment Channel address signature verify fail.").WithID(act.ChannelId)
 	}
 	// 分配金额可以为零但不能为负
 	if act.LeftAmount.IsNegative() {
-		return fmt.Errorf("Payment channel distribution amount cannot be negative.")
+		return errs.New(errs.CodeInvalidParameter, "Payment channel distribution amount cannot be negative.")
 	}
 	// 检查分配金额
 	var totalAmount, e1 = paychan.LeftAmount.Add(&paychan.RightAmount)
@@ -531,7 +537,7 @@ func (act *Action_21_ClosePaymentChannelBySetupOnlyLeftAmount) WriteinChainState
 	}
 	// 分配金额不能超过总金额
 	if act.LeftAmount.MoreThan(totalAmount) {
-		return fmt.Errorf("LeftAmount %s cannot more than total amount %s.",
+		return errs.New(errs.CodeInvalidParameter, "LeftAmount %s cannot more than total amount %s.",
 			act.LeftAmount.ToFinString(), totalAmount.ToFinString())
 	}
 	// 计算右侧金额
@@ -546,7 +552,7 @@ func (act *Action_21_ClosePaymentChannelBySetupOnlyLeftAmount) WriteinChainState
 	leftNewSAT := act.LeftSatoshi.GetRealSatoshi()
 	if leftNewSAT > totalOldSAT {
 		// 单侧分配金额不能超过总金额
-		return fmt.Errorf("Left satoshi %d cannot more than total %d.", leftNewSAT, totalOldSAT)
+		return errs.New(errs.CodeInvalidParameter, "Left satoshi %d cannot more than total %d.", leftNewSAT, totalOldSAT)
 	}
 	rightNewSAT := totalOldSAT - leftNewSAT
 	return closePaymentChannelWriteinChainState(state, act.ChannelId,
@@ -558,7 +564,7 @@ func (act *Action_21_ClosePaymentChannelBySetupOnlyLeftAmount) RecoverChainState
 	// 查询通道
 	paychan := state.Channel(act.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
 	}
 	// 检查分配金额
 	var totalAmount, _ = paychan.LeftAmount.Add(&paychan.RightAmount)
@@ -584,10 +590,10 @@ func closePaymentChannelWriteinChainState(state interfaces.ChainStateOperation,
 	var e error
 	// 判断通道已经关闭
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(channelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(channelId))
 	}
 	if paychan.IsClosed() {
-		return fmt.Errorf("Payment Channel <%s> is be closed.", hex.EncodeToString(channelId))
+		return errs.New(errs.CodeStatusConflict, "Payment Channel <%s> is be closed.", hex.EncodeToString(channelId))
 	}
 	// 通过时间计算利息
 	if newLeftAmt == nil || newRightAmt == nil {
@@ -598,7 +604,7 @@ func closePaymentChannelWriteinChainState(state interfaces.ChainStateOperation,
 	// 计算总数
 	// 分配金额可以为零但不能为负
 	if newLeftAmt.IsNegative() || newRightAmt.IsNegative() {
-		return fmt.Errorf("Payment channel distribution amount cannot be negative.")
+		return errs.New(errs.CodeInvalidParameter, "Payment channel distribution amount cannot be negative.")
 	}
 	// 检查分配金额是否与存入金额相等
 	tt1, e1 := newLeftAmt.Add(newRightAmt)
@@ -611,7 +617,7 @@ func closePaymentChannelWriteinChainState(state interfaces.ChainStateOperation,
 	}
 	if tt1.NotEqual(tt2) {
 		// 不相等
-		return fmt.Errorf("HAC distribution amount must equal with lock in.")
+		return errs.New(errs.CodeInvalidParameter, "HAC distribution amount must equal with lock in.")
 	}
 	// 计算获得当前的区块高度
 	//var curheight uint64 = 1
@@ -638,7 +644,7 @@ func closePaymentChannelWriteinChainState(state interfaces.ChainStateOperation,
 	totalNewSAT := leftNewSAT + rightNewSAT
 	// 检查总量是否匹配
 	if totalOldSAT != totalNewSAT {
-		return fmt.Errorf("SAT distribution error: need total %d SAT but got %d (left: %d, right: %d).",
+		return errs.New(errs.CodeInvalidParameter, "SAT distribution error: need total %d SAT but got %d (left: %d, right: %d).",
 			totalOldSAT, totalNewSAT, leftNewSAT, rightNewSAT)
 	}
 	if leftNewSAT > 0 {
@@ -671,23 +677,24 @@ func closePaymentChannelWriteinChainState(state interfaces.ChainStateOperation,
 		return e2
 	}
 	// 减少解锁的HAC
-	lockamt := paychan.LeftAmount.ToMei() + paychan.RightAmount.ToMei()
-	totalsupply.DoSub(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, lockamt) // 减少锁定的HAC统计
+	lockamt, e := paychan.LeftAmount.Add(&paychan.RightAmount)
+	if e != nil {
+		return e
+	}
+	lockmei := paychan.LeftAmount.ToMei() + paychan.RightAmount.ToMei()
+	totalsupply.DoSubAmountWithFloat(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, lockamt, lockmei) // 减少锁定的HAC统计
 	if totalNewSAT > 0 {
-		totalsupply.DoSub(stores.TotalSupplyStoreTypeOfLocatedSATInChannel, float64(totalNewSAT)) // 减少锁定的SAT统计
+		totalsupply.DoSubCount(stores.TotalSupplyStoreTypeOfLocatedSATInChannel, uint64(totalNewSAT)) // 减少锁定的SAT统计
 	}
-	totalsupply.DoSub(stores.TotalSupplyStoreTypeOfChannelOfOpening, 1) // 减少通道数量统计
+	totalsupply.DoSubCount(stores.TotalSupplyStoreTypeOfChannelOfOpening, 1) // 减少通道数量统计
 	// 增加通道利息统计
 	if haveinterest {
-		releaseamt := leftAmount.ToMei() + rightAmount.ToMei()
-		//fmt.Println("(act *Action_3_ClosePaymentChannel) WriteinChainState", releaseamt, lockamt, releaseamt - lockamt, )
-		//fmt.Println(paychanptr.LeftAddress.ToReadable(), paychanptr.LeftAmount.ToFinString(), paychanptr.LeftAmount.ToMei())
-		//fmt.Println(paychanptr.RightAddress.ToReadable(), paychanptr.RightAmount.ToFinString(), paychanptr.RightAmount.ToMei())
-		//fmt.Println(leftAmount.ToFinString(), leftAmount.ToMei(), rightAmount.ToFinString(), rightAmount.ToMei())
-		if releaseamt-lockamt < 0 {
-			return fmt.Errorf("!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!")
+		interestamt, e := channelInterestAmount(leftAmount, rightAmount, lockamt)
+		if e != nil {
+			return e
 		}
-		totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfChannelInterest, releaseamt-lockamt)
+		releasemei := leftAmount.ToMei() + rightAmount.ToMei()
+		totalsupply.DoAddAmountWithFloat(stores.TotalSupplyStoreTypeOfChannelInterest, interestamt, releasemei-lockmei)
 	}
 	// update total supply
 	e3 := state.UpdateSetTotalSupply(totalsupply)
@@ -708,11 +715,11 @@ func closePaymentChannelRecoverChainState_deprecated(state interfaces.ChainState
 	paychan := state.Channel(channelId)
 	if paychan == nil {
 		// 通道必须被保存，才能被回退
-		panic(fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(channelId)))
+		panic(errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(channelId)))
 	}
 	// 判断通道必须是已经关闭的状态
 	if paychan.IsClosed() {
-		panic(fmt.Errorf("Payment Channel <%s> is be closed.", hex.EncodeToString(channelId)))
+		panic(errs.New(errs.CodeStatusConflict, "Payment Channel <%s> is be closed.", hex.EncodeToString(channelId)))
 	}
 	if newLeftAmt == nil || newRightAmt == nil {
 		// 自动使用存入的金额计算利息
@@ -751,12 +758,20 @@ func closePaymentChannelRecoverChainState_deprecated(state interfaces.ChainState
 		return e2
 	}
 	// 回退解锁的HAC
-	lockamt := paychan.LeftAmount.ToMei() + paychan.RightAmount.ToMei()
-	totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, lockamt)
+	lockamt, e := paychan.LeftAmount.Add(&paychan.RightAmount)
+	if e != nil {
+		return e
+	}
+	lockmei := paychan.LeftAmount.ToMei() + paychan.RightAmount.ToMei()
+	totalsupply.DoAddAmountWithFloat(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, lockamt, lockmei)
 	// 回退通道利息统计
 	if haveinterest {
-		releaseamt := leftAmount.ToMei() + rightAmount.ToMei()
-		totalsupply.DoSub(stores.TotalSupplyStoreTypeOfChannelInterest, releaseamt-lockamt)
+		interestamt, e := channelInterestAmount(leftAmount, rightAmount, lockamt)
+		if e != nil {
+			return e
+		}
+		releasemei := leftAmount.ToMei() + rightAmount.ToMei()
+		totalsupply.DoSubAmountWithFloat(stores.TotalSupplyStoreTypeOfChannelInterest, interestamt, releasemei-lockmei)
 	}
 	// update total supply
 	e3 := state.UpdateSetTotalSupply(totalsupply)
@@ -766,6 +781,18 @@ func closePaymentChannelRecoverChainState_deprecated(state interfaces.ChainState
 	return nil
 }
 
+// 通道利息数额 = 释放总额 - 锁定总额
+func channelInterestAmount(leftAmount, rightAmount, lockamt *fields.Amount) (*fields.Amount, error) {
+	releaseamt, e := leftAmount.Add(rightAmount)
+	if e != nil {
+		return nil, e
+	}
+	if releaseamt.LessThan(lockamt) {
+		return nil, errs.New(errs.CodeInvalidParameter, "channel release amount %s less than lock amount %s", releaseamt.ToFinString(), lockamt.ToFinString())
+	}
+	return releaseamt.Sub(lockamt)
+}
+
 // 计算通道利息
 // bool 是否有利息
 // interestgiveto 利息分配给谁
@@ -781,8 +808,8 @@ func calculateChannelInterest(curheight uint64, openBelongHeight uint64, leftAmo
 		wfzn = 10 // 千分之一 10/10000
 	}
 	if insnum > 0 {
-		// 计算通道利息奖励
-		a1, a2, e := coinbase.DoAppendCompoundInterestProportionOfHeightV2(leftAmount, rightAmount, insnum, wfzn, interestgiveto)
+		// 计算通道利息奖励，分叉高度之后使用定点计算
+		a1, a2, e := coinbase.DoAppendCompoundInterestProportionOfHeight(curheight, leftAmount, rightAmount, insnum, wfzn, interestgiveto)
 		if e != nil {
 			return nil, nil, false, e
 		}
@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	"github.com/hacash/core/channel"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -73,7 +73,7 @@ func (act *Action_22_UnilateralClosePaymentChannelByNothing) WriteinChainState(s
 	var e error
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -82,17 +82,17 @@ func (act *Action_22_UnilateralClosePaymentChannelByNothing) WriteinChainState(s
 	// 查询通道
 	paychan := state.Channel(act.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
 	}
 	// 检查状态（必须为开启状态）
 	if paychan.IsOpening() == false {
-		return fmt.Errorf("Payment Channel status is not on opening.")
+		return errs.New(errs.CodeStatusConflict, "Payment Channel status is not on opening.")
 	}
 	// 检查两个账户地址看地址是否匹配
 	addrIsLeft := paychan.LeftAddress.Equal(act.AssertCloseAddress)
 	addrIsRight := paychan.RightAddress.Equal(act.AssertCloseAddress)
 	if !addrIsLeft && !addrIsRight {
-		return fmt.Errorf("Payment Channel <%s> address signature verify fail.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeBadSignature, "Payment Channel address signature verify fail.").WithID(act.ChannelId)
 	}
 	// 挑战者状态
 	clghei := state.GetPendingBlockHeight()
@@ -120,7 +120,7 @@ func (act *Action_22_UnilateralClosePaymentChannelByNothing) RecoverChainState(s
 	// 查询通道
 	paychan := state.Channel(act.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
 	}
 	// 回退状态
 	paychan.SetOpening()
@@ -200,7 +200,7 @@ func (elm *Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeRe
 func (act *Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeReconciliation) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -213,7 +213,7 @@ func (act *Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeRe
 	// 查询通道
 	paychan := state.Channel(channelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel <%s> not find.", hex.EncodeToString(channelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel <%s> not find.", hex.EncodeToString(channelId))
 	}
 	// 检查两个账户地址签名，双方都检查
 	// 进入挑战期还是夺取资金
@@ -227,7 +227,7 @@ func (act *Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeRe
 	// 查询通道
 	paychan := state.Channel(channelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(channelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(channelId))
 	}
 
 	// 回退
@@ -319,7 +319,7 @@ func (act *Action_24_UnilateralCloseOrRespondChallengePaymentChannelByChannelCha
 	var e error
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -329,7 +329,7 @@ func (act *Action_24_UnilateralCloseOrRespondChallengePaymentChannelByChannelCha
 	// 查询通道
 	paychan := state.Channel(act.ChannelChainTransferTargetProveBody.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel <%s> not find.", hex.EncodeToString(act.ChannelChainTransferTargetProveBody.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel <%s> not find.", hex.EncodeToString(act.ChannelChainTransferTargetProveBody.ChannelId))
 	}
 
 	// 检查通道哈希是否正确
@@ -343,7 +343,7 @@ func (act *Action_24_UnilateralCloseOrRespondChallengePaymentChannelByChannelCha
 		}
 	}
 	if !isHashCheckOk {
-		return fmt.Errorf("ChannelChainTransferTargetProveBody hash <%s> not find.", hxhalf.ToHex())
+		return errs.New(errs.CodeNotFound, "ChannelChainTransferTargetProveBody hash <%s> not find.", hxhalf.ToHex())
 	}
 
 	// 检查双方通道地址是否包含在签名列表内
@@ -357,7 +357,7 @@ func (act *Action_24_UnilateralCloseOrRespondChallengePaymentChannelByChannelCha
 		}
 	}
 	if !lsgok || !rsgok {
-		return fmt.Errorf("Channel signature address is missing.")
+		return errs.New(errs.CodeBadSignature, "Channel signature address is missing.")
 	}
 
 	// 检查所有签名是否完整和正确
@@ -375,7 +375,7 @@ func (act *Action_24_UnilateralCloseOrRespondChallengePaymentChannelByChannelCha
 	// 查询通道
 	paychan := state.Channel(act.ChannelChainTransferTargetProveBody.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelChainTransferTargetProveBody.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelChainTransferTargetProveBody.ChannelId))
 	}
 
 	// 回退
@@ -467,7 +467,7 @@ func (act *Action_26_UnilateralCloseOrRespondChallengePaymentChannelByChannelOnc
 	var e error
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -479,17 +479,17 @@ func (act *Action_26_UnilateralCloseOrRespondChallengePaymentChannelByChannelOnc
 	// 查询通道
 	paychan := state.Channel(act.ChannelChainTransferTargetProveBody.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel <%s> not find.", hex.EncodeToString(act.ChannelChainTransferTargetProveBody.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel <%s> not find.", hex.EncodeToString(act.ChannelChainTransferTargetProveBody.ChannelId))
 	}
 
 	// 查询互换交易
 	swapex := state.Chaswap(act.ProveBodyHashChecker)
 	if swapex == nil {
-		return fmt.Errorf("Chaswap tranfer <%s> not find.", act.ProveBodyHashChecker.ToHex())
+		return errs.New(errs.CodeNotFound, "Chaswap tranfer <%s> not find.", act.ProveBodyHashChecker.ToHex())
 	}
 	// 是否已经使用过
 	if swapex.IsBeUsed.Check() {
-		return fmt.Errorf("Chaswap tranfer <%s> already be used.", act.ProveBodyHashChecker.ToHex())
+		return errs.New(errs.CodeAlreadyExists, "Chaswap tranfer already be used.").WithID(act.ProveBodyHashChecker)
 	}
 
 	// 检查必须签名的地址是否完整和正确
@@ -500,7 +500,7 @@ func (act *Action_26_UnilateralCloseOrRespondChallengePaymentChannelByChannelOnc
 	_, hasleft := addrsmap[string(paychan.LeftAddress)]
 	_, hasright := addrsmap[string(paychan.RightAddress)]
 	if !hasleft || !hasright {
-		return fmt.Errorf("Chaswap tranfer signature error.")
+		return errs.New(errs.CodeBadSignature, "Chaswap tranfer signature error.")
 	}
 
 	// 标记票据已使用
@@ -519,7 +519,7 @@ func (act *Action_26_UnilateralCloseOrRespondChallengePaymentChannelByChannelOnc
 	// 查询通道
 	paychan := state.Channel(act.ChannelChainTransferTargetProveBody.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelChainTransferTargetProveBody.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelChainTransferTargetProveBody.ChannelId))
 	}
 
 	// 回退使用状态
@@ -549,13 +549,13 @@ func checkChannelGotoChallegingOrFinalDistributionWriteinChainState(state interf
 
 	// 通道不能已经关闭
 	if paychan.IsClosed() {
-		return fmt.Errorf("Payment Channel <%s> is closed.", hex.EncodeToString(channelId))
+		return errs.New(errs.CodeStatusConflict, "Payment Channel <%s> is closed.", hex.EncodeToString(channelId))
 	}
 	// 检查地址匹配你
 	var assertAddressIsLeft = paychan.LeftAddress.Equal(assertAddress)
 	var assertAddressIsRight = paychan.RightAddress.Equal(assertAddress)
 	if !assertAddressIsLeft && !assertAddressIsRight {
-		return fmt.Errorf("Payment Channel AssertAddress is not match left or right.")
+		return errs.New(errs.CodeNotPermitted, "Payment Channel AssertAddress is not match left or right.")
 	}
 	// 检查两个账户地址签名，双方都检查
 	e20 := obj.CheckAddressAndSign(paychan.LeftAddress, paychan.RightAddress)
@@ -566,7 +566,7 @@ func checkChannelGotoChallegingOrFinalDistributionWriteinChainState(state interf
 	channelReuseVersion := obj.GetReuseVersion()
 	billAutoNumber := obj.GetAutoNumber()
 	if channelReuseVersion != uint32(paychan.ReuseVersion) {
-		return fmt.Errorf("Payment Channel ReuseVersion is not match, need <%d> but got <%d>.",
+		return errs.New(errs.CodeInvalidParameter, "Payment Channel ReuseVersion is not match, need <%d> but got <%d>.",
 			paychan.ReuseVersion, channelReuseVersion)
 	}
 	// 检查对账单资金数额和重用版本
@@ -581,7 +581,7 @@ func checkChannelGotoChallegingOrFinalDistributionWriteinChainState(state interf
 		return e22
 	}
 	if billTotalAmt.NotEqual(paychanTotalAmt) {
-		return fmt.Errorf("Payment Channel Total Amount is not match, need %s but got %s.",
+		return errs.New(errs.CodeInvalidParameter, "Payment Channel Total Amount is not match, need %s but got %s.",
 			paychanTotalAmt.ToFinString(), billTotalAmt.ToFinString())
 	}
 	// 仲裁金额
@@ -607,13 +607,13 @@ func checkChannelGotoChallegingOrFinalDistributionWriteinChainState(state interf
 
 		// 只能夺取对方，不能既自己提出仲裁，然后又自己回应挑战
 		if paychan.AssertAddressIsLeftOrRight.Check() == assertAddressIsLeft {
-			return fmt.Errorf("The arbitration request and the response cannot be the same address")
+			return errs.New(errs.CodeInvalidParameter, "The arbitration request and the response cannot be the same address")
 		}
 
 		// 判断仲裁，是否夺取对方资金
 		if billAutoNumber <= uint64(paychan.AssertBillAutoNumber) {
 			// 账单流水号不满足（必须大于等待挑战的流水号）
-			return fmt.Errorf("Payment Channel BillAutoNumber must more than %d.", paychan.AssertBillAutoNumber)
+			return errs.New(errs.CodeInvalidParameter, "Payment Channel BillAutoNumber must more than %d.", paychan.AssertBillAutoNumber)
 		}
 		// 更高的流水号
 		// 夺取全部资金，关闭通道
@@ -634,7 +634,7 @@ func checkChannelGotoChallegingOrFinalDistributionWriteinChainState(state interf
 		return closePaymentChannelWriteinChainState(state, channelId, paychan, lamt, ramt, lsat, rsat, isFinalClosed)
 
 	} else {
-		return fmt.Errorf("Payment Channel <%s> status error.", hex.EncodeToString(channelId))
+		return errs.New(errs.CodeStatusConflict, "Payment Channel <%s> status error.", hex.EncodeToString(channelId))
 	}
 }
 
@@ -648,7 +648,7 @@ func checkChannelGotoChallegingOrFinalDistributionRecoverChainState(state interf
 	// 判断通道状态，是进入挑战期，还是最终夺取
 	if paychan.IsFinalDistributionClosed() {
 		if !paychan.IsHaveChallengeLog.Check() {
-			return fmt.Errorf("IsHaveChallengeLog is not find.")
+			return errs.New(errs.CodeNotFound, "IsHaveChallengeLog is not find.")
 		}
 		// 计算回退数额
 		paychanTotalAmt, _ := paychan.LeftAmount.Add(&paychan.RightAmount)
@@ -672,7 +672,7 @@ func checkChannelGotoChallegingOrFinalDistributionRecoverChainState(state interf
 		return state.ChannelUpdate(channelId, paychan)
 
 	} else {
-		return fmt.Errorf("Payment Channel <%s> status error.", hex.EncodeToString(channelId))
+		return errs.New(errs.CodeStatusConflict, "Payment Channel <%s> status error.", hex.EncodeToString(channelId))
 	}
 }
 
@@ -728,7 +728,7 @@ func (elm *Action_27_ClosePaymentChannelByClaimDistribution) RequestSignAddresse
 func (act *Action_27_ClosePaymentChannelByClaimDistribution) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -737,18 +737,18 @@ func (act *Action_27_ClosePaymentChannelByClaimDistribution) WriteinChainState(s
 	// 查询通道
 	paychan := state.Channel(act.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
 	}
 	// 检查状态（必须为挑战期状态）
 	if paychan.IsChallenging() == false {
-		return fmt.Errorf("Payment Channel status is not on challenging.")
+		return errs.New(errs.CodeStatusConflict, "Payment Channel status is not on challenging.")
 	}
 	// 检查挑战期限
 	clghei := state.GetPendingBlockHeight()
 	expireHei := uint64(paychan.ChallengeLaunchHeight) + uint64(paychan.ArbitrationLockBlock)
 	if clghei <= expireHei {
 		// 挑战期还没过
-		return fmt.Errorf("Payment Channel Challenging expire is %d.", expireHei)
+		return errs.New(errs.CodeExpired, "Payment Channel Challenging expire is %d.", expireHei)
 	}
 	// 按主张分配资金，结束通道
 	var lamt = fields.NewEmptyAmount()
@@ -782,7 +782,7 @@ func (act *Action_27_ClosePaymentChannelByClaimDistribution) RecoverChainState(s
 	// 查询通道
 	paychan := state.Channel(act.ChannelId)
 	if paychan == nil {
-		return fmt.Errorf("Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
+		return errs.New(errs.CodeNotFound, "Payment Channel Id <%s> not find.", hex.EncodeToString(act.ChannelId))
 	}
 	// 回退状态
 	// 按主张分配资金，结束通道
@@ -3,8 +3,8 @@ package actions
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"github.com/hacash/core/account"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -128,7 +128,7 @@ func (elm *ChannelAmountAndOnChainAmountTransferEachOtherByAtomicExchange) Check
 	// 检查地址最低数量
 	sgmn := len(elm.OnchainTransferFromAndMustSignAddresses)
 	if sgmn < 2 || sgmn > 3 || sgmn != int(elm.AddressCount) || sgmn != len(elm.MustSigns) {
-		return fmt.Errorf("Address or Sign length error, need 2~3 but got %d, %d, %d.",
+		return errs.New(errs.CodeBadSignature, "Address or Sign length error, need 2~3 but got %d, %d, %d.",
 			sgmn, int(elm.AddressCount), len(elm.MustSigns))
 	}
 
@@ -139,13 +139,13 @@ func (elm *ChannelAmountAndOnChainAmountTransferEachOtherByAtomicExchange) Check
 		sgaddr := account.NewAddressFromPublicKeyV0(sign.PublicKey)
 		// 判断地址顺序
 		if addr.NotEqual(sgaddr) {
-			return fmt.Errorf("Address not match, need %s nut got %s.",
+			return errs.New(errs.CodeNotPermitted, "Address not match, need %s nut got %s.",
 				addr.ToReadable(), fields.Address(sgaddr).ToReadable())
 		}
 		// 检查签名
 		ok, _ := account.CheckSignByHash32(conhx, sign.PublicKey, sign.Signature)
 		if !ok {
-			return fmt.Errorf("Left account %s verify signature fail.", addr.ToReadable())
+			return errs.New(errs.CodeBadSignature, "Left account verify signature fail.").WithAddress(addr)
 		}
 	}
 
@@ -209,7 +209,7 @@ func (act *Action_25_PaymantChannelAndOnchainAtomicExchange) WriteinChainState(s
 	var e error
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -222,12 +222,11 @@ func (act *Action_25_PaymantChannelAndOnchainAtomicExchange) WriteinChainState(s
 	if chaswap != nil {
 		// 已经存在，不可重复提交
 		// 否则将会导致多次重复转账
-		return fmt.Errorf("ChannelTranferProveBodyHashChecker <%s> is existence.",
-			swaphx.ToHex())
+		return errs.New(errs.CodeAlreadyExists, "ChannelTranferProveBodyHashChecker is existence.").WithID(swaphx)
 	}
 
 	if len(act.ExchangeEvidence.OnchainTransferFromAndMustSignAddresses) < 2 {
-		return fmt.Errorf("Address lenght error.")
+		return errs.New(errs.CodeMalformed, "Address lenght error.")
 	}
 
 	// 提交时不验证通道相关内容，仅仅操作链上转账
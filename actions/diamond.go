@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -166,13 +166,13 @@ func (act *Action_4_DiamondCreate) WriteinChainState(state interfaces.ChainState
 		// 交易只能包含唯一一个action
 		belongactionnum := len(act.belong_trs.GetActions())
 		if 1 != belongactionnum {
-			return fmt.Errorf("Diamond create tx need only one action but got %d actions.", belongactionnum)
+			return errs.New(errs.CodeInvalidParameter, "Diamond create tx need only one action but got %d actions.", belongactionnum)
 		}
 		// 检查区块高度
 		// 检查区块高度值是否为5的倍数
-		// {BACKTOPOOL} 表示扔回交易池等待下个区块再次处理
+		// CodeRetryLater 表示扔回交易池等待下个区块再次处理（错误信息仍带 {BACKTOPOOL} 前缀，兼容旧的判断）
 		if blkhei%5 != 0 {
-			return fmt.Errorf("{BACKTOPOOL} Diamond must be in block height multiple of 5.")
+			return errs.New(errs.CodeRetryLater, "Diamond must be in block height multiple of 5.").WithHeight(blkhei)
 		}
 
 		// 矿工状态检查
@@ -188,29 +188,29 @@ func (act *Action_4_DiamondCreate) WriteinChainState(state interfaces.ChainState
 			prevdiamondnum, prevdiamondhash := uint32(lastdiamond.Number), lastdiamond.ContainBlockHash
 			// 检查钻石是否是从上一个区块得来
 			if act.PrevHash.Equal(prevdiamondhash) != true {
-				return fmt.Errorf("Diamond prev hash must be <%s> but got <%s>.", hex.EncodeToString(prevdiamondhash), hex.EncodeToString(act.PrevHash))
+				return errs.New(errs.CodeInvalidParameter, "Diamond prev hash must be <%s> but got <%s>.", hex.EncodeToString(prevdiamondhash), hex.EncodeToString(act.PrevHash))
 			}
 			if prevdiamondnum+1 != uint32(act.Number) {
-				return fmt.Errorf("Diamond number must be <%d> but got <%d>.", prevdiamondnum+1, act.Number)
+				return errs.New(errs.CodeInvalidParameter, "Diamond number must be <%d> but got <%d>.", prevdiamondnum+1, act.Number)
 			}
 		}
 		// 检查钻石挖矿计算
 		diamondstrval, isdia := x16rs.IsDiamondHashResultString(diamondStr)
 		if !isdia {
-			return fmt.Errorf("String <%s> is not diamond.", diamondStr)
+			return errs.New(errs.CodeInvalidParameter, "String <%s> is not diamond.", diamondStr)
 		}
 		if strings.Compare(diamondstrval, string(act.Diamond)) != 0 {
-			return fmt.Errorf("Diamond need <%s> but got <%s>", act.Diamond, diamondstrval)
+			return errs.New(errs.CodeInvalidParameter, "Diamond need <%s> but got <%s>", act.Diamond, diamondstrval)
 		}
 		// 检查钻石难度值
 		difok := x16rs.CheckDiamondDifficulty(uint32(act.Number), diamondResHash)
 		if !difok {
-			return fmt.Errorf("Diamond difficulty not meet the requirements.")
+			return errs.New(errs.CodeInvalidParameter, "Diamond difficulty not meet the requirements.")
 		}
 		// 查询钻石是否已经存在
 		hasaddr := state.Diamond(act.Diamond)
 		if hasaddr != nil {
-			return fmt.Errorf("Diamond <%s> already exist.", string(act.Diamond))
+			return errs.New(errs.CodeAlreadyExists, "Diamond <%s> already exist.", string(act.Diamond))
 		}
 		// 检查一个区块只能包含一枚钻石
 		pendingdiamond, e2 := state.GetPendingSubmitStoreDiamond()
@@ -218,7 +218,7 @@ func (act *Action_4_DiamondCreate) WriteinChainState(state interfaces.ChainState
 			return e2
 		}
 		if pendingdiamond != nil {
-			return fmt.Errorf("This block height:%d has already exist diamond:<%s> .", blkhei, pendingdiamond.Diamond)
+			return errs.New(errs.CodeAlreadyExists, "This block height has already exist diamond:<%s> .", pendingdiamond.Diamond).WithHeight(blkhei)
 		}
 		// 全部条件检查成功
 	}
@@ -232,7 +232,7 @@ func (act *Action_4_DiamondCreate) WriteinChainState(state interfaces.ChainState
 		return e3
 	}
 	// 增加钻石余额 +1
-	e9 := DoAddDiamondFromChainState(state, act.Address, 1)
+	e9 := DoAddDiamondFromChainState(state, act.Address, []fields.DiamondName{act.Diamond})
 	if e9 != nil {
 		return e9
 	}
@@ -240,7 +240,7 @@ func (act *Action_4_DiamondCreate) WriteinChainState(state interfaces.ChainState
 	// 设置矿工状态
 	// 标记本区块已经包含钻石
 	// 存储对象，计算视觉基因
-	visualGene, e15 := calculateVisualGeneByDiamondStuffHash(uint32(act.Number), diamondResHash, diamondStr, diamondVisualUseContainBlockHash)
+	visualGene, e15 := CalculateVisualGeneByDiamondStuffHash(uint32(act.Number), diamondResHash, diamondStr, diamondVisualUseContainBlockHash)
 	if e15 != nil {
 		return e15
 	}
@@ -293,7 +293,7 @@ func (act *Action_4_DiamondCreate) WriteinChainState(state interfaces.ChainState
 		return e5
 	}
 
-	totalsupply.Set(stores.TotalSupplyStoreTypeOfDiamond, float64(act.Number))
+	totalsupply.SetCount(stores.TotalSupplyStoreTypeOfDiamond, int64(act.Number))
 	// update total supply
 	e7 := state.UpdateSetTotalSupply(totalsupply)
 	if e7 != nil {
@@ -324,7 +324,7 @@ func (act *Action_4_DiamondCreate) RecoverChainState(state interfaces.ChainState
 	// 回退矿工状态
 	chainstore := state.BlockStore()
 	if chainstore == nil {
-		return fmt.Errorf("not find BlockStore object.")
+		return errs.New(errs.CodeUnknown, "not find BlockStore object.")
 
 	}
 	prevDiamond, e2 := chainstore.ReadDiamondByNumber(uint32(act.Number) - 1)
@@ -337,7 +337,7 @@ func (act *Action_4_DiamondCreate) RecoverChainState(state interfaces.ChainState
 		return e3
 	}
 	// 扣除钻石余额 -1
-	e9 := DoSubDiamondFromChainState(state, act.Address, 1)
+	e9 := DoSubDiamondFromChainState(state, act.Address, []fields.DiamondName{act.Diamond})
 	if e9 != nil {
 		return e9
 	}
@@ -346,7 +346,7 @@ func (act *Action_4_DiamondCreate) RecoverChainState(state interfaces.ChainState
 	if e2 != nil {
 		return e2
 	}
-	totalsupply.Set(stores.TotalSupplyStoreTypeOfDiamond, float64(uint32(act.Number)-1))
+	totalsupply.SetCount(stores.TotalSupplyStoreTypeOfDiamond, int64(act.Number)-1)
 	// update total supply
 	e7 := state.UpdateSetTotalSupply(totalsupply)
 	if e7 != nil {
@@ -372,12 +372,12 @@ func (act *Action_4_DiamondCreate) IsBurning90PersentTxFees() bool {
 ///////////////////////////////////////////////////////////////
 
 // 计算钻石的可视化基因
-func calculateVisualGeneByDiamondStuffHash(number uint32, stuffhx []byte, diamondstr string, peddingblkhash []byte) (fields.Bytes10, error) {
+func CalculateVisualGeneByDiamondStuffHash(number uint32, stuffhx []byte, diamondstr string, peddingblkhash []byte) (fields.Bytes10, error) {
 	if len(stuffhx) != 32 || len(peddingblkhash) != 32 {
-		return nil, fmt.Errorf("stuffhx and peddingblkhash length must 32")
+		return nil, errs.New(errs.CodeMalformed, "stuffhx and peddingblkhash length must 32")
 	}
 	if len(diamondstr) != 16 {
-		return nil, fmt.Errorf("diamondstr length must 16")
+		return nil, errs.New(errs.CodeMalformed, "diamondstr length must 16")
 	}
 	vgenehash := make([]byte, 32)
 	copy(vgenehash, stuffhx)
@@ -548,21 +548,21 @@ func (act *Action_5_DiamondTransfer) WriteinChainState(state interfaces.ChainSta
 
 	// 自己不能转给自己
 	if bytes.Compare(act.ToAddress, trsMainAddress) == 0 {
-		return fmt.Errorf("Cannot transfer to self.")
+		return errs.New(errs.CodeInvalidParameter, "Cannot transfer to self.")
 	}
 	// 查询钻石是否已经存在
 	diaitem := state.Diamond(act.Diamond)
 	if diaitem == nil {
-		return fmt.Errorf("Diamond <%s> not exist.", string(act.Diamond))
+		return errs.New(errs.CodeNotFound, "Diamond <%s> not exist.", string(act.Diamond))
 	}
 	item := diaitem
 	// 检查是否抵押，是否可以转账
 	if diaitem.Status != stores.DiamondStatusNormal {
-		return fmt.Errorf("Diamond <%s> has been mortgaged and cannot be transferred.", string(act.Diamond))
+		return errs.New(errs.CodeStatusConflict, "Diamond <%s> has been mortgaged and cannot be transferred.", string(act.Diamond))
 	}
 	// 检查所属
 	if bytes.Compare(item.Address, trsMainAddress) != 0 {
-		return fmt.Errorf("Diamond <%s> not belong to belong_trs address.", string(act.Diamond))
+		return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to belong_trs address.", string(act.Diamond))
 	}
 	// 转移钻石
 	item.Address = act.ToAddress
@@ -571,11 +571,12 @@ func (act *Action_5_DiamondTransfer) WriteinChainState(state interfaces.ChainSta
 		return err
 	}
 	// 转移钻石余额
-	e9 := DoSimpleDiamondTransferFromChainState(state, trsMainAddress, act.ToAddress, 1)
+	e9 := DoSimpleDiamondTransferFromChainState(state, trsMainAddress, act.ToAddress, []fields.DiamondName{act.Diamond})
 	if e9 != nil {
 		return e9
 	}
-	return nil
+	// 流转历史
+	return doAppendDiamondHistory(state, act.trs, act.Kind(), trsMainAddress, act.ToAddress, []fields.DiamondName{act.Diamond})
 }
 
 func (act *Action_5_DiamondTransfer) RecoverChainState(state interfaces.ChainStateOperation) error {
@@ -586,7 +587,7 @@ func (act *Action_5_DiamondTransfer) RecoverChainState(state interfaces.ChainSta
 	// get diamond
 	diaitem := state.Diamond(act.Diamond)
 	if diaitem == nil {
-		return fmt.Errorf("Diamond <%s> not exist.", string(act.Diamond))
+		return errs.New(errs.CodeNotFound, "Diamond <%s> not exist.", string(act.Diamond))
 	}
 	item := diaitem
 	// 回退钻石
@@ -596,11 +597,12 @@ func (act *Action_5_DiamondTransfer) RecoverChainState(state interfaces.ChainSta
 		return err
 	}
 	// 回退钻石余额
-	e9 := DoSimpleDiamondTransferFromChainState(state, act.ToAddress, trsMainAddress, 1)
+	e9 := DoSimpleDiamondTransferFromChainState(state, act.ToAddress, trsMainAddress, []fields.DiamondName{act.Diamond})
 	if e9 != nil {
 		return e9
 	}
-	return nil
+	// 回退流转历史
+	return doDropDiamondHistory(state, act.trs, act.Kind(), []fields.DiamondName{act.Diamond})
 }
 
 func (elm *Action_5_DiamondTransfer) SetBelongTransaction(t interfaces.Transaction) {
@@ -689,14 +691,14 @@ func (act *Action_6_OutfeeQuantityDiamondTransfer) WriteinChainState(state inter
 	// 数量检查
 	dianum := int(act.DiamondList.Count)
 	if dianum == 0 || dianum != len(act.DiamondList.Diamonds) {
-		return fmt.Errorf("Diamonds quantity error")
+		return errs.New(errs.CodeMalformed, "Diamonds quantity error")
 	}
 	if dianum > 200 {
-		return fmt.Errorf("Diamonds quantity cannot over 200")
+		return errs.New(errs.CodeInvalidParameter, "Diamonds quantity cannot over 200")
 	}
 	// 自己不能转给自己
 	if bytes.Compare(act.FromAddress, act.ToAddress) == 0 {
-		return fmt.Errorf("Cannot transfer to self.")
+		return errs.New(errs.CodeInvalidParameter, "Cannot transfer to self.")
 	}
 	// 批量转移钻石
 	for i := 0; i < len(act.DiamondList.Diamonds); i++ {
@@ -709,16 +711,16 @@ func (act *Action_6_OutfeeQuantityDiamondTransfer) WriteinChainState(state inter
 		diaitem := state.Diamond(diamond)
 		if diaitem == nil {
 			//panic("Quantity Diamond <%s> not exist. " + string(diamond))
-			return fmt.Errorf("Quantity Diamond <%s> not exist.", string(diamond))
+			return errs.New(errs.CodeNotFound, "Quantity Diamond <%s> not exist.", string(diamond))
 		}
 		item := diaitem
 		// 检查是否抵押，是否可以转账
 		if diaitem.Status != stores.DiamondStatusNormal {
-			return fmt.Errorf("Diamond <%s> has been mortgaged and cannot be transferred.", string(diamond))
+			return errs.New(errs.CodeStatusConflict, "Diamond <%s> has been mortgaged and cannot be transferred.", string(diamond))
 		}
 		// 检查所属
 		if bytes.Compare(item.Address, act.FromAddress) != 0 {
-			return fmt.Errorf("Diamond <%s> not belong to address '%s'", string(diamond), act.FromAddress.ToReadable())
+			return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to address '%s'", string(diamond), act.FromAddress.ToReadable())
 		}
 		// 转移钻石
 		item.Address = act.ToAddress
@@ -728,11 +730,12 @@ func (act *Action_6_OutfeeQuantityDiamondTransfer) WriteinChainState(state inter
 		}
 	}
 	// 转移钻石余额
-	e9 := DoSimpleDiamondTransferFromChainState(state, act.FromAddress, act.ToAddress, fields.DiamondNumber(dianum))
+	e9 := DoSimpleDiamondTransferFromChainState(state, act.FromAddress, act.ToAddress, act.DiamondList.Diamonds)
 	if e9 != nil {
 		return e9
 	}
-	return nil
+	// 流转历史
+	return doAppendDiamondHistory(state, act.trs, act.Kind(), act.FromAddress, act.ToAddress, act.DiamondList.Diamonds)
 }
 
 func (act *Action_6_OutfeeQuantityDiamondTransfer) RecoverChainState(state interfaces.ChainStateOperation) error {
@@ -745,7 +748,7 @@ func (act *Action_6_OutfeeQuantityDiamondTransfer) RecoverChainState(state inter
 		// get diamond
 		diaitem := state.Diamond(diamond)
 		if diaitem == nil {
-			return fmt.Errorf("Diamond <%s> not exist.", string(diamond))
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not exist.", string(diamond))
 		}
 		item := diaitem
 		// 回退钻石
@@ -756,11 +759,12 @@ func (act *Action_6_OutfeeQuantityDiamondTransfer) RecoverChainState(state inter
 		}
 	}
 	// 回退钻石余额
-	e9 := DoSimpleDiamondTransferFromChainState(state, act.ToAddress, act.FromAddress, fields.DiamondNumber(act.DiamondList.Count))
+	e9 := DoSimpleDiamondTransferFromChainState(state, act.ToAddress, act.FromAddress, act.DiamondList.Diamonds)
 	if e9 != nil {
 		return e9
 	}
-	return nil
+	// 回退流转历史
+	return doDropDiamondHistory(state, act.trs, act.Kind(), act.DiamondList.Diamonds)
 }
 
 func (elm *Action_6_OutfeeQuantityDiamondTransfer) SetBelongTransaction(t interfaces.Transaction) {
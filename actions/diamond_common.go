@@ -2,14 +2,32 @@ package actions
 
 import (
 	"bytes"
-	"fmt"
+	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
 )
 
-// diamond 转账
-func DoSimpleDiamondTransferFromChainState(state interfaces.ChainStateOperation, addr1 fields.Address, addr2 fields.Address, dia fields.DiamondNumber) error {
+// 地址持有钻石索引与钻石流转历史的启用高度
+// 两者都在共识动作内写入状态，必须等全网节点升级之后在同一高度统一启用，与定点计算使用同一分叉高度
+// 启用区块在执行交易之前补建一次全部持有索引并写入流转历史的所属快照，见 DoActivateDiamondIndex
+const DiamondIndexActiveBlockHeight = coinbase.FixedPointMathForkBlockHeight
+
+// 是否维护钻石索引，本地开发测试模式下全部高度启用
+func IsDiamondIndexActive(blockHeight uint64) bool {
+	return sys.TestDebugLocalDevelopmentMark || blockHeight >= DiamondIndexActiveBlockHeight
+}
+
+// 是否为启用区块，本地开发测试模式从创世开始维护，不需要补建
+func IsDiamondIndexActivateBlock(blockHeight uint64) bool {
+	return !sys.TestDebugLocalDevelopmentMark && blockHeight == DiamondIndexActiveBlockHeight
+}
+
+// diamond 转账，同时维护地址持有钻石索引
+func DoSimpleDiamondTransferFromChainState(state interfaces.ChainStateOperation, addr1 fields.Address, addr2 fields.Address, diamonds []fields.DiamondName) error {
+	dia := fields.DiamondNumber(len(diamonds))
 	if bytes.Compare(addr1, addr2) == 0 {
 		return nil // 可以自己转给自己，不改变状态，白费手续费
 	}
@@ -18,12 +36,12 @@ func DoSimpleDiamondTransferFromChainState(state interfaces.ChainStateOperation,
 	}
 	bls1 := state.Balance(addr1)
 	if bls1 == nil {
-		return fmt.Errorf("Diamond not find.")
+		return errs.New(errs.CodeInsufficientDiamond, "Diamond not find.").WithAddress(addr1)
 	}
 	dia1 := bls1.Diamond
 	// 检查余额
 	if uint64(dia1) < uint64(dia) {
-		return fmt.Errorf("Address %s diamond %d not enough, need more %d.", addr1.ToReadable(), dia1, dia)
+		return errs.New(errs.CodeInsufficientDiamond, "diamond %d not enough, need more %d.", dia1, dia).WithAddress(addr1)
 	}
 	bls2 := state.Balance(addr2)
 	if bls2 == nil {
@@ -40,12 +58,17 @@ func DoSimpleDiamondTransferFromChainState(state interfaces.ChainStateOperation,
 	if bse2 != nil {
 		return bse2
 	}
-	// return ok
-	return nil
+	// 持有索引
+	e1 := doRemoveDiamondOwnerIndex(state, addr1, diamonds)
+	if e1 != nil {
+		return e1
+	}
+	return doAddDiamondOwnerIndex(state, addr2, diamonds)
 }
 
-// 单纯增加 Diamond 余额
-func DoAddDiamondFromChainState(state interfaces.ChainStateOperation, addr fields.Address, dia fields.DiamondNumber) error {
+// 单纯增加 Diamond 余额，同时加入持有索引
+func DoAddDiamondFromChainState(state interfaces.ChainStateOperation, addr fields.Address, diamonds []fields.DiamondName) error {
+	dia := fields.DiamondNumber(len(diamonds))
 	if dia == 0 {
 		return nil // 数量为0，直接成功
 	}
@@ -61,22 +84,23 @@ func DoAddDiamondFromChainState(state interfaces.ChainStateOperation, addr field
 	if bserr != nil {
 		return bserr
 	}
-	return nil
+	return doAddDiamondOwnerIndex(state, addr, diamonds)
 }
 
-// 单纯扣除 diamond 余额
-func DoSubDiamondFromChainState(state interfaces.ChainStateOperation, addr fields.Address, dia fields.DiamondNumber) error {
+// 单纯扣除 diamond 余额，同时移出持有索引
+func DoSubDiamondFromChainState(state interfaces.ChainStateOperation, addr fields.Address, diamonds []fields.DiamondName) error {
+	dia := fields.DiamondNumber(len(diamonds))
 	if dia == 0 {
 		return nil // 数量为0，直接成功
 	}
 	blssto := state.Balance(addr)
 	if blssto == nil {
-		return fmt.Errorf("address %s diamond need %d not enough.", addr.ToReadable(), dia)
+		return errs.New(errs.CodeInsufficientDiamond, "diamond need %d not enough.", dia).WithAddress(addr)
 	}
 	basedia := blssto.Diamond
 	// 检查余额
 	if uint64(basedia) < uint64(dia) {
-		return fmt.Errorf("address %s satoshi %d not enough, need more %d.", addr.ToReadable(), basedia, dia)
+		return errs.New(errs.CodeInsufficientDiamond, "diamond %d not enough, need more %d.", basedia, dia).WithAddress(addr)
 	}
 	newdia := uint64(basedia) - uint64(dia)
 	blssto.Diamond = fields.DiamondNumber(newdia)
@@ -84,5 +108,65 @@ func DoSubDiamondFromChainState(state interfaces.ChainStateOperation, addr field
 	if bserr != nil {
 		return bserr
 	}
+	return doRemoveDiamondOwnerIndex(state, addr, diamonds)
+}
+
+// 加入地址持有钻石索引
+func doAddDiamondOwnerIndex(state interfaces.ChainStateOperation, addr fields.Address, diamonds []fields.DiamondName) error {
+	if !IsDiamondIndexActive(state.GetPendingBlockHeight()) {
+		return nil // 未启用
+	}
+	for _, dia := range diamonds {
+		e := state.DiamondOwnerAdd(addr, dia)
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// 移出地址持有钻石索引
+func doRemoveDiamondOwnerIndex(state interfaces.ChainStateOperation, addr fields.Address, diamonds []fields.DiamondName) error {
+	if !IsDiamondIndexActive(state.GetPendingBlockHeight()) {
+		return nil // 未启用
+	}
+	for _, dia := range diamonds {
+		e := state.DiamondOwnerDel(addr, dia)
+		if e != nil {
+			return e
+		}
+	}
 	return nil
 }
+
+// 启用索引：在启用区块执行交易之前全量扫描钻石储存一次，补建全部地址的持有索引
+// 抵押、哈希时间锁定中的钻石已扣除余额，不加入索引，赎回、退回时由余额函数重新加入
+// 同时为每枚钻石写入一条所属快照作为流转历史的起点，之前的流转不再补录
+func DoActivateDiamondIndex(state interfaces.ChainStateOperation) error {
+	iter, ok := state.(interfaces.DiamondStateIterator)
+	if !ok {
+		return errs.New(errs.CodeUnsupportedKind, "Chain state not support diamond iterate, cannot activate diamond index.")
+	}
+	height := fields.BlockHeight(state.GetPendingBlockHeight())
+	var err error = nil
+	e := iter.IterateDiamond(func(name fields.DiamondName, dia *stores.Diamond) bool {
+		if dia.Status == stores.DiamondStatusNormal || dia.Status == stores.DiamondStatusListing {
+			err = state.DiamondOwnerAdd(dia.Address, name)
+			if err != nil {
+				return false
+			}
+		}
+		err = state.DiamondHistoryPush(name, &stores.DiamondHistoryRecord{
+			BlockHeight: height,
+			TxHash:      fields.EmptyZeroBytes32,
+			ActionKind:  fields.VarUint2(stores.DiamondHistoryKindSnapshot),
+			FromAddress: dia.Address,
+			ToAddress:   dia.Address,
+		})
+		return err == nil
+	})
+	if e != nil {
+		return e
+	}
+	return err
+}
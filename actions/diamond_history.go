@@ -0,0 +1,58 @@
+package actions
+
+import (
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+)
+
+// 追加钻石流转历史，与持有索引同一启用高度
+func doAppendDiamondHistory(state interfaces.ChainStateOperation, trs interfaces.Transaction, kind uint16, from fields.Address, to fields.Address, diamonds []fields.DiamondName) error {
+	height := state.GetPendingBlockHeight()
+	if !IsDiamondIndexActive(height) {
+		return nil // 未启用
+	}
+	txhash := trs.Hash()
+	for _, dia := range diamonds {
+		e := state.DiamondHistoryPush(dia, &stores.DiamondHistoryRecord{
+			BlockHeight: fields.BlockHeight(height),
+			TxHash:      txhash,
+			ActionKind:  fields.VarUint2(kind),
+			FromAddress: from,
+			ToAddress:   to,
+		})
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// 回退钻石流转历史：只移除尾部属于该交易和 action 类型的记录
+func doDropDiamondHistory(state interfaces.ChainStateOperation, trs interfaces.Transaction, kind uint16, diamonds []fields.DiamondName) error {
+	if !IsDiamondIndexActive(state.GetPendingBlockHeight()) {
+		return nil // 未启用
+	}
+	txhash := trs.Hash()
+	for _, dia := range diamonds {
+		_, total, e := state.DiamondHistoryList(dia, 0, 1)
+		if e != nil {
+			return e
+		}
+		if total == 0 {
+			continue // 无记录
+		}
+		last, _, e := state.DiamondHistoryList(dia, total-1, 1)
+		if e != nil {
+			return e
+		}
+		if len(last) != 1 || !last[0].IsOf(txhash, kind) {
+			continue
+		}
+		e = state.DiamondHistoryPop(dia)
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
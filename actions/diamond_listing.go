@@ -0,0 +1,565 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/*
+
+钻石挂单出售（链上托管）
+
+. 挂单：卖方锁定一批钻石，标明 HAC 要价、可选的指定买方和到期高度
+. 成交：到期之前买方支付要价，钻石原子转移给买方
+. 撤单：卖方随时可以撤单解锁钻石
+
+挂单期间钻石仍归属卖方，钻石余额不变，只标记为挂单状态，不能转账或抵押
+
+*/
+
+// 钻石挂单
+type Action_33_DiamondListingCreate struct {
+	ListingId fields.DiamondListingId // 挂单ID
+
+	SellerAddress     fields.Address              // 卖方
+	DesignatedBuyer   fields.OptionalAddress      // 指定买方，可选
+	AskPrice          fields.Amount               // 要价 HAC
+	ExpireBlockHeight fields.BlockHeight          // 到期区块高度
+	ListedDiamondList fields.DiamondListMaxLen200 // 出售的钻石
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_33_DiamondListingCreate) Kind() uint16 {
+	return 33
+}
+
+// json api
+func (elm *Action_33_DiamondListingCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	data["listing_id"] = elm.ListingId.ToHex()
+	data["seller"] = elm.SellerAddress.ToReadable()
+	data["designated_buyer"] = elm.DesignatedBuyer.ShowReadableOrEmpty()
+	data["ask_price"] = elm.AskPrice.ToFinString()
+	data["expire_height"] = uint64(elm.ExpireBlockHeight)
+	data["diamonds"] = elm.ListedDiamondList.SerializeHACDlistToCommaSplitString()
+	return data
+}
+
+func (elm *Action_33_DiamondListingCreate) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.ListingId.Serialize()
+	var b2, _ = elm.SellerAddress.Serialize()
+	var b3, _ = elm.DesignatedBuyer.Serialize()
+	var b4, _ = elm.AskPrice.Serialize()
+	var b5, _ = elm.ExpireBlockHeight.Serialize()
+	var b6, e = elm.ListedDiamondList.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_33_DiamondListingCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.ListingId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DesignatedBuyer.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.AskPrice.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ListedDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_33_DiamondListingCreate) Size() uint32 {
+	return 2 + elm.ListingId.Size() +
+		elm.SellerAddress.Size() +
+		elm.DesignatedBuyer.Size() +
+		elm.AskPrice.Size() +
+		elm.ExpireBlockHeight.Size() +
+		elm.ListedDiamondList.Size()
+}
+
+func (act *Action_33_DiamondListingCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.SellerAddress,
+	} // 卖方需要签名
+}
+
+func (act *Action_33_DiamondListingCreate) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	// 检查id格式
+	if len(act.ListingId) != stores.DiamondListingIdLength ||
+		act.ListingId[0] == 0 ||
+		act.ListingId[stores.DiamondListingIdLength-1] == 0 {
+		return errs.New(errs.CodeMalformed, "Diamond Listing Id format error.")
+	}
+
+	// 查询id是否存在
+	if state.DiamondListing(act.ListingId) != nil {
+		return errs.New(errs.CodeAlreadyExists, "Diamond Listing already exist.").WithID(act.ListingId)
+	}
+
+	// 指定买方不能是卖方自己
+	if act.DesignatedBuyer.Exist.Check() && act.DesignatedBuyer.Addr.Equal(act.SellerAddress) {
+		return errs.New(errs.CodeInvalidParameter, "Designated buyer cannot be the seller.")
+	}
+
+	// 要价必须为正
+	if !act.AskPrice.IsPositive() {
+		return errs.New(errs.CodeInvalidParameter, "Ask price must be positive.")
+	}
+
+	// 到期高度必须在未来
+	if uint64(act.ExpireBlockHeight) <= paddingHeight {
+		return errs.New(errs.CodeInvalidParameter, "ExpireBlockHeight %d must over than %d.", act.ExpireBlockHeight, paddingHeight)
+	}
+
+	// 数量检查
+	dianum := int(act.ListedDiamondList.Count)
+	if dianum == 0 || dianum != len(act.ListedDiamondList.Diamonds) {
+		return errs.New(errs.CodeMalformed, "Diamonds quantity error")
+	}
+	if dianum > 200 {
+		return errs.New(errs.CodeInvalidParameter, "Diamonds quantity cannot over 200")
+	}
+
+	// 锁定钻石
+	for i := 0; i < dianum; i++ {
+		diamond := act.ListedDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem == nil {
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Address.NotEqual(act.SellerAddress) {
+			return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to address '%s'", string(diamond), act.SellerAddress.ToReadable())
+		}
+		if diaitem.Status != stores.DiamondStatusNormal {
+			return errs.New(errs.CodeStatusConflict, "Diamond <%s> has been mortgaged or locked.", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusListing // 标记挂单
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+
+	// 保存挂单
+	lststo := &stores.DiamondListing{
+		Status:            stores.DiamondListingStatusOpen,
+		CreateBlockHeight: fields.BlockHeight(paddingHeight),
+		ExpireBlockHeight: act.ExpireBlockHeight,
+		SellerAddress:     act.SellerAddress,
+		DesignatedBuyer:   act.DesignatedBuyer,
+		AskPrice:          act.AskPrice,
+		ListedDiamondList: act.ListedDiamondList,
+	}
+	return state.DiamondListingCreate(act.ListingId, lststo)
+}
+
+func (act *Action_33_DiamondListingCreate) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	// 回退钻石状态
+	for i := 0; i < len(act.ListedDiamondList.Diamonds); i++ {
+		diamond := act.ListedDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem != nil {
+			diaitem.Status = stores.DiamondStatusNormal
+			state.DiamondSet(diamond, diaitem)
+		}
+	}
+	// 删除挂单
+	return state.DiamondListingDelete(act.ListingId)
+}
+
+// 设置所属 belong_trs
+func (act *Action_33_DiamondListingCreate) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_33_DiamondListingCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+/////////////////////////////////////////////////
+
+// 钻石挂单成交
+type Action_34_DiamondListingFill struct {
+	ListingId    fields.DiamondListingId // 挂单ID
+	BuyerAddress fields.Address          // 买方
+	PayAmount    fields.Amount           // 支付金额，必须等于要价
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_34_DiamondListingFill) Kind() uint16 {
+	return 34
+}
+
+// json api
+func (elm *Action_34_DiamondListingFill) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	data["listing_id"] = elm.ListingId.ToHex()
+	data["buyer"] = elm.BuyerAddress.ToReadable()
+	data["pay_amount"] = elm.PayAmount.ToFinString()
+	return data
+}
+
+func (elm *Action_34_DiamondListingFill) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.ListingId.Serialize()
+	var b2, _ = elm.BuyerAddress.Serialize()
+	var b3, _ = elm.PayAmount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_34_DiamondListingFill) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.ListingId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PayAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_34_DiamondListingFill) Size() uint32 {
+	return 2 + elm.ListingId.Size() +
+		elm.BuyerAddress.Size() +
+		elm.PayAmount.Size()
+}
+
+func (act *Action_34_DiamondListingFill) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.BuyerAddress,
+	} // 买方需要签名
+}
+
+func (act *Action_34_DiamondListingFill) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	lstObj := state.DiamondListing(act.ListingId)
+	if lstObj == nil {
+		return errs.New(errs.CodeNotFound, "Diamond Listing <%s> not exist.", act.ListingId.ToHex())
+	}
+	if lstObj.IsClosed() {
+		return errs.New(errs.CodeStatusConflict, "Diamond Listing <%s> has been closed.", act.ListingId.ToHex())
+	}
+	if paddingHeight >= uint64(lstObj.ExpireBlockHeight) {
+		return errs.New(errs.CodeExpired, "Diamond Listing <%s> expired at height %d.", act.ListingId.ToHex(), lstObj.ExpireBlockHeight)
+	}
+
+	// 检查买方
+	if act.BuyerAddress.Equal(lstObj.SellerAddress) {
+		return errs.New(errs.CodeInvalidParameter, "Seller cannot buy own listing.")
+	}
+	if lstObj.DesignatedBuyer.Exist.Check() && lstObj.DesignatedBuyer.Addr.NotEqual(act.BuyerAddress) {
+		return errs.New(errs.CodeNotPermitted, "Diamond Listing <%s> only can be filled by %s.", act.ListingId.ToHex(), lstObj.DesignatedBuyer.Addr.ToReadable())
+	}
+
+	// 检查支付金额
+	if !act.PayAmount.Equal(&lstObj.AskPrice) {
+		return errs.New(errs.CodeInvalidParameter, "Pay amount must be %s but got %s.", lstObj.AskPrice.ToFinString(), act.PayAmount.ToFinString())
+	}
+
+	// 支付 HAC
+	e2 := DoSimpleTransferFromChainState(state, act.BuyerAddress, lstObj.SellerAddress, act.PayAmount)
+	if e2 != nil {
+		return e2
+	}
+
+	// 转移钻石
+	dianum := len(lstObj.ListedDiamondList.Diamonds)
+	for i := 0; i < dianum; i++ {
+		diamond := lstObj.ListedDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem == nil {
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Address.NotEqual(lstObj.SellerAddress) {
+			return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to address '%s'", string(diamond), lstObj.SellerAddress.ToReadable())
+		}
+		if diaitem.Status != stores.DiamondStatusListing {
+			return errs.New(errs.CodeStatusConflict, "Diamond <%s> status is not [stores.DiamondStatusListing].", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusNormal
+		diaitem.Address = act.BuyerAddress
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	e6 := DoSimpleDiamondTransferFromChainState(state, lstObj.SellerAddress, act.BuyerAddress, lstObj.ListedDiamondList.Diamonds)
+	if e6 != nil {
+		return e6
+	}
+	// 流转历史
+	e7 := doAppendDiamondHistory(state, act.belong_trs, act.Kind(), lstObj.SellerAddress, act.BuyerAddress, lstObj.ListedDiamondList.Diamonds)
+	if e7 != nil {
+		return e7
+	}
+
+	// 更新挂单状态
+	lstObj.SetSoldStatus(paddingHeight, act.BuyerAddress)
+	return state.DiamondListingUpdate(act.ListingId, lstObj)
+}
+
+func (act *Action_34_DiamondListingFill) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	lstObj := state.DiamondListing(act.ListingId)
+	if lstObj == nil {
+		return errs.New(errs.CodeNotFound, "Diamond Listing <%s> not exist.", act.ListingId.ToHex())
+	}
+
+	// 回退钻石
+	dianum := len(lstObj.ListedDiamondList.Diamonds)
+	for i := 0; i < dianum; i++ {
+		diamond := lstObj.ListedDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem != nil {
+			diaitem.Status = stores.DiamondStatusListing
+			diaitem.Address = lstObj.SellerAddress
+			state.DiamondSet(diamond, diaitem)
+		}
+	}
+	DoSimpleDiamondTransferFromChainState(state, act.BuyerAddress, lstObj.SellerAddress, lstObj.ListedDiamondList.Diamonds)
+	doDropDiamondHistory(state, act.belong_trs, act.Kind(), lstObj.ListedDiamondList.Diamonds)
+	// 回退 HAC
+	DoSimpleTransferFromChainState(state, lstObj.SellerAddress, act.BuyerAddress, act.PayAmount)
+
+	// 回退挂单状态
+	lstObj.DropClosedStatus()
+	return state.DiamondListingUpdate(act.ListingId, lstObj)
+}
+
+// 设置所属 belong_trs
+func (act *Action_34_DiamondListingFill) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_34_DiamondListingFill) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+/////////////////////////////////////////////////
+
+// 钻石挂单撤单
+type Action_35_DiamondListingCancel struct {
+	ListingId     fields.DiamondListingId // 挂单ID
+	SellerAddress fields.Address          // 卖方
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_35_DiamondListingCancel) Kind() uint16 {
+	return 35
+}
+
+// json api
+func (elm *Action_35_DiamondListingCancel) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	data["listing_id"] = elm.ListingId.ToHex()
+	data["seller"] = elm.SellerAddress.ToReadable()
+	return data
+}
+
+func (elm *Action_35_DiamondListingCancel) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.ListingId.Serialize()
+	var b2, _ = elm.SellerAddress.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_35_DiamondListingCancel) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.ListingId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_35_DiamondListingCancel) Size() uint32 {
+	return 2 + elm.ListingId.Size() + elm.SellerAddress.Size()
+}
+
+func (act *Action_35_DiamondListingCancel) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.SellerAddress,
+	} // 卖方需要签名
+}
+
+func (act *Action_35_DiamondListingCancel) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	lstObj := state.DiamondListing(act.ListingId)
+	if lstObj == nil {
+		return errs.New(errs.CodeNotFound, "Diamond Listing <%s> not exist.", act.ListingId.ToHex())
+	}
+	if lstObj.IsClosed() {
+		return errs.New(errs.CodeStatusConflict, "Diamond Listing <%s> has been closed.", act.ListingId.ToHex())
+	}
+	if act.SellerAddress.NotEqual(lstObj.SellerAddress) {
+		return errs.New(errs.CodeNotPermitted, "Diamond Listing <%s> only can be cancelled by %s.", act.ListingId.ToHex(), lstObj.SellerAddress.ToReadable())
+	}
+
+	// 解锁钻石
+	for i := 0; i < len(lstObj.ListedDiamondList.Diamonds); i++ {
+		diamond := lstObj.ListedDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem == nil {
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Status != stores.DiamondStatusListing {
+			return errs.New(errs.CodeStatusConflict, "Diamond <%s> status is not [stores.DiamondStatusListing].", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusNormal
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+
+	// 更新挂单状态
+	lstObj.SetCancelledStatus(paddingHeight)
+	return state.DiamondListingUpdate(act.ListingId, lstObj)
+}
+
+func (act *Action_35_DiamondListingCancel) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	lstObj := state.DiamondListing(act.ListingId)
+	if lstObj == nil {
+		return errs.New(errs.CodeNotFound, "Diamond Listing <%s> not exist.", act.ListingId.ToHex())
+	}
+	for i := 0; i < len(lstObj.ListedDiamondList.Diamonds); i++ {
+		diamond := lstObj.ListedDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem != nil {
+			diaitem.Status = stores.DiamondStatusListing
+			state.DiamondSet(diamond, diaitem)
+		}
+	}
+	lstObj.DropClosedStatus()
+	return state.DiamondListingUpdate(act.ListingId, lstObj)
+}
+
+// 设置所属 belong_trs
+func (act *Action_35_DiamondListingCancel) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_35_DiamondListingCancel) IsBurning90PersentTxFees() bool {
+	return false
+}
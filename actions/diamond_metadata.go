@@ -0,0 +1,262 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+	"unicode/utf8"
+)
+
+/**
+ * 钻石附加元数据：持有人为钻石写入 URI、内容哈希或短文本
+ * 版本号必须为当前版本加一，避免重放和并发覆盖
+ * 元数据长期保存在状态中，交易手续费含量（FeePurity 按每 8 字节计）不得低于 DiamondMetadataMinFeePurity
+ */
+
+const (
+	DiamondMetadataMaxLength    = 255           // 内容最大字节数
+	DiamondMetadataMinFeePurity = 1000000000000 // 最低手续费含量：每 8 字节 0.0001 枚（含量单位为 unit 232）
+)
+
+// 钻石附加元数据
+type Action_44_DiamondMetadataSet struct {
+	Diamond      fields.DiamondName
+	OwnerAddress fields.Address  // 持有人，需签名
+	Version      fields.VarUint4 // 新版本号
+	DataType     fields.VarUint1
+	Data         fields.ExtendMessageMaxLen255
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func NewAction_44_DiamondMetadataSet(diamond fields.DiamondName, owner fields.Address, version uint32, datatype fields.VarUint1, data []byte) (*Action_44_DiamondMetadataSet, error) {
+	if len(data) > DiamondMetadataMaxLength {
+		return nil, errs.New(errs.CodeInvalidParameter, "Metadata length cannot over %d.", DiamondMetadataMaxLength)
+	}
+	return &Action_44_DiamondMetadataSet{
+		Diamond:      diamond,
+		OwnerAddress: owner,
+		Version:      fields.VarUint4(version),
+		DataType:     datatype,
+		Data: fields.ExtendMessageMaxLen255{
+			Count:   fields.VarUint1(len(data)),
+			Message: data,
+		},
+	}, nil
+}
+
+func (elm *Action_44_DiamondMetadataSet) Kind() uint16 {
+	return 44
+}
+
+// json api
+func (elm *Action_44_DiamondMetadataSet) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	data["diamond"] = string(elm.Diamond)
+	data["owner"] = elm.OwnerAddress.ToReadable()
+	data["version"] = uint32(elm.Version)
+	data["data_type"] = uint8(elm.DataType)
+	if elm.DataType == stores.DiamondMetadataTypeContentHash {
+		data["data"] = hex.EncodeToString(elm.Data.Message)
+	} else {
+		data["data"] = string(elm.Data.Message)
+	}
+	return data
+}
+
+func (elm *Action_44_DiamondMetadataSet) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	if int(elm.Data.Count) != len(elm.Data.Message) {
+		return nil, errs.New(errs.CodeMalformed, "Metadata length count error")
+	}
+	var b1, _ = elm.Diamond.Serialize()
+	var b2, _ = elm.OwnerAddress.Serialize()
+	var b3, _ = elm.Version.Serialize()
+	var b4, _ = elm.DataType.Serialize()
+	var b5, _ = elm.Data.Serialize()
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_44_DiamondMetadataSet) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.Diamond.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.OwnerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Version.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DataType.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Data.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_44_DiamondMetadataSet) Size() uint32 {
+	return 2 +
+		elm.Diamond.Size() +
+		elm.OwnerAddress.Size() +
+		elm.Version.Size() +
+		elm.DataType.Size() +
+		elm.Data.Size()
+}
+
+func (elm *Action_44_DiamondMetadataSet) RequestSignAddresses() []fields.Address {
+	return []fields.Address{elm.OwnerAddress} // 需持有人签名
+}
+
+// 检查内容格式
+func (elm *Action_44_DiamondMetadataSet) checkData() error {
+	datalen := len(elm.Data.Message)
+	if int(elm.Data.Count) != datalen {
+		return errs.New(errs.CodeMalformed, "Metadata length count error.")
+	}
+	switch elm.DataType {
+	case stores.DiamondMetadataTypeClear:
+		if datalen != 0 {
+			return errs.New(errs.CodeInvalidParameter, "Clear metadata must be empty.")
+		}
+	case stores.DiamondMetadataTypeURI, stores.DiamondMetadataTypeText:
+		if datalen == 0 {
+			return errs.New(errs.CodeInvalidParameter, "Metadata cannot be empty.")
+		}
+		if !utf8.Valid(elm.Data.Message) {
+			return errs.New(errs.CodeInvalidParameter, "Metadata must be valid utf8.")
+		}
+	case stores.DiamondMetadataTypeContentHash:
+		if datalen != 32 {
+			return errs.New(errs.CodeInvalidParameter, "Content hash size must be 32 but got %d.", datalen)
+		}
+	default:
+		return errs.New(errs.CodeUnsupportedKind, "Metadata type <%d> not support.", elm.DataType)
+	}
+	return nil
+}
+
+func (act *Action_44_DiamondMetadataSet) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	e1 := act.checkData()
+	if e1 != nil {
+		return e1
+	}
+
+	// 按交易大小计费
+	if act.belong_trs.FeePurity() < DiamondMetadataMinFeePurity {
+		return errs.New(errs.CodeInvalidParameter, "Transaction fee purity %d is too low, need at least %d.", act.belong_trs.FeePurity(), DiamondMetadataMinFeePurity)
+	}
+
+	// 检查钻石
+	diaitem := state.Diamond(act.Diamond)
+	if diaitem == nil {
+		return errs.New(errs.CodeNotFound, "Diamond <%s> not exist.", string(act.Diamond))
+	}
+	if diaitem.Address.NotEqual(act.OwnerAddress) {
+		return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to address '%s'", string(act.Diamond), act.OwnerAddress.ToReadable())
+	}
+	// 抵押、挂单等状态下不能修改
+	if diaitem.Status != stores.DiamondStatusNormal {
+		return errs.New(errs.CodeStatusConflict, "Diamond <%s> status is not normal.", string(act.Diamond))
+	}
+
+	// 检查版本号
+	meta := state.DiamondMetadata(act.Diamond)
+	if meta == nil {
+		meta = stores.NewDiamondMetadata()
+	}
+	curver := meta.CurrentVersion()
+	if uint32(act.Version) != curver+1 {
+		return errs.New(errs.CodeStatusConflict, "Diamond <%s> metadata version must be %d but got %d.", string(act.Diamond), curver+1, act.Version)
+	}
+	// 写满时移出最早的版本
+	if oldest := meta.EvictOldest(); oldest != nil {
+		e2 := state.DiamondMetadataArchiveSet(act.Diamond, oldest)
+		if e2 != nil {
+			return e2
+		}
+	}
+
+	// 保存
+	meta.Append(&stores.DiamondMetadataRecord{
+		Version:     act.Version,
+		BlockHeight: fields.BlockHeight(state.GetPendingBlockHeight()),
+		Owner:       act.OwnerAddress,
+		DataType:    act.DataType,
+		Data:        act.Data,
+	})
+	return state.DiamondMetadataSet(act.Diamond, meta)
+}
+
+func (act *Action_44_DiamondMetadataSet) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	// 回退版本
+	meta := state.DiamondMetadata(act.Diamond)
+	if meta == nil || !meta.DropLast(uint32(act.Version)) {
+		return errs.New(errs.CodeNotFound, "Diamond <%s> metadata version %d not find.", string(act.Diamond), act.Version)
+	}
+	// 放回写入时移出的旧版本
+	if oldver := meta.EvictedVersion(); oldver > 0 {
+		oldest := state.DiamondMetadataArchive(act.Diamond, oldver)
+		if oldest == nil {
+			return errs.New(errs.CodeNotFound, "Diamond <%s> metadata archive version %d not find.", string(act.Diamond), oldver)
+		}
+		meta.RestoreOldest(oldest)
+		e1 := state.DiamondMetadataArchiveDel(act.Diamond, oldver)
+		if e1 != nil {
+			return e1
+		}
+	}
+	if meta.Count == 0 {
+		return state.DiamondMetadataDel(act.Diamond)
+	}
+	return state.DiamondMetadataSet(act.Diamond, meta)
+}
+
+// 设置所属 belong_trs
+func (act *Action_44_DiamondMetadataSet) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_44_DiamondMetadataSet) IsBurning90PersentTxFees() bool {
+	return false
+}
@@ -0,0 +1,303 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/**
+ * 钻石多地址转账：一个持有地址把钻石分别转给多个地址
+ * 按收款地址分组编码，每组最多 200 枚，同一地址超过 200 枚时可分为多组，适合空投
+ */
+
+const (
+	DiamondMultiTransferMaxDiamonds = 1000 // 单个 action 最多转移钻石数量
+)
+
+// 转给同一地址的一组钻石
+type DiamondTransferGroup struct {
+	ToAddress   fields.Address
+	DiamondList fields.DiamondListMaxLen200
+}
+
+func (elm DiamondTransferGroup) Size() uint32 {
+	return elm.ToAddress.Size() + elm.DiamondList.Size()
+}
+
+func (elm DiamondTransferGroup) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.ToAddress.Serialize()
+	var b2, e = elm.DiamondList.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *DiamondTransferGroup) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.ToAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+// 钻石多地址转账
+type Action_43_DiamondMultiRecipientTransfer struct {
+	FromAddress fields.Address // 拥有钻石的账户
+	GroupCount  fields.VarUint2
+	Groups      []DiamondTransferGroup
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func NewAction_43_DiamondMultiRecipientTransfer(from fields.Address) *Action_43_DiamondMultiRecipientTransfer {
+	return &Action_43_DiamondMultiRecipientTransfer{
+		FromAddress: from,
+		GroupCount:  0,
+		Groups:      []DiamondTransferGroup{},
+	}
+}
+
+// 添加一枚钻石，同一收款地址自动归为一组
+func (elm *Action_43_DiamondMultiRecipientTransfer) AppendDiamond(diamond fields.DiamondName, to fields.Address) error {
+	if elm.DiamondCount() >= DiamondMultiTransferMaxDiamonds {
+		return errs.New(errs.CodeInvalidParameter, "Diamonds quantity cannot over %d", DiamondMultiTransferMaxDiamonds)
+	}
+	for i := range elm.Groups {
+		g := &elm.Groups[i]
+		if g.ToAddress.Equal(to) && len(g.DiamondList.Diamonds) < 200 {
+			g.DiamondList.Diamonds = append(g.DiamondList.Diamonds, diamond)
+			g.DiamondList.Count = fields.VarUint1(len(g.DiamondList.Diamonds))
+			return nil
+		}
+	}
+	elm.Groups = append(elm.Groups, DiamondTransferGroup{
+		ToAddress: to,
+		DiamondList: fields.DiamondListMaxLen200{
+			Count:    1,
+			Diamonds: []fields.DiamondName{diamond},
+		},
+	})
+	elm.GroupCount = fields.VarUint2(len(elm.Groups))
+	return nil
+}
+
+// 钻石总数
+func (elm *Action_43_DiamondMultiRecipientTransfer) DiamondCount() int {
+	num := 0
+	for _, g := range elm.Groups {
+		num += len(g.DiamondList.Diamonds)
+	}
+	return num
+}
+
+func (elm *Action_43_DiamondMultiRecipientTransfer) Kind() uint16 {
+	return 43
+}
+
+// json api
+func (elm *Action_43_DiamondMultiRecipientTransfer) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	var list = make([]map[string]interface{}, len(elm.Groups))
+	for i, v := range elm.Groups {
+		list[i] = map[string]interface{}{
+			"to":       v.ToAddress.ToReadable(),
+			"diamonds": v.DiamondList.SerializeHACDlistToCommaSplitString(),
+		}
+	}
+	data["from"] = elm.FromAddress.ToReadable()
+	data["groups"] = list
+	return data
+}
+
+func (elm *Action_43_DiamondMultiRecipientTransfer) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	if int(elm.GroupCount) != len(elm.Groups) {
+		return nil, errs.New(errs.CodeMalformed, "Groups quantity count error")
+	}
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.FromAddress.Serialize()
+	var b2, _ = elm.GroupCount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	for _, v := range elm.Groups {
+		var bi, e = v.Serialize()
+		if e != nil {
+			return nil, e
+		}
+		buffer.Write(bi)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_43_DiamondMultiRecipientTransfer) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.FromAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.GroupCount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if int(elm.GroupCount) > DiamondMultiTransferMaxDiamonds {
+		return 0, errs.New(errs.CodeInvalidParameter, "Groups quantity cannot over %d", DiamondMultiTransferMaxDiamonds)
+	}
+	elm.Groups = make([]DiamondTransferGroup, int(elm.GroupCount))
+	for i := 0; i < int(elm.GroupCount); i++ {
+		seek, e = elm.Groups[i].Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+func (elm *Action_43_DiamondMultiRecipientTransfer) Size() uint32 {
+	size := 2 + elm.FromAddress.Size() + elm.GroupCount.Size()
+	for _, v := range elm.Groups {
+		size += v.Size()
+	}
+	return size
+}
+
+func (elm *Action_43_DiamondMultiRecipientTransfer) RequestSignAddresses() []fields.Address {
+	return []fields.Address{elm.FromAddress} // 需from签名
+}
+
+func (act *Action_43_DiamondMultiRecipientTransfer) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	// 数量检查
+	grpnum := int(act.GroupCount)
+	if grpnum == 0 || grpnum != len(act.Groups) {
+		return errs.New(errs.CodeMalformed, "Groups quantity error")
+	}
+	dianum := act.DiamondCount()
+	if dianum > DiamondMultiTransferMaxDiamonds {
+		return errs.New(errs.CodeInvalidParameter, "Diamonds quantity cannot over %d", DiamondMultiTransferMaxDiamonds)
+	}
+
+	// 先检查全部分组和钻石，任何一枚不满足则整体失败
+	diamonds := make(map[string]bool, dianum)
+	for i, g := range act.Groups {
+		num := int(g.DiamondList.Count)
+		if num == 0 || num != len(g.DiamondList.Diamonds) {
+			return errs.New(errs.CodeMalformed, "Group %d diamonds quantity error", i)
+		}
+		// 自己不能转给自己
+		if g.ToAddress.Equal(act.FromAddress) {
+			return errs.New(errs.CodeInvalidParameter, "Group %d cannot transfer to self.", i)
+		}
+		for _, diamond := range g.DiamondList.Diamonds {
+			if diamonds[string(diamond)] {
+				return errs.New(errs.CodeInvalidParameter, "Diamond <%s> repeated.", string(diamond))
+			}
+			diamonds[string(diamond)] = true
+			diaitem := state.Diamond(diamond)
+			if diaitem == nil {
+				return errs.New(errs.CodeNotFound, "Diamond <%s> not exist.", string(diamond))
+			}
+			// 检查是否抵押，是否可以转账
+			if diaitem.Status != stores.DiamondStatusNormal {
+				return errs.New(errs.CodeStatusConflict, "Diamond <%s> has been mortgaged and cannot be transferred.", string(diamond))
+			}
+			// 检查所属
+			if diaitem.Address.NotEqual(act.FromAddress) {
+				return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to address '%s'", string(diamond), act.FromAddress.ToReadable())
+			}
+		}
+	}
+
+	// 依次转移
+	for _, g := range act.Groups {
+		for _, diamond := range g.DiamondList.Diamonds {
+			diaitem := state.Diamond(diamond)
+			diaitem.Address = g.ToAddress
+			e5 := state.DiamondSet(diamond, diaitem)
+			if e5 != nil {
+				return e5
+			}
+		}
+		// 转移钻石余额
+		e9 := DoSimpleDiamondTransferFromChainState(state, act.FromAddress, g.ToAddress, g.DiamondList.Diamonds)
+		if e9 != nil {
+			return e9
+		}
+		// 流转历史
+		e10 := doAppendDiamondHistory(state, act.belong_trs, act.Kind(), act.FromAddress, g.ToAddress, g.DiamondList.Diamonds)
+		if e10 != nil {
+			return e10
+		}
+	}
+	return nil
+}
+
+func (act *Action_43_DiamondMultiRecipientTransfer) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	// 倒序回退
+	for i := len(act.Groups) - 1; i >= 0; i-- {
+		g := act.Groups[i]
+		for _, diamond := range g.DiamondList.Diamonds {
+			diaitem := state.Diamond(diamond)
+			if diaitem == nil {
+				return errs.New(errs.CodeNotFound, "Diamond <%s> not exist.", string(diamond))
+			}
+			diaitem.Address = act.FromAddress
+			e5 := state.DiamondSet(diamond, diaitem)
+			if e5 != nil {
+				return e5
+			}
+		}
+		e9 := DoSimpleDiamondTransferFromChainState(state, g.ToAddress, act.FromAddress, g.DiamondList.Diamonds)
+		if e9 != nil {
+			return e9
+		}
+		e10 := doDropDiamondHistory(state, act.belong_trs, act.Kind(), g.DiamondList.Diamonds)
+		if e10 != nil {
+			return e10
+		}
+	}
+	return nil
+}
+
+// 设置所属 belong_trs
+func (act *Action_43_DiamondMultiRecipientTransfer) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_43_DiamondMultiRecipientTransfer) IsBurning90PersentTxFees() bool {
+	return false
+}
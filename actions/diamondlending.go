@@ -4,17 +4,18 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/lending"
 	"github.com/hacash/core/stores"
 	"github.com/hacash/core/sys"
 )
 
 const (
 	// 借贷周期区块数量
-	DiamondsSystemLendingBorrowPeriodBlockNumber uint64 = 10000
+	DiamondsSystemLendingBorrowPeriodBlockNumber uint64 = lending.DiamondSystemLendingBorrowPeriodBlockNumber
 )
 
 /*
@@ -124,7 +125,7 @@ func (*Action_15_DiamondsSystemLendingCreate) RequestSignAddresses() []fields.Ad
 func (act *Action_15_DiamondsSystemLendingCreate) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -137,27 +138,27 @@ func (act *Action_15_DiamondsSystemLendingCreate) WriteinChainState(state interf
 	if len(act.LendingID) != stores.DiamondSyslendIdLength ||
 		act.LendingID[0] == 0 ||
 		act.LendingID[stores.DiamondSyslendIdLength-1] == 0 {
-		return fmt.Errorf("Diamond Lending Id format error.")
+		return errs.New(errs.CodeMalformed, "Diamond Lending Id format error.")
 	}
 
 	// 查询id是否存在
 	dmdlendObj := state.DiamondSystemLending(act.LendingID)
 	if dmdlendObj != nil {
-		return fmt.Errorf("Diamond Lending <%s> already exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeAlreadyExists, "Diamond Lending already exist.").WithID(act.LendingID)
 	}
 
 	// 数量检查
 	dianum := int(act.MortgageDiamondList.Count)
 	if dianum == 0 || dianum != len(act.MortgageDiamondList.Diamonds) {
-		return fmt.Errorf("Diamonds quantity error")
+		return errs.New(errs.CodeMalformed, "Diamonds quantity error")
 	}
 	if dianum > 200 {
-		return fmt.Errorf("Diamonds quantity cannot over 200")
+		return errs.New(errs.CodeInvalidParameter, "Diamonds quantity cannot over 200")
 	}
 
 	// 检查周期数
 	if act.BorrowPeriod < 1 || act.BorrowPeriod > 20 {
-		return fmt.Errorf("BorrowPeriod must between 1 ~ 20")
+		return errs.New(errs.CodeInvalidParameter, "BorrowPeriod must between 1 ~ 20")
 	}
 
 	// 可借出HAC
@@ -170,15 +171,15 @@ func (act *Action_15_DiamondsSystemLendingCreate) WriteinChainState(state interf
 		// 查询钻石是否存在
 		diaitem := state.Diamond(diamond)
 		if diaitem == nil {
-			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not find.", string(diamond))
 		}
 		// 检查所属地址
 		if diaitem.Address.NotEqual(feeAddr) {
-			return fmt.Errorf("Diamond <%s> not belong to address '%s'", string(diamond), feeAddr.ToReadable())
+			return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to address '%s'", string(diamond), feeAddr.ToReadable())
 		}
 		// 检查钻石状态，是否可以抵押
 		if diaitem.Status != stores.DiamondStatusNormal {
-			return fmt.Errorf("Diamond <%s> has been mortgaged and cannot be transferred.", string(diamond))
+			return errs.New(errs.CodeStatusConflict, "Diamond <%s> has been mortgaged and cannot be transferred.", string(diamond))
 		}
 		// 标记抵押钻石
 		diaitem.Status = stores.DiamondStatusLendingSystem // 抵押给系统
@@ -191,7 +192,7 @@ func (act *Action_15_DiamondsSystemLendingCreate) WriteinChainState(state interf
 			return e5
 		}
 		if diasmelt == nil {
-			return fmt.Errorf("Diamond <%s> not exist.", string(diamond))
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not exist.", string(diamond))
 		}
 		// 统计可借出HAC数量
 		totalLoanHAC += int64(diasmelt.AverageBidBurnPrice)
@@ -201,11 +202,16 @@ func (act *Action_15_DiamondsSystemLendingCreate) WriteinChainState(state interf
 	totalAmt := fields.NewAmountByUnit248(totalLoanHAC)
 	// 验证数量
 	if totalAmt.NotEqual(&act.LoanTotalAmount) {
-		return fmt.Errorf("LoanTotalAmountMei must %s but got %s", totalAmt.ToFinString(), act.LoanTotalAmount.ToFinString())
+		return errs.New(errs.CodeInvalidParameter, "LoanTotalAmountMei must %s but got %s", totalAmt.ToFinString(), act.LoanTotalAmount.ToFinString())
 	}
 
 	// 减少钻石余额
-	e9 := DoSubDiamondFromChainState(state, feeAddr, fields.DiamondNumber(dianum))
+	e9 := DoSubDiamondFromChainState(state, feeAddr, act.MortgageDiamondList.Diamonds)
+	if e9 != nil {
+		return e9
+	}
+	// 流转历史：抵押给系统
+	e9 = doAppendDiamondHistory(state, act.belong_trs, act.Kind(), feeAddr, stores.DiamondHistorySystemLendingAddress, act.MortgageDiamondList.Diamonds)
 	if e9 != nil {
 		return e9
 	}
@@ -237,14 +243,14 @@ func (act *Action_15_DiamondsSystemLendingCreate) WriteinChainState(state interf
 		return e20
 	}
 	// 增加实时钻石系统抵押数量统计
-	totalsupply.DoAdd(
+	totalsupply.DoAddCount(
 		stores.TotalSupplyStoreTypeOfSystemLendingDiamondCurrentMortgageCount,
-		float64(dianum),
+		uint64(dianum),
 	)
 	// 钻石系统抵押数量统计 累计借出流水
-	totalsupply.DoAdd(
+	totalsupply.DoAddAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingDiamondCumulationLoanHacAmount,
-		act.LoanTotalAmount.ToMei(),
+		&act.LoanTotalAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -259,7 +265,7 @@ func (act *Action_15_DiamondsSystemLendingCreate) WriteinChainState(state interf
 func (act *Action_15_DiamondsSystemLendingCreate) RecoverChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -284,7 +290,12 @@ func (act *Action_15_DiamondsSystemLendingCreate) RecoverChainState(state interf
 
 	// 回退钻石余额
 	dianum := act.MortgageDiamondList.Count
-	e9 := DoAddDiamondFromChainState(state, feeAddr, fields.DiamondNumber(dianum))
+	e9 := DoAddDiamondFromChainState(state, feeAddr, act.MortgageDiamondList.Diamonds)
+	if e9 != nil {
+		return e9
+	}
+	// 回退流转历史
+	e9 = doDropDiamondHistory(state, act.belong_trs, act.Kind(), act.MortgageDiamondList.Diamonds)
 	if e9 != nil {
 		return e9
 	}
@@ -301,14 +312,14 @@ func (act *Action_15_DiamondsSystemLendingCreate) RecoverChainState(state interf
 		return e20
 	}
 	// 减少实时钻石系统抵押数量统计 回退
-	totalsupply.DoSub(
+	totalsupply.DoSubCount(
 		stores.TotalSupplyStoreTypeOfSystemLendingDiamondCurrentMortgageCount,
-		float64(dianum),
+		uint64(dianum),
 	)
 	// 钻石系统抵押数量统计 累计借出流水 回退
-	totalsupply.DoSub(
+	totalsupply.DoSubAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingDiamondCumulationLoanHacAmount,
-		act.LoanTotalAmount.ToMei(),
+		&act.LoanTotalAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -413,16 +424,11 @@ func (act *Action_16_DiamondsSystemLendingRansom) WriteinChainState(state interf
 	}
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
-	// 借贷周期 10000区块约 35天
-	dslbpbn := DiamondsSystemLendingBorrowPeriodBlockNumber
-
-	// 测试使用
-	if sys.TestDebugLocalDevelopmentMark {
-		dslbpbn = 10 // 测试时使用 50 个区块为一个周期
-	}
+	// 借贷周期 10000区块约 35天，测试时 10 个区块
+	dslbpbn := lending.DiamondSystemLendingPeriodBlockNumber()
 
 	paddingHeight := state.GetPendingBlockHeight()
 	feeAddr := act.belong_trs.GetAddress()
@@ -431,19 +437,19 @@ func (act *Action_16_DiamondsSystemLendingRansom) WriteinChainState(state interf
 	if len(act.LendingID) != stores.DiamondSyslendIdLength ||
 		act.LendingID[0] == 0 ||
 		act.LendingID[stores.DiamondSyslendIdLength-1] == 0 {
-		return fmt.Errorf("Diamond Lending Id format error.")
+		return errs.New(errs.CodeMalformed, "Diamond Lending Id format error.")
 	}
 
 	// 查询id是否存在
 	dmdlendObj := state.DiamondSystemLending(act.LendingID)
 	if dmdlendObj == nil {
-		return fmt.Errorf("Diamond Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeNotFound, "Diamond Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 检查是否赎回状态
 	if dmdlendObj.IsRansomed.Check() {
 		// 已经赎回。不可再次赎回
-		return fmt.Errorf("Diamond Lending <%s> has been redeemed.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeStatusConflict, "Diamond Lending <%s> has been redeemed.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 计算赎回期限和所需赎回金额（判断是否可以公共赎回）
@@ -458,7 +464,7 @@ func (act *Action_16_DiamondsSystemLendingRansom) WriteinChainState(state interf
 
 	// 检查赎回金额是否有效（赎回金额真的大于实时计算的可赎回金额）
 	if act.RansomAmount.LessThan(validRansomAmt) {
-		return fmt.Errorf("Valid ransom amount must not less than %s but got %s", validRansomAmt.ToFinString(), act.RansomAmount.ToFinString())
+		return errs.New(errs.CodeInvalidParameter, "Valid ransom amount must not less than %s but got %s", validRansomAmt.ToFinString(), act.RansomAmount.ToFinString())
 	}
 
 	// 赎回操作，扣除HAC余额（以便首先检查余额是否充足）
@@ -476,11 +482,11 @@ func (act *Action_16_DiamondsSystemLendingRansom) WriteinChainState(state interf
 		// 查询钻石是否存在
 		diaitem := state.Diamond(diamond)
 		if diaitem == nil {
-			return fmt.Errorf("diamond <%s> not find.", string(diamond))
+			return errs.New(errs.CodeNotFound, "diamond <%s> not find.", string(diamond))
 		}
 		// 检查钻石状态
 		if diaitem.Status != stores.DiamondStatusLendingSystem {
-			return fmt.Errorf("diamond <%s> status is not [stores.DiamondStatusLendingSystem].", string(diamond))
+			return errs.New(errs.CodeStatusConflict, "diamond <%s> status is not [stores.DiamondStatusLendingSystem].", string(diamond))
 		}
 		// 标记赎回钻石
 		diaitem.Status = stores.DiamondStatusNormal // 赎回钻石状态
@@ -492,7 +498,12 @@ func (act *Action_16_DiamondsSystemLendingRansom) WriteinChainState(state interf
 	}
 
 	// 增加钻石余额
-	e9 := DoAddDiamondFromChainState(state, feeAddr, fields.DiamondNumber(dianum))
+	e9 := DoAddDiamondFromChainState(state, feeAddr, dmdlendObj.MortgageDiamondList.Diamonds)
+	if e9 != nil {
+		return e9
+	}
+	// 流转历史：从系统赎回
+	e9 = doAppendDiamondHistory(state, act.belong_trs, act.Kind(), stores.DiamondHistorySystemLendingAddress, feeAddr, dmdlendObj.MortgageDiamondList.Diamonds)
 	if e9 != nil {
 		return e9
 	}
@@ -513,14 +524,14 @@ func (act *Action_16_DiamondsSystemLendingRansom) WriteinChainState(state interf
 		return e20
 	}
 	// 减少实时钻石系统抵押数量统计，实时减扣
-	totalsupply.DoSub(
+	totalsupply.DoSubCount(
 		stores.TotalSupplyStoreTypeOfSystemLendingDiamondCurrentMortgageCount,
-		float64(dianum),
+		uint64(dianum),
 	)
 	// 钻石系统抵押数量统计 累计赎回流水
-	totalsupply.DoAdd(
+	totalsupply.DoAddAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingDiamondCumulationRansomHacAmount,
-		act.RansomAmount.ToMei(),
+		&act.RansomAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -535,7 +546,7 @@ func (act *Action_16_DiamondsSystemLendingRansom) WriteinChainState(state interf
 func (act *Action_16_DiamondsSystemLendingRansom) RecoverChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -547,7 +558,7 @@ func (act *Action_16_DiamondsSystemLendingRansom) RecoverChainState(state interf
 	// 回退所有赎回
 	dmdlendObj := state.DiamondSystemLending(act.LendingID)
 	if dmdlendObj == nil {
-		return fmt.Errorf("Diamond Lending <%d> not exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeNotFound, "Diamond Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 回退赎回状态
@@ -572,7 +583,12 @@ func (act *Action_16_DiamondsSystemLendingRansom) RecoverChainState(state interf
 
 	// 回退钻石余额
 	dianum := dmdlendObj.MortgageDiamondList.Count
-	e9 := DoSubDiamondFromChainState(state, feeAddr, fields.DiamondNumber(dianum))
+	e9 := DoSubDiamondFromChainState(state, feeAddr, dmdlendObj.MortgageDiamondList.Diamonds)
+	if e9 != nil {
+		return e9
+	}
+	// 回退流转历史
+	e9 = doDropDiamondHistory(state, act.belong_trs, act.Kind(), dmdlendObj.MortgageDiamondList.Diamonds)
 	if e9 != nil {
 		return e9
 	}
@@ -589,14 +605,14 @@ func (act *Action_16_DiamondsSystemLendingRansom) RecoverChainState(state interf
 		return e20
 	}
 	// 增加实时钻石系统抵押数量统计，增加，恢复
-	totalsupply.DoAdd(
+	totalsupply.DoAddCount(
 		stores.TotalSupplyStoreTypeOfSystemLendingDiamondCurrentMortgageCount,
-		float64(dianum),
+		uint64(dianum),
 	)
 	// 钻石系统抵押数量统计 累计赎回流水， 减少， 回退
-	totalsupply.DoSub(
+	totalsupply.DoSubAmount(
 		stores.TotalSupplyStoreTypeOfSystemLendingDiamondCumulationRansomHacAmount,
-		act.RansomAmount.ToMei(),
+		&act.RansomAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -0,0 +1,527 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/*
+
+三方担保交易（买方 / 卖方 / 仲裁人）
+
+. 创建：买方锁定 HAC，指定卖方、仲裁人和可选的超时高度
+. 放款：三方中任意两方签名，资金转给卖方
+. 退款：三方中任意两方签名，资金退回买方；
+        设置了超时并且已经超时，则任何人无需签名即可触发退款
+
+*/
+
+// 创建担保交易
+type Action_36_EscrowCreate struct {
+	EscrowId fields.EscrowId // 担保ID
+
+	BuyerAddress   fields.Address // 买方，付款
+	SellerAddress  fields.Address // 卖方
+	ArbiterAddress fields.Address // 仲裁人
+
+	LockAmount         fields.Amount      // 锁定的 HAC
+	TimeoutBlockHeight fields.BlockHeight // 超时自动退款高度，为 0 表示不设超时
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_36_EscrowCreate) Kind() uint16 {
+	return 36
+}
+
+// json api
+func (elm *Action_36_EscrowCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"escrow_id":            elm.EscrowId.ToHex(),
+		"buyer_address":        elm.BuyerAddress.ToReadable(),
+		"seller_address":       elm.SellerAddress.ToReadable(),
+		"arbiter_address":      elm.ArbiterAddress.ToReadable(),
+		"lock_amount":          elm.LockAmount.ToFinString(),
+		"timeout_block_height": uint64(elm.TimeoutBlockHeight),
+	}
+	return data
+}
+
+func (elm *Action_36_EscrowCreate) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.EscrowId.Serialize()
+	var b2, _ = elm.BuyerAddress.Serialize()
+	var b3, _ = elm.SellerAddress.Serialize()
+	var b4, _ = elm.ArbiterAddress.Serialize()
+	var b5, _ = elm.LockAmount.Serialize()
+	var b6, _ = elm.TimeoutBlockHeight.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_36_EscrowCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.EscrowId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ArbiterAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TimeoutBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_36_EscrowCreate) Size() uint32 {
+	return 2 + elm.EscrowId.Size() +
+		elm.BuyerAddress.Size() +
+		elm.SellerAddress.Size() +
+		elm.ArbiterAddress.Size() +
+		elm.LockAmount.Size() +
+		elm.TimeoutBlockHeight.Size()
+}
+
+func (act *Action_36_EscrowCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.BuyerAddress,
+	} // 买方需要签名
+}
+
+func (act *Action_36_EscrowCreate) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	// 检查id格式
+	if len(act.EscrowId) != stores.EscrowIdLength ||
+		act.EscrowId[0] == 0 ||
+		act.EscrowId[stores.EscrowIdLength-1] == 0 {
+		return errs.New(errs.CodeMalformed, "Escrow Id format error.")
+	}
+
+	// 查询id是否存在
+	if state.Escrow(act.EscrowId) != nil {
+		return errs.New(errs.CodeAlreadyExists, "Escrow already exist.").WithID(act.EscrowId)
+	}
+
+	// 三方地址必须各不相同
+	if act.BuyerAddress.Equal(act.SellerAddress) ||
+		act.BuyerAddress.Equal(act.ArbiterAddress) ||
+		act.SellerAddress.Equal(act.ArbiterAddress) {
+		return errs.New(errs.CodeInvalidParameter, "Buyer, seller and arbiter address must be different.")
+	}
+
+	// 金额必须为正
+	if !act.LockAmount.IsPositive() {
+		return errs.New(errs.CodeInvalidParameter, "Lock amount must be positive.")
+	}
+
+	// 超时高度必须在未来
+	if act.TimeoutBlockHeight > 0 && uint64(act.TimeoutBlockHeight) <= paddingHeight {
+		return errs.New(errs.CodeInvalidParameter, "TimeoutBlockHeight %d must over than %d.", act.TimeoutBlockHeight, paddingHeight)
+	}
+
+	// 扣除买方 HAC
+	e1 := DoSubBalanceFromChainState(state, act.BuyerAddress, act.LockAmount)
+	if e1 != nil {
+		return e1
+	}
+
+	// 保存担保
+	escsto := &stores.Escrow{
+		Status:             stores.EscrowStatusLocked,
+		CreateBlockHeight:  fields.BlockHeight(paddingHeight),
+		TimeoutBlockHeight: act.TimeoutBlockHeight,
+		BuyerAddress:       act.BuyerAddress,
+		SellerAddress:      act.SellerAddress,
+		ArbiterAddress:     act.ArbiterAddress,
+		LockAmount:         act.LockAmount,
+	}
+	return state.EscrowCreate(act.EscrowId, escsto)
+}
+
+func (act *Action_36_EscrowCreate) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	// 回退 HAC
+	DoAddBalanceFromChainState(state, act.BuyerAddress, act.LockAmount)
+	// 删除担保
+	return state.EscrowDelete(act.EscrowId)
+}
+
+// 设置所属 belong_trs
+func (act *Action_36_EscrowCreate) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_36_EscrowCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+/////////////////////////////////////////////////
+
+// 担保授权签名地址，放款或退款需要三方中的两方
+type EscrowAuthorizers struct {
+	Count     fields.VarUint1
+	Addresses []fields.Address
+}
+
+// json api
+func (elm EscrowAuthorizers) ToReadableList() []string {
+	list := make([]string, 0, len(elm.Addresses))
+	for _, addr := range elm.Addresses {
+		list = append(list, addr.ToReadable())
+	}
+	return list
+}
+
+func (elm EscrowAuthorizers) Size() uint32 {
+	return elm.Count.Size() + uint32(len(elm.Addresses))*fields.AddressSize
+}
+
+func (elm EscrowAuthorizers) Serialize() ([]byte, error) {
+	if int(elm.Count) != len(elm.Addresses) {
+		return nil, errs.New(errs.CodeMalformed, "Authorizers quantity count error")
+	}
+	var buffer bytes.Buffer
+	var b1, _ = elm.Count.Serialize()
+	buffer.Write(b1)
+	for _, addr := range elm.Addresses {
+		var bt, _ = addr.Serialize()
+		buffer.Write(bt)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *EscrowAuthorizers) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.Count.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if elm.Count > 2 {
+		return 0, errs.New(errs.CodeInvalidParameter, "Authorizers quantity cannot over 2")
+	}
+	elm.Addresses = make([]fields.Address, int(elm.Count))
+	for i := 0; i < int(elm.Count); i++ {
+		seek, e = elm.Addresses[i].Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+// 检查是否为担保三方中两个不同的参与方
+func (elm EscrowAuthorizers) checkTwoOfThree(escrow *stores.Escrow) error {
+	if int(elm.Count) != 2 || len(elm.Addresses) != 2 {
+		return errs.New(errs.CodeNotPermitted, "Escrow must be authorized by two of buyer, seller and arbiter.")
+	}
+	if elm.Addresses[0].Equal(elm.Addresses[1]) {
+		return errs.New(errs.CodeInvalidParameter, "Escrow authorizers cannot be the same address.")
+	}
+	for _, addr := range elm.Addresses {
+		if !escrow.IsParticipant(addr) {
+			return errs.New(errs.CodeNotPermitted, "Address %s is not a participant of the escrow.", addr.ToReadable())
+		}
+	}
+	return nil
+}
+
+/////////////////////////////////////////////////
+
+// 担保放款给卖方
+type Action_37_EscrowRelease struct {
+	EscrowId    fields.EscrowId // 担保ID
+	Authorizers EscrowAuthorizers
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_37_EscrowRelease) Kind() uint16 {
+	return 37
+}
+
+// json api
+func (elm *Action_37_EscrowRelease) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"escrow_id":   elm.EscrowId.ToHex(),
+		"authorizers": elm.Authorizers.ToReadableList(),
+	}
+	return data
+}
+
+func (elm *Action_37_EscrowRelease) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.EscrowId.Serialize()
+	var b2, e = elm.Authorizers.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_37_EscrowRelease) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.EscrowId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Authorizers.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_37_EscrowRelease) Size() uint32 {
+	return 2 + elm.EscrowId.Size() + elm.Authorizers.Size()
+}
+
+func (act *Action_37_EscrowRelease) RequestSignAddresses() []fields.Address {
+	return act.Authorizers.Addresses // 两方需要签名
+}
+
+func (act *Action_37_EscrowRelease) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	escObj := state.Escrow(act.EscrowId)
+	if escObj == nil {
+		return errs.New(errs.CodeNotFound, "Escrow <%s> not exist.", act.EscrowId.ToHex())
+	}
+	if escObj.IsClosed() {
+		return errs.New(errs.CodeStatusConflict, "Escrow <%s> has been closed.", act.EscrowId.ToHex())
+	}
+	e1 := act.Authorizers.checkTwoOfThree(escObj)
+	if e1 != nil {
+		return e1
+	}
+
+	// 放款给卖方
+	e2 := DoAddBalanceFromChainState(state, escObj.SellerAddress, escObj.LockAmount)
+	if e2 != nil {
+		return e2
+	}
+
+	escObj.SetClosedStatus(stores.EscrowStatusReleased, paddingHeight)
+	return state.EscrowUpdate(act.EscrowId, escObj)
+}
+
+func (act *Action_37_EscrowRelease) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	escObj := state.Escrow(act.EscrowId)
+	if escObj == nil {
+		return errs.New(errs.CodeNotFound, "Escrow <%s> not exist.", act.EscrowId.ToHex())
+	}
+	DoSubBalanceFromChainState(state, escObj.SellerAddress, escObj.LockAmount)
+	escObj.DropClosedStatus()
+	return state.EscrowUpdate(act.EscrowId, escObj)
+}
+
+// 设置所属 belong_trs
+func (act *Action_37_EscrowRelease) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_37_EscrowRelease) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+/////////////////////////////////////////////////
+
+// 担保退款给买方
+type Action_38_EscrowRefund struct {
+	EscrowId    fields.EscrowId   // 担保ID
+	Authorizers EscrowAuthorizers // 超时退款时为空
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_38_EscrowRefund) Kind() uint16 {
+	return 38
+}
+
+// json api
+func (elm *Action_38_EscrowRefund) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"escrow_id":   elm.EscrowId.ToHex(),
+		"authorizers": elm.Authorizers.ToReadableList(),
+	}
+	return data
+}
+
+func (elm *Action_38_EscrowRefund) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.EscrowId.Serialize()
+	var b2, e = elm.Authorizers.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_38_EscrowRefund) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.EscrowId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Authorizers.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_38_EscrowRefund) Size() uint32 {
+	return 2 + elm.EscrowId.Size() + elm.Authorizers.Size()
+}
+
+func (act *Action_38_EscrowRefund) RequestSignAddresses() []fields.Address {
+	return act.Authorizers.Addresses // 两方需要签名，超时退款不需要
+}
+
+func (act *Action_38_EscrowRefund) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	escObj := state.Escrow(act.EscrowId)
+	if escObj == nil {
+		return errs.New(errs.CodeNotFound, "Escrow <%s> not exist.", act.EscrowId.ToHex())
+	}
+	if escObj.IsClosed() {
+		return errs.New(errs.CodeStatusConflict, "Escrow <%s> has been closed.", act.EscrowId.ToHex())
+	}
+	if act.Authorizers.Count == 0 {
+		// 无签名退款，必须已经超时
+		if !escObj.IsTimeout(paddingHeight) {
+			return errs.New(errs.CodeNotPermitted, "Escrow <%s> cannot refund without authorization before timeout.", act.EscrowId.ToHex())
+		}
+	} else {
+		e1 := act.Authorizers.checkTwoOfThree(escObj)
+		if e1 != nil {
+			return e1
+		}
+	}
+
+	// 退款给买方
+	e2 := DoAddBalanceFromChainState(state, escObj.BuyerAddress, escObj.LockAmount)
+	if e2 != nil {
+		return e2
+	}
+
+	escObj.SetClosedStatus(stores.EscrowStatusRefunded, paddingHeight)
+	return state.EscrowUpdate(act.EscrowId, escObj)
+}
+
+func (act *Action_38_EscrowRefund) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	escObj := state.Escrow(act.EscrowId)
+	if escObj == nil {
+		return errs.New(errs.CodeNotFound, "Escrow <%s> not exist.", act.EscrowId.ToHex())
+	}
+	DoSubBalanceFromChainState(state, escObj.BuyerAddress, escObj.LockAmount)
+	escObj.DropClosedStatus()
+	return state.EscrowUpdate(act.EscrowId, escObj)
+}
+
+// 设置所属 belong_trs
+func (act *Action_38_EscrowRefund) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_38_EscrowRefund) IsBurning90PersentTxFees() bool {
+	return false
+}
@@ -0,0 +1,520 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+)
+
+/*
+
+通用哈希时间锁（HTLC），用于与比特币等其它链进行跨链原子互换
+
+. 创建：发送方锁定 HAC、SAT 和钻石，指定收款方、哈希锁和超时高度
+. 领取：超时之前任何人提交正确的原像，资产转给收款方
+. 退回：达到超时高度之后任何人可以提交，资产退回发送方
+
+合约关闭后不删除，领取时公开的原像保存在状态里，供对手方读取
+
+*/
+
+// 创建哈希时间锁
+type Action_31_HtlcCreate struct {
+	HtlcId fields.HtlcId // 合约ID
+
+	SenderAddress   fields.Address // 发送方
+	ReceiverAddress fields.Address // 收款方
+
+	HashType           fields.VarUint1    // 哈希算法 0.sha256 1.sha3
+	Hashlock           fields.Hash        // 哈希锁
+	TimeoutBlockHeight fields.BlockHeight // 超时区块高度
+
+	LockAmount      fields.Amount               // 锁定的 HAC
+	LockSatoshi     fields.SatoshiVariation     // 锁定的 SAT
+	LockDiamondList fields.DiamondListMaxLen200 // 锁定的钻石
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_31_HtlcCreate) Kind() uint16 {
+	return 31
+}
+
+// json api
+func (elm *Action_31_HtlcCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"htlc_id":              elm.HtlcId.ToHex(),
+		"sender_address":       elm.SenderAddress.ToReadable(),
+		"receiver_address":     elm.ReceiverAddress.ToReadable(),
+		"hash_type":            uint8(elm.HashType),
+		"hashlock":             elm.Hashlock.ToHex(),
+		"timeout_block_height": uint64(elm.TimeoutBlockHeight),
+		"lock_amount":          elm.LockAmount.ToFinString(),
+		"lock_satoshi":         uint64(elm.LockSatoshi.GetRealSatoshi()),
+		"lock_diamond":         elm.LockDiamondList.SerializeHACDlistToCommaSplitString(),
+	}
+	return data
+}
+
+func (elm *Action_31_HtlcCreate) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.HtlcId.Serialize()
+	var b2, _ = elm.SenderAddress.Serialize()
+	var b3, _ = elm.ReceiverAddress.Serialize()
+	var b4, _ = elm.HashType.Serialize()
+	var b5, _ = elm.Hashlock.Serialize()
+	var b6, _ = elm.TimeoutBlockHeight.Serialize()
+	var b7, _ = elm.LockAmount.Serialize()
+	var b8, _ = elm.LockSatoshi.Serialize()
+	var b9, e = elm.LockDiamondList.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	buffer.Write(b9)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_31_HtlcCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.HtlcId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SenderAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ReceiverAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.HashType.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Hashlock.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TimeoutBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_31_HtlcCreate) Size() uint32 {
+	return 2 + elm.HtlcId.Size() +
+		elm.SenderAddress.Size() +
+		elm.ReceiverAddress.Size() +
+		elm.HashType.Size() +
+		elm.Hashlock.Size() +
+		elm.TimeoutBlockHeight.Size() +
+		elm.LockAmount.Size() +
+		elm.LockSatoshi.Size() +
+		elm.LockDiamondList.Size()
+}
+
+func (act *Action_31_HtlcCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.SenderAddress,
+	} // 发送方需要签名
+}
+
+func (act *Action_31_HtlcCreate) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	// 检查id格式
+	if len(act.HtlcId) != stores.HtlcIdLength ||
+		act.HtlcId[0] == 0 ||
+		act.HtlcId[stores.HtlcIdLength-1] == 0 {
+		return errs.New(errs.CodeMalformed, "Htlc Id format error.")
+	}
+
+	// 查询id是否存在
+	if state.Htlc(act.HtlcId) != nil {
+		return errs.New(errs.CodeAlreadyExists, "Htlc already exist.").WithID(act.HtlcId)
+	}
+
+	// 不能发给自己
+	if act.SenderAddress.Equal(act.ReceiverAddress) {
+		return errs.New(errs.CodeInvalidParameter, "Cannot lock to myself.")
+	}
+
+	// 检查哈希算法
+	if act.HashType != stores.HtlcHashTypeSha256 && act.HashType != stores.HtlcHashTypeSha3 {
+		return errs.New(errs.CodeUnsupportedKind, "Htlc hash type <%d> not support.", act.HashType)
+	}
+
+	// 超时高度必须在未来
+	if uint64(act.TimeoutBlockHeight) <= paddingHeight {
+		return errs.New(errs.CodeInvalidParameter, "TimeoutBlockHeight %d must over than %d.", act.TimeoutBlockHeight, paddingHeight)
+	}
+
+	// 锁定数量检查
+	dianum := int(act.LockDiamondList.Count)
+	if dianum != len(act.LockDiamondList.Diamonds) {
+		return errs.New(errs.CodeMalformed, "Diamonds quantity error")
+	}
+	if dianum > 200 {
+		return errs.New(errs.CodeInvalidParameter, "Diamonds quantity cannot over 200")
+	}
+	if act.LockAmount.IsNotEmpty() && !act.LockAmount.IsPositive() {
+		return errs.New(errs.CodeInvalidParameter, "Lock amount is not positive.")
+	}
+	if act.LockAmount.IsEmpty() && act.LockSatoshi.NotEmpty.Is(false) && dianum == 0 {
+		return errs.New(errs.CodeInvalidParameter, "Lock amount, satoshi and diamond cannot be empty at the same time")
+	}
+
+	// 锁定钻石
+	for i := 0; i < dianum; i++ {
+		diamond := act.LockDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem == nil {
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Address.NotEqual(act.SenderAddress) {
+			return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to address '%s'", string(diamond), act.SenderAddress.ToReadable())
+		}
+		if diaitem.Status != stores.DiamondStatusNormal {
+			return errs.New(errs.CodeStatusConflict, "Diamond <%s> has been mortgaged or locked.", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusHtlcLocked // 标记锁定
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	e6 := DoSubDiamondFromChainState(state, act.SenderAddress, act.LockDiamondList.Diamonds)
+	if e6 != nil {
+		return e6
+	}
+
+	// 扣除 SAT
+	if act.LockSatoshi.NotEmpty.Check() {
+		e7 := DoSubSatoshiFromChainState(state, act.SenderAddress, act.LockSatoshi.ValueSAT)
+		if e7 != nil {
+			return e7
+		}
+	}
+
+	// 扣除 HAC
+	if act.LockAmount.IsNotEmpty() {
+		e8 := DoSubBalanceFromChainState(state, act.SenderAddress, act.LockAmount)
+		if e8 != nil {
+			return e8
+		}
+	}
+
+	// 保存合约
+	htlcsto := &stores.Htlc{
+		Status:             stores.HtlcStatusLocked,
+		CreateBlockHeight:  fields.BlockHeight(paddingHeight),
+		TimeoutBlockHeight: act.TimeoutBlockHeight,
+		SenderAddress:      act.SenderAddress,
+		ReceiverAddress:    act.ReceiverAddress,
+		HashType:           act.HashType,
+		Hashlock:           act.Hashlock,
+		LockAmount:         act.LockAmount,
+		LockSatoshi:        act.LockSatoshi,
+		LockDiamondList:    act.LockDiamondList,
+	}
+	return state.HtlcCreate(act.HtlcId, htlcsto)
+}
+
+func (act *Action_31_HtlcCreate) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	// 回退钻石状态
+	for i := 0; i < len(act.LockDiamondList.Diamonds); i++ {
+		diamond := act.LockDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem != nil {
+			diaitem.Status = stores.DiamondStatusNormal
+			state.DiamondSet(diamond, diaitem)
+		}
+	}
+	DoAddDiamondFromChainState(state, act.SenderAddress, act.LockDiamondList.Diamonds)
+	// 回退 SAT 和 HAC
+	if act.LockSatoshi.NotEmpty.Check() {
+		DoAddSatoshiFromChainState(state, act.SenderAddress, act.LockSatoshi.ValueSAT)
+	}
+	if act.LockAmount.IsNotEmpty() {
+		DoAddBalanceFromChainState(state, act.SenderAddress, act.LockAmount)
+	}
+	// 删除合约
+	return state.HtlcDelete(act.HtlcId)
+}
+
+// 设置所属 belong_trs
+func (act *Action_31_HtlcCreate) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_31_HtlcCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+/////////////////////////////////////////////////
+
+// 哈希时间锁解锁：凭原像领取，或超时后退回
+type Action_32_HtlcUnlock struct {
+	HtlcId   fields.HtlcId  // 合约ID
+	IsRefund fields.Bool    // 是否为超时退回
+	Preimage fields.Bytes32 // 原像，领取时才有
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_32_HtlcUnlock) Kind() uint16 {
+	return 32
+}
+
+// json api
+func (elm *Action_32_HtlcUnlock) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"htlc_id":   elm.HtlcId.ToHex(),
+		"is_refund": elm.IsRefund.Check(),
+	}
+	if elm.IsRefund.Is(false) {
+		data["preimage"] = elm.Preimage.ToHex() // 领取时才有
+	}
+	return data
+}
+
+func (elm *Action_32_HtlcUnlock) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.HtlcId.Serialize()
+	var b2, _ = elm.IsRefund.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	if elm.IsRefund.Is(false) {
+		var b3, _ = elm.Preimage.Serialize()
+		buffer.Write(b3)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_32_HtlcUnlock) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.HtlcId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.IsRefund.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if elm.IsRefund.Is(false) {
+		seek, e = elm.Preimage.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+func (elm *Action_32_HtlcUnlock) Size() uint32 {
+	size := 2 + elm.HtlcId.Size() + elm.IsRefund.Size()
+	if elm.IsRefund.Is(false) {
+		size += elm.Preimage.Size()
+	}
+	return size
+}
+
+func (*Action_32_HtlcUnlock) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // not sign
+}
+
+func (act *Action_32_HtlcUnlock) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	htlcObj := state.Htlc(act.HtlcId)
+	if htlcObj == nil {
+		return errs.New(errs.CodeNotFound, "Htlc <%s> not exist.", act.HtlcId.ToHex())
+	}
+	if htlcObj.IsClosed() {
+		return errs.New(errs.CodeStatusConflict, "Htlc <%s> has been closed.", act.HtlcId.ToHex())
+	}
+
+	isTimeout := paddingHeight >= uint64(htlcObj.TimeoutBlockHeight)
+	var toAddr fields.Address
+	if act.IsRefund.Check() {
+		// 退回：必须已经超时
+		if !isTimeout {
+			return errs.New(errs.CodeStatusConflict, "Htlc <%s> cannot refund before height %d.", act.HtlcId.ToHex(), htlcObj.TimeoutBlockHeight)
+		}
+		toAddr = htlcObj.SenderAddress
+		htlcObj.SetRefundedStatus(paddingHeight)
+	} else {
+		// 领取：必须在超时之前，并且原像正确
+		if isTimeout {
+			return errs.New(errs.CodeExpired, "Htlc <%s> cannot claim after height %d.", act.HtlcId.ToHex(), htlcObj.TimeoutBlockHeight)
+		}
+		if !htlcObj.CheckPreimage(act.Preimage) {
+			return errs.New(errs.CodeInvalidParameter, "Htlc <%s> preimage not match the hashlock.", act.HtlcId.ToHex())
+		}
+		toAddr = htlcObj.ReceiverAddress
+		htlcObj.SetClaimedStatus(paddingHeight, act.Preimage)
+	}
+
+	// 转移钻石
+	dianum := len(htlcObj.LockDiamondList.Diamonds)
+	for i := 0; i < dianum; i++ {
+		diamond := htlcObj.LockDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem == nil {
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Status != stores.DiamondStatusHtlcLocked {
+			return errs.New(errs.CodeStatusConflict, "Diamond <%s> status is not [stores.DiamondStatusHtlcLocked].", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusNormal
+		diaitem.Address = toAddr
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	e6 := DoAddDiamondFromChainState(state, toAddr, htlcObj.LockDiamondList.Diamonds)
+	if e6 != nil {
+		return e6
+	}
+	// 领取时钻石归属改变，记录流转历史（退回不改变归属）
+	if dianum > 0 && toAddr.NotEqual(htlcObj.SenderAddress) {
+		e9 := doAppendDiamondHistory(state, act.belong_trs, act.Kind(), htlcObj.SenderAddress, toAddr, htlcObj.LockDiamondList.Diamonds)
+		if e9 != nil {
+			return e9
+		}
+	}
+
+	// 转移 SAT 和 HAC
+	if htlcObj.LockSatoshi.NotEmpty.Check() {
+		e7 := DoAddSatoshiFromChainState(state, toAddr, htlcObj.LockSatoshi.ValueSAT)
+		if e7 != nil {
+			return e7
+		}
+	}
+	if htlcObj.LockAmount.IsNotEmpty() {
+		e8 := DoAddBalanceFromChainState(state, toAddr, htlcObj.LockAmount)
+		if e8 != nil {
+			return e8
+		}
+	}
+
+	// 更新合约状态
+	return state.HtlcUpdate(act.HtlcId, htlcObj)
+}
+
+func (act *Action_32_HtlcUnlock) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	htlcObj := state.Htlc(act.HtlcId)
+	if htlcObj == nil {
+		return errs.New(errs.CodeNotFound, "Htlc <%s> not exist.", act.HtlcId.ToHex())
+	}
+	toAddr := htlcObj.ReceiverAddress
+	if act.IsRefund.Check() {
+		toAddr = htlcObj.SenderAddress
+	}
+
+	// 回退钻石
+	dianum := len(htlcObj.LockDiamondList.Diamonds)
+	for i := 0; i < dianum; i++ {
+		diamond := htlcObj.LockDiamondList.Diamonds[i]
+		diaitem := state.Diamond(diamond)
+		if diaitem != nil {
+			diaitem.Status = stores.DiamondStatusHtlcLocked
+			diaitem.Address = htlcObj.SenderAddress
+			state.DiamondSet(diamond, diaitem)
+		}
+	}
+	DoSubDiamondFromChainState(state, toAddr, htlcObj.LockDiamondList.Diamonds)
+	doDropDiamondHistory(state, act.belong_trs, act.Kind(), htlcObj.LockDiamondList.Diamonds)
+	// 回退 SAT 和 HAC
+	if htlcObj.LockSatoshi.NotEmpty.Check() {
+		DoSubSatoshiFromChainState(state, toAddr, htlcObj.LockSatoshi.ValueSAT)
+	}
+	if htlcObj.LockAmount.IsNotEmpty() {
+		DoSubBalanceFromChainState(state, toAddr, htlcObj.LockAmount)
+	}
+
+	// 回退合约状态
+	htlcObj.DropClosedStatus()
+	return state.HtlcUpdate(act.HtlcId, htlcObj)
+}
+
+// 设置所属 belong_trs
+func (act *Action_32_HtlcUnlock) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_32_HtlcUnlock) IsBurning90PersentTxFees() bool {
+	return false
+}
@@ -4,13 +4,22 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
+	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
-	"math/big"
+	"github.com/hacash/core/sys"
 )
 
+// 从此高度开始检查锁仓主地址的版本号，与定点计算分叉同一高度
+// 之前的主网区块没有这项检查，重新同步时保持原有结果
+const LockblsAddressVersionCheckBlockHeight = coinbase.FixedPointMathForkBlockHeight
+
+func isLockblsAddressVersionCheckActive(blockHeight uint64) bool {
+	return sys.TestDebugLocalDevelopmentMark || blockHeight >= LockblsAddressVersionCheckBlockHeight
+}
+
 type Action_9_LockblsCreate struct {
 	LockblsId           fields.LockblsId   // 线性锁仓id
 	PaymentAddress      fields.Address     // 付款地址
@@ -119,35 +128,39 @@ func (act *Action_9_LockblsCreate) WriteinChainState(state interfaces.ChainState
 	if len(act.LockblsId) != stores.LockblsIdLength || act.LockblsId[0] == 0 || act.LockblsId[stores.LockblsIdLength-1] == 0 {
 		// 用户创建的 锁仓ID， 第一位和最后一位不能为零
 		// 第一位为零的ID是比特币单向转移的锁仓id
-		return fmt.Errorf("LockblsId format error.")
+		return errs.New(errs.CodeMalformed, "LockblsId format error.")
+	}
+	// 检查主地址版本号，分叉高度之前的区块保持原有规则
+	if isLockblsAddressVersionCheckActive(state.GetPendingBlockHeight()) && !act.MasterAddress.IsStandardVersion() {
+		return errs.New(errs.CodeInvalidParameter, "MasterAddress version error.").WithAddress(act.MasterAddress)
 	}
 	// 检查是否key已经存在
 	haslock := state.Lockbls(act.LockblsId)
 	if haslock != nil {
-		return fmt.Errorf("Lockbls id<%s> already.", hex.EncodeToString(act.LockblsId))
+		return errs.New(errs.CodeAlreadyExists, "Lockbls id already.").WithID(act.LockblsId)
 	}
 	// 检查 步进 block number
 	if act.LinearBlockNumber < 288 {
-		return fmt.Errorf("LinearBlockNumber cannot less 288.")
+		return errs.New(errs.CodeInvalidParameter, "LinearBlockNumber cannot less 288.")
 	}
 	if act.LinearBlockNumber > 1600*10000 {
-		return fmt.Errorf("LinearBlockNumber cannot over 16000000.")
+		return errs.New(errs.CodeInvalidParameter, "LinearBlockNumber cannot over 16000000.")
 	}
 	// 检查数额
 	if !act.TotalStockAmount.IsPositive() || !act.LinearReleaseAmount.IsPositive() {
-		return fmt.Errorf("TotalStockAmount or LinearReleaseAmount error.")
+		return errs.New(errs.CodeInvalidParameter, "TotalStockAmount or LinearReleaseAmount error.")
 	}
 	// 检查余额
 	mainblsamt := state.Balance(act.PaymentAddress)
 	if mainblsamt == nil {
-		return fmt.Errorf("Balance cannot empty.")
+		return errs.New(errs.CodeInsufficientBalance, "Balance cannot empty.")
 	}
 	if mainblsamt.Hacash.LessThan(&act.TotalStockAmount) {
-		return fmt.Errorf("Balance not enough.")
+		return errs.New(errs.CodeInsufficientBalance, "Balance not enough.")
 	}
 	// 步进不能大于存入额
 	if act.TotalStockAmount.LessThan(&act.LinearReleaseAmount) {
-		return fmt.Errorf("LinearReleaseAmount cannot more than TotalStockAmount.")
+		return errs.New(errs.CodeInvalidParameter, "LinearReleaseAmount cannot more than TotalStockAmount.")
 	}
 
 	// 存储
@@ -169,6 +182,17 @@ func (act *Action_9_LockblsCreate) WriteinChainState(state interfaces.ChainState
 		return e2
 	}
 
+	// total supply 统计：普通锁仓内锁定的HAC增加
+	totalsupply, e3 := state.ReadTotalSupply()
+	if e3 != nil {
+		return e3
+	}
+	totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfLocatedHACInLockbls, &act.TotalStockAmount)
+	e4 := state.UpdateSetTotalSupply(totalsupply)
+	if e4 != nil {
+		return e4
+	}
+
 	// ok
 	return nil
 }
@@ -187,6 +211,16 @@ func (act *Action_9_LockblsCreate) RecoverChainState(state interfaces.ChainState
 	if e2 != nil {
 		return e2
 	}
+	// 回退统计
+	totalsupply, e3 := state.ReadTotalSupply()
+	if e3 != nil {
+		return e3
+	}
+	totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfLocatedHACInLockbls, &act.TotalStockAmount)
+	e4 := state.UpdateSetTotalSupply(totalsupply)
+	if e4 != nil {
+		return e4
+	}
 
 	// ok
 	return nil
@@ -266,47 +300,35 @@ func (act *Action_10_LockblsRelease) WriteinChainState(state interfaces.ChainSta
 
 	// 因为只能提取到指定地址，所以任何人都能提取，不需要锁仓地址的签名
 	// 查询
-	lockbls := state.Lockbls(act.LockblsId)
+	lockbls := readLockbls(state, act.LockblsId)
 	if lockbls == nil {
-		return fmt.Errorf("Lockbls id<%s> not find.", hex.EncodeToString(act.LockblsId))
+		return errs.New(errs.CodeNotFound, "Lockbls id<%s> not find.", hex.EncodeToString(act.LockblsId))
 	}
 	// 提取出来
 	currentBlockHeight := state.GetPendingBlockHeight()
 	if currentBlockHeight < uint64(lockbls.EffectBlockHeight) {
-		return fmt.Errorf("EffectBlockHeight be set %d", lockbls.EffectBlockHeight)
+		return errs.New(errs.CodeInvalidParameter, "EffectBlockHeight be set %d", lockbls.EffectBlockHeight)
 	}
-	// 计算提取额度
+	// 检查是否到达第一次释放高度
 	// rlsnum == 可提取次数
 	rlsnum := (currentBlockHeight - uint64(lockbls.EffectBlockHeight)) / uint64(lockbls.LinearBlockNumber)
 	if rlsnum == 0 {
-		return fmt.Errorf("first release Block Height is %d, ", uint64(lockbls.EffectBlockHeight)+uint64(lockbls.LinearBlockNumber))
+		return errs.New(errs.CodeInvalidParameter, "first release Block Height is %d, ", uint64(lockbls.EffectBlockHeight)+uint64(lockbls.LinearBlockNumber))
 	}
-	totalrlsamt := lockbls.TotalLockAmount
-	steprlsamt := lockbls.LinearReleaseAmount
 	// 有效可提余额
 	lockblsamt := lockbls.BalanceAmount
 	// 对比
 	if lockblsamt.LessThan(&act.ReleaseAmount) {
-		return fmt.Errorf("BalanceAmount not enough.") // 余额不足
+		return errs.New(errs.CodeInsufficientBalance, "BalanceAmount not enough.") // 余额不足
 	}
-	maxrlsamtbig := new(big.Int).Mul(steprlsamt.GetValue(), new(big.Int).SetUint64(rlsnum))
-	currentMaxReleaseAmount, e3 := fields.NewAmountByBigInt(maxrlsamtbig)
+	// 有效可提余额（已减除掉已经提走的）
+	currentMaxReleaseAmount, e3 := lockbls.ReleasableAt(currentBlockHeight)
 	if e3 != nil {
 		return e3
 	}
-	// 可提余额要减除掉已经提走的
-	alreadyExtractedAmount, e9 := totalrlsamt.Sub(&lockblsamt) // 已经提走的余额
-	if e9 != nil {
-		return e9
-	}
-	// 有效可提余额
-	currentMaxReleaseAmount, e9 = currentMaxReleaseAmount.Sub(alreadyExtractedAmount)
-	if e9 != nil {
-		return e9
-	}
 	// 可提余额判断
 	if currentMaxReleaseAmount.LessThan(&act.ReleaseAmount) {
-		return fmt.Errorf("Current Max Release Amount not enough.") // 目前可提余额不足
+		return errs.New(errs.CodeInsufficientBalance, "Current Max Release Amount not enough.") // 目前可提余额不足
 	}
 
 	// 更新锁仓余额
@@ -330,20 +352,24 @@ func (act *Action_10_LockblsRelease) WriteinChainState(state interfaces.ChainSta
 		}
 	}
 	// total supply 统计
-	isbtcmoveunlock := act.LockblsId[0] == 0 // 第一位为 0 则是比特币转移的锁定
-	if isbtcmoveunlock {
-		totalsupply, e2 := state.ReadTotalSupply()
-		if e2 != nil {
-			return e2
-		}
+	totalsupply, e2 := state.ReadTotalSupply()
+	if e2 != nil {
+		return e2
+	}
+	if stores.IsLockblsIdOfBtcMove(act.LockblsId) { // 第一位为 0 则是比特币转移的锁定
 		// 累加解锁的HAC
-		addamt := act.ReleaseAmount.ToMei()
-		totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, addamt)
-		// update total supply
-		e3 := state.UpdateSetTotalSupply(totalsupply)
-		if e3 != nil {
-			return e3
-		}
+		totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, &act.ReleaseAmount)
+	} else if lockbls.IsVesting.Check() {
+		// 归属锁仓内锁定的HAC减少
+		totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfLocatedHACInVestingLockbls, &act.ReleaseAmount)
+	} else {
+		// 普通锁仓内锁定的HAC减少
+		totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfLocatedHACInLockbls, &act.ReleaseAmount)
+	}
+	// update total supply
+	e5 := state.UpdateSetTotalSupply(totalsupply)
+	if e5 != nil {
+		return e5
 	}
 	// 加上余额
 	return DoAddBalanceFromChainState(state, lockbls.MasterAddress, act.ReleaseAmount)
@@ -353,9 +379,9 @@ func (act *Action_10_LockblsRelease) RecoverChainState(state interfaces.ChainSta
 	if act.belong_trs == nil {
 		panic("Action belong to transaction not be nil !")
 	}
-	lockbls := state.Lockbls(act.LockblsId)
+	lockbls := readLockbls(state, act.LockblsId)
 	if lockbls == nil {
-		return fmt.Errorf("Lockbls id<%s> not find.", hex.EncodeToString(act.LockblsId))
+		return errs.New(errs.CodeNotFound, "Lockbls id<%s> not find.", hex.EncodeToString(act.LockblsId))
 	}
 	// 锁仓回退
 	// 更新锁仓余额
@@ -368,20 +394,22 @@ func (act *Action_10_LockblsRelease) RecoverChainState(state interfaces.ChainSta
 	// 扣除 储存
 	state.LockblsUpdate(act.LockblsId, lockbls)
 	// total supply 统计
-	isbtcmoveunlock := act.LockblsId[0] == 0 // 第一位为 0 则是比特币转移的锁定
-	if isbtcmoveunlock {
-		totalsupply, e2 := state.ReadTotalSupply()
-		if e2 != nil {
-			return e2
-		}
+	totalsupply, e2 := state.ReadTotalSupply()
+	if e2 != nil {
+		return e2
+	}
+	if stores.IsLockblsIdOfBtcMove(act.LockblsId) { // 第一位为 0 则是比特币转移的锁定
 		// 累加解锁的HAC
-		addamt := act.ReleaseAmount.ToMei()
-		totalsupply.DoSub(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, addamt)
-		// update total supply
-		e3 := state.UpdateSetTotalSupply(totalsupply)
-		if e3 != nil {
-			return e3
-		}
+		totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, &act.ReleaseAmount)
+	} else if lockbls.IsVesting.Check() {
+		totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfLocatedHACInVestingLockbls, &act.ReleaseAmount)
+	} else {
+		totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfLocatedHACInLockbls, &act.ReleaseAmount)
+	}
+	// update total supply
+	e3 := state.UpdateSetTotalSupply(totalsupply)
+	if e3 != nil {
+		return e3
 	}
 	// 回退余额
 	return DoSubBalanceFromChainState(state, lockbls.MasterAddress, act.ReleaseAmount)
@@ -396,3 +424,476 @@ func (act *Action_10_LockblsRelease) SetBelongTransaction(trs interfaces.Transac
 func (act *Action_10_LockblsRelease) IsBurning90PersentTxFees() bool {
 	return false
 }
+
+///////////////////////////////////////////////////////////////////////////////////////////////
+
+/*
+
+归属锁仓（vesting）
+
+. 悬崖高度之前归属额度为零
+. 悬崖之后按步进区块数线性归属，到最终释放高度全部归属
+. 可选由付款方撤销，收回尚未归属的额度，已归属部分仍可由主地址提取
+
+提取仍然使用 Action_10_LockblsRelease
+
+*/
+
+type Action_39_LockblsVestingCreate struct {
+	LockblsId         fields.LockblsId   // 线性锁仓id
+	PaymentAddress    fields.Address     // 付款地址
+	MasterAddress     fields.Address     // 主地址（领取权）
+	EffectBlockHeight fields.BlockHeight // 生效（开始）区块
+	CliffBlockHeight  fields.BlockHeight // 悬崖高度
+	EndBlockHeight    fields.BlockHeight // 最终释放高度
+	LinearBlockNumber fields.VarUint3    // 步进区块数
+	TotalStockAmount  fields.Amount      // 总共存入额度
+	IsRevocable       fields.Bool        // 付款方是否可以撤销
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_39_LockblsVestingCreate) Kind() uint16 {
+	return 39
+}
+
+func (elm *Action_39_LockblsVestingCreate) Size() uint32 {
+	return 2 +
+		elm.LockblsId.Size() +
+		elm.PaymentAddress.Size() +
+		elm.MasterAddress.Size() +
+		elm.EffectBlockHeight.Size() +
+		elm.CliffBlockHeight.Size() +
+		elm.EndBlockHeight.Size() +
+		elm.LinearBlockNumber.Size() +
+		elm.TotalStockAmount.Size() +
+		elm.IsRevocable.Size()
+}
+
+// json api
+func (elm *Action_39_LockblsVestingCreate) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"lockbls_id":          elm.LockblsId.ToHex(),
+		"payment_address":     elm.PaymentAddress.ToReadable(),
+		"master_address":      elm.MasterAddress.ToReadable(),
+		"effect_block_height": uint64(elm.EffectBlockHeight),
+		"cliff_block_height":  uint64(elm.CliffBlockHeight),
+		"end_block_height":    uint64(elm.EndBlockHeight),
+		"linear_block_number": uint64(elm.LinearBlockNumber),
+		"total_stock_amount":  elm.TotalStockAmount.ToFinString(),
+		"is_revocable":        elm.IsRevocable.Check(),
+	}
+	return data
+}
+
+func (elm *Action_39_LockblsVestingCreate) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var b1, _ = elm.LockblsId.Serialize()
+	var b2, _ = elm.PaymentAddress.Serialize()
+	var b3, _ = elm.MasterAddress.Serialize()
+	var b4, _ = elm.EffectBlockHeight.Serialize()
+	var b5, _ = elm.CliffBlockHeight.Serialize()
+	var b6, _ = elm.EndBlockHeight.Serialize()
+	var b7, _ = elm.LinearBlockNumber.Serialize()
+	var b8, _ = elm.TotalStockAmount.Serialize()
+	var b9, _ = elm.IsRevocable.Serialize()
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	buffer.Write(b9)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_39_LockblsVestingCreate) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LockblsId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PaymentAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.MasterAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.EffectBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CliffBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.EndBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LinearBlockNumber.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TotalStockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.IsRevocable.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (act *Action_39_LockblsVestingCreate) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.PaymentAddress, // 锁仓支付账户需要签名
+	}
+}
+
+func (act *Action_39_LockblsVestingCreate) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	// 检查id值合法性
+	if len(act.LockblsId) != stores.LockblsIdLength || act.LockblsId[0] == 0 || act.LockblsId[stores.LockblsIdLength-1] == 0 {
+		return errs.New(errs.CodeMalformed, "LockblsId format error.")
+	}
+	if !act.MasterAddress.IsStandardVersion() {
+		return errs.New(errs.CodeInvalidParameter, "MasterAddress version error.").WithAddress(act.MasterAddress)
+	}
+	// 检查是否key已经存在
+	haslock := state.Lockbls(act.LockblsId)
+	if haslock != nil {
+		return errs.New(errs.CodeAlreadyExists, "Lockbls id already.").WithID(act.LockblsId)
+	}
+	// 检查 步进 block number
+	minLinearBlockNumber := fields.VarUint3(288)
+	if sys.TestDebugLocalDevelopmentMark {
+		minLinearBlockNumber = 1 // 测试环境
+	}
+	if act.LinearBlockNumber < minLinearBlockNumber {
+		return errs.New(errs.CodeInvalidParameter, "LinearBlockNumber cannot less %d.", minLinearBlockNumber)
+	}
+	if act.LinearBlockNumber > 1600*10000 {
+		return errs.New(errs.CodeInvalidParameter, "LinearBlockNumber cannot over 16000000.")
+	}
+	// 检查高度 生效 <= 悬崖 <= 最终，并且至少一个步进
+	if act.CliffBlockHeight < act.EffectBlockHeight || act.EndBlockHeight < act.CliffBlockHeight {
+		return errs.New(errs.CodeInvalidParameter, "Block height must be EffectBlockHeight <= CliffBlockHeight <= EndBlockHeight.")
+	}
+	if uint64(act.EndBlockHeight)-uint64(act.EffectBlockHeight) < uint64(act.LinearBlockNumber) {
+		return errs.New(errs.CodeInvalidParameter, "EndBlockHeight must over EffectBlockHeight at least %d.", act.LinearBlockNumber)
+	}
+	// 检查数额
+	if !act.TotalStockAmount.IsPositive() {
+		return errs.New(errs.CodeInvalidParameter, "TotalStockAmount error.")
+	}
+
+	// 存储
+	lockbls := stores.NewEmptyLockbls(act.MasterAddress)
+	lockbls.EffectBlockHeight = act.EffectBlockHeight
+	lockbls.LinearBlockNumber = act.LinearBlockNumber
+	lockbls.TotalLockAmount = act.TotalStockAmount
+	lockbls.BalanceAmount = act.TotalStockAmount
+	lockbls.LinearReleaseAmount = fields.NewEmptyAmountValue() // 按归属计算，不使用固定步进额度
+	lockbls.IsVesting.Set(true)
+	lockbls.Vesting = stores.LockblsVesting{
+		CliffBlockHeight:   act.CliffBlockHeight,
+		EndBlockHeight:     act.EndBlockHeight,
+		RevokerAddress:     fields.NewEmptyOptionalAddress(),
+		RevokedBlockHeight: 0,
+	}
+	if act.IsRevocable.Check() {
+		lockbls.Vesting.RevokerAddress = fields.OptionalAddress{
+			Exist: fields.CreateBool(true),
+			Addr:  act.PaymentAddress,
+		}
+	}
+	// 扣除 payment
+	e1 := DoSubBalanceFromChainState(state, act.PaymentAddress, act.TotalStockAmount)
+	if e1 != nil {
+		return e1
+	}
+	// 保存锁仓，归属扩展单独保存
+	e2 := state.LockblsCreate(act.LockblsId, lockbls)
+	if e2 != nil {
+		return e2
+	}
+	e4 := state.LockblsVestingSet(act.LockblsId, &lockbls.Vesting)
+	if e4 != nil {
+		return e4
+	}
+	// total supply 统计
+	totalsupply, e3 := state.ReadTotalSupply()
+	if e3 != nil {
+		return e3
+	}
+	totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfLocatedHACInVestingLockbls, &act.TotalStockAmount)
+	return state.UpdateSetTotalSupply(totalsupply)
+}
+
+func (act *Action_39_LockblsVestingCreate) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+	// 回退 hac
+	e1 := DoAddBalanceFromChainState(state, act.PaymentAddress, act.TotalStockAmount)
+	if e1 != nil {
+		return e1
+	}
+	// 删除 lockbls
+	e2 := state.LockblsDelete(act.LockblsId)
+	if e2 != nil {
+		return e2
+	}
+	e4 := state.LockblsVestingDel(act.LockblsId)
+	if e4 != nil {
+		return e4
+	}
+	// total supply 统计
+	totalsupply, e3 := state.ReadTotalSupply()
+	if e3 != nil {
+		return e3
+	}
+	totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfLocatedHACInVestingLockbls, &act.TotalStockAmount)
+	return state.UpdateSetTotalSupply(totalsupply)
+}
+
+// 设置所属 belong_trs
+func (act *Action_39_LockblsVestingCreate) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_39_LockblsVestingCreate) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////////////////////
+
+// 撤销归属锁仓，收回未归属额度
+type Action_40_LockblsVestingRevoke struct {
+	LockblsId      fields.LockblsId // 线性锁仓id
+	RevokerAddress fields.Address   // 撤销人（付款方）
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_40_LockblsVestingRevoke) Kind() uint16 {
+	return 40
+}
+
+// json api
+func (elm *Action_40_LockblsVestingRevoke) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"lockbls_id":      elm.LockblsId.ToHex(),
+		"revoker_address": elm.RevokerAddress.ToReadable(),
+	}
+	return data
+}
+
+func (elm *Action_40_LockblsVestingRevoke) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var b1, _ = elm.LockblsId.Serialize()
+	var b2, _ = elm.RevokerAddress.Serialize()
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_40_LockblsVestingRevoke) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LockblsId.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RevokerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_40_LockblsVestingRevoke) Size() uint32 {
+	return 2 + elm.LockblsId.Size() + elm.RevokerAddress.Size()
+}
+
+func (act *Action_40_LockblsVestingRevoke) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.RevokerAddress, // 撤销人需要签名
+	}
+}
+
+func (act *Action_40_LockblsVestingRevoke) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	lockbls := readLockbls(state, act.LockblsId)
+	if lockbls == nil {
+		return errs.New(errs.CodeNotFound, "Lockbls id<%s> not find.", hex.EncodeToString(act.LockblsId))
+	}
+	if lockbls.IsVesting.Is(false) {
+		return errs.New(errs.CodeStatusConflict, "Lockbls id<%s> is not vesting.", hex.EncodeToString(act.LockblsId))
+	}
+	revoker := lockbls.Vesting.RevokerAddress
+	if revoker.Exist.Is(false) {
+		return errs.New(errs.CodeStatusConflict, "Lockbls id<%s> is not revocable.", hex.EncodeToString(act.LockblsId))
+	}
+	if revoker.Addr.NotEqual(act.RevokerAddress) {
+		return errs.New(errs.CodeNotPermitted, "Lockbls id<%s> only can be revoked by %s.", hex.EncodeToString(act.LockblsId), revoker.Addr.ToReadable())
+	}
+	if lockbls.Vesting.IsRevoked() {
+		return errs.New(errs.CodeStatusConflict, "Lockbls id<%s> has been revoked.", hex.EncodeToString(act.LockblsId))
+	}
+
+	// 计算未归属额度
+	currentBlockHeight := state.GetPendingBlockHeight()
+	unvested, e1 := lockbls.UnvestedAt(currentBlockHeight)
+	if e1 != nil {
+		return e1
+	}
+	if !unvested.IsPositive() {
+		return errs.New(errs.CodeStatusConflict, "Lockbls id<%s> has been fully vested.", hex.EncodeToString(act.LockblsId))
+	}
+	// 总额和余额都减去未归属部分
+	newTotal, e2 := lockbls.TotalLockAmount.Sub(unvested)
+	if e2 != nil {
+		return e2
+	}
+	newBalance, e3 := lockbls.BalanceAmount.Sub(unvested)
+	if e3 != nil {
+		return e3
+	}
+	if newBalance.IsNegative() {
+		return errs.New(errs.CodeInsufficientBalance, "Lockbls BalanceAmount not enough.")
+	}
+	lockbls.TotalLockAmount = *newTotal
+	lockbls.BalanceAmount = *newBalance
+	lockbls.Vesting.RevokedBlockHeight = fields.BlockHeight(currentBlockHeight)
+	lockbls.Vesting.RevokedAmount = *unvested
+	e4 := updateLockblsVesting(state, act.LockblsId, lockbls)
+	if e4 != nil {
+		return e4
+	}
+	// 退回撤销人
+	e5 := DoAddBalanceFromChainState(state, act.RevokerAddress, *unvested)
+	if e5 != nil {
+		return e5
+	}
+	// total supply 统计
+	totalsupply, e6 := state.ReadTotalSupply()
+	if e6 != nil {
+		return e6
+	}
+	totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfLocatedHACInVestingLockbls, unvested)
+	totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfVestingLockblsRevokedAmount, unvested)
+	return state.UpdateSetTotalSupply(totalsupply)
+}
+
+func (act *Action_40_LockblsVestingRevoke) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	lockbls := readLockbls(state, act.LockblsId)
+	if lockbls == nil || lockbls.IsVesting.Is(false) {
+		return errs.New(errs.CodeNotFound, "Lockbls id<%s> not find.", hex.EncodeToString(act.LockblsId))
+	}
+	unvested := lockbls.Vesting.RevokedAmount
+	// 回退总额和余额
+	oldTotal, e1 := lockbls.TotalLockAmount.Add(&unvested)
+	if e1 != nil {
+		return e1
+	}
+	oldBalance, e2 := lockbls.BalanceAmount.Add(&unvested)
+	if e2 != nil {
+		return e2
+	}
+	lockbls.TotalLockAmount = *oldTotal
+	lockbls.BalanceAmount = *oldBalance
+	lockbls.Vesting.RevokedBlockHeight = 0
+	lockbls.Vesting.RevokedAmount = fields.NewEmptyAmountValue()
+	e3 := updateLockblsVesting(state, act.LockblsId, lockbls)
+	if e3 != nil {
+		return e3
+	}
+	// 回退余额
+	DoSubBalanceFromChainState(state, act.RevokerAddress, unvested)
+	// total supply 统计
+	totalsupply, e3 := state.ReadTotalSupply()
+	if e3 != nil {
+		return e3
+	}
+	totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfLocatedHACInVestingLockbls, &unvested)
+	totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfVestingLockblsRevokedAmount, &unvested)
+	return state.UpdateSetTotalSupply(totalsupply)
+}
+
+// 设置所属 belong_trs
+func (act *Action_40_LockblsVestingRevoke) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_40_LockblsVestingRevoke) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+// 读取锁仓，归属锁仓扩展保存在单独的储存空间，一并载入
+func readLockbls(state interfaces.ChainStateOperation, id fields.LockblsId) *stores.Lockbls {
+	lockbls := state.Lockbls(id)
+	if lockbls == nil {
+		return nil
+	}
+	lockbls.IsVesting = fields.CreateBool(false)
+	if vesting := state.LockblsVesting(id); vesting != nil {
+		lockbls.IsVesting.Set(true)
+		lockbls.Vesting = *vesting
+	}
+	return lockbls
+}
+
+// 更新归属锁仓：锁仓数额和归属扩展分别保存
+func updateLockblsVesting(state interfaces.ChainStateOperation, id fields.LockblsId, lockbls *stores.Lockbls) error {
+	e := state.LockblsUpdate(id, lockbls)
+	if e != nil {
+		return e
+	}
+	return state.LockblsVestingSet(id, &lockbls.Vesting)
+}
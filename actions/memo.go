@@ -0,0 +1,194 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/sys"
+)
+
+/**
+ * 交易备注（充值标签、付款说明等）
+ * 备注内容随 action 一起序列化，所以包含在签名哈希之内，
+ * 备注占用的字节也计入交易 Size() 从而由 FeePurity() 计价
+ */
+
+const (
+	TransactionMemoTypePlaintext fields.VarUint1 = 0 // 明文备注
+	TransactionMemoTypeEncrypted fields.VarUint1 = 1 // ECIES 加密给收款方的备注
+
+	TransactionMemoPlaintextMaxLength = 128 // 明文备注最大字节数
+	TransactionMemoEncryptedMaxLength = 255 // 密文备注最大字节数，明文最多约 111 字节
+)
+
+// 交易备注
+type Action_29_TransactionMemo struct {
+	MemoType fields.VarUint1               // 备注类型 0.明文 1.加密
+	Memo     fields.ExtendMessageMaxLen255 // 备注内容
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+// 明文备注
+func NewAction_29_TransactionMemoByPlaintext(memo string) (*Action_29_TransactionMemo, error) {
+	if len(memo) > TransactionMemoPlaintextMaxLength {
+		return nil, errs.New(errs.CodeInvalidParameter, "Memo length cannot over %d.", TransactionMemoPlaintextMaxLength)
+	}
+	return &Action_29_TransactionMemo{
+		MemoType: TransactionMemoTypePlaintext,
+		Memo: fields.ExtendMessageMaxLen255{
+			Count:   fields.VarUint1(len(memo)),
+			Message: []byte(memo),
+		},
+	}, nil
+}
+
+// 使用收款方公钥加密的备注
+func NewAction_29_TransactionMemoByEncrypt(toPublicKey []byte, memo []byte) (*Action_29_TransactionMemo, error) {
+	data, e := account.EncryptByPublicKey(toPublicKey, memo)
+	if e != nil {
+		return nil, e
+	}
+	if len(data) > TransactionMemoEncryptedMaxLength {
+		return nil, errs.New(errs.CodeInvalidParameter, "Encrypted memo length cannot over %d.", TransactionMemoEncryptedMaxLength)
+	}
+	return &Action_29_TransactionMemo{
+		MemoType: TransactionMemoTypeEncrypted,
+		Memo: fields.ExtendMessageMaxLen255{
+			Count:   fields.VarUint1(len(data)),
+			Message: data,
+		},
+	}, nil
+}
+
+func (elm *Action_29_TransactionMemo) Kind() uint16 {
+	return 29
+}
+
+// json api
+func (elm *Action_29_TransactionMemo) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	if elm.MemoType == TransactionMemoTypeEncrypted {
+		data["memo_type"] = "encrypted"
+		data["memo"] = hex.EncodeToString(elm.Memo.Message)
+	} else {
+		data["memo_type"] = "plaintext"
+		data["memo"] = string(elm.Memo.Message)
+	}
+	return data
+}
+
+func (elm *Action_29_TransactionMemo) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	if int(elm.Memo.Count) != len(elm.Memo.Message) {
+		return nil, errs.New(errs.CodeMalformed, "Memo length count error")
+	}
+	var b1, _ = elm.MemoType.Serialize()
+	var b2, _ = elm.Memo.Serialize()
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	buffer.Write(b1)
+	buffer.Write(b2)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_29_TransactionMemo) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.MemoType.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Memo.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_29_TransactionMemo) Size() uint32 {
+	return 2 + elm.MemoType.Size() + elm.Memo.Size()
+}
+
+func (*Action_29_TransactionMemo) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // not sign
+}
+
+func (act *Action_29_TransactionMemo) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+	// 检查备注类型和长度
+	memolen := len(act.Memo.Message)
+	if int(act.Memo.Count) != memolen {
+		return errs.New(errs.CodeMalformed, "Memo length count error.")
+	}
+	if memolen == 0 {
+		return errs.New(errs.CodeInvalidParameter, "Memo cannot be empty.")
+	}
+	switch act.MemoType {
+	case TransactionMemoTypePlaintext:
+		if memolen > TransactionMemoPlaintextMaxLength {
+			return errs.New(errs.CodeInvalidParameter, "Plaintext memo length cannot over %d.", TransactionMemoPlaintextMaxLength)
+		}
+	case TransactionMemoTypeEncrypted:
+		if memolen > TransactionMemoEncryptedMaxLength {
+			return errs.New(errs.CodeInvalidParameter, "Encrypted memo length cannot over %d.", TransactionMemoEncryptedMaxLength)
+		}
+	default:
+		return errs.New(errs.CodeUnsupportedKind, "Memo type <%d> not support.", act.MemoType)
+	}
+	// 一笔交易只能包含一条备注
+	memonum := 0
+	for _, a := range act.belong_trs.GetActions() {
+		if _, ok := a.(*Action_29_TransactionMemo); ok {
+			memonum++
+		}
+	}
+	if memonum > 1 {
+		return errs.New(errs.CodeInvalidParameter, "Transaction can only contain one memo but got %d.", memonum)
+	}
+	// 备注不修改任何状态
+	return nil
+}
+
+func (act *Action_29_TransactionMemo) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+	// 不修改任何状态
+	return nil
+}
+
+// 设置所属 belong_trs
+func (act *Action_29_TransactionMemo) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_29_TransactionMemo) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+// 收款方使用私钥解密备注
+func (act *Action_29_TransactionMemo) DecryptMemo(privateKey []byte) ([]byte, error) {
+	if act.MemoType != TransactionMemoTypeEncrypted {
+		return act.Memo.Message, nil
+	}
+	return account.DecryptByPrivateKey(privateKey, act.Memo.Message)
+}
@@ -3,7 +3,7 @@ package actions
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 )
@@ -73,7 +73,7 @@ func (act *Action_8_SimpleSatoshiTransfer) WriteinChainState(state interfaces.Ch
 
 	if act.Amount <= 0 {
 		// 转账不能为 0 或负
-		return fmt.Errorf("Amount <%d> error.", act.Amount)
+		return errs.New(errs.CodeInvalidParameter, "Amount <%d> error.", act.Amount)
 	}
 	// 转移
 	fromAddress := act.belong_trs.GetAddress()
@@ -174,7 +174,7 @@ func (act *Action_11_FromToSatoshiTransfer) WriteinChainState(state interfaces.C
 
 	if act.Amount <= 0 {
 		// 转账不能为 0 或负
-		return fmt.Errorf("Amount <%d> error.", act.Amount)
+		return errs.New(errs.CodeInvalidParameter, "Amount <%d> error.", act.Amount)
 	}
 
 	// 转移
@@ -267,7 +267,7 @@ func (act *Action_28_FromSatoshiTransfer) WriteinChainState(state interfaces.Cha
 
 	if act.Amount <= 0 {
 		// 转账不能为 0 或负
-		return fmt.Errorf("Amount <%d> error.", act.Amount)
+		return errs.New(errs.CodeInvalidParameter, "Amount <%d> error.", act.Amount)
 	}
 
 	// 转移
@@ -2,7 +2,7 @@ package actions
 
 import (
 	"bytes"
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -11,16 +11,16 @@ import (
 // btc 转账 （amt 单位 聪）
 func DoSimpleSatoshiTransferFromChainState(state interfaces.ChainStateOperation, addr1 fields.Address, addr2 fields.Address, sat fields.Satoshi) error {
 	if sat == 0 {
-		return fmt.Errorf("Satoshi transfer amount is empty") // 不允许转账数量为0
+		return errs.New(errs.CodeInvalidParameter, "Satoshi transfer amount is empty") // 不允许转账数量为0
 	}
 	bls1 := state.Balance(addr1)
 	if bls1 == nil {
-		return fmt.Errorf("Satoshi need %d but empty.", sat)
+		return errs.New(errs.CodeInsufficientSAT, "Satoshi need %d but empty.", sat).WithAddress(addr1)
 	}
 	sat1 := bls1.Satoshi
 	// 检查余额
 	if uint64(sat1) < uint64(sat) {
-		return fmt.Errorf("Address %s satoshi %d not enough, need at least %d.", addr1.ToReadable(), sat1, sat)
+		return errs.New(errs.CodeInsufficientSAT, "satoshi %d not enough, need at least %d.", sat1, sat).WithAddress(addr1)
 	}
 	// 检查自己转给自己
 	if bytes.Compare(addr1, addr2) == 0 {
@@ -72,12 +72,12 @@ func DoSubSatoshiFromChainState(state interfaces.ChainStateOperation, addr field
 	}
 	blssto := state.Balance(addr)
 	if blssto == nil {
-		return fmt.Errorf("address %s satoshi need %d but empty.", addr.ToReadable(), sat)
+		return errs.New(errs.CodeInsufficientSAT, "satoshi need %d but empty.", sat).WithAddress(addr)
 	}
 	basesat := blssto.Satoshi
 	// 检查余额
 	if uint64(basesat) < uint64(sat) {
-		return fmt.Errorf("address %s satoshi %d not enough, need more %d.", addr.ToReadable(), basesat, sat)
+		return errs.New(errs.CodeInsufficientSAT, "satoshi %d not enough, need more %d.", basesat, sat).WithAddress(addr)
 	}
 	newsat := uint64(basesat) - uint64(sat) // 扣除
 	blssto.Satoshi = fields.Satoshi(newsat)
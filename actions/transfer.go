@@ -3,7 +3,7 @@ package actions
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/sys"
@@ -73,7 +73,7 @@ func (act *Action_1_SimpleToTransfer) WriteinChainState(state interfaces.ChainSt
 
 	// check amount value
 	if !act.Amount.IsPositive() {
-		return fmt.Errorf("Amount is not positive.")
+		return errs.New(errs.CodeInvalidParameter, "Amount is not positive.")
 	}
 	// 转移
 	return DoSimpleTransferFromChainState(state, act.belong_trs.GetAddress(), act.ToAddress, act.Amount)
@@ -169,7 +169,7 @@ func (elm *Action_13_FromTransfer) RequestSignAddresses() []fields.Address {
 func (act *Action_13_FromTransfer) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -178,7 +178,7 @@ func (act *Action_13_FromTransfer) WriteinChainState(state interfaces.ChainState
 
 	// check amount value
 	if !act.Amount.IsPositive() {
-		return fmt.Errorf("Amount is not positive.")
+		return errs.New(errs.CodeInvalidParameter, "Amount is not positive.")
 	}
 	// 转移
 	return DoSimpleTransferFromChainState(state, act.FromAddress, act.belong_trs.GetAddress(), act.Amount)
@@ -275,7 +275,7 @@ func (elm *Action_14_FromToTransfer) RequestSignAddresses() []fields.Address {
 func (act *Action_14_FromToTransfer) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -284,7 +284,7 @@ func (act *Action_14_FromToTransfer) WriteinChainState(state interfaces.ChainSta
 
 	// check amount value
 	if !act.Amount.IsPositive() {
-		return fmt.Errorf("Amount is not positive.")
+		return errs.New(errs.CodeInvalidParameter, "Amount is not positive.")
 	}
 	// 转移
 	return DoSimpleTransferFromChainState(state, act.FromAddress, act.ToAddress, act.Amount)
@@ -0,0 +1,277 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/sys"
+	"math/big"
+)
+
+/**
+ * 批量付款：一个付款方（交易主地址）向多个地址同时支付 HAC 和 SAT
+ * 每一笔收款只占用 地址 + 金额 + SAT 的字节，省去重复的 action 头
+ */
+
+const (
+	BatchPayoutTransferMaxRecipients = 1000 // 单个 action 最多收款地址数量
+)
+
+// 批量付款中的一笔收款
+type BatchPayoutTransferItem struct {
+	ToAddress fields.Address          // 收款地址
+	Amount    fields.Amount           // HAC 数额，可以为空
+	Satoshi   fields.SatoshiVariation // SAT 数额，可选
+}
+
+func (elm BatchPayoutTransferItem) Size() uint32 {
+	return elm.ToAddress.Size() + elm.Amount.Size() + elm.Satoshi.Size()
+}
+
+func (elm BatchPayoutTransferItem) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.ToAddress.Serialize()
+	var b2, _ = elm.Amount.Serialize()
+	var b3, _ = elm.Satoshi.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	return buffer.Bytes(), nil
+}
+
+func (elm *BatchPayoutTransferItem) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.ToAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Amount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Satoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+// 批量付款
+type Action_30_BatchPayoutTransfer struct {
+	RecipientCount fields.VarUint2
+	Recipients     []BatchPayoutTransferItem
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func NewAction_30_BatchPayoutTransfer() *Action_30_BatchPayoutTransfer {
+	return &Action_30_BatchPayoutTransfer{
+		RecipientCount: 0,
+		Recipients:     []BatchPayoutTransferItem{},
+	}
+}
+
+// 添加一笔收款
+func (elm *Action_30_BatchPayoutTransfer) AppendRecipient(addr fields.Address, amt *fields.Amount, sat fields.Satoshi) error {
+	if len(elm.Recipients) >= BatchPayoutTransferMaxRecipients {
+		return errs.New(errs.CodeInvalidParameter, "Recipients quantity cannot over %d", BatchPayoutTransferMaxRecipients)
+	}
+	if amt == nil {
+		amt = fields.NewEmptyAmount()
+	}
+	elm.Recipients = append(elm.Recipients, BatchPayoutTransferItem{
+		ToAddress: addr,
+		Amount:    *amt,
+		Satoshi:   sat.GetSatoshiVariation(),
+	})
+	elm.RecipientCount = fields.VarUint2(len(elm.Recipients))
+	return nil
+}
+
+func (elm *Action_30_BatchPayoutTransfer) Kind() uint16 {
+	return 30
+}
+
+// json api
+func (elm *Action_30_BatchPayoutTransfer) Describe() map[string]interface{} {
+	var data = map[string]interface{}{}
+	var list = make([]map[string]interface{}, len(elm.Recipients))
+	for i, v := range elm.Recipients {
+		list[i] = map[string]interface{}{
+			"to":      v.ToAddress.ToReadable(),
+			"amount":  v.Amount.ToFinString(),
+			"satoshi": uint64(v.Satoshi.GetRealSatoshi()),
+		}
+	}
+	data["recipients"] = list
+	return data
+}
+
+func (elm *Action_30_BatchPayoutTransfer) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	if int(elm.RecipientCount) != len(elm.Recipients) {
+		return nil, errs.New(errs.CodeMalformed, "Recipients quantity count error")
+	}
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.RecipientCount.Serialize()
+	buffer.Write(b1)
+	for _, v := range elm.Recipients {
+		var bi, e = v.Serialize()
+		if e != nil {
+			return nil, e
+		}
+		buffer.Write(bi)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_30_BatchPayoutTransfer) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.RecipientCount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if int(elm.RecipientCount) > BatchPayoutTransferMaxRecipients {
+		return 0, errs.New(errs.CodeInvalidParameter, "Recipients quantity cannot over %d", BatchPayoutTransferMaxRecipients)
+	}
+	elm.Recipients = make([]BatchPayoutTransferItem, int(elm.RecipientCount))
+	for i := 0; i < int(elm.RecipientCount); i++ {
+		seek, e = elm.Recipients[i].Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+func (elm *Action_30_BatchPayoutTransfer) Size() uint32 {
+	size := 2 + elm.RecipientCount.Size()
+	for _, v := range elm.Recipients {
+		size += v.Size()
+	}
+	return size
+}
+
+func (*Action_30_BatchPayoutTransfer) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // not sign
+}
+
+func (act *Action_30_BatchPayoutTransfer) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	payaddr := act.belong_trs.GetAddress()
+
+	// 数量检查
+	rcpnum := int(act.RecipientCount)
+	if rcpnum == 0 || rcpnum != len(act.Recipients) {
+		return errs.New(errs.CodeMalformed, "Recipients quantity error")
+	}
+	if rcpnum > BatchPayoutTransferMaxRecipients {
+		return errs.New(errs.CodeInvalidParameter, "Recipients quantity cannot over %d", BatchPayoutTransferMaxRecipients)
+	}
+
+	// 先检查每一笔并统计总额，余额不足则整体失败，不会只执行一部分
+	totalhac := big.NewInt(0)
+	totalsat := uint64(0)
+	for i, v := range act.Recipients {
+		hashac := v.Amount.IsNotEmpty()
+		hassat := v.Satoshi.GetRealSatoshi() > 0
+		if hashac && !v.Amount.IsPositive() {
+			return errs.New(errs.CodeInvalidParameter, "Recipient %d amount is not positive.", i)
+		}
+		if !hashac && !hassat {
+			return errs.New(errs.CodeInvalidParameter, "Recipient %d amount and satoshi cannot be empty at the same time.", i)
+		}
+		if v.ToAddress.Equal(payaddr) {
+			return errs.New(errs.CodeInvalidParameter, "Recipient %d cannot transfer to self.", i)
+		}
+		if hashac {
+			totalhac = totalhac.Add(totalhac, v.Amount.GetValue())
+		}
+		if hassat {
+			addsat := uint64(v.Satoshi.GetRealSatoshi())
+			if totalsat+addsat < totalsat {
+				return errs.New(errs.CodeInvalidParameter, "Total satoshi overflow.")
+			}
+			totalsat += addsat
+		}
+	}
+	paybls := state.Balance(payaddr)
+	if paybls == nil {
+		return errs.New(errs.CodeInsufficientBalance, "Balance not find.")
+	}
+	if paybls.Hacash.GetValue().Cmp(totalhac) == -1 {
+		return errs.New(errs.CodeInsufficientBalance, "address %s balance %s not enough.", payaddr.ToReadable(), paybls.Hacash.ToFinString())
+	}
+	if uint64(paybls.Satoshi) < totalsat {
+		return errs.New(errs.CodeInsufficientSAT, "address %s satoshi %d not enough, need at least %d.", payaddr.ToReadable(), paybls.Satoshi, totalsat)
+	}
+
+	// 依次转账
+	for _, v := range act.Recipients {
+		if v.Amount.IsNotEmpty() {
+			e := DoSimpleTransferFromChainState(state, payaddr, v.ToAddress, v.Amount)
+			if e != nil {
+				return e
+			}
+		}
+		if sat := v.Satoshi.GetRealSatoshi(); sat > 0 {
+			e := DoSimpleSatoshiTransferFromChainState(state, payaddr, v.ToAddress, sat)
+			if e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (act *Action_30_BatchPayoutTransfer) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+	payaddr := act.belong_trs.GetAddress()
+	// 倒序回退
+	for i := len(act.Recipients) - 1; i >= 0; i-- {
+		v := act.Recipients[i]
+		if sat := v.Satoshi.GetRealSatoshi(); sat > 0 {
+			e := DoSimpleSatoshiTransferFromChainState(state, v.ToAddress, payaddr, sat)
+			if e != nil {
+				return e
+			}
+		}
+		if v.Amount.IsNotEmpty() {
+			e := DoSimpleTransferFromChainState(state, v.ToAddress, payaddr, v.Amount)
+			if e != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// 设置所属 belong_trs
+func (act *Action_30_BatchPayoutTransfer) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_30_BatchPayoutTransfer) IsBurning90PersentTxFees() bool {
+	return false
+}
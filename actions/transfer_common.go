@@ -1,7 +1,7 @@
 package actions
 
 import (
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -47,7 +47,7 @@ func DoSimpleTransferFromChainState(state interfaces.ChainStateOperation, addr1
 	if bls1 == nil {
 		// test
 		//fmt.Println( addr1.ToReadable(), "Balance ", amt.ToFinString(), " not find." )
-		return fmt.Errorf("Balance not find.")
+		return errs.New(errs.CodeInsufficientBalance, "Balance not find.").WithAddress(addr1)
 	}
 	amt1 := bls1.Hacash
 	//fmt.Println("amt1: " + amt1.ToFinString())
@@ -55,7 +55,7 @@ func DoSimpleTransferFromChainState(state interfaces.ChainStateOperation, addr1
 		//x, _ := amt.Sub(&amt1)
 		//print_xxxxxxx(addr1, x)
 		//fmt.Println("[balance not enough]", "addr1: ", addr1.ToReadable(), "amt: " + amt.ToFinString(), "amt1: " + amt1.ToFinString())
-		return fmt.Errorf("address %s balance %s not enough， need %s.", addr1.ToReadable(), amt1.ToFinString(), amt.ToFinString())
+		return errs.New(errs.CodeInsufficientBalance, "balance %s not enough, need %s.", amt1.ToFinString(), amt.ToFinString()).WithAddress(addr1)
 	}
 	// 判断是否为自己转给自己
 	if isTrsToMySelf {
@@ -89,7 +89,7 @@ func DoSimpleTransferFromChainState(state interfaces.ChainStateOperation, addr1
 		return ederr2
 	}
 	if ischg1 || ischg2 {
-		return fmt.Errorf("amount can not to store")
+		return errs.New(errs.CodeInvalidParameter, "amount can not to store")
 	}
 	amtsub = amtsub_1
 	amtadd = amtadd_1
@@ -129,7 +129,7 @@ func DoAddBalanceFromChainState(state interfaces.ChainStateOperation, addr field
 		return ec1
 	}
 	if ischg {
-		return fmt.Errorf("amount can not to store")
+		return errs.New(errs.CodeInvalidParameter, "amount can not to store")
 	}
 	//addrrr, _ := base58check.Encode(addr)
 	//fmt.Println( "DoAddBalanceFromChainState: ++++++++++ ", addr.ToReadable(), amtsave.ToFinString() )
@@ -145,7 +145,7 @@ func DoAddBalanceFromChainState(state interfaces.ChainStateOperation, addr field
 func DoSubBalanceFromChainState(state interfaces.ChainStateOperation, addr fields.Address, amt fields.Amount) error {
 	blssto := state.Balance(addr)
 	if blssto == nil {
-		return fmt.Errorf("address %s amount need %s not enough.", addr.ToReadable(), amt.ToFinString())
+		return errs.New(errs.CodeInsufficientBalance, "amount need %s not enough.", amt.ToFinString()).WithAddress(addr)
 	}
 	baseamt := blssto.Hacash
 	//fmt.Println("baseamt: " + baseamt.ToFinString())
@@ -153,7 +153,7 @@ func DoSubBalanceFromChainState(state interfaces.ChainStateOperation, addr field
 		//x, _ := amt.Sub(&baseamt)
 		//print_xxxxxxx(addr, x)
 		//fmt.Println("[balance not enough]", "block height: 0", "addr: ", addr.ToReadable(), "baseamt: " + baseamt.ToFinString(), "amt: " + amt.ToFinString())
-		return fmt.Errorf("address %s balance %s not enough， need %s.", addr.ToReadable(), baseamt.ToFinString(), amt.ToFinString())
+		return errs.New(errs.CodeInsufficientBalance, "balance %s not enough, need %s.", baseamt.ToFinString(), amt.ToFinString()).WithAddress(addr)
 	}
 	//fmt.Println("amt fee: " + amt.ToFinString())
 	amtnew, e1 := baseamt.Sub(&amt)
@@ -165,7 +165,7 @@ func DoSubBalanceFromChainState(state interfaces.ChainStateOperation, addr field
 		return ec1
 	}
 	if ischg {
-		return fmt.Errorf("amount can not to store")
+		return errs.New(errs.CodeInvalidParameter, "amount can not to store")
 	}
 	//fmt.Println("amtnew1: " + amtnew1.ToFinString())
 	blssto.Hacash = *amtnew1
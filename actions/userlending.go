@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -178,7 +178,7 @@ func (act *Action_19_UsersLendingCreate) RequestSignAddresses() []fields.Address
 func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -187,22 +187,22 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 
 	// 不能自己借给自己
 	if act.MortgagorAddress.Equal(act.LenderAddress) {
-		return fmt.Errorf("Cannot lending to myself.")
+		return errs.New(errs.CodeInvalidParameter, "Cannot lending to myself.")
 	}
 
 	// 检查数额长度
 	if len(act.LoanTotalAmount.Numeral) > 4 {
-		return fmt.Errorf("Amount <%s> byte length is too long.", act.LoanTotalAmount.ToFinString())
+		return errs.New(errs.CodeMalformed, "Amount <%s> byte length is too long.", act.LoanTotalAmount.ToFinString())
 	}
 	if len(act.AgreedRedemptionAmount.Numeral) > 4 {
-		return fmt.Errorf("Amount <%s> byte length is too long.", act.AgreedRedemptionAmount.ToFinString())
+		return errs.New(errs.CodeMalformed, "Amount <%s> byte length is too long.", act.AgreedRedemptionAmount.ToFinString())
 	}
 	if len(act.PreBurningInterestAmount.Numeral) > 4 {
-		return fmt.Errorf("Amount <%s> byte length is too long.", act.PreBurningInterestAmount.ToFinString())
+		return errs.New(errs.CodeMalformed, "Amount <%s> byte length is too long.", act.PreBurningInterestAmount.ToFinString())
 	}
 	// 借贷数额不能为空
 	if act.LoanTotalAmount.IsEmpty() || act.AgreedRedemptionAmount.IsEmpty() || act.PreBurningInterestAmount.IsEmpty() {
-		return fmt.Errorf("Amount cannot be empty.")
+		return errs.New(errs.CodeInvalidParameter, "Amount cannot be empty.")
 	}
 
 	// 区块
@@ -212,13 +212,13 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 	if len(act.LendingID) != stores.UserLendingIdLength ||
 		act.LendingID[0] == 0 ||
 		act.LendingID[stores.UserLendingIdLength-1] == 0 {
-		return fmt.Errorf("Diamond Lending Id format error.")
+		return errs.New(errs.CodeMalformed, "Diamond Lending Id format error.")
 	}
 
 	// 查询id是否存在
 	usrlendObj := state.UserLending(act.LendingID)
 	if usrlendObj != nil {
-		return fmt.Errorf("User Lending <%s> already exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeAlreadyExists, "User Lending already exist.").WithID(act.LendingID)
 	}
 
 	// 钻石数量检查
@@ -226,7 +226,7 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 
 	// 检查抵押物数量
 	if act.MortgageBitcoin.NotEmpty.Is(false) && dianum == 0 {
-		return fmt.Errorf("Mortgage diamond and bitcoin cannot be empty at the same time")
+		return errs.New(errs.CodeInvalidParameter, "Mortgage diamond and bitcoin cannot be empty at the same time")
 	}
 
 	// 检查赎回期限高度
@@ -236,14 +236,14 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 	}
 	if uint64(act.AgreedExpireBlockHeight) < effectiveExpireBlockHeight {
 		// 约定赎回期至少在288个区块以后
-		return fmt.Errorf("AgreedExpireBlockHeight %d is too short, must over than %d.", act.AgreedExpireBlockHeight, effectiveExpireBlockHeight)
+		return errs.New(errs.CodeInvalidParameter, "AgreedExpireBlockHeight %d is too short, must over than %d.", act.AgreedExpireBlockHeight, effectiveExpireBlockHeight)
 	}
 
 	if dianum != len(act.MortgageDiamondList.Diamonds) {
-		return fmt.Errorf("Diamonds quantity error")
+		return errs.New(errs.CodeMalformed, "Diamonds quantity error")
 	}
 	if dianum > 200 {
-		return fmt.Errorf("Diamonds quantity cannot over 200")
+		return errs.New(errs.CodeInvalidParameter, "Diamonds quantity cannot over 200")
 	}
 
 	// 批量抵押钻石
@@ -252,15 +252,15 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 		// 查询钻石是否存在
 		diaitem := state.Diamond(diamond)
 		if diaitem == nil {
-			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+			return errs.New(errs.CodeNotFound, "Diamond <%s> not find.", string(diamond))
 		}
 		// 检查钻石所属地址
 		if diaitem.Address.NotEqual(act.MortgagorAddress) {
-			return fmt.Errorf("Diamond <%s> not belong to address '%s'", string(diamond), act.MortgagorAddress.ToReadable())
+			return errs.New(errs.CodeNotPermitted, "Diamond <%s> not belong to address '%s'", string(diamond), act.MortgagorAddress.ToReadable())
 		}
 		// 检查是否已经抵押，是否可以抵押
 		if diaitem.Status != stores.DiamondStatusNormal {
-			return fmt.Errorf("Diamond <%s> has been mortgaged.", string(diamond))
+			return errs.New(errs.CodeStatusConflict, "Diamond <%s> has been mortgaged.", string(diamond))
 		}
 		// 标记抵押钻石
 		diaitem.Status = stores.DiamondStatusLendingOtherUser // 标记抵押给其它用户
@@ -271,7 +271,12 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 	}
 
 	// 减少抵押人钻石余额
-	e9 := DoSubDiamondFromChainState(state, act.MortgagorAddress, fields.DiamondNumber(dianum))
+	e9 := DoSubDiamondFromChainState(state, act.MortgagorAddress, act.MortgageDiamondList.Diamonds)
+	if e9 != nil {
+		return e9
+	}
+	// 流转历史：抵押给放款人
+	e9 = doAppendDiamondHistory(state, act.belong_trs, act.Kind(), act.MortgagorAddress, act.LenderAddress, act.MortgageDiamondList.Diamonds)
 	if e9 != nil {
 		return e9
 	}
@@ -292,7 +297,7 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 	}
 	if act.PreBurningInterestAmount.LessThan(mustBurnDesk) {
 		// 销毁利息不能少于借贷数额的 1%
-		return fmt.Errorf("PreBurningInterestAmount <%s> can not less than <%s>", act.PreBurningInterestAmount.ToFinString(), mustBurnDesk.ToFinString())
+		return errs.New(errs.CodeInvalidParameter, "PreBurningInterestAmount <%s> can not less than <%s>", act.PreBurningInterestAmount.ToFinString(), mustBurnDesk.ToFinString())
 	}
 
 	// 销毁利息，由放款人支付
@@ -334,28 +339,28 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 	}
 	// 增加钻石借贷数量流水
 	if dianum > 0 {
-		totalsupply.DoAdd(
+		totalsupply.DoAddCount(
 			stores.TotalSupplyStoreTypeOfUsersLendingCumulationDiamond,
-			float64(dianum),
+			uint64(dianum),
 		)
 	}
 	// 增加比特币借贷数量流水
 	if act.MortgageBitcoin.NotEmpty.Check() {
-		totalsupply.DoAdd(
+		totalsupply.DoAddCount(
 			stores.TotalSupplyStoreTypeOfUsersLendingCumulationBitcoin,
-			float64(act.MortgageBitcoin.ValueSAT),
+			uint64(act.MortgageBitcoin.ValueSAT),
 		)
 
 	}
 	// 用户间借贷额 HAC 流水
-	totalsupply.DoAdd(
+	totalsupply.DoAddAmount(
 		stores.TotalSupplyStoreTypeOfUsersLendingCumulationHacAmount,
-		act.LoanTotalAmount.ToMei(),
+		&act.LoanTotalAmount,
 	)
 	// 预先销毁 1% 利息累计
-	totalsupply.DoAdd(
+	totalsupply.DoAddAmount(
 		stores.TotalSupplyStoreTypeOfUsersLendingBurningOnePercentInterestHacAmount,
-		act.PreBurningInterestAmount.ToMei(),
+		&act.PreBurningInterestAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -370,7 +375,7 @@ func (act *Action_19_UsersLendingCreate) WriteinChainState(state interfaces.Chai
 func (act *Action_19_UsersLendingCreate) RecoverChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -383,7 +388,7 @@ func (act *Action_19_UsersLendingCreate) RecoverChainState(state interfaces.Chai
 	// 查询id是否存在
 	usrlendObj := state.UserLending(act.LendingID)
 	if usrlendObj == nil {
-		return fmt.Errorf("User Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeNotFound, "User Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 回退 批量抵押钻石
@@ -398,7 +403,8 @@ func (act *Action_19_UsersLendingCreate) RecoverChainState(state interfaces.Chai
 
 	// 回退 钻石余额 增加
 	if dianum > 0 {
-		DoAddDiamondFromChainState(state, act.MortgagorAddress, fields.DiamondNumber(dianum))
+		DoAddDiamondFromChainState(state, act.MortgagorAddress, act.MortgageDiamondList.Diamonds)
+		doDropDiamondHistory(state, act.belong_trs, act.Kind(), act.MortgageDiamondList.Diamonds)
 	}
 
 	// 回退  扣除比特币 增加
@@ -422,28 +428,28 @@ func (act *Action_19_UsersLendingCreate) RecoverChainState(state interfaces.Chai
 	}
 	// 回退扣除  增加钻石借贷数量流水 扣除
 	if dianum > 0 {
-		totalsupply.DoSub(
+		totalsupply.DoSubCount(
 			stores.TotalSupplyStoreTypeOfUsersLendingCumulationDiamond,
-			float64(dianum),
+			uint64(dianum),
 		)
 	}
 	// 回退扣除   增加比特币借贷数量流水
 	if act.MortgageBitcoin.NotEmpty.Check() {
-		totalsupply.DoSub(
+		totalsupply.DoSubCount(
 			stores.TotalSupplyStoreTypeOfUsersLendingCumulationBitcoin,
-			float64(act.MortgageBitcoin.ValueSAT),
+			uint64(act.MortgageBitcoin.ValueSAT),
 		)
 
 	}
 	// 回退扣除   用户间借贷额流水
-	totalsupply.DoSub(
+	totalsupply.DoSubAmount(
 		stores.TotalSupplyStoreTypeOfUsersLendingCumulationHacAmount,
-		act.LoanTotalAmount.ToMei(),
+		&act.LoanTotalAmount,
 	)
 	// 回退扣除   预先销毁 1% 利息累计
-	totalsupply.DoSub(
+	totalsupply.DoSubAmount(
 		stores.TotalSupplyStoreTypeOfUsersLendingBurningOnePercentInterestHacAmount,
-		act.PreBurningInterestAmount.ToMei(),
+		&act.PreBurningInterestAmount,
 	)
 	// 更新统计
 	e21 := state.UpdateSetTotalSupply(totalsupply)
@@ -545,7 +551,7 @@ func (*Action_20_UsersLendingRansom) RequestSignAddresses() []fields.Address {
 func (act *Action_20_UsersLendingRansom) WriteinChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -559,19 +565,19 @@ func (act *Action_20_UsersLendingRansom) WriteinChainState(state interfaces.Chai
 	if len(act.LendingID) != stores.UserLendingIdLength ||
 		act.LendingID[0] == 0 ||
 		act.LendingID[stores.UserLendingIdLength-1] == 0 {
-		return fmt.Errorf("User Lending Id format error.")
+		return errs.New(errs.CodeMalformed, "User Lending Id format error.")
 	}
 
 	// 查询id是否存在
 	usrlendObj := state.UserLending(act.LendingID)
 	if usrlendObj == nil {
-		return fmt.Errorf("User Lending <%s> not exist.", act.LendingID.ToHex())
+		return errs.New(errs.CodeNotFound, "User Lending <%s> not exist.", act.LendingID.ToHex())
 	}
 
 	// 检查是否赎回状态
 	if usrlendObj.IsRansomed.Check() {
 		// 已经赎回。不可再次赎回
-		return fmt.Errorf("User Lending <%s> has been redeemed.", act.LendingID.ToHex())
+		return errs.New(errs.CodeStatusConflict, "User Lending <%s> has been redeemed.", act.LendingID.ToHex())
 	}
 
 	// 赎回人类型
@@ -584,12 +590,12 @@ func (act *Action_20_UsersLendingRansom) WriteinChainState(state interfaces.Chai
 	// 未开启公开赎回，则第三方不能赎回
 	if usrlendObj.IsPublicRedeemable.Is(false) && isPublicDoRedeem {
 		// 公共赎回关闭
-		return fmt.Errorf("Public redeemable not open.")
+		return errs.New(errs.CodeStatusConflict, "Public redeemable not open.")
 	}
 
 	// 抵押期内非抵押人不能赎回
 	if isWithinMortgageTime && !isMortgagorDoRedeem {
-		return fmt.Errorf("only %s can do redeem before height %d.", usrlendObj.MortgagorAddress.ToReadable(), usrlendObj.ExpireBlockHeight)
+		return errs.New(errs.CodeNotPermitted, "only %s can do redeem before height %d.", usrlendObj.MortgagorAddress.ToReadable(), usrlendObj.ExpireBlockHeight)
 	}
 
 	// 超出抵押期限
@@ -599,17 +605,17 @@ func (act *Action_20_UsersLendingRansom) WriteinChainState(state interfaces.Chai
 		usrlendObj.IsRedemptionOvertime.Is(false) &&
 		isMortgagorDoRedeem {
 		// 抵押期外，没有约定自动展期和公共可赎回，则抵押人不能赎回
-		return fmt.Errorf("only %s can do redeem after height %d.", usrlendObj.LenderAddress.ToReadable(), usrlendObj.ExpireBlockHeight)
+		return errs.New(errs.CodeNotPermitted, "only %s can do redeem after height %d.", usrlendObj.LenderAddress.ToReadable(), usrlendObj.ExpireBlockHeight)
 	}
 
 	// 放款人扣留抵押品，赎回金额必须为零
 	if isLendersDoRedeem && act.RansomAmount.IsNotEmpty() {
-		return fmt.Errorf("Ransom amount must be zore but got %s with lender address %s do redeem", act.RansomAmount.ToFinString(), usrlendObj.LenderAddress.ToReadable())
+		return errs.New(errs.CodeInvalidParameter, "Ransom amount must be zore but got %s with lender address %s do redeem", act.RansomAmount.ToFinString(), usrlendObj.LenderAddress.ToReadable())
 	}
 
 	// 非放款人扣留，抵押人或第三方赎回，则检查赎回金额
 	if !isLendersDoRedeem && act.RansomAmount.LessThan(&usrlendObj.AgreedRedemptionAmount) {
-		return fmt.Errorf("Ransom amount cannot less than %s but got %s.", usrlendObj.AgreedRedemptionAmount.ToFinString(), act.RansomAmount.ToFinString())
+		return errs.New(errs.CodeInvalidParameter, "Ransom amount cannot less than %s but got %s.", usrlendObj.AgreedRedemptionAmount.ToFinString(), act.RansomAmount.ToFinString())
 	}
 
 	// 支付赎金
@@ -634,15 +640,15 @@ func (act *Action_20_UsersLendingRansom) WriteinChainState(state interfaces.Chai
 		// 查询钻石是否存在
 		diaitem := state.Diamond(diamond)
 		if diaitem == nil {
-			return fmt.Errorf("diamond <%s> not find.", string(diamond))
+			return errs.New(errs.CodeNotFound, "diamond <%s> not find.", string(diamond))
 		}
 		// 检查钻石归属地址
 		if diaitem.Address.NotEqual(usrlendObj.MortgagorAddress) {
-			return fmt.Errorf("diamond <%s> not belong to address %s", usrlendObj.MortgagorAddress.ToReadable())
+			return errs.New(errs.CodeNotPermitted, "diamond <%s> not belong to address %s", string(diamond), usrlendObj.MortgagorAddress.ToReadable())
 		}
 		// 检查钻石状态
 		if diaitem.Status != stores.DiamondStatusLendingOtherUser {
-			return fmt.Errorf("diamond <%s> status is not [stores.DiamondStatusLendingOtherUser].", string(diamond))
+			return errs.New(errs.CodeStatusConflict, "diamond <%s> status is not [stores.DiamondStatusLendingOtherUser].", string(diamond))
 		}
 		// 标记钻石
 		diaitem.Status = stores.DiamondStatusNormal // 赎回钻石状态
@@ -655,7 +661,12 @@ func (act *Action_20_UsersLendingRansom) WriteinChainState(state interfaces.Chai
 
 	// 增加钻石余额（赎回人或放款人）
 	if dianum > 0 {
-		e9 := DoAddDiamondFromChainState(state, feeAddr, fields.DiamondNumber(dianum))
+		e9 := DoAddDiamondFromChainState(state, feeAddr, usrlendObj.MortgageDiamondList.Diamonds)
+		if e9 != nil {
+			return e9
+		}
+		// 流转历史：赎回或扣押，与部分还款释放一致，从出借人释放给取回人
+		e9 = doAppendDiamondHistory(state, act.belong_trs, act.Kind(), usrlendObj.LenderAddress, feeAddr, usrlendObj.MortgageDiamondList.Diamonds)
 		if e9 != nil {
 			return e9
 		}
@@ -686,7 +697,7 @@ func (act *Action_20_UsersLendingRansom) WriteinChainState(state interfaces.Chai
 func (act *Action_20_UsersLendingRansom) RecoverChainState(state interfaces.ChainStateOperation) error {
 
 	if !sys.TestDebugLocalDevelopmentMark {
-		return fmt.Errorf("mainnet not yet") // 暂未启用等待review
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
 	}
 
 	if act.belong_trs == nil {
@@ -698,7 +709,7 @@ func (act *Action_20_UsersLendingRansom) RecoverChainState(state interfaces.Chai
 	// 查询id是否存在
 	usrlendObj := state.UserLending(act.LendingID)
 	if usrlendObj == nil {
-		return fmt.Errorf("User Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
+		return errs.New(errs.CodeNotFound, "User Lending <%s> not exist.", hex.EncodeToString(act.LendingID))
 	}
 
 	// 赎回类型
@@ -728,7 +739,8 @@ func (act *Action_20_UsersLendingRansom) RecoverChainState(state interfaces.Chai
 
 	// 回退 减少钻石余额
 	if dianum > 0 {
-		DoSubDiamondFromChainState(state, feeAddr, fields.DiamondNumber(dianum))
+		DoSubDiamondFromChainState(state, feeAddr, usrlendObj.MortgageDiamondList.Diamonds)
+		doDropDiamondHistory(state, act.belong_trs, act.Kind(), usrlendObj.MortgageDiamondList.Diamonds)
 	}
 
 	// 回退减少比特币余额
@@ -0,0 +1,612 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+	"math/big"
+)
+
+/*
+
+用户间借贷，部分还款流程：
+
+. 检查合约ID格式、合约存在和状态
+. 只有抵押人可以部分还款，且必须处于抵押人可赎回的期间
+. 还款金额必须小于约定的赎回金额（全额赎回使用 Action_20）
+. 按 还款金额 / 约定赎回金额 的比例（向下取整）释放抵押钻石和比特币
+. 释放的钻石必须是抵押钻石表末尾的若干枚，以便回退时恢复原顺序
+
+. 支付还款给放款人
+. 修改释放钻石状态，增加抵押人钻石和比特币余额
+. 减少合约抵押物和约定赎回金额
+. 累计部分还款流水
+
+*/
+
+// 计算按比例释放的数量 floor(total * repay / agreed)
+func userLendingProportionalShare(total uint64, repay, agreed *fields.Amount) uint64 {
+	num := new(big.Int).SetUint64(total)
+	num = num.Mul(num, repay.GetValue())
+	num = num.Div(num, agreed.GetValue())
+	return num.Uint64()
+}
+
+// 用户间借贷，部分还款
+type Action_41_UsersLendingPartialRepay struct {
+	LendingID          fields.UserLendingId        // 借贷合约ID
+	RepayAmount        fields.Amount               // 还款金额
+	ReleaseDiamondList fields.DiamondListMaxLen200 // 释放的钻石（抵押钻石表末尾）
+	ReleaseBitcoin     fields.SatoshiVariation     // 释放的比特币 单位：SAT
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_41_UsersLendingPartialRepay) Kind() uint16 {
+	return 41
+}
+
+// json api
+func (elm *Action_41_UsersLendingPartialRepay) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"lending_id":      elm.LendingID.ToHex(),
+		"repay_amount":    elm.RepayAmount.ToFinString(),
+		"release_diamond": elm.ReleaseDiamondList.SerializeHACDlistToCommaSplitString(),
+		"release_satoshi": uint64(elm.ReleaseBitcoin.GetRealSatoshi()),
+	}
+	return data
+}
+
+func (elm *Action_41_UsersLendingPartialRepay) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.LendingID.Serialize()
+	var b2, _ = elm.RepayAmount.Serialize()
+	var b3, _ = elm.ReleaseDiamondList.Serialize()
+	var b4, _ = elm.ReleaseBitcoin.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_41_UsersLendingPartialRepay) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LendingID.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.RepayAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ReleaseDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ReleaseBitcoin.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_41_UsersLendingPartialRepay) Size() uint32 {
+	return 2 +
+		elm.LendingID.Size() +
+		elm.RepayAmount.Size() +
+		elm.ReleaseDiamondList.Size() +
+		elm.ReleaseBitcoin.Size()
+}
+
+func (*Action_41_UsersLendingPartialRepay) RequestSignAddresses() []fields.Address {
+	return []fields.Address{} // 抵押人为交易主地址，无需另外签名
+}
+
+func (act *Action_41_UsersLendingPartialRepay) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+	feeAddr := act.belong_trs.GetAddress()
+
+	// 检查id格式
+	if len(act.LendingID) != stores.UserLendingIdLength ||
+		act.LendingID[0] == 0 ||
+		act.LendingID[stores.UserLendingIdLength-1] == 0 {
+		return errs.New(errs.CodeMalformed, "User Lending Id format error.")
+	}
+
+	// 检查数额
+	if len(act.RepayAmount.Numeral) > 4 {
+		return errs.New(errs.CodeMalformed, "Amount <%s> byte length is too long.", act.RepayAmount.ToFinString())
+	}
+	if !act.RepayAmount.IsPositive() {
+		return errs.New(errs.CodeInvalidParameter, "Repay amount must be positive.")
+	}
+
+	// 查询id是否存在
+	usrlendObj := state.UserLending(act.LendingID)
+	if usrlendObj == nil {
+		return errs.New(errs.CodeNotFound, "User Lending <%s> not exist.", act.LendingID.ToHex())
+	}
+	if usrlendObj.IsRansomed.Check() {
+		return errs.New(errs.CodeStatusConflict, "User Lending <%s> has been redeemed.", act.LendingID.ToHex())
+	}
+
+	// 只有抵押人可以部分还款
+	if feeAddr.NotEqual(usrlendObj.MortgagorAddress) {
+		return errs.New(errs.CodeNotPermitted, "only %s can do partial repay.", usrlendObj.MortgagorAddress.ToReadable())
+	}
+	// 超期后，未开启自动展期或公共赎回，则抵押人不能还款
+	if paddingHeight > uint64(usrlendObj.ExpireBlockHeight) &&
+		usrlendObj.IsPublicRedeemable.Is(false) &&
+		usrlendObj.IsRedemptionOvertime.Is(false) {
+		return errs.New(errs.CodeExpired, "cannot do partial repay after height %d.", usrlendObj.ExpireBlockHeight)
+	}
+
+	// 部分还款必须小于约定赎回金额
+	if !act.RepayAmount.LessThan(&usrlendObj.AgreedRedemptionAmount) {
+		return errs.New(errs.CodeInvalidParameter, "Repay amount %s must less than agreed redemption amount %s, use ransom action for full redeem.", act.RepayAmount.ToFinString(), usrlendObj.AgreedRedemptionAmount.ToFinString())
+	}
+
+	// 检查释放钻石：数量按比例，且为抵押钻石表末尾
+	mtgdias := usrlendObj.MortgageDiamondList.Diamonds
+	reldianum := len(act.ReleaseDiamondList.Diamonds)
+	if int(act.ReleaseDiamondList.Count) != reldianum {
+		return errs.New(errs.CodeMalformed, "Release diamonds quantity error")
+	}
+	mustdianum := userLendingProportionalShare(uint64(len(mtgdias)), &act.RepayAmount, &usrlendObj.AgreedRedemptionAmount)
+	if uint64(reldianum) != mustdianum {
+		return errs.New(errs.CodeInvalidParameter, "Release diamonds quantity must be %d but got %d.", mustdianum, reldianum)
+	}
+	keepdianum := len(mtgdias) - reldianum
+	for i, diamond := range act.ReleaseDiamondList.Diamonds {
+		if bytes.Compare(diamond, mtgdias[keepdianum+i]) != 0 {
+			return errs.New(errs.CodeInvalidParameter, "Release diamond <%s> must be <%s>.", string(diamond), string(mtgdias[keepdianum+i]))
+		}
+	}
+
+	// 检查释放比特币
+	var mtgsat uint64 = 0
+	if usrlendObj.MortgageBitcoin.NotEmpty.Check() {
+		mtgsat = uint64(usrlendObj.MortgageBitcoin.ValueSAT)
+	}
+	relsat := uint64(act.ReleaseBitcoin.GetRealSatoshi())
+	mustsat := userLendingProportionalShare(mtgsat, &act.RepayAmount, &usrlendObj.AgreedRedemptionAmount)
+	if relsat != mustsat {
+		return errs.New(errs.CodeInvalidParameter, "Release satoshi must be %d but got %d.", mustsat, relsat)
+	}
+
+	// 支付还款给放款人
+	e2 := DoSimpleTransferFromChainState(state, feeAddr, usrlendObj.LenderAddress, act.RepayAmount)
+	if e2 != nil {
+		return e2
+	}
+
+	// 释放钻石
+	for _, diamond := range act.ReleaseDiamondList.Diamonds {
+		diaitem := state.Diamond(diamond)
+		if diaitem == nil {
+			return errs.New(errs.CodeNotFound, "diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Address.NotEqual(usrlendObj.MortgagorAddress) {
+			return errs.New(errs.CodeNotPermitted, "diamond <%s> not belong to address %s", string(diamond), usrlendObj.MortgagorAddress.ToReadable())
+		}
+		if diaitem.Status != stores.DiamondStatusLendingOtherUser {
+			return errs.New(errs.CodeStatusConflict, "diamond <%s> status is not [stores.DiamondStatusLendingOtherUser].", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusNormal // 解除抵押
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	if reldianum > 0 {
+		e9 := DoAddDiamondFromChainState(state, feeAddr, act.ReleaseDiamondList.Diamonds)
+		if e9 != nil {
+			return e9
+		}
+		// 流转历史：从放款人处赎回
+		e13 := doAppendDiamondHistory(state, act.belong_trs, act.Kind(), usrlendObj.LenderAddress, feeAddr, act.ReleaseDiamondList.Diamonds)
+		if e13 != nil {
+			return e13
+		}
+	}
+
+	// 释放比特币
+	if relsat > 0 {
+		e10 := DoAddSatoshiFromChainState(state, feeAddr, fields.Satoshi(relsat))
+		if e10 != nil {
+			return e10
+		}
+	}
+
+	// 修改借贷合约
+	newagreed, e11 := usrlendObj.AgreedRedemptionAmount.Sub(&act.RepayAmount)
+	if e11 != nil {
+		return e11
+	}
+	usrlendObj.AgreedRedemptionAmount = *newagreed
+	usrlendObj.MortgageDiamondList.Diamonds = mtgdias[0:keepdianum]
+	usrlendObj.MortgageDiamondList.Count = fields.VarUint1(keepdianum)
+	if relsat > 0 {
+		usrlendObj.MortgageBitcoin.ValueSAT = fields.Satoshi(mtgsat - relsat)
+		if usrlendObj.MortgageBitcoin.ValueSAT == 0 {
+			usrlendObj.MortgageBitcoin.NotEmpty.Set(false) // 全部释放，与未抵押比特币的合约格式一致
+		}
+	}
+	e12 := state.UserLendingUpdate(act.LendingID, usrlendObj)
+	if e12 != nil {
+		return e12
+	}
+
+	// 累计部分还款流水
+	totalsupply, e20 := state.ReadTotalSupply()
+	if e20 != nil {
+		return e20
+	}
+	totalsupply.DoAddAmount(
+		stores.TotalSupplyStoreTypeOfUsersLendingPartialRepayHacAmount,
+		&act.RepayAmount,
+	)
+	e21 := state.UpdateSetTotalSupply(totalsupply)
+	if e21 != nil {
+		return e21
+	}
+
+	// 完毕
+	return nil
+}
+
+func (act *Action_41_UsersLendingPartialRepay) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	feeAddr := act.belong_trs.GetAddress()
+
+	usrlendObj := state.UserLending(act.LendingID)
+	if usrlendObj == nil {
+		return errs.New(errs.CodeNotFound, "User Lending <%s> not exist.", act.LendingID.ToHex())
+	}
+
+	// 回退还款
+	e2 := DoSimpleTransferFromChainState(state, usrlendObj.LenderAddress, feeAddr, act.RepayAmount)
+	if e2 != nil {
+		return e2
+	}
+
+	// 回退钻石抵押状态
+	for _, diamond := range act.ReleaseDiamondList.Diamonds {
+		diaitem := state.Diamond(diamond)
+		if diaitem == nil {
+			return errs.New(errs.CodeNotFound, "diamond <%s> not find.", string(diamond))
+		}
+		diaitem.Status = stores.DiamondStatusLendingOtherUser
+		e5 := state.DiamondSet(diamond, diaitem)
+		if e5 != nil {
+			return e5
+		}
+	}
+	reldianum := len(act.ReleaseDiamondList.Diamonds)
+	if reldianum > 0 {
+		e9 := DoSubDiamondFromChainState(state, feeAddr, act.ReleaseDiamondList.Diamonds)
+		if e9 != nil {
+			return e9
+		}
+		e13 := doDropDiamondHistory(state, act.belong_trs, act.Kind(), act.ReleaseDiamondList.Diamonds)
+		if e13 != nil {
+			return e13
+		}
+	}
+
+	// 回退比特币
+	relsat := act.ReleaseBitcoin.GetRealSatoshi()
+	if relsat > 0 {
+		e10 := DoSubSatoshiFromChainState(state, feeAddr, relsat)
+		if e10 != nil {
+			return e10
+		}
+		usrlendObj.MortgageBitcoin.NotEmpty.Set(true)
+		usrlendObj.MortgageBitcoin.ValueSAT += relsat
+	}
+
+	// 回退借贷合约
+	oldagreed, e11 := usrlendObj.AgreedRedemptionAmount.Add(&act.RepayAmount)
+	if e11 != nil {
+		return e11
+	}
+	usrlendObj.AgreedRedemptionAmount = *oldagreed
+	usrlendObj.MortgageDiamondList.Diamonds = append(usrlendObj.MortgageDiamondList.Diamonds, act.ReleaseDiamondList.Diamonds...)
+	usrlendObj.MortgageDiamondList.Count = fields.VarUint1(len(usrlendObj.MortgageDiamondList.Diamonds))
+	e12 := state.UserLendingUpdate(act.LendingID, usrlendObj)
+	if e12 != nil {
+		return e12
+	}
+
+	// 回退统计
+	totalsupply, e20 := state.ReadTotalSupply()
+	if e20 != nil {
+		return e20
+	}
+	totalsupply.DoSubAmount(
+		stores.TotalSupplyStoreTypeOfUsersLendingPartialRepayHacAmount,
+		&act.RepayAmount,
+	)
+	return state.UpdateSetTotalSupply(totalsupply)
+}
+
+// 设置所属 belong_trs
+func (act *Action_41_UsersLendingPartialRepay) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_41_UsersLendingPartialRepay) IsBurning90PersentTxFees() bool {
+	return false
+}
+
+/*
+
+用户间借贷，协商展期流程：
+
+. 抵押人和放款人都需要签名
+. 展期前的到期高度和约定赎回金额必须与合约当前值一致（防止签名被重放）
+. 新的到期高度必须晚于原到期高度，且至少在 288 个区块以后
+. 修改合约到期高度和约定赎回金额
+. 累计展期次数
+
+*/
+
+// 用户间借贷，协商展期
+type Action_42_UsersLendingExtend struct {
+	LendingID fields.UserLendingId // 借贷合约ID
+
+	MortgagorAddress fields.Address // 抵押人地址
+	LenderAddress    fields.Address // 放款人地址
+
+	PrevExpireBlockHeight      fields.BlockHeight // 原约定到期高度
+	NewExpireBlockHeight       fields.BlockHeight // 新约定到期高度
+	PrevAgreedRedemptionAmount fields.Amount      // 原约定赎回金额
+	NewAgreedRedemptionAmount  fields.Amount      // 新约定赎回金额
+
+	// data ptr
+	belong_trs interfaces.Transaction
+}
+
+func (elm *Action_42_UsersLendingExtend) Kind() uint16 {
+	return 42
+}
+
+// json api
+func (elm *Action_42_UsersLendingExtend) Describe() map[string]interface{} {
+	var data = map[string]interface{}{
+		"lending_id":             elm.LendingID.ToHex(),
+		"mortgagor":              elm.MortgagorAddress.ToReadable(),
+		"lender":                 elm.LenderAddress.ToReadable(),
+		"prev_expire_height":     uint64(elm.PrevExpireBlockHeight),
+		"new_expire_height":      uint64(elm.NewExpireBlockHeight),
+		"prev_redemption_amount": elm.PrevAgreedRedemptionAmount.ToFinString(),
+		"new_redemption_amount":  elm.NewAgreedRedemptionAmount.ToFinString(),
+	}
+	return data
+}
+
+func (elm *Action_42_UsersLendingExtend) Serialize() ([]byte, error) {
+	var kindByte = make([]byte, 2)
+	binary.BigEndian.PutUint16(kindByte, elm.Kind())
+	var buffer bytes.Buffer
+	buffer.Write(kindByte)
+	var b1, _ = elm.LendingID.Serialize()
+	var b2, _ = elm.MortgagorAddress.Serialize()
+	var b3, _ = elm.LenderAddress.Serialize()
+	var b4, _ = elm.PrevExpireBlockHeight.Serialize()
+	var b5, _ = elm.NewExpireBlockHeight.Serialize()
+	var b6, _ = elm.PrevAgreedRedemptionAmount.Serialize()
+	var b7, _ = elm.NewAgreedRedemptionAmount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	return buffer.Bytes(), nil
+}
+
+func (elm *Action_42_UsersLendingExtend) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.LendingID.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.MortgagorAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LenderAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PrevExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.NewExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PrevAgreedRedemptionAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.NewAgreedRedemptionAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+func (elm *Action_42_UsersLendingExtend) Size() uint32 {
+	return 2 +
+		elm.LendingID.Size() +
+		elm.MortgagorAddress.Size() +
+		elm.LenderAddress.Size() +
+		elm.PrevExpireBlockHeight.Size() +
+		elm.NewExpireBlockHeight.Size() +
+		elm.PrevAgreedRedemptionAmount.Size() +
+		elm.NewAgreedRedemptionAmount.Size()
+}
+
+func (act *Action_42_UsersLendingExtend) RequestSignAddresses() []fields.Address {
+	return []fields.Address{
+		act.MortgagorAddress,
+		act.LenderAddress,
+	} // 抵押人和放款人都需要签名
+}
+
+func (act *Action_42_UsersLendingExtend) WriteinChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	paddingHeight := state.GetPendingBlockHeight()
+
+	// 检查id格式
+	if len(act.LendingID) != stores.UserLendingIdLength ||
+		act.LendingID[0] == 0 ||
+		act.LendingID[stores.UserLendingIdLength-1] == 0 {
+		return errs.New(errs.CodeMalformed, "User Lending Id format error.")
+	}
+
+	// 检查数额
+	if len(act.NewAgreedRedemptionAmount.Numeral) > 4 {
+		return errs.New(errs.CodeMalformed, "Amount <%s> byte length is too long.", act.NewAgreedRedemptionAmount.ToFinString())
+	}
+	if !act.NewAgreedRedemptionAmount.IsPositive() {
+		return errs.New(errs.CodeInvalidParameter, "Agreed redemption amount must be positive.")
+	}
+
+	usrlendObj := state.UserLending(act.LendingID)
+	if usrlendObj == nil {
+		return errs.New(errs.CodeNotFound, "User Lending <%s> not exist.", act.LendingID.ToHex())
+	}
+	if usrlendObj.IsRansomed.Check() {
+		return errs.New(errs.CodeStatusConflict, "User Lending <%s> has been redeemed.", act.LendingID.ToHex())
+	}
+
+	// 检查当事人
+	if act.MortgagorAddress.NotEqual(usrlendObj.MortgagorAddress) ||
+		act.LenderAddress.NotEqual(usrlendObj.LenderAddress) {
+		return errs.New(errs.CodeNotPermitted, "Mortgagor or lender address not match user lending <%s>.", act.LendingID.ToHex())
+	}
+
+	// 原值必须与合约一致
+	if act.PrevExpireBlockHeight != usrlendObj.ExpireBlockHeight {
+		return errs.New(errs.CodeInvalidParameter, "Prev expire block height need %d but got %d.", usrlendObj.ExpireBlockHeight, act.PrevExpireBlockHeight)
+	}
+	if act.PrevAgreedRedemptionAmount.GetValue().Cmp(usrlendObj.AgreedRedemptionAmount.GetValue()) != 0 {
+		return errs.New(errs.CodeInvalidParameter, "Prev agreed redemption amount need %s but got %s.", usrlendObj.AgreedRedemptionAmount.ToFinString(), act.PrevAgreedRedemptionAmount.ToFinString())
+	}
+
+	// 检查新的到期高度
+	if act.NewExpireBlockHeight <= act.PrevExpireBlockHeight {
+		return errs.New(errs.CodeInvalidParameter, "New expire block height %d must over than %d.", act.NewExpireBlockHeight, act.PrevExpireBlockHeight)
+	}
+	effectiveExpireBlockHeight := paddingHeight + 288
+	if sys.TestDebugLocalDevelopmentMark {
+		effectiveExpireBlockHeight = paddingHeight + 10 // 测试环境10个区块
+	}
+	if uint64(act.NewExpireBlockHeight) < effectiveExpireBlockHeight {
+		return errs.New(errs.CodeInvalidParameter, "NewExpireBlockHeight %d is too short, must over than %d.", act.NewExpireBlockHeight, effectiveExpireBlockHeight)
+	}
+
+	// 修改借贷合约
+	usrlendObj.ExpireBlockHeight = act.NewExpireBlockHeight
+	usrlendObj.AgreedRedemptionAmount = act.NewAgreedRedemptionAmount
+	e11 := state.UserLendingUpdate(act.LendingID, usrlendObj)
+	if e11 != nil {
+		return e11
+	}
+
+	// 累计展期次数
+	totalsupply, e20 := state.ReadTotalSupply()
+	if e20 != nil {
+		return e20
+	}
+	totalsupply.DoAddCount(stores.TotalSupplyStoreTypeOfUsersLendingExtendCount, 1)
+	e21 := state.UpdateSetTotalSupply(totalsupply)
+	if e21 != nil {
+		return e21
+	}
+
+	// 完毕
+	return nil
+}
+
+func (act *Action_42_UsersLendingExtend) RecoverChainState(state interfaces.ChainStateOperation) error {
+
+	if !sys.TestDebugLocalDevelopmentMark {
+		return errs.New(errs.CodeNotEnabled, "mainnet not yet") // 暂未启用等待review
+	}
+
+	if act.belong_trs == nil {
+		panic("Action belong to transaction not be nil !")
+	}
+
+	usrlendObj := state.UserLending(act.LendingID)
+	if usrlendObj == nil {
+		return errs.New(errs.CodeNotFound, "User Lending <%s> not exist.", act.LendingID.ToHex())
+	}
+
+	// 回退借贷合约
+	usrlendObj.ExpireBlockHeight = act.PrevExpireBlockHeight
+	usrlendObj.AgreedRedemptionAmount = act.PrevAgreedRedemptionAmount
+	e12 := state.UserLendingUpdate(act.LendingID, usrlendObj)
+	if e12 != nil {
+		return e12
+	}
+
+	// 回退统计
+	totalsupply, e20 := state.ReadTotalSupply()
+	if e20 != nil {
+		return e20
+	}
+	totalsupply.DoSubCount(stores.TotalSupplyStoreTypeOfUsersLendingExtendCount, 1)
+	return state.UpdateSetTotalSupply(totalsupply)
+}
+
+// 设置所属 belong_trs
+func (act *Action_42_UsersLendingExtend) SetBelongTransaction(trs interfaces.Transaction) {
+	act.belong_trs = trs
+}
+
+// burning fees  // 是否销毁本笔交易的 90% 的交易费用
+func (act *Action_42_UsersLendingExtend) IsBurning90PersentTxFees() bool {
+	return false
+}
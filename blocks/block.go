@@ -3,6 +3,7 @@ package blocks
 import (
 	"bytes"
 	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 
@@ -31,12 +32,12 @@ func NewBlockByVersion(ty uint8) (interfaces.Block, error) {
 		return new(Block_v1), nil
 		////////////////////   END   ////////////////////
 	}
-	return nil, fmt.Errorf("Cannot find Block type of " + string(ty))
+	return nil, errs.New(errs.CodeUnsupportedKind, "Cannot find Block type of %d", ty)
 }
 
 func ParseBlock(buf []byte, seek uint32) (interfaces.Block, uint32, error) {
 	if len(buf) < 1 {
-		return nil, 0, fmt.Errorf("buf too short")
+		return nil, 0, errs.New(errs.CodeMalformed, "buf too short")
 	}
 	version := uint8(buf[seek])
 	var blk, e = NewBlockByVersion(version)
@@ -44,12 +45,15 @@ func ParseBlock(buf []byte, seek uint32) (interfaces.Block, uint32, error) {
 		return nil, 0, e
 	}
 	var mv, err = blk.Parse(buf, seek+1)
-	return blk, mv, err
+	if err != nil {
+		return blk, mv, errs.FromOr(errs.CodeMalformed, err)
+	}
+	return blk, mv, nil
 }
 
 func ParseBlockHead(buf []byte, seek uint32) (interfaces.Block, uint32, error) {
 	if int(seek)+1 > len(buf) {
-		return nil, 0, fmt.Errorf("buf too short")
+		return nil, 0, errs.New(errs.CodeMalformed, "buf too short")
 	}
 	version := uint8(buf[seek])
 	var blk, ee = NewBlockByVersion(version)
@@ -62,7 +66,7 @@ func ParseBlockHead(buf []byte, seek uint32) (interfaces.Block, uint32, error) {
 }
 func ParseExcludeTransactions(buf []byte, seek uint32) (interfaces.Block, uint32, error) {
 	if buf == nil || len(buf) < 1 {
-		return nil, 0, fmt.Errorf("buf is too short")
+		return nil, 0, errs.New(errs.CodeMalformed, "buf is too short")
 	}
 	version := uint8(buf[seek])
 	var blk, ee = NewBlockByVersion(version)
@@ -7,6 +7,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/transactions"
@@ -418,6 +420,13 @@ func (block *Block_v1) WriteinChainState(blockstate interfaces.ChainStateOperati
 	txlen := len(block.Transactions)
 	totalfeeuserpay := fields.NewEmptyAmount()
 	totalfeeminergot := fields.NewEmptyAmount()
+	// 启用区块，执行交易之前补建钻石索引
+	if actions.IsDiamondIndexActivateBlock(uint64(block.Height)) {
+		e := actions.DoActivateDiamondIndex(blockstate)
+		if e != nil {
+			return e
+		}
+	}
 	// 第一条交易为coinbase交易，客户交易从第二条开始
 	for i := 1; i < txlen; i++ {
 		tx := block.Transactions[i]
@@ -438,15 +447,15 @@ func (block *Block_v1) WriteinChainState(blockstate interfaces.ChainStateOperati
 	}
 	// coinbase
 	if txlen < 1 {
-		return fmt.Errorf("not find coinbase tx")
+		return errs.New(errs.CodeMalformed, "not find coinbase tx")
 	}
 	tx0 := block.Transactions[0]
 	if tx0.Type() != 0 {
-		return fmt.Errorf("transaction[0] not coinbase tx")
+		return errs.New(errs.CodeMalformed, "transaction[0] not coinbase tx")
 	}
 	coinbase, ok := tx0.(*transactions.Transaction_0_Coinbase)
 	if !ok {
-		return fmt.Errorf("transaction[0] not coinbase tx")
+		return errs.New(errs.CodeMalformed, "transaction[0] not coinbase tx")
 	}
 	coinbase.TotalFeeUserPayed = *totalfeeuserpay      // 支付总手续费
 	coinbase.TotalFeeMinerReceived = *totalfeeminergot // 收到总手续费
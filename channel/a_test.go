@@ -4,9 +4,80 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/fields"
 	"testing"
 )
 
+func newRouteChannel(n byte, left, right *account.Account, lmei, rmei int64, feeunit uint8) *RouteChannel {
+	cid := bytes.Repeat([]byte{n}, 16)
+	return &RouteChannel{
+		ChannelId:    cid,
+		ReuseVersion: 1,
+		AutoNumber:   uint64(n),
+		LeftAddress:  left.Address,
+		RightAddress: right.Address,
+		LeftBalance:  *fields.NewAmountByUnit248(lmei),
+		RightBalance: *fields.NewAmountByUnit248(rmei),
+		Fee:          *fields.NewAmountSmall(1, feeunit),
+	}
+}
+
+func Test_route(t *testing.T) {
+
+	a := account.CreateAccountByPassword("route a")
+	b := account.CreateAccountByPassword("route b")
+	c := account.CreateAccountByPassword("route c")
+	d := account.CreateAccountByPassword("route d")
+
+	graph := NewChannelGraph()
+	graph.AddChannel(newRouteChannel(1, a, b, 10, 10, 244))
+	graph.AddChannel(newRouteChannel(2, c, b, 10, 10, 244)) // 右侧付款
+	graph.AddChannel(newRouteChannel(3, c, d, 10, 10, 244))
+	graph.AddChannel(newRouteChannel(4, a, d, 1, 10, 244))  // 容量不足
+	graph.AddChannel(newRouteChannel(5, b, d, 10, 10, 248)) // 手续费太高
+
+	route, e := graph.FindRoute(a.Address, d.Address, fields.NewAmountByUnit248(5))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(route.Hops) != 3 || route.Hops[1].Channel.ChannelId[0] != 2 {
+		t.Fatal("route path error")
+	}
+	fmt.Println(route.TotalAmount().ToFinString(), route.TotalFee.ToFinString())
+	if route.TotalFee.ToFinString() != "ㄜ2:244" || !route.Hops[0].Fee.IsEmpty() {
+		t.Fatal("route fee error")
+	}
+	_, e = graph.FindRoute(a.Address, d.Address, fields.NewAmountByUnit248(11))
+	if e == nil {
+		t.Fatal("capacity must not enough")
+	}
+
+	// 组装并签名
+	docs, e := route.AssemblePayment(1600000000, nil, a, b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if docs.ChainPayment.CheckMustAddressAndSigns() == nil {
+		t.Fatal("signs must not complete")
+	}
+	docs.ChainPayment.DoSignFillPosition(c)
+	docs.ChainPayment.DoSignFillPosition(d)
+	e = docs.ChainPayment.CheckMustAddressAndSigns()
+	if e != nil {
+		t.Fatal(e)
+	}
+	bills := docs.SplitBills()
+	for _, bill := range bills {
+		if bill.CheckValidity() != nil {
+			t.Fatal("prove body hash not included")
+		}
+	}
+	if bills[1].ChannelChainTransferTargetProveBody.PayDirection != 2 || bills[1].GetAutoNumber() != 3 {
+		t.Fatal("prove body error")
+	}
+}
+
 func Test1(t *testing.T) {
 
 	btstr := "017ff377a442250bbd0de17ce8d2e6ba0800000001000000000000000201f70101001ecf9afca1c31fdeacc2091acc91c2dc5ef28a79009cba1cb8f332141964668ea906a38f339f1bbccaf70108f7010c0061680e8d4d7bbb0407d8681d0d86d1e91e00167908003230e909db0810e670a369c6868274136dad12ef00f393e23a42bc3431eccfbd0b829929cb9abd20ad005c110abc683fcdfa40027dab15f9f064a7a36bf700a536637453340a1c1d8ca3f1dec0b44c5728d7a3009cba1cb8f332141964668ea906a38f339f1bbcca001ecf9afca1c31fdeacc2091acc91c2dc5ef28a79009d7d95e7e9997a3355e6a4d04e1be8adf0fb95320012336ca7aad576b58d25fb9f0eab8b1e059662720552134c04b9e02d0a7cc72e0b6be6278ed3269aa35add771aab55b46a5320874bbf5fcb64d48d35f11c7821107ee11b459456699f18722aa4535f1c92b1cb0ff857b56ab5608eb204e70414ff96a85e7e02ecc09b6ac3123cb5809cea7d39ee136a8c4e611c4328702450366532863bd1b3634723762137bc88f9d2c8c3925016ff417dbe969a99dbbfdd929b6149b9e1d66305a258b00d137b3a2e0d09070036abb41965bbd5e458334c56a806ab1fb29403cb6f7785cabfb38e39f30c35a7a87c845d604c908d402ee232b98f3b4be8c007ce312489a80b48060c10fddf0b59608090659ba973964424d9ee7612c38b756e79e08d5dd1fa46b53ed1c166b9d2f1c6ba7281004a19a59a0454e9f8a3de47bb02a83451e8167768731e89d04a89f24f9df9d5e5351b337ef3e564892590721fcd06eb9c3d994f5d5d986f74807f0f195ce96715584f12b0a83fca23ab318629736534d2cceaea592411620ad937a676009e8d7a2e45fee6175e363715d60ecefb0344ee89b8a8720daf111f3370d127919db2075c2bed1dbf1bfd3ef020f72276f8f14c5543f59333bb68a8acb9aa4f53dceaa8697d8c56a5e5212476b75423e70964106ce3dceed8e7be5094068bef9eadb5aad34b746d26a3da0e6dda9c09931b0285a2fe9808a81e92aae51b80052478f76096a8eff337d0a6a4d875e4eea6f6e8fc2b9374786307eb827f402e73055cc128fc9ceb8fdcc2738ab7a66e0d8e63ee4ac93b50c3382b3170c6e9d9b34589ac0c0a558e729e3a7d92d0edd05572efcb03f1e4fbfb53b19229bc9cb67f3756f7d40fae6e512457adf3c3b0468b837c25260a32ddca82caf825d5ce83a4476e5e50d7eb433e435764a9116e6ddfec489c2b301bb0026a2342129087bd85ef11784c4023664ca367ce0cf04711fef3b1ece0038d8dd5292df3a0f4e8d9dea43a3177844edf34de685b40ec14f748dbb1319ea13a2c65bb237fc5f7493e94f49dc54791753af32388424c1230b76848a2213a0737e81728bf42afc98f0593cf4c49235fb71c49ad09d60ae8699d98c643f0ba11021750656f71814e9eccb2fbc692b38c2f64a86651f39c404711218c160701f0de8fd97ca9077afba91e8b521b188c4ed81c018eb3f37cc46c43224c7d16e0d68f22aa99c4462a8d5dae8f5ffbf3c09227bdb61165c7653b9bd35288f36edc1f15"
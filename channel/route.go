@@ -0,0 +1,347 @@
+package channel
+
+import (
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"sync"
+)
+
+/**
+ * 通道链多跳路由
+ *
+ * 维护已知通道的图（两侧余额即各方向的容量，转发手续费），为付款方寻找到收款方的路径
+ * 然后构造每一跳的对账数据（ChannelChainTransferProveBodyInfo）和需要全部相关地址签名的通道链支付
+ *
+ * 手续费：通过某通道转发时，由该通道的付款侧（转发节点）收取，第一跳由付款方自己支付，不收取
+ * 所以第 i 跳的支付金额为：支付金额 + 之后各跳手续费之和
+ * 目前只路由 HAC ，SAT 分配保持不变
+ */
+
+const (
+	RouteMaxHops = 200 // 最多途径通道数量
+)
+
+// 路由图中的通道
+type RouteChannel struct {
+	ChannelId    fields.ChannelId
+	ReuseVersion uint32
+	AutoNumber   uint64 // 当前最新票据流水号
+
+	LeftAddress  fields.Address
+	RightAddress fields.Address
+	LeftBalance  fields.Amount // 左侧余额，即左=>右方向容量
+	RightBalance fields.Amount // 右侧余额，即右=>左方向容量
+	LeftSatoshi  fields.Satoshi
+	RightSatoshi fields.Satoshi
+
+	Fee fields.Amount // 转发手续费
+}
+
+// 路径中的一跳
+type RouteHop struct {
+	Channel     *RouteChannel
+	FromAddress fields.Address
+	ToAddress   fields.Address
+	PayAmount   *fields.Amount // 本跳支付金额
+	Fee         *fields.Amount // 本跳转发节点收取的手续费，第一跳为零
+}
+
+// 支付路径
+type PaymentRoute struct {
+	PayerAddress fields.Address
+	PayeeAddress fields.Address
+	Amount       *fields.Amount // 收款方收到的金额
+	TotalFee     *fields.Amount // 手续费合计
+	Hops         []*RouteHop    // 从付款方到收款方
+}
+
+// 通道图
+type ChannelGraph struct {
+	channels map[string]*RouteChannel
+	nodes    map[string][]*RouteChannel // 地址 => 相关通道
+
+	mux sync.RWMutex
+}
+
+func NewChannelGraph() *ChannelGraph {
+	return &ChannelGraph{
+		channels: make(map[string]*RouteChannel),
+		nodes:    make(map[string][]*RouteChannel),
+	}
+}
+
+// 添加或更新通道
+func (g *ChannelGraph) AddChannel(ch *RouteChannel) error {
+	if len(ch.ChannelId) != 16 || len(ch.LeftAddress) != fields.AddressSize || len(ch.RightAddress) != fields.AddressSize {
+		return errs.New(errs.CodeInvalidParameter, "Route channel data error.")
+	}
+	if ch.LeftAddress.Equal(ch.RightAddress) {
+		return errs.New(errs.CodeInvalidParameter, "Route channel left and right address cannot be the same.")
+	}
+	if ch.LeftBalance.IsNegative() || ch.RightBalance.IsNegative() || ch.Fee.IsNegative() {
+		return errs.New(errs.CodeInvalidParameter, "Route channel amount cannot be negative.")
+	}
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.removeChannel(ch.ChannelId)
+	g.channels[string(ch.ChannelId)] = ch
+	g.nodes[string(ch.LeftAddress)] = append(g.nodes[string(ch.LeftAddress)], ch)
+	g.nodes[string(ch.RightAddress)] = append(g.nodes[string(ch.RightAddress)], ch)
+	return nil
+}
+
+// 移除通道
+func (g *ChannelGraph) RemoveChannel(cid fields.ChannelId) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.removeChannel(cid)
+}
+
+func (g *ChannelGraph) removeChannel(cid fields.ChannelId) {
+	ch, ok := g.channels[string(cid)]
+	if !ok {
+		return
+	}
+	delete(g.channels, string(cid))
+	for _, addr := range []fields.Address{ch.LeftAddress, ch.RightAddress} {
+		list := g.nodes[string(addr)]
+		for i, v := range list {
+			if v == ch {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(g.nodes, string(addr))
+		} else {
+			g.nodes[string(addr)] = list
+		}
+	}
+}
+
+// 查询通道
+func (g *ChannelGraph) Channel(cid fields.ChannelId) *RouteChannel {
+	g.mux.RLock()
+	defer g.mux.RUnlock()
+	return g.channels[string(cid)]
+}
+
+// 寻找手续费最低的路径，手续费相同时跳数最少
+// 从收款方反向搜索，这样每一跳的支付金额（包含之后的手续费）在检查容量时已经确定
+func (g *ChannelGraph) FindRoute(payer, payee fields.Address, amount *fields.Amount) (*PaymentRoute, error) {
+	if payer.Equal(payee) {
+		return nil, errs.New(errs.CodeInvalidParameter, "Payer and payee cannot be the same.")
+	}
+	if amount == nil || !amount.IsPositive() {
+		return nil, errs.New(errs.CodeInvalidParameter, "Payment amount must be positive.")
+	}
+	g.mux.RLock()
+	defer g.mux.RUnlock()
+
+	type routeNode struct {
+		fees *fields.Amount // 到收款方的手续费合计（不含本节点作为付款方时）
+		hops int
+		next *RouteChannel // 本节点付款的通道
+		done bool
+	}
+	nodes := map[string]*routeNode{
+		string(payee): {fees: fields.NewEmptyAmount(), hops: 0},
+	}
+	for {
+		// 选择未完成的最小手续费节点
+		var curkey string
+		var cur *routeNode
+		for k, n := range nodes {
+			if n.done {
+				continue
+			}
+			if cur == nil || n.fees.LessThan(cur.fees) || (n.fees.Equal(cur.fees) && n.hops < cur.hops) {
+				curkey, cur = k, n
+			}
+		}
+		if cur == nil {
+			return nil, errs.New(errs.CodeNotFound, "No route from %s to %s for amount %s.",
+				payer.ToReadable(), payee.ToReadable(), amount.ToFinString())
+		}
+		cur.done = true
+		if curkey == string(payer) {
+			break
+		}
+		if cur.hops >= RouteMaxHops {
+			continue
+		}
+		// 本跳支付金额
+		payamt, e := amount.Add(cur.fees)
+		if e != nil {
+			return nil, e
+		}
+		for _, ch := range g.nodes[curkey] {
+			// 上一节点是通道的另一侧，向本节点付款
+			prev, capacity := ch.LeftAddress, &ch.LeftBalance
+			if ch.LeftAddress.Equal(fields.Address(curkey)) {
+				prev, capacity = ch.RightAddress, &ch.RightBalance
+			}
+			if capacity.LessThan(payamt) {
+				continue // 容量不足
+			}
+			fees := cur.fees
+			if prev.NotEqual(payer) {
+				fees, e = cur.fees.Add(&ch.Fee) // 转发节点收取手续费
+				if e != nil {
+					return nil, e
+				}
+			}
+			pn, ok := nodes[string(prev)]
+			if ok && (pn.done || !fees.LessThan(pn.fees) && !(fees.Equal(pn.fees) && cur.hops+1 < pn.hops)) {
+				continue
+			}
+			nodes[string(prev)] = &routeNode{fees: fees, hops: cur.hops + 1, next: ch}
+		}
+	}
+
+	// 从付款方沿路径生成每一跳
+	route := &PaymentRoute{
+		PayerAddress: payer,
+		PayeeAddress: payee,
+		Amount:       amount,
+		TotalFee:     nodes[string(payer)].fees,
+		Hops:         make([]*RouteHop, 0, nodes[string(payer)].hops),
+	}
+	from := payer
+	for from.NotEqual(payee) {
+		n := nodes[string(from)]
+		to := n.next.LeftAddress
+		if to.Equal(from) {
+			to = n.next.RightAddress
+		}
+		downfees := nodes[string(to)].fees
+		payamt, _ := amount.Add(downfees)
+		fee, _ := n.fees.Sub(downfees)
+		route.Hops = append(route.Hops, &RouteHop{
+			Channel:     n.next,
+			FromAddress: from,
+			ToAddress:   to,
+			PayAmount:   payamt,
+			Fee:         fee,
+		})
+		from = to
+	}
+	return route, nil
+}
+
+// 付款方需要支付的总额
+func (r *PaymentRoute) TotalAmount() *fields.Amount {
+	if len(r.Hops) == 0 {
+		return fields.NewEmptyAmount()
+	}
+	return r.Hops[0].PayAmount
+}
+
+// 路径上所有需要签名的地址
+func (r *PaymentRoute) MustSignAddresses() []fields.Address {
+	addrs := make([]fields.Address, 0, len(r.Hops)*2)
+	for _, hop := range r.Hops {
+		addrs = append(addrs, hop.Channel.LeftAddress, hop.Channel.RightAddress)
+	}
+	_, addrs = CleanSortMustSignAddresses(addrs)
+	return addrs
+}
+
+// 构造每一跳的对账数据，流水号为通道当前流水号加一
+func (r *PaymentRoute) CreateProveBodys() (*ChannelPayProveBodyList, error) {
+	bodys := make([]*ChannelChainTransferProveBodyInfo, len(r.Hops))
+	for i, hop := range r.Hops {
+		ch := hop.Channel
+		lamt, ramt := &ch.LeftBalance, &ch.RightBalance
+		var direction = ChannelTransferDirectionHacashLeftToRight
+		var e1, e2 error
+		if hop.FromAddress.Equal(ch.LeftAddress) {
+			lamt, e1 = ch.LeftBalance.Sub(hop.PayAmount)
+			ramt, e2 = ch.RightBalance.Add(hop.PayAmount)
+		} else {
+			direction = ChannelTransferDirectionHacashRightToLeft
+			lamt, e1 = ch.LeftBalance.Add(hop.PayAmount)
+			ramt, e2 = ch.RightBalance.Sub(hop.PayAmount)
+		}
+		if e1 != nil || e2 != nil {
+			return nil, errs.New(errs.CodeInvalidParameter, "Channel <%s> balance calculate error.", ch.ChannelId.ToHex())
+		}
+		if lamt.IsNegative() || ramt.IsNegative() {
+			return nil, errs.New(errs.CodeInsufficientBalance, "Channel <%s> balance not enough.", ch.ChannelId.ToHex())
+		}
+		bodys[i] = &ChannelChainTransferProveBodyInfo{
+			ChannelId:      ch.ChannelId,
+			ReuseVersion:   fields.VarUint4(ch.ReuseVersion),
+			BillAutoNumber: fields.VarUint8(ch.AutoNumber + 1),
+			PayDirection:   fields.VarUint1(direction),
+			PayAmount:      *hop.PayAmount,
+			PaySatoshi:     fields.NewEmptySatoshiVariation(),
+			LeftBalance:    *lamt,
+			RightBalance:   *ramt,
+			LeftSatoshi:    ch.LeftSatoshi.GetSatoshiVariation(),
+			RightSatoshi:   ch.RightSatoshi.GetSatoshiVariation(),
+			LeftAddress:    ch.LeftAddress,
+			RightAddress:   ch.RightAddress,
+		}
+	}
+	return &ChannelPayProveBodyList{
+		Count:      fields.VarUint1(len(bodys)),
+		ProveBodys: bodys,
+	}, nil
+}
+
+// 组装通道链支付单据，并用给出的账户签名
+// 其它地址可以之后通过 ChainPayment.DoSignFillPosition 补充签名，全部签名后通过 CheckMustAddressAndSigns
+func (r *PaymentRoute) AssemblePayment(timestamp uint64, orderNoteHash fields.HashHalfChecker, signers ...*account.Account) (*ChannelPayCompleteDocuments, error) {
+	if len(r.Hops) == 0 || len(r.Hops) > RouteMaxHops {
+		return nil, errs.New(errs.CodeInvalidParameter, "Route hops quantity error.")
+	}
+	if orderNoteHash == nil {
+		orderNoteHash = make([]byte, fields.HashHalfCheckerSize)
+	}
+	bodys, e := r.CreateProveBodys()
+	if e != nil {
+		return nil, e
+	}
+	addrs := r.MustSignAddresses()
+	checkers := make([]fields.HashHalfChecker, len(bodys.ProveBodys))
+	for i, body := range bodys.ProveBodys {
+		checkers[i] = body.GetSignStuffHashHalfChecker()
+	}
+	signs := make([]fields.Sign, len(addrs))
+	for i := range signs {
+		signs[i] = fields.CreateEmptySign()
+	}
+	payment := &OffChainFormPaymentChannelTransfer{
+		Timestamp:                            fields.BlockTxTimestamp(timestamp),
+		OrderNoteHashHalfChecker:             orderNoteHash,
+		MustSignCount:                        fields.VarUint1(len(addrs)),
+		MustSignAddresses:                    addrs,
+		ChannelCount:                         fields.VarUint1(len(checkers)),
+		ChannelTransferProveHashHalfCheckers: checkers,
+		MustSigns:                            signs,
+	}
+	for _, acc := range signers {
+		_, e = payment.DoSignFillPosition(acc)
+		if e != nil {
+			return nil, e
+		}
+	}
+	return &ChannelPayCompleteDocuments{
+		ProveBodys:   bodys,
+		ChainPayment: payment,
+	}, nil
+}
+
+// 拆分为每个通道的对账票据
+func (c *ChannelPayCompleteDocuments) SplitBills() []*OffChainCrossNodeSimplePaymentReconciliationBill {
+	bills := make([]*OffChainCrossNodeSimplePaymentReconciliationBill, len(c.ProveBodys.ProveBodys))
+	for i, body := range c.ProveBodys.ProveBodys {
+		bills[i] = &OffChainCrossNodeSimplePaymentReconciliationBill{
+			ChannelChainTransferTargetProveBody: *body,
+			ChannelChainTransferData:            *c.ChainPayment,
+		}
+	}
+	return bills
+}
@@ -0,0 +1,313 @@
+package session
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"testing"
+	"time"
+)
+
+func newTestChannel(left, right *account.Account) (fields.ChannelId, *stores.Channel) {
+	cid := fields.ChannelId([]byte("abcdefghijklmnop"))
+	paychan := stores.CreateEmptyChannel()
+	paychan.ArbitrationLockBlock = 100
+	paychan.LeftAddress = left.Address
+	paychan.LeftAmount = *fields.NewAmountSmall(10, 248)
+	paychan.RightAddress = right.Address
+	paychan.RightAmount = *fields.NewAmountSmall(10, 248)
+	paychan.RightSatoshi = fields.Satoshi(5000).GetSatoshiVariation()
+	return cid, paychan
+}
+
+func Test_session_payment(t *testing.T) {
+
+	left := account.CreateAccountByPassword("channel left")
+	right := account.CreateAccountByPassword("channel right")
+	cid, paychan := newTestChannel(left, right)
+
+	lss, e := NewChannelSession(cid, paychan, left)
+	if e != nil {
+		t.Fatal(e)
+	}
+	rss, e := NewChannelSession(cid, paychan, right)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// 未支付，按存入分配关闭
+	act, _ := lss.BuildCloseAction(paychan, 1)
+	if act.Kind() != 3 {
+		t.Fatal("need Action_3")
+	}
+
+	// 左侧支付 HAC
+	bill, e := lss.CreatePayment(fields.NewAmountSmall(3, 248), 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = lss.CreatePayment(fields.NewAmountSmall(1, 248), 0)
+	if e == nil {
+		t.Fatal("pending payment must conflict")
+	}
+	bill, e = rss.Countersign(bill)
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = lss.ConfirmPayment(bill)
+	if e != nil {
+		t.Fatal(e)
+	}
+	lamt, ramt, _, _ := lss.Balances()
+	fmt.Println(lss.AutoNumber(), lamt.ToFinString(), ramt.ToFinString())
+	if lss.AutoNumber() != 1 || rss.AutoNumber() != 1 || lamt.ToFinString() != "ㄜ7:248" {
+		t.Fatal("balance error")
+	}
+	act, _ = lss.BuildCloseAction(paychan, 1)
+	if act.Kind() != 12 {
+		t.Fatal("need Action_12")
+	}
+
+	// 右侧支付全部 SAT
+	bill, e = rss.CreatePayment(nil, 5000)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// 篡改金额不守恒
+	fake := *bill
+	fake.LeftBalance = *fields.NewAmountSmall(9, 248)
+	_, e = lss.Countersign(&fake)
+	if e == nil {
+		t.Fatal("balance must conservation")
+	}
+	bill, e = lss.Countersign(bill)
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = rss.ConfirmPayment(bill)
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, _, lsat, rsat := rss.Balances()
+	if lsat != 5000 || rsat != 0 || rss.AutoNumber() != 2 {
+		t.Fatal("satoshi error")
+	}
+	_, e = rss.BuildCloseAction(paychan, 1)
+	if e == nil {
+		t.Fatal("satoshi to left cannot be closed by agreement")
+	}
+
+	// 超额支付
+	_, e = rss.CreatePayment(fields.NewAmountSmall(2, 249), 0)
+	if e == nil {
+		t.Fatal("balance must not enough")
+	}
+
+	// 放弃支付后流水号作废
+	_, e = lss.CreatePayment(fields.NewAmountSmall(1, 248), 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	lss.CancelPayment()
+	if lss.AutoNumber() != 3 || lss.LatestAutoNumber() != 2 {
+		t.Fatal("cancel payment error")
+	}
+
+	// 左侧单方面关闭
+	act, e = lss.BuildUnilateralCloseAction(paychan)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if act.Kind() != 23 {
+		t.Fatal("need Action_23")
+	}
+
+	// 右侧用旧票据发起挑战
+	paychan.SetChallenging(50, false, fields.NewAmountSmall(13, 248), 5000, 1)
+	if rss.CanRespondChallenge(paychan) || !lss.CanRespondChallenge(paychan) {
+		t.Fatal("challenge respond side error")
+	}
+	act, e = lss.BuildSettleAction(paychan, 60)
+	if e != nil {
+		t.Fatal(e)
+	}
+	act23 := act.(*actions.Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeReconciliation)
+	e = act23.Reconciliation.CheckAddressAndSign(paychan.LeftAddress, paychan.RightAddress)
+	if e != nil {
+		t.Fatal(e)
+	}
+	fmt.Println(act23.AssertAddress.ToReadable(), act23.Reconciliation.BillAutoNumber)
+
+	// 挑战期结束
+	_, e = rss.BuildCloseAction(paychan, 150)
+	if e == nil {
+		t.Fatal("challenge not expire")
+	}
+	act, e = rss.BuildSettleAction(paychan, 151)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if act.Kind() != 27 {
+		t.Fatal("need Action_27")
+	}
+}
+
+// 本地链状态和交易池替代
+type testChainState struct {
+	channels map[string]*stores.Channel
+	txs      []interfaces.Transaction
+}
+
+func (c *testChainState) Channel(cid fields.ChannelId) *stores.Channel {
+	return c.channels[string(cid)]
+}
+
+func (c *testChainState) AddTx(tx interfaces.Transaction) error {
+	c.txs = append(c.txs, tx)
+	return nil
+}
+
+func Test_watchtower(t *testing.T) {
+
+	left := account.CreateAccountByPassword("channel left")
+	right := account.CreateAccountByPassword("channel right")
+	cid, paychan := newTestChannel(left, right)
+	state := &testChainState{channels: map[string]*stores.Channel{string(cid): paychan}}
+
+	lss, _ := NewChannelSession(cid, paychan, left)
+	rss, _ := NewChannelSession(cid, paychan, right)
+	for i := 0; i < 2; i++ {
+		bill, _ := lss.CreatePayment(fields.NewAmountSmall(1, 248), 0)
+		bill, e := rss.Countersign(bill)
+		if e != nil {
+			t.Fatal(e)
+		}
+		lss.ConfirmPayment(bill)
+	}
+
+	tower := NewWatchtower(state, state, fields.NewAmountSmall(1, 244))
+	tower.Watch(rss)
+	if len(tower.CheckChannels(10)) != 0 {
+		t.Fatal("channel is opening")
+	}
+
+	// 左侧用旧票据单方面关闭
+	paychan.SetChallenging(10, true, fields.NewAmountSmall(9, 248), 0, 1)
+	res := tower.CheckChannels(11)
+	if len(res) != 1 || res[0].Error != nil || len(state.txs) != 1 {
+		t.Fatal("watchtower must respond")
+	}
+	tx := res[0].Transaction
+	ok, e := tx.VerifyAllNeedSigns()
+	if !ok || e != nil {
+		t.Fatal("response transaction sign error")
+	}
+	act := tx.GetActions()[0].(*actions.Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeReconciliation)
+	if act.AssertAddress.NotEqual(right.Address) || act.Reconciliation.BillAutoNumber != 2 {
+		t.Fatal("response action error")
+	}
+	fmt.Println(tx.Hash().ToHex(), act.Reconciliation.BillAutoNumber)
+
+	// 等待打包，到期后重新提交
+	if len(tower.CheckChannels(12)) != 0 {
+		t.Fatal("must wait for resubmit")
+	}
+	if len(tower.CheckChannels(11+WatchtowerResubmitBlocks)) != 1 || len(state.txs) != 2 {
+		t.Fatal("must resubmit")
+	}
+
+	// 挑战期已过，不再回应
+	if len(tower.CheckChannels(10+uint64(paychan.ArbitrationLockBlock)+1)) != 0 || len(state.txs) != 2 {
+		t.Fatal("challenge is expired")
+	}
+
+	// 主张最新票据，不需要回应
+	paychan.SetChallenging(30, true, fields.NewAmountSmall(8, 248), 0, 2)
+	if len(tower.CheckChannels(31)) != 0 {
+		t.Fatal("bill is not stale")
+	}
+
+	// 通道关闭后不再监视
+	paychan.SetFinalArbitrationClosed(fields.NewAmountSmall(8, 248), 0)
+	tower.CheckChannels(40)
+	if tower.WatchCount() != 0 {
+		t.Fatal("closed channel must be unwatched")
+	}
+}
+
+// 订阅区块的区块链替代
+type testBlockChain struct {
+	interfaces.BlockChain
+	blkch chan interfaces.Block
+}
+
+func (c *testBlockChain) SubscribeValidatedBlockOnInsert(blkch chan interfaces.Block) {
+	c.blkch = blkch
+}
+
+type testBlock struct {
+	interfaces.Block
+	height uint64
+}
+
+func (b *testBlock) GetHeight() uint64 {
+	return b.height
+}
+
+// 交易提交后通知
+type testNotifySubmitter struct {
+	txs chan interfaces.Transaction
+}
+
+func (s *testNotifySubmitter) AddTx(tx interfaces.Transaction) error {
+	s.txs <- tx
+	return nil
+}
+
+func Test_watchtower_stop(t *testing.T) {
+
+	left := account.CreateAccountByPassword("channel left")
+	right := account.CreateAccountByPassword("channel right")
+	cid, paychan := newTestChannel(left, right)
+	state := &testChainState{channels: map[string]*stores.Channel{string(cid): paychan}}
+
+	lss, _ := NewChannelSession(cid, paychan, left)
+	rss, _ := NewChannelSession(cid, paychan, right)
+	for i := 0; i < 2; i++ {
+		bill, _ := lss.CreatePayment(fields.NewAmountSmall(1, 248), 0)
+		bill, e := rss.Countersign(bill)
+		if e != nil {
+			t.Fatal(e)
+		}
+		lss.ConfirmPayment(bill)
+	}
+	paychan.SetChallenging(10, true, fields.NewAmountSmall(9, 248), 0, 1)
+
+	txpool := &testNotifySubmitter{txs: make(chan interfaces.Transaction, 4)}
+	blockchain := &testBlockChain{}
+	tower := NewWatchtower(state, txpool, fields.NewAmountSmall(1, 244))
+	tower.Watch(rss)
+	tower.Start(blockchain)
+
+	blockchain.blkch <- &testBlock{height: 10}
+	select {
+	case <-txpool.txs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchtower must respond")
+	}
+
+	// 停止后区块照常读出，不再提交回应
+	tower.Stop()
+	tower.Stop()
+	for i := uint64(1); i <= 4; i++ {
+		blockchain.blkch <- &testBlock{height: 10 + i*WatchtowerResubmitBlocks}
+	}
+	select {
+	case <-txpool.txs:
+		t.Fatal("watchtower is stopped")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
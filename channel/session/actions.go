@@ -0,0 +1,204 @@
+package session
+
+import (
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/channel"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+)
+
+/**
+ * 根据链上通道状态构造 action
+ *
+ * 开启状态：
+ *   协商关闭 BuildCloseAction => Action_3 / Action_12 / Action_21 ，交易需要双方签名
+ *   单方面关闭 BuildUnilateralCloseAction => Action_22 / Action_23 / Action_24 / Action_26 ，进入挑战期
+ * 挑战期：
+ *   对方主张了旧票据 BuildChallengeResponse => Action_23 / Action_24 / Action_26 ，夺取全部资金
+ *   挑战期结束 BuildCloseAction => Action_27 ，按主张分配
+ */
+
+// 检查链上通道与会话一致
+func (s *ChannelSession) checkChannel(paychan *stores.Channel) error {
+	if paychan == nil {
+		return errs.New(errs.CodeNotFound, "Payment Channel <%s> not find.", s.ChannelId.ToHex())
+	}
+	if paychan.LeftAddress.NotEqual(s.LeftAddress) || paychan.RightAddress.NotEqual(s.RightAddress) {
+		return errs.New(errs.CodeInvalidParameter, "Payment Channel <%s> address not match.", s.ChannelId.ToHex())
+	}
+	if uint32(paychan.ReuseVersion) != s.ReuseVersion {
+		return errs.New(errs.CodeStatusConflict, "Payment Channel ReuseVersion not match, need <%d> but got <%d>.", s.ReuseVersion, paychan.ReuseVersion)
+	}
+	if paychan.IsClosed() {
+		return errs.New(errs.CodeStatusConflict, "Payment Channel <%s> is be closed.", s.ChannelId.ToHex())
+	}
+	return nil
+}
+
+// 挑战期结束的区块高度
+func ChallengeExpireHeight(paychan *stores.Channel) uint64 {
+	return uint64(paychan.ChallengeLaunchHeight) + uint64(paychan.ArbitrationLockBlock)
+}
+
+// 按当前状态自动选择：挑战回应优先，其次关闭通道
+func (s *ChannelSession) BuildSettleAction(paychan *stores.Channel, pendingHeight uint64) (interfaces.Action, error) {
+	if s.CanRespondChallenge(paychan) {
+		return s.BuildChallengeResponse(paychan)
+	}
+	return s.BuildCloseAction(paychan, pendingHeight)
+}
+
+// 构造关闭通道的 action
+// 开启状态按最新票据协商关闭，挑战期结束后按主张分配
+func (s *ChannelSession) BuildCloseAction(paychan *stores.Channel, pendingHeight uint64) (interfaces.Action, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e := s.checkChannel(paychan)
+	if e != nil {
+		return nil, e
+	}
+	if paychan.IsChallenging() {
+		expire := ChallengeExpireHeight(paychan)
+		if pendingHeight <= expire {
+			return nil, errs.New(errs.CodeRetryLater, "Payment Channel Challenging expire is %d.", expire).WithHeight(expire)
+		}
+		return &actions.Action_27_ClosePaymentChannelByClaimDistribution{
+			ChannelId: s.ChannelId,
+		}, nil
+	}
+	if !paychan.IsOpening() {
+		return nil, errs.New(errs.CodeStatusConflict, "Payment Channel <%s> status error.", s.ChannelId.ToHex())
+	}
+	if s.pending != nil {
+		return nil, errs.New(errs.CodeStatusConflict, "Payment <%d> is waiting for countersign.", s.pending.GetAutoNumber())
+	}
+	// 协商关闭
+	lamt, ramt, lsat, _ := s.balances()
+	if lamt.Equal(&s.depositLeftAmount) && lsat == s.depositLeftSatoshi {
+		// 分配未改变
+		return &actions.Action_3_ClosePaymentChannel{
+			ChannelId: s.ChannelId,
+		}, nil
+	}
+	if lsat == s.depositLeftSatoshi {
+		// 仅 HAC 分配改变，SAT 按存入分配
+		return &actions.Action_12_ClosePaymentChannelBySetupAmount{
+			ChannelId:    s.ChannelId,
+			LeftAddress:  s.LeftAddress,
+			LeftAmount:   lamt,
+			RightAddress: s.RightAddress,
+			RightAmount:  ramt,
+		}, nil
+	}
+	if lsat == 0 {
+		// Action_21 只序列化左侧 HAC ，SAT 全部归右侧
+		return &actions.Action_21_ClosePaymentChannelBySetupOnlyLeftAmount{
+			ChannelId:   s.ChannelId,
+			LeftAmount:  lamt,
+			LeftSatoshi: fields.NewEmptySatoshiVariation(),
+		}, nil
+	}
+	return nil, errs.New(errs.CodeNotPermitted, "Satoshi distribution %d cannot be closed by agreement, use unilateral close.", lsat)
+}
+
+// 对方是否主张了比本地最新票据更旧的票据
+func (s *ChannelSession) CanRespondChallenge(paychan *stores.Channel) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.checkChallenge(paychan) == nil
+}
+
+func (s *ChannelSession) checkChallenge(paychan *stores.Channel) error {
+	e := s.checkChannel(paychan)
+	if e != nil {
+		return e
+	}
+	if !paychan.IsChallenging() {
+		return errs.New(errs.CodeStatusConflict, "Payment Channel status is not on challenging.")
+	}
+	// 不能回应自己的主张
+	if paychan.AssertAddressIsLeftOrRight.Check() == s.selfIsLeft {
+		return errs.New(errs.CodeNotPermitted, "The arbitration request and the response cannot be the same address")
+	}
+	basis := s.latestBasis()
+	if basis == nil || basis.GetAutoNumber() <= uint64(paychan.AssertBillAutoNumber) {
+		return errs.New(errs.CodeNotFound, "No bill AutoNumber more than %d.", paychan.AssertBillAutoNumber)
+	}
+	return nil
+}
+
+// 构造挑战回应：用流水号更大的最新票据夺取通道全部资金
+func (s *ChannelSession) BuildChallengeResponse(paychan *stores.Channel) (interfaces.Action, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e := s.checkChallenge(paychan)
+	if e != nil {
+		return nil, e
+	}
+	return s.buildAssertAction()
+}
+
+// 构造单方面关闭：用最新票据主张分配，没有票据时按存入金额主张，进入挑战期
+func (s *ChannelSession) BuildUnilateralCloseAction(paychan *stores.Channel) (interfaces.Action, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e := s.checkChannel(paychan)
+	if e != nil {
+		return nil, e
+	}
+	if !paychan.IsOpening() {
+		return nil, errs.New(errs.CodeStatusConflict, "Payment Channel <%s> is not opening.", s.ChannelId.ToHex())
+	}
+	if s.latestBasis() == nil {
+		return &actions.Action_22_UnilateralClosePaymentChannelByNothing{
+			ChannelId:          s.ChannelId,
+			AssertCloseAddress: s.selfAcc.Address,
+		}, nil
+	}
+	return s.buildAssertAction()
+}
+
+// 用最新票据构造主张 action
+func (s *ChannelSession) buildAssertAction() (interfaces.Action, error) {
+	var assertAddress = fields.Address(s.selfAcc.Address)
+	if s.latestSwap != nil {
+		return &actions.Action_26_UnilateralCloseOrRespondChallengePaymentChannelByChannelOnchainAtomicExchange{
+			AssertAddress:                       assertAddress,
+			ProveBodyHashChecker:                s.latestSwap.HashChecker,
+			ChannelChainTransferTargetProveBody: *s.latestSwap.ProveBody,
+		}, nil
+	}
+	switch bill := s.latestBill.(type) {
+	case *channel.OffChainFormPaymentChannelRealtimeReconciliation:
+		// 签名数据相同，可直接转为链上仲裁对账单
+		return &actions.Action_23_UnilateralCloseOrRespondChallengePaymentChannelByRealtimeReconciliation{
+			AssertAddress: assertAddress,
+			Reconciliation: channel.OnChainArbitrationBasisReconciliation{
+				ChannelId:      bill.ChannelId,
+				ReuseVersion:   bill.ReuseVersion,
+				BillAutoNumber: bill.BillAutoNumber,
+				LeftBalance:    bill.LeftBalance,
+				RightBalance:   bill.RightBalance,
+				LeftSatoshi:    bill.LeftSatoshi,
+				RightSatoshi:   bill.RightSatoshi,
+				LeftSign:       bill.LeftSign,
+				RightSign:      bill.RightSign,
+			},
+		}, nil
+	case *channel.OffChainCrossNodeSimplePaymentReconciliationBill:
+		return &actions.Action_24_UnilateralCloseOrRespondChallengePaymentChannelByChannelChainTransferBody{
+			AssertAddress:                       assertAddress,
+			ChannelChainTransferData:            bill.ChannelChainTransferData,
+			ChannelChainTransferTargetProveBody: bill.ChannelChainTransferTargetProveBody,
+		}, nil
+	case nil:
+		return nil, errs.New(errs.CodeNotFound, "Payment Channel <%s> has no bill.", s.ChannelId.ToHex())
+	default:
+		return nil, errs.New(errs.CodeUnsupportedKind, "Unsupported bill type <%d>.", bill.TypeCode())
+	}
+}
@@ -0,0 +1,479 @@
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/channel"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"sync"
+	"time"
+)
+
+/**
+ * 支付通道客户端会话（状态机）
+ *
+ * 维护本地一侧的通道状态：最新的双方签名票据、通道重用版本、账单流水号，任何票据都必须满足资金守恒
+ *
+ * 支付流程：
+ * 1. 付款方 CreatePayment 生成流水号加一的对账单并签名，发送给收款方
+ * 2. 收款方 Countersign 检查守恒和流水号后补充签名，成为最新票据，返回给付款方
+ * 3. 付款方 ConfirmPayment 确认双方签名，成为最新票据
+ *
+ * 通道链支付或链上原子互换产生的票据通过 AcceptBill / AcceptAtomicExchangeProve 接收
+ * 根据链上通道状态构造关闭交易（Action_3/12/21/27）或挑战回应（Action_23/24/26），见 actions.go
+ * 自动回应对方的单方面关闭见 watchtower.go
+ */
+
+// 链上原子互换凭据（Action_25 已上链，凭据哈希为对账数据的哈希）
+type AtomicExchangeProve struct {
+	HashChecker fields.HashHalfChecker
+	ProveBody   *channel.ChannelChainTransferProveBodyInfo
+}
+
+// 票据的资金分配
+type balanceBasis interface {
+	GetChannelId() fields.ChannelId
+	GetLeftBalance() fields.Amount
+	GetRightBalance() fields.Amount
+	GetLeftSatoshi() fields.Satoshi
+	GetRightSatoshi() fields.Satoshi
+	GetReuseVersion() uint32
+	GetAutoNumber() uint64
+}
+
+type ChannelSession struct {
+	ChannelId    fields.ChannelId
+	ReuseVersion uint32
+	LeftAddress  fields.Address
+	RightAddress fields.Address
+
+	selfAcc    *account.Account
+	selfIsLeft bool
+
+	// 开通时存入的资金分配
+	depositLeftAmount   fields.Amount
+	depositRightAmount  fields.Amount
+	depositLeftSatoshi  fields.Satoshi
+	depositRightSatoshi fields.Satoshi
+
+	// 资金总额，任何票据都必须守恒
+	totalAmount  *fields.Amount
+	totalSatoshi fields.Satoshi
+
+	autoNumber uint64                            // 已使用的最大流水号
+	latestBill channel.ReconciliationBalanceBill // 最新的双方签名票据
+	latestSwap *AtomicExchangeProve              // 或最新的链上原子互换凭据，与 latestBill 只有一个不为空
+
+	pending *channel.OffChainFormPaymentChannelRealtimeReconciliation // 本方已签名，等待对方补签
+
+	mux sync.Mutex
+}
+
+// 根据链上通道创建会话，self 必须是通道的一方
+func NewChannelSession(cid fields.ChannelId, paychan *stores.Channel, self *account.Account) (*ChannelSession, error) {
+	if paychan == nil {
+		return nil, errs.New(errs.CodeNotFound, "Payment Channel <%s> not find.", cid.ToHex())
+	}
+	if paychan.IsClosed() {
+		return nil, errs.New(errs.CodeStatusConflict, "Payment Channel <%s> is be closed.", cid.ToHex())
+	}
+	var selfaddr = fields.Address(self.Address)
+	var isleft = paychan.LeftAddress.Equal(selfaddr)
+	if !isleft && paychan.RightAddress.NotEqual(selfaddr) {
+		return nil, errs.New(errs.CodeNotPermitted, "Address %s is not the side of payment channel <%s>.", selfaddr.ToReadable(), cid.ToHex())
+	}
+	ttamt, e := paychan.LeftAmount.Add(&paychan.RightAmount)
+	if e != nil {
+		return nil, e
+	}
+	s := &ChannelSession{
+		ChannelId:           cid,
+		ReuseVersion:        uint32(paychan.ReuseVersion),
+		LeftAddress:         paychan.LeftAddress,
+		RightAddress:        paychan.RightAddress,
+		selfAcc:             self,
+		selfIsLeft:          isleft,
+		depositLeftAmount:   paychan.LeftAmount,
+		depositRightAmount:  paychan.RightAmount,
+		depositLeftSatoshi:  paychan.LeftSatoshi.GetRealSatoshi(),
+		depositRightSatoshi: paychan.RightSatoshi.GetRealSatoshi(),
+		totalAmount:         ttamt,
+		autoNumber:          0,
+	}
+	s.totalSatoshi = s.depositLeftSatoshi + s.depositRightSatoshi
+	return s, nil
+}
+
+// 本方是否为左侧
+func (s *ChannelSession) IsLeft() bool {
+	return s.selfIsLeft
+}
+
+// 对方地址
+func (s *ChannelSession) RemoteAddress() fields.Address {
+	if s.selfIsLeft {
+		return s.RightAddress
+	}
+	return s.LeftAddress
+}
+
+// 已使用的最大流水号
+func (s *ChannelSession) AutoNumber() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.autoNumber
+}
+
+// 最新的双方签名票据，没有时为 nil
+func (s *ChannelSession) LatestBill() channel.ReconciliationBalanceBill {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.latestBill
+}
+
+// 最新的链上原子互换凭据，没有时为 nil
+func (s *ChannelSession) LatestAtomicExchangeProve() *AtomicExchangeProve {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.latestSwap
+}
+
+// 最新票据的流水号，没有票据时为 0
+func (s *ChannelSession) LatestAutoNumber() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if b := s.latestBasis(); b != nil {
+		return b.GetAutoNumber()
+	}
+	return 0
+}
+
+// 当前资金分配：最新票据，没有票据时为存入的金额
+func (s *ChannelSession) Balances() (lamt, ramt fields.Amount, lsat, rsat fields.Satoshi) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.balances()
+}
+
+func (s *ChannelSession) balances() (lamt, ramt fields.Amount, lsat, rsat fields.Satoshi) {
+	if b := s.latestBasis(); b != nil {
+		return b.GetLeftBalance(), b.GetRightBalance(), b.GetLeftSatoshi(), b.GetRightSatoshi()
+	}
+	return s.depositLeftAmount, s.depositRightAmount, s.depositLeftSatoshi, s.depositRightSatoshi
+}
+
+func (s *ChannelSession) latestBasis() balanceBasis {
+	if s.latestSwap != nil {
+		return s.latestSwap.ProveBody
+	}
+	if s.latestBill != nil {
+		return s.latestBill
+	}
+	return nil
+}
+
+//////////////////////////////////////////////////////////
+
+// 检查通道、重用版本、流水号和资金守恒
+func (s *ChannelSession) checkBasis(b balanceBasis) error {
+	if !bytes.Equal(b.GetChannelId(), s.ChannelId) {
+		return errs.New(errs.CodeInvalidParameter, "Channel id not match, need <%s> but got <%s>.", s.ChannelId.ToHex(), b.GetChannelId().ToHex())
+	}
+	if b.GetReuseVersion() != s.ReuseVersion {
+		return errs.New(errs.CodeStatusConflict, "Channel ReuseVersion not match, need <%d> but got <%d>.", s.ReuseVersion, b.GetReuseVersion())
+	}
+	if b.GetAutoNumber() <= s.autoNumber {
+		return errs.New(errs.CodeStatusConflict, "Bill AutoNumber must more than %d but got %d.", s.autoNumber, b.GetAutoNumber())
+	}
+	lamt, ramt := b.GetLeftBalance(), b.GetRightBalance()
+	if lamt.IsNegative() || ramt.IsNegative() {
+		return errs.New(errs.CodeInvalidParameter, "Bill balance cannot be negative.")
+	}
+	ttamt, e := lamt.Add(&ramt)
+	if e != nil {
+		return e
+	}
+	if ttamt.NotEqual(s.totalAmount) {
+		return errs.New(errs.CodeInvalidParameter, "Bill total amount not match, need %s but got %s.", s.totalAmount.ToFinString(), ttamt.ToFinString())
+	}
+	ttsat := b.GetLeftSatoshi() + b.GetRightSatoshi()
+	if ttsat != s.totalSatoshi {
+		return errs.New(errs.CodeInvalidParameter, "Bill total satoshi not match, need %d but got %d.", s.totalSatoshi, ttsat)
+	}
+	return nil
+}
+
+// 检查地址是否与通道两侧一致
+func (s *ChannelSession) checkAddresses(laddr, raddr fields.Address) error {
+	if s.LeftAddress.NotEqual(laddr) || s.RightAddress.NotEqual(raddr) {
+		return errs.New(errs.CodeInvalidParameter, "Bill address not match payment channel <%s>.", s.ChannelId.ToHex())
+	}
+	return nil
+}
+
+// 检查对账单一侧的签名
+func checkSideSign(hx fields.Hash, sign fields.Sign, addr fields.Address) error {
+	sgaddr := fields.Address(account.NewAddressFromPublicKeyV0(sign.PublicKey))
+	if sgaddr.NotEqual(addr) {
+		return errs.New(errs.CodeBadSignature, "Sign address not match, need %s but got %s.", addr.ToReadable(), sgaddr.ToReadable())
+	}
+	ok, _ := account.CheckSignByHash32(hx, sign.PublicKey, sign.Signature)
+	if !ok {
+		return errs.New(errs.CodeBadSignature, "Account %s verify signature fail.", addr.ToReadable())
+	}
+	return nil
+}
+
+// 本方签名对账单
+func (s *ChannelSession) signReconciliation(bill *channel.OffChainFormPaymentChannelRealtimeReconciliation) error {
+	signature, e := s.selfAcc.Private.Sign(bill.SignStuffHash())
+	if e != nil {
+		return fmt.Errorf("Private Key '%s' do sign error", fields.Address(s.selfAcc.Address).ToReadable())
+	}
+	sign := fields.Sign{
+		PublicKey: s.selfAcc.PublicKey,
+		Signature: signature.Serialize64(),
+	}
+	if s.selfIsLeft {
+		bill.LeftSign = sign
+	} else {
+		bill.RightSign = sign
+	}
+	return nil
+}
+
+// 检查对账单的地址和双方签名
+func (s *ChannelSession) checkReconciliation(bill *channel.OffChainFormPaymentChannelRealtimeReconciliation) error {
+	e := s.checkAddresses(bill.LeftAddress, bill.RightAddress)
+	if e != nil {
+		return e
+	}
+	hx := bill.SignStuffHash()
+	e = checkSideSign(hx, bill.LeftSign, s.LeftAddress)
+	if e != nil {
+		return e
+	}
+	return checkSideSign(hx, bill.RightSign, s.RightAddress)
+}
+
+// 更新为最新票据
+func (s *ChannelSession) commitBill(bill channel.ReconciliationBalanceBill, swap *AtomicExchangeProve) {
+	s.latestBill = bill
+	s.latestSwap = swap
+	if swap != nil {
+		s.autoNumber = swap.ProveBody.GetAutoNumber()
+	} else {
+		s.autoNumber = bill.GetAutoNumber()
+	}
+	// 等待中的支付已被取代
+	if s.pending != nil && s.pending.GetAutoNumber() <= s.autoNumber {
+		s.pending = nil
+	}
+}
+
+//////////////////////////////////////////////////////////
+
+// 付款方：创建支付对账单并签名，等待对方补签
+func (s *ChannelSession) CreatePayment(amount *fields.Amount, satoshi fields.Satoshi) (*channel.OffChainFormPaymentChannelRealtimeReconciliation, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.pending != nil {
+		return nil, errs.New(errs.CodeStatusConflict, "Payment <%d> is waiting for countersign.", s.pending.GetAutoNumber())
+	}
+	if amount == nil {
+		amount = fields.NewEmptyAmount()
+	}
+	if amount.IsNegative() {
+		return nil, errs.New(errs.CodeInvalidParameter, "Payment amount cannot be negative.")
+	}
+	if !amount.IsPositive() && satoshi == 0 {
+		return nil, errs.New(errs.CodeInvalidParameter, "Payment amount and satoshi cannot both be empty.")
+	}
+	// 计算新的分配
+	lamt, ramt, lsat, rsat := s.balances()
+	payamt, paysat, gotamt, gotsat := &lamt, &lsat, &ramt, &rsat
+	if !s.selfIsLeft {
+		payamt, paysat, gotamt, gotsat = &ramt, &rsat, &lamt, &lsat
+	}
+	if payamt.LessThan(amount) {
+		return nil, errs.New(errs.CodeInsufficientBalance, "Channel balance %s not enough to pay %s.", payamt.ToFinString(), amount.ToFinString())
+	}
+	if *paysat < satoshi {
+		return nil, errs.New(errs.CodeInsufficientSAT, "Channel satoshi %d not enough to pay %d.", *paysat, satoshi)
+	}
+	newpay, e1 := payamt.Sub(amount)
+	if e1 != nil {
+		return nil, e1
+	}
+	newgot, e2 := gotamt.Add(amount)
+	if e2 != nil {
+		return nil, e2
+	}
+	*payamt, *gotamt = *newpay, *newgot
+	*paysat, *gotsat = *paysat-satoshi, *gotsat+satoshi
+
+	bill := &channel.OffChainFormPaymentChannelRealtimeReconciliation{
+		ChannelId:      s.ChannelId,
+		ReuseVersion:   fields.VarUint4(s.ReuseVersion),
+		BillAutoNumber: fields.VarUint8(s.autoNumber + 1),
+		LeftBalance:    lamt,
+		RightBalance:   ramt,
+		LeftSatoshi:    lsat.GetSatoshiVariation(),
+		RightSatoshi:   rsat.GetSatoshiVariation(),
+		LeftAddress:    s.LeftAddress,
+		RightAddress:   s.RightAddress,
+		Timestamp:      fields.BlockTxTimestamp(time.Now().Unix()),
+		LeftSign:       fields.CreateEmptySign(),
+		RightSign:      fields.CreateEmptySign(),
+	}
+	e := s.signReconciliation(bill)
+	if e != nil {
+		return nil, e
+	}
+	s.pending = bill
+	return bill, nil
+}
+
+// 收款方：检查对方发起的支付，补充签名后成为最新票据
+func (s *ChannelSession) Countersign(bill *channel.OffChainFormPaymentChannelRealtimeReconciliation) (*channel.OffChainFormPaymentChannelRealtimeReconciliation, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.pending != nil {
+		// 双方同时发起支付，流水号冲突
+		return nil, errs.New(errs.CodeStatusConflict, "Payment <%d> is waiting for countersign.", s.pending.GetAutoNumber())
+	}
+	e := s.checkBasis(bill)
+	if e != nil {
+		return nil, e
+	}
+	e = s.checkAddresses(bill.LeftAddress, bill.RightAddress)
+	if e != nil {
+		return nil, e
+	}
+	// 本方资金只能增加
+	lamt, ramt, lsat, rsat := s.balances()
+	myamt, mysat, newamt, newsat := lamt, lsat, bill.LeftBalance, bill.GetLeftSatoshi()
+	remotesign := bill.RightSign
+	if !s.selfIsLeft {
+		myamt, mysat, newamt, newsat = ramt, rsat, bill.RightBalance, bill.GetRightSatoshi()
+		remotesign = bill.LeftSign
+	}
+	if newamt.LessThan(&myamt) || newsat < mysat {
+		return nil, errs.New(errs.CodeNotPermitted, "Payment cannot reduce the balance of receiver.")
+	}
+	// 对方签名
+	e = checkSideSign(bill.SignStuffHash(), remotesign, s.RemoteAddress())
+	if e != nil {
+		return nil, e
+	}
+	// 补签
+	e = s.signReconciliation(bill)
+	if e != nil {
+		return nil, e
+	}
+	s.commitBill(bill, nil)
+	return bill, nil
+}
+
+// 付款方：收到对方补签后的对账单，确认成为最新票据
+func (s *ChannelSession) ConfirmPayment(bill *channel.OffChainFormPaymentChannelRealtimeReconciliation) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.pending == nil {
+		return errs.New(errs.CodeNotFound, "No payment is waiting for countersign.")
+	}
+	if !s.pending.SignStuffHash().Equal(bill.SignStuffHash()) {
+		return errs.New(errs.CodeInvalidParameter, "Bill not match payment <%d>.", s.pending.GetAutoNumber())
+	}
+	e := s.checkReconciliation(bill)
+	if e != nil {
+		return e
+	}
+	s.commitBill(bill, nil)
+	return nil
+}
+
+// 付款方：对方拒绝补签，放弃等待中的支付
+// 本方签名已经发出，流水号作废不再使用，之后的票据流水号更大，可以在仲裁时覆盖它
+func (s *ChannelSession) CancelPayment() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.pending == nil {
+		return
+	}
+	if n := s.pending.GetAutoNumber(); n > s.autoNumber {
+		s.autoNumber = n
+	}
+	s.pending = nil
+}
+
+// 接收其它途径得到的双方签名票据（通道链支付、对账）
+func (s *ChannelSession) AcceptBill(bill channel.ReconciliationBalanceBill) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e := s.checkBasis(bill)
+	if e != nil {
+		return e
+	}
+	e = s.checkAddresses(bill.GetLeftAddress(), bill.GetRightAddress())
+	if e != nil {
+		return e
+	}
+	switch b := bill.(type) {
+	case *channel.OffChainFormPaymentChannelRealtimeReconciliation:
+		e = s.checkReconciliation(b)
+	case *channel.OffChainCrossNodeSimplePaymentReconciliationBill:
+		e = b.CheckValidity()
+		if e != nil {
+			return e
+		}
+		// 通道两侧地址都必须签名
+		lsgok, rsgok := false, false
+		for _, v := range b.ChannelChainTransferData.MustSignAddresses {
+			lsgok = lsgok || v.Equal(s.LeftAddress)
+			rsgok = rsgok || v.Equal(s.RightAddress)
+		}
+		if !lsgok || !rsgok {
+			return errs.New(errs.CodeBadSignature, "Channel signature address is missing.")
+		}
+		e = b.VerifySignature()
+	default:
+		return errs.New(errs.CodeUnsupportedKind, "Unsupported bill type <%d>.", bill.TypeCode())
+	}
+	if e != nil {
+		return e
+	}
+	s.commitBill(bill, nil)
+	return nil
+}
+
+// 接收链上原子互换凭据
+func (s *ChannelSession) AcceptAtomicExchangeProve(prove *AtomicExchangeProve) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if prove == nil || prove.ProveBody == nil {
+		return errs.New(errs.CodeInvalidParameter, "Atomic exchange prove body cannot be empty.")
+	}
+	e := s.checkBasis(prove.ProveBody)
+	if e != nil {
+		return e
+	}
+	e = s.checkAddresses(prove.ProveBody.LeftAddress, prove.ProveBody.RightAddress)
+	if e != nil {
+		return e
+	}
+	if !prove.ProveBody.GetSignStuffHashHalfChecker().Equal(prove.HashChecker) {
+		return errs.New(errs.CodeInvalidParameter, "Prove body hash not match <%s>.", prove.HashChecker.ToHex())
+	}
+	s.commitBill(nil, prove)
+	return nil
+}
@@ -0,0 +1,197 @@
+package session
+
+import (
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/transactions"
+	"sync"
+)
+
+/**
+ * 通道瞭望塔
+ *
+ * 订阅已验证的区块，每个区块检查所有监视中的通道：
+ * 对方发起单方面关闭（Action_22）或主张了旧票据进入挑战期，而本地持有流水号更大的票据时，
+ * 必须在 ArbitrationLockBlock 到期前提交回应，否则对方可以按主张分配（Action_27）
+ * 瞭望塔用会话的最新票据构造 Action_23/24/26 回应，签名后提交到交易池
+ *
+ * 回应交易未被打包时，每隔 WatchtowerResubmitBlocks 个区块重新提交一次
+ * 待打包高度超过 ChallengeExpireHeight 后回应不再有效，不再提交
+ */
+
+const (
+	WatchtowerResubmitBlocks uint64 = 6 // 重新提交回应的间隔区块数
+)
+
+// 读取链上通道状态，ChainState 满足此接口
+type ChannelStateReader interface {
+	Channel(fields.ChannelId) *stores.Channel
+}
+
+// 提交交易，TxPool 满足此接口
+type TransactionSubmitter interface {
+	AddTx(interfaces.Transaction) error
+}
+
+// 一次挑战回应
+type WatchtowerResponse struct {
+	ChannelId   fields.ChannelId
+	BlockHeight uint64 // 提交时的待打包区块高度
+	Transaction *transactions.Transaction_2_Simple
+	Error       error // 构造或提交失败的错误
+}
+
+type Watchtower struct {
+	state  ChannelStateReader
+	txpool TransactionSubmitter
+	fee    fields.Amount // 回应交易的手续费
+
+	sessions  map[string]*ChannelSession
+	responded map[string]uint64 // 已提交回应的区块高度
+
+	done     chan struct{} // 关闭时停止监视
+	stopOnce sync.Once
+	loopWait sync.WaitGroup
+
+	mux sync.Mutex
+}
+
+func NewWatchtower(state ChannelStateReader, txpool TransactionSubmitter, fee *fields.Amount) *Watchtower {
+	return &Watchtower{
+		state:     state,
+		txpool:    txpool,
+		fee:       *fee,
+		sessions:  make(map[string]*ChannelSession),
+		responded: make(map[string]uint64),
+		done:      make(chan struct{}),
+	}
+}
+
+// 监视通道
+func (w *Watchtower) Watch(s *ChannelSession) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.sessions[string(s.ChannelId)] = s
+}
+
+// 取消监视
+func (w *Watchtower) Unwatch(cid fields.ChannelId) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	delete(w.sessions, string(cid))
+	delete(w.responded, string(cid))
+}
+
+// 监视中的通道数量
+func (w *Watchtower) WatchCount() int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return len(w.sessions)
+}
+
+// 订阅区块链插入的已验证区块，开始监视
+func (w *Watchtower) Start(blockchain interfaces.BlockChain) {
+	blkch := make(chan interfaces.Block, 16)
+	blockchain.SubscribeValidatedBlockOnInsert(blkch)
+	w.loopWait.Add(1)
+	go w.loop(blkch)
+}
+
+// 停止监视，返回后不再检查通道和提交回应，可重复调用
+func (w *Watchtower) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+	w.loopWait.Wait()
+}
+
+func (w *Watchtower) loop(blkch chan interfaces.Block) {
+	for {
+		select {
+		case <-w.done:
+			w.loopWait.Done()
+			// 区块链没有取消订阅，继续读出丢弃，避免阻塞区块插入
+			for range blkch {
+			}
+			return
+		case blk, ok := <-blkch:
+			if !ok {
+				w.loopWait.Done()
+				return
+			}
+			select {
+			case <-w.done:
+				continue // 已停止，不再检查
+			default:
+			}
+			w.CheckChannels(blk.GetHeight() + 1)
+		}
+	}
+}
+
+// 检查所有监视中的通道，pendingHeight 为下一个待打包区块的高度
+// 返回本次提交的回应
+func (w *Watchtower) CheckChannels(pendingHeight uint64) []*WatchtowerResponse {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	responses := make([]*WatchtowerResponse, 0)
+	for key, sess := range w.sessions {
+		paychan := w.state.Channel(sess.ChannelId)
+		if paychan == nil || paychan.IsClosed() || uint32(paychan.ReuseVersion) != sess.ReuseVersion {
+			// 通道已关闭或已重用，不再监视
+			delete(w.sessions, key)
+			delete(w.responded, key)
+			continue
+		}
+		if !sess.CanRespondChallenge(paychan) {
+			delete(w.responded, key)
+			continue
+		}
+		if pendingHeight > ChallengeExpireHeight(paychan) {
+			// 挑战期已过，对方可以按主张分配，回应不再有效
+			delete(w.responded, key)
+			continue
+		}
+		// 已经提交过，等待打包
+		if hei, ok := w.responded[key]; ok && pendingHeight < hei+WatchtowerResubmitBlocks {
+			continue
+		}
+		res := &WatchtowerResponse{
+			ChannelId:   sess.ChannelId,
+			BlockHeight: pendingHeight,
+		}
+		res.Transaction, res.Error = w.BuildResponseTransaction(sess, paychan)
+		if res.Error == nil {
+			res.Error = w.txpool.AddTx(res.Transaction)
+		}
+		if res.Error == nil {
+			w.responded[key] = pendingHeight
+		}
+		responses = append(responses, res)
+	}
+	return responses
+}
+
+// 构造并签名挑战回应交易，手续费由本方地址支付
+func (w *Watchtower) BuildResponseTransaction(sess *ChannelSession, paychan *stores.Channel) (*transactions.Transaction_2_Simple, error) {
+	act, e := sess.BuildChallengeResponse(paychan)
+	if e != nil {
+		return nil, e
+	}
+	tx, e := transactions.NewEmptyTransaction_2_Simple(sess.selfAcc.Address)
+	if e != nil {
+		return nil, e
+	}
+	tx.Fee = w.fee
+	e = tx.AppendAction(act)
+	if e != nil {
+		return nil, e
+	}
+	e = tx.FillTargetSign(sess.selfAcc)
+	if e != nil {
+		return nil, e
+	}
+	return tx, nil
+}
@@ -3,6 +3,7 @@ package coinbase
 import (
 	"fmt"
 	"github.com/hacash/core/fields"
+	"github.com/hacash/core/sys"
 	"testing"
 )
 
@@ -49,3 +50,116 @@ func Test2(t *testing.T) {
 	}
 
 }
+
+// 分叉高度之前的计算结果必须保持不变，之后的定点计算结果与原浮点结果对照
+func Test_fixed_point_cross_check(t *testing.T) {
+
+	mainaddr, _ := fields.CheckReadableAddress("1MzNY1oA3kfgYi75zquj3SRUPYztzXHzK9")
+	loan := fields.NewAmountByUnit248(100)
+
+	// 比特币借贷赎回金额
+	btcvectors := []struct {
+		height uint64
+		stage  uint8
+		amount string
+	}{
+		{1, 1, "ㄜ1:250"}, {21, 2, "ㄜ1:250"}, {22, 3, "ㄜ99:248"}, {30, 3, "ㄜ91:248"},
+		{55, 3, "ㄜ66:248"}, {71, 3, "ㄜ5:249"}, {100, 3, "ㄜ21:248"},
+	}
+	for _, v := range btcvectors {
+		for _, fork := range []uint64{0, FixedPointMathForkBlockHeight} {
+			st, amt, e := CalculationBitcoinSystemLendingRedeemAmount(*mainaddr, *mainaddr, loan, 10, 1+fork, v.height+fork)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if st != v.stage || amt.ToFinString() != v.amount {
+				t.Fatalf("bitcoin lending redeem height %d fork %d got %d %s", v.height, fork, st, amt.ToFinString())
+			}
+		}
+	}
+
+	// 比特币借贷可借数量（单位：百万分之一枚）
+	loanvectors := []struct {
+		lend, total   uint64
+		loan, predes  int64
+		fploan, fppre uint64
+	}{
+		{10, 1000, 360000000, 7200000, 360000000, 7200000},
+		{20, 1000, 320000000, 6400000, 320000000, 6400000},
+		{47, 1000, 212000000, 4240000, 212000000, 4240000},
+		{50, 1000, 191000000, 3820000, 191000000, 3820000},
+		{100, 1000, 91000000, 1820000, 91000000, 1820000},
+		{500, 1000, 11000000, 1000000, 11000000, 1000000},
+	}
+	for _, v := range loanvectors {
+		a, b := CalculationOfInterestBitcoinMortgageLoanAmount(float64(v.lend) / float64(v.total) * 100)
+		if int64(a*100*10000) != v.loan || int64(b*100*10000) != v.predes {
+			t.Fatalf("bitcoin lending loan amount %d/%d got %f %f", v.lend, v.total, a, b)
+		}
+		c, d, e := CalculationOfInterestBitcoinMortgageLoanAmountFixedPoint(v.lend, v.total)
+		if e != nil || c != v.fploan || d != v.fppre {
+			t.Fatalf("bitcoin lending loan amount fixed point %d/%d got %d %d", v.lend, v.total, c, d)
+		}
+	}
+
+	// 通道利息：V2 逐次截断，V3 一次性精确计算
+	chvectors := []struct {
+		amount string
+		insnum uint64
+		v2, v3 string
+	}{
+		{"ㄜ1:248", 1, "ㄜ1,002:245", "ㄜ1,002:245"},
+		{"ㄜ1:248", 42, "ㄜ108,574,502:240", "ㄜ108,574,518:240"},
+		{"ㄜ12345:244", 100, "ㄜ149,403,065,717:237", "ㄜ149,403,065,767:237"},
+		{"ㄜ99999999:248", 42, "ㄜ10,857,451,747,144,279:240", "ㄜ10,857,451,747,144,299:240"},
+	}
+	for _, v := range chvectors {
+		amt, _ := fields.NewAmountFromFinString(v.amount)
+		r1, r2, _ := DoAppendCompoundInterestProportionOfHeightV2(amt, amt, v.insnum, 10, 1)
+		f1, f2, _ := DoAppendCompoundInterestProportionOfHeightV3(amt, amt, v.insnum, 10, 1)
+		if r1.ToFinString() != v.v2 || r2.ToFinString() != amt.ToFinString() {
+			t.Fatalf("channel interest V2 %s %d got %s", v.amount, v.insnum, r1.ToFinString())
+		}
+		if f1.ToFinString() != v.v3 || f2.ToFinString() != amt.ToFinString() {
+			t.Fatalf("channel interest V3 %s %d got %s", v.amount, v.insnum, f1.ToFinString())
+		}
+	}
+}
+
+// 分叉高度之前按高度计算的结果与 V2 完全一致，已上链的区块不受影响
+func Test_fixed_point_fork_height(t *testing.T) {
+
+	amounts := []string{"ㄜ1:248", "ㄜ12345:244", "ㄜ99999999:248", "ㄜ3:247"}
+	heights := []uint64{200001, 600000, 800000, FixedPointMathForkBlockHeight - 1}
+	for _, s := range amounts {
+		amt, _ := fields.NewAmountFromFinString(s)
+		for _, hei := range heights {
+			for give := fields.VarUint1(0); give <= 2; give++ {
+				r1, r2, _ := DoAppendCompoundInterestProportionOfHeightV2(amt, amt, 42, 10, give)
+				f1, f2, e := DoAppendCompoundInterestProportionOfHeight(hei, amt, amt, 42, 10, give)
+				if e != nil {
+					t.Fatal(e)
+				}
+				if r1.ToFinString() != f1.ToFinString() || r2.ToFinString() != f2.ToFinString() {
+					t.Fatalf("height %d before fork must equal V2, %s got %s", hei, r1.ToFinString(), f1.ToFinString())
+				}
+			}
+		}
+		// 分叉高度之后使用 V3
+		v1, _, _ := DoAppendCompoundInterestProportionOfHeightV3(amt, amt, 42, 10, 1)
+		f1, _, _ := DoAppendCompoundInterestProportionOfHeight(FixedPointMathForkBlockHeight, amt, amt, 42, 10, 1)
+		if v1.ToFinString() != f1.ToFinString() {
+			t.Fatal("fork height must use V3")
+		}
+	}
+
+	// 本地开发测试模式下全部高度启用
+	if IsFixedPointMathActive(1) {
+		t.Fatal("fixed point math must not active before fork")
+	}
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+	if !IsFixedPointMathActive(1) {
+		t.Fatal("fixed point math must active in development")
+	}
+}
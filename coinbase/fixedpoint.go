@@ -0,0 +1,170 @@
+package coinbase
+
+import (
+	"fmt"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/sys"
+	"math/big"
+)
+
+/**
+ * 定点整数计算
+ * 共识相关的利息、借贷计算在分叉高度之后全部使用 big.Int 精确计算，不再使用 float64
+ * 避免不同架构、不同编译器浮点运算结果不一致导致分叉
+ * 分叉高度之前的计算保持不变
+ */
+
+const (
+	// 定点计算启用高度
+	// 通道利息在主网已经按 V2 浮点计算写入了大量区块，启用高度必须高于当前主网高度，
+	// 否则重新同步的节点会得到与已有链不同的结果；按每块 5 分钟约每年 105120 块，
+	// 选择在当前高度之后数年的整数高度，留出全网节点升级的时间
+	FixedPointMathForkBlockHeight uint64 = 1200000
+)
+
+// 是否使用定点计算，本地开发测试模式下全部高度启用
+func IsFixedPointMathActive(blockHeight uint64) bool {
+	if sys.TestDebugLocalDevelopmentMark {
+		return true
+	}
+	return blockHeight >= FixedPointMathForkBlockHeight
+}
+
+// 按区块高度计算通道利息奖励：分叉高度之前 V2 ，之后 V3
+func DoAppendCompoundInterestProportionOfHeight(blockHeight uint64, amt1 *fields.Amount, amt2 *fields.Amount, caclnum uint64, wfzn uint64, interestgiveto fields.VarUint1) (*fields.Amount, *fields.Amount, error) {
+	if IsFixedPointMathActive(blockHeight) {
+		return DoAppendCompoundInterestProportionOfHeightV3(amt1, amt2, caclnum, wfzn, interestgiveto)
+	}
+	return DoAppendCompoundInterestProportionOfHeightV2(amt1, amt2, caclnum, wfzn, interestgiveto)
+}
+
+// 10 的 n 次方
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// 截断到 10^unit 的整数倍（去掉更小的单位）
+func truncateToUnit(value *big.Int, unit int) *big.Int {
+	if unit <= 0 {
+		return value
+	}
+	base := pow10(unit)
+	return new(big.Int).Mul(new(big.Int).Quo(value, base), base)
+}
+
+// 计算通道利息奖励，定点版本，参数含义与 V2 相同
+func DoAppendCompoundInterestProportionOfHeightV3(amt1 *fields.Amount, amt2 *fields.Amount, caclnum uint64, wfzn uint64, interestgiveto fields.VarUint1) (*fields.Amount, *fields.Amount, error) {
+	if caclnum == 0 {
+		return amt1, amt2, nil
+	}
+	if len(amt1.Numeral) > 4 || len(amt2.Numeral) > 4 {
+		return nil, nil, fmt.Errorf("amount numeral bytes too long.")
+	}
+	switch interestgiveto {
+	case 0:
+		// 利息两方分配
+		resamt1, e1 := calculateInterestAndPrincipalFixedPoint(amt1, amt1, caclnum, wfzn)
+		if e1 != nil {
+			return nil, nil, e1
+		}
+		resamt2, e2 := calculateInterestAndPrincipalFixedPoint(amt2, amt2, caclnum, wfzn)
+		if e2 != nil {
+			return nil, nil, e2
+		}
+		return resamt1, resamt2, nil
+	case 1, 2:
+		totalamt, e0 := amt1.Add(amt2)
+		if e0 != nil {
+			return nil, nil, e0
+		}
+		if interestgiveto == 1 {
+			// 利息全给left方
+			resamt1, e1 := calculateInterestAndPrincipalFixedPoint(amt1, totalamt, caclnum, wfzn)
+			if e1 != nil {
+				return nil, nil, e1
+			}
+			return resamt1, amt2, nil
+		}
+		// 利息全给right方
+		resamt2, e2 := calculateInterestAndPrincipalFixedPoint(amt2, totalamt, caclnum, wfzn)
+		if e2 != nil {
+			return nil, nil, e2
+		}
+		return amt1, resamt2, nil
+	}
+	return nil, nil, fmt.Errorf("cannot support interestgiveto value: %d", interestgiveto)
+}
+
+// 计算利息，定点版本
+// 利息 = 本金 * ((10000+wfzn)^n - 10000^n) / 10000^n ，一次性计算不逐次截断
+// 与 V2 相同，保留到本金单位之下 8 位，本金单位小于 8 则忽略利息
+func calculateInterestAndPrincipalFixedPoint(useramt *fields.Amount, basevalamt *fields.Amount, caclnum uint64, wfzn uint64) (*fields.Amount, error) {
+	minunit := int(basevalamt.Unit) - 8
+	if minunit < 0 {
+		return useramt, nil // 数额极小， 忽略， 余额不变
+	}
+	n := big.NewInt(int64(caclnum))
+	den := new(big.Int).Exp(big.NewInt(10000), n, nil)
+	num := new(big.Int).Exp(big.NewInt(10000+int64(wfzn)), n, nil)
+	num = num.Sub(num, den)
+	interest := new(big.Int).Mul(basevalamt.GetValue(), num)
+	interest = interest.Quo(interest, den)
+	interest = truncateToUnit(interest, minunit)
+	result := interest.Add(interest, useramt.GetValue())
+	return fields.NewAmountByBigInt(result)
+}
+
+// 比特币系统借贷荷兰拍卖期的赎回金额，定点版本
+// 赎回金额 = 借出金额 * (maxDown - overhei) / maxDown ，保留到单位 240
+func calculationBitcoinSystemLendingAuctionAmountFixedPoint(loanTotalAmount *fields.Amount, maxDown uint64, overhei uint64) (*fields.Amount, error) {
+	value := new(big.Int).Mul(loanTotalAmount.GetValue(), new(big.Int).SetUint64(maxDown-overhei))
+	value = value.Quo(value, new(big.Int).SetUint64(maxDown))
+	return fields.NewAmountByBigInt(truncateToUnit(value, 240))
+}
+
+// 比特币抵押借贷： 计算每一份可借数量和预付利息，定点版本
+// lendingPortion 算上本次抵押后已经借出的总份数，totalPortion 总份数
+// 返回值单位为百万分之一枚，与 CalculationOfInterestBitcoinMortgageLoanAmount 的结果乘以 100*10000 后取整一致
+func CalculationOfInterestBitcoinMortgageLoanAmountFixedPoint(lendingPortion uint64, totalPortion uint64) (uint64, uint64, error) {
+	if lendingPortion == 0 || totalPortion == 0 {
+		return 0, 0, fmt.Errorf("lending portion and total portion cannot be zero")
+	}
+	const micro = 100 * 10000
+	lend := new(big.Int).SetUint64(lendingPortion)
+	total := new(big.Int).SetUint64(totalPortion)
+
+	// 借出百分比 ttp = lend * 100 / total ，最低为 1
+	var loanMicro *big.Int
+	isOver200 := true // 最低 1% 时可借 991
+	if lendingPortion*100 < totalPortion {
+		loanMicro = big.NewInt(991 * micro) // ((100 / 1) - 1) * 10 + 1
+	} else {
+		// (total - lend) * 10 / lend + 1 > 200
+		left := new(big.Int).Mul(new(big.Int).Sub(total, lend), big.NewInt(10))
+		isOver200 = left.Cmp(new(big.Int).Mul(lend, big.NewInt(199))) == 1
+		// ((100 / ttp) - 1) * 10 + 1 = (total - lend) * 10 / lend + 1
+		loanMicro = new(big.Int).Sub(total, lend)
+		loanMicro = loanMicro.Mul(loanMicro, big.NewInt(10*micro))
+		loanMicro = loanMicro.Quo(loanMicro, lend)
+		loanMicro = loanMicro.Add(loanMicro, big.NewInt(micro))
+	}
+
+	// 接触数量调整：200 + 200 * (5 - ttp) / 5 = 400 - 4000 * lend / total ，向下取整
+	if isOver200 {
+		sub := new(big.Int).Mul(lend, big.NewInt(4000*micro))
+		sub = sub.Add(sub, new(big.Int).Sub(total, big.NewInt(1))) // 向上取整
+		sub = sub.Quo(sub, total)
+		loanMicro = new(big.Int).Sub(big.NewInt(400*micro), sub)
+	}
+	if loanMicro.Sign() < 0 {
+		loanMicro = big.NewInt(0)
+	}
+
+	// 预先付息数量 2% ，最低为 1
+	predesMicro := new(big.Int).Quo(loanMicro, big.NewInt(50))
+	if predesMicro.Cmp(big.NewInt(micro)) == -1 {
+		predesMicro = big.NewInt(micro)
+	}
+
+	return loanMicro.Uint64(), predesMicro.Uint64(), nil
+}
@@ -45,6 +45,11 @@ func CalculationBitcoinSystemLendingRedeemAmount(
 		overhei := penddingBlockHeight - publicHeight
 		if overhei >= maxDown {
 			realRansomAmt = fields.NewEmptyAmount() // 可赎回已降低至0
+		} else if IsFixedPointMathActive(penddingBlockHeight) {
+			realRansomAmt, e = calculationBitcoinSystemLendingAuctionAmountFixedPoint(loanTotalAmount, maxDown, overhei)
+			if e != nil {
+				return 0, nil, e
+			}
 		} else {
 			boli := (float64(maxDown) - float64(overhei)) / float64(maxDown) * loanTotalAmount.ToMei()
 			boli *= 100000000
@@ -118,6 +123,7 @@ func CalculationDiamondSystemLendingRedeemAmount(
 }
 
 // 比特币抵押借贷： 计算可借数量
+// 分叉高度之后使用 CalculationOfInterestBitcoinMortgageLoanAmountFixedPoint
 // totalLendingPercentage 已经借出的总百分比，单位：%
 // 返回可借数量和预付利息
 func CalculationOfInterestBitcoinMortgageLoanAmount(totalLendingPercentage float64) (float64, float64) {
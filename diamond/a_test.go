@@ -0,0 +1,258 @@
+package diamond
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_diamond_check_rules(t *testing.T) {
+
+	addr, _ := fields.CheckReadableAddress("1MzNY1oA3kfgYi75zquj3SRUPYztzXHzK9")
+	prevhash := bytes.Repeat([]byte{1}, 32)
+	prev := &PrevDiamond{Number: 20000, ContainBlockHash: prevhash}
+
+	task := NewMiningTask(prev, *addr, []byte("hello"))
+	if task.Number != 20001 || len(task.CustomMessage) != 32 {
+		t.Fatal("mining task error")
+	}
+	act := task.NewAction(fields.Bytes8(make([]byte, 8)), "WTYUIAHXVM")
+
+	// 序号和上一个区块哈希
+	act.Number = 20003
+	if _, e := CheckDiamondCreate(act, prev, 0); !errs.Is(e, errs.CodeInvalidParameter) {
+		t.Fatal("need number error")
+	}
+	act.Number = 20001
+	act.PrevHash = make([]byte, 32)
+	if e := CheckDiamondPrev(act, prev); e == nil {
+		t.Fatal("need prev hash error")
+	}
+	act.PrevHash = prevhash
+	if e := CheckDiamondPrev(act, prev); e != nil {
+		t.Fatal(e)
+	}
+
+	// 自定义消息
+	if CheckCustomMessage(100, bytes.Repeat([]byte{2}, 32)) == nil {
+		t.Fatal("need custom message error")
+	}
+	if CheckCustomMessage(100, make([]byte, 32)) != nil || CheckCustomMessage(20001, bytes.Repeat([]byte{2}, 32)) != nil {
+		t.Fatal("custom message check error")
+	}
+
+	// 区块高度
+	if !IsDiamondContainBlockHeight(100) || IsDiamondContainBlockHeight(101) {
+		t.Fatal("height check error")
+	}
+	if NextDiamondContainBlockHeight(101) != 105 || NextDiamondContainBlockHeight(105) != 105 {
+		t.Fatal("next height error")
+	}
+
+	// 全零 nonce 不是钻石
+	_, e := CheckDiamondCreate(act, prev, 101)
+	fmt.Println(e)
+	if e == nil {
+		t.Fatal("need mining error")
+	}
+}
+
+func Test_nonce_search(t *testing.T) {
+
+	addr, _ := fields.CheckReadableAddress("1MzNY1oA3kfgYi75zquj3SRUPYztzXHzK9")
+	task := NewMiningTask(nil, *addr, nil)
+	searcher := NewNonceSearcher(task, 4)
+	res, e := searcher.Search(0, 64, nil)
+	if res == nil {
+		// 64 次之内几乎不可能找到
+		if !errs.Is(e, errs.CodeNotFound) || searcher.HashCount() != 64 {
+			t.Fatalf("search error %v, count %d", e, searcher.HashCount())
+		}
+	}
+
+	// 关闭 stop 后立即停止
+	stop := make(chan struct{})
+	close(stop)
+	searcher = NewNonceSearcher(task, 2)
+	_, e = searcher.Search(0, 0, stop)
+	if !errs.Is(e, errs.CodeNotFound) {
+		t.Fatal("need stop")
+	}
+}
+
+func Test_bid_stats(t *testing.T) {
+
+	addr1 := fields.Address(bytes.Repeat([]byte{1}, 21))
+	addr2 := fields.Address(bytes.Repeat([]byte{2}, 21))
+	smelts := make([]*stores.DiamondSmelt, 0)
+	fees := []string{"ㄜ1:248", "ㄜ3:248", "ㄜ5:248", "ㄜ10:248"}
+	for i, f := range fees {
+		amt, _ := fields.NewAmountFromFinString(f)
+		miner := addr1
+		if i == 3 {
+			miner = addr2
+		}
+		smelt := &stores.DiamondSmelt{Number: fields.DiamondNumber(29999 + i), MinerAddress: miner}
+		smelt.ParseApproxFeeOffer(amt)
+		smelts = append(smelts, smelt)
+	}
+	st, e := NewBidStats(smelts)
+	if e != nil {
+		t.Fatal(e)
+	}
+	fmt.Println(st.Describe())
+	// 30001 和 30002 销毁 90%
+	if st.TotalFee.ToMeiString() != "19" || st.TotalBurned.ToMeiString() != "13.5" {
+		t.Fatal("total error", st.TotalFee.ToMeiString(), st.TotalBurned.ToMeiString())
+	}
+	if st.MinFee != 1 || st.MaxFee != 10 || st.MeanFee != 4.75 || st.MedianFee != 4 {
+		t.Fatal("fee error")
+	}
+	// [0,1) [1,2) [2,4) [4,8) [8,16)
+	if len(st.Distribution) != 5 || st.Distribution[1].Count != 1 || st.Distribution[3].Count != 1 || st.Distribution[4].Count != 1 {
+		t.Fatal("distribution error")
+	}
+	mas := st.MovingAverage(2)
+	if mas[0] != 1 || mas[1] != 2 || mas[3] != 7.5 {
+		t.Fatal("moving average error", mas)
+	}
+	if len(st.Miners) != 2 || !st.Miners[0].Address.Equal(addr1) || st.Miners[0].Count != 3 {
+		t.Fatal("miners error")
+	}
+}
+
+func Test_provenance_last_address(t *testing.T) {
+
+	miner := fields.Address(bytes.Repeat([]byte{1}, 21))
+	seller := fields.Address(bytes.Repeat([]byte{2}, 21))
+	buyer := fields.Address(bytes.Repeat([]byte{3}, 21))
+	lender := fields.Address(bytes.Repeat([]byte{4}, 21))
+
+	p := &Provenance{Smelt: &stores.DiamondSmelt{MinerAddress: miner}}
+	if p.LastAddress().NotEqual(miner) {
+		t.Fatal("no history must be miner")
+	}
+	p.Records = []*stores.DiamondHistoryRecord{
+		{ActionKind: 5, FromAddress: miner, ToAddress: seller},
+		{ActionKind: 34, FromAddress: seller, ToAddress: buyer}, // 挂单成交
+		{ActionKind: 19, FromAddress: buyer, ToAddress: lender}, // 抵押不改变所属
+	}
+	if p.LastAddress().NotEqual(buyer) {
+		t.Fatal("last address must be buyer")
+	}
+	// 以链状态为准
+	p.Owner = seller
+	if p.LastAddress().NotEqual(seller) {
+		t.Fatal("last address must follow chain state")
+	}
+}
+
+// 只实现余额和持有索引读写的链状态，持有索引按 stores.DiamondOwnerKey 逐条保存
+type testOwnerState struct {
+	interfaces.ChainStateOperation
+	height   uint64
+	balances map[string]*stores.Balance
+	owners   map[string]bool
+	history  map[string][]*stores.DiamondHistoryRecord
+	diamonds map[string]*stores.Diamond
+}
+
+func (s *testOwnerState) GetPendingBlockHeight() uint64 { return s.height }
+func (s *testOwnerState) Balance(addr fields.Address) *stores.Balance {
+	return s.balances[string(addr)]
+}
+func (s *testOwnerState) DiamondOwnerAdd(addr fields.Address, name fields.DiamondName) error {
+	s.owners[string(stores.DiamondOwnerKey(addr, name))] = true
+	return nil
+}
+func (s *testOwnerState) DiamondOwnerDel(addr fields.Address, name fields.DiamondName) error {
+	delete(s.owners, string(stores.DiamondOwnerKey(addr, name)))
+	return nil
+}
+func (s *testOwnerState) DiamondOwnerList(addr fields.Address, offset, limit uint32) ([]fields.DiamondName, uint32, error) {
+	keys := make([]string, 0)
+	for k := range s.owners {
+		if strings.HasPrefix(k, string(addr)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys) // 模拟前缀顺序扫描
+	total := uint32(len(keys))
+	list := make([]fields.DiamondName, 0)
+	for i := offset; i < total && (limit == 0 || i < offset+limit); i++ {
+		_, name, _ := stores.ParseDiamondOwnerKey([]byte(keys[i]))
+		list = append(list, name)
+	}
+	return list, total, nil
+}
+func (s *testOwnerState) DiamondHistoryPush(name fields.DiamondName, r *stores.DiamondHistoryRecord) error {
+	s.history[string(name)] = append(s.history[string(name)], r)
+	return nil
+}
+func (s *testOwnerState) IterateDiamond(fn func(fields.DiamondName, *stores.Diamond) bool) error {
+	for name, dia := range s.diamonds {
+		if !fn(fields.DiamondName(name), dia) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func Test_owner_index_read(t *testing.T) {
+
+	addr1 := fields.Address(bytes.Repeat([]byte{1}, 21))
+	addr2 := fields.Address(bytes.Repeat([]byte{2}, 21))
+	state := &testOwnerState{
+		height: actions.DiamondIndexActiveBlockHeight - 1,
+		balances: map[string]*stores.Balance{
+			string(addr1): {Diamond: 3},
+			string(addr2): {Diamond: 1},
+		},
+		owners:  map[string]bool{},
+		history: map[string][]*stores.DiamondHistoryRecord{},
+		diamonds: map[string]*stores.Diamond{
+			"WTYUIA": {Status: stores.DiamondStatusNormal, Address: addr1},
+			"NHMYYM": {Status: stores.DiamondStatusListing, Address: addr1},
+			"AAAAAA": {Status: stores.DiamondStatusNormal, Address: addr1},
+			"ZZZZZZ": {Status: stores.DiamondStatusLendingSystem, Address: addr1}, // 抵押中不在索引内
+			"BBBBBB": {Status: stores.DiamondStatusNormal, Address: addr2},
+		},
+	}
+	// 启用之前不提供查询
+	if _, _, e := ReadOwnerList(state, addr1, 0, 10); !errs.Is(e, errs.CodeNotEnabled) {
+		t.Fatal("owner index not active must be error")
+	}
+	// 启用区块补建
+	state.height = actions.DiamondIndexActiveBlockHeight
+	if !actions.IsDiamondIndexActivateBlock(state.height) {
+		t.Fatal("activate block height error")
+	}
+	if e := actions.DoActivateDiamondIndex(state); e != nil {
+		t.Fatal("activate error", e)
+	}
+	// 每枚钻石一条所属快照，包括抵押中的
+	if len(state.history) != 5 || state.history["ZZZZZZ"][0].ToAddress.NotEqual(addr1) || state.history["ZZZZZZ"][0].ActionKind != 0 {
+		t.Fatal("activate history snapshot error")
+	}
+	list, total, e := ReadOwnerList(state, addr1, 1, 10)
+	if e != nil || total != 3 || len(list) != 2 || string(list[0]) != "NHMYYM" || string(list[1]) != "WTYUIA" {
+		t.Fatal("owner list error", e)
+	}
+	// 数量与余额不一致
+	state.DiamondOwnerDel(addr1, []byte("AAAAAA"))
+	if _, _, e := ReadOwnerList(state, addr1, 0, 10); !errs.Is(e, errs.CodeStatusConflict) {
+		t.Fatal("owner index count not match must be error")
+	}
+	// 没有持有
+	list, total, e = ReadOwnerList(state, fields.Address(bytes.Repeat([]byte{3}, 21)), 0, 10)
+	if e != nil || total != 0 || len(list) != 0 {
+		t.Fatal("empty owner list error", e)
+	}
+}
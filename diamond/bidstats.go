@@ -0,0 +1,250 @@
+package diamond
+
+import (
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"math"
+	"math/big"
+	"sort"
+)
+
+/**
+ * 钻石竞价统计
+ * 按钻石序号区间读取 DiamondSmelt ，统计竞价手续费分布、销毁总量、移动平均竞价和矿工挖出数量
+ * ApproxFeeOffer 为压缩保存的近似值（4 位有效数字，向上取），统计结果同样是近似值
+ * 从第 30001 枚开始销毁竞价手续费的 90%
+ */
+
+const (
+	BidStatsMaxRange      uint32 = 10000 // 单次统计最多钻石数
+	BidStatsDefaultWindow int    = 20    // 默认移动平均窗口
+)
+
+// 竞价分布区间 [MinMei, MaxMei)
+type FeeBucket struct {
+	MinMei float64
+	MaxMei float64
+	Count  int
+}
+
+// 矿工挖出数量
+type MinerCount struct {
+	Address fields.Address
+	Count   int
+}
+
+// 竞价统计
+type BidStats struct {
+	StartNumber uint32
+	EndNumber   uint32
+	Window      int // 移动平均窗口
+
+	Fees        []float64      // 每枚钻石的竞价（枚），按序号
+	TotalFee    *fields.Amount // 竞价合计
+	TotalBurned *fields.Amount // 销毁合计
+
+	MinFee    float64
+	MaxFee    float64
+	MeanFee   float64
+	MedianFee float64
+
+	Distribution []*FeeBucket  // 按 2 的幂次分段：[0,1) [1,2) [2,4) ...
+	Miners       []*MinerCount // 按数量降序
+
+	LastAverageBidBurnPrice uint16 // 最后一枚的平均竞价销毁价格（链上记录）
+}
+
+// 该序号钻石竞价的销毁数额
+func BurnedBidFee(number uint32, fee *fields.Amount) *fields.Amount {
+	if number <= actions.DiamondCreateBurning90PercentTxFeesAboveNumber || fee == nil {
+		return fields.NewEmptyAmount()
+	}
+	val := fee.GetValue()
+	val.Mul(val, big.NewInt(9))
+	val.Div(val, big.NewInt(10))
+	amt, e := fields.NewAmountByBigInt(val)
+	if e != nil {
+		return fields.NewEmptyAmount()
+	}
+	return amt
+}
+
+// 从区块储存读取区间 [start, end] 的钻石并统计
+func ReadBidStats(store interfaces.BlockStore, start uint32, end uint32) (*BidStats, error) {
+	if start == 0 || end < start {
+		return nil, errs.New(errs.CodeInvalidParameter, "Diamond number range <%d, %d> error.", start, end)
+	}
+	if end-start+1 > BidStatsMaxRange {
+		return nil, errs.New(errs.CodeInvalidParameter, "Diamond number range cannot over %d.", BidStatsMaxRange)
+	}
+	smelts := make([]*stores.DiamondSmelt, 0, end-start+1)
+	for num := start; num <= end; num++ {
+		smelt, e := store.ReadDiamondByNumber(num)
+		if e != nil {
+			return nil, e
+		}
+		if smelt == nil {
+			return nil, errs.New(errs.CodeNotFound, "Diamond <%d> not exist.", num)
+		}
+		smelts = append(smelts, smelt)
+	}
+	return NewBidStats(smelts)
+}
+
+// 统计已读取的钻石，需按序号排列
+func NewBidStats(smelts []*stores.DiamondSmelt) (*BidStats, error) {
+	if len(smelts) == 0 {
+		return nil, errs.New(errs.CodeInvalidParameter, "Diamond list cannot be empty.")
+	}
+	st := &BidStats{
+		StartNumber: uint32(smelts[0].Number),
+		EndNumber:   uint32(smelts[len(smelts)-1].Number),
+		Window:      BidStatsDefaultWindow,
+		Fees:        make([]float64, len(smelts)),
+		TotalFee:    fields.NewEmptyAmount(),
+		TotalBurned: fields.NewEmptyAmount(),
+		MinFee:      math.MaxFloat64,
+	}
+	miners := make(map[string]*MinerCount)
+	var e error
+	for i, smelt := range smelts {
+		fee := smelt.GetApproxFeeOffer()
+		st.TotalFee, e = st.TotalFee.Add(fee)
+		if e != nil {
+			return nil, e
+		}
+		st.TotalBurned, e = st.TotalBurned.Add(BurnedBidFee(uint32(smelt.Number), fee))
+		if e != nil {
+			return nil, e
+		}
+		mei := fee.ToMei()
+		st.Fees[i] = mei
+		st.MinFee = math.Min(st.MinFee, mei)
+		st.MaxFee = math.Max(st.MaxFee, mei)
+		st.MeanFee += mei
+		// 矿工
+		key := string(smelt.MinerAddress)
+		if mc, ok := miners[key]; ok {
+			mc.Count++
+		} else {
+			miners[key] = &MinerCount{Address: smelt.MinerAddress, Count: 1}
+		}
+	}
+	st.MeanFee /= float64(len(smelts))
+	st.MedianFee = median(st.Fees)
+	st.Distribution = feeDistribution(st.Fees, st.MaxFee)
+	st.Miners = make([]*MinerCount, 0, len(miners))
+	for _, mc := range miners {
+		st.Miners = append(st.Miners, mc)
+	}
+	sort.Slice(st.Miners, func(i, j int) bool {
+		if st.Miners[i].Count != st.Miners[j].Count {
+			return st.Miners[i].Count > st.Miners[j].Count
+		}
+		return string(st.Miners[i].Address) < string(st.Miners[j].Address)
+	})
+	st.LastAverageBidBurnPrice = uint16(smelts[len(smelts)-1].AverageBidBurnPrice)
+	return st, nil
+}
+
+// 移动平均竞价，第 i 项为截至第 i 枚、最近 window 枚的平均值
+func (st *BidStats) MovingAverage(window int) []float64 {
+	if window < 1 {
+		window = 1
+	}
+	res := make([]float64, len(st.Fees))
+	sum := 0.0
+	for i, fee := range st.Fees {
+		sum += fee
+		if i >= window {
+			sum -= st.Fees[i-window]
+		}
+		n := i + 1
+		if n > window {
+			n = window
+		}
+		res[i] = sum / float64(n)
+	}
+	return res
+}
+
+// 最近 Window 枚的平均竞价，可作为出价参考
+func (st *BidStats) RecentAverage() float64 {
+	mas := st.MovingAverage(st.Window)
+	if len(mas) == 0 {
+		return 0
+	}
+	return mas[len(mas)-1]
+}
+
+// 中位数
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// 按 2 的幂次分段统计
+func feeDistribution(fees []float64, maxfee float64) []*FeeBucket {
+	buckets := []*FeeBucket{{MinMei: 0, MaxMei: 1}}
+	for buckets[len(buckets)-1].MaxMei <= maxfee {
+		last := buckets[len(buckets)-1].MaxMei
+		buckets = append(buckets, &FeeBucket{MinMei: last, MaxMei: last * 2})
+	}
+	for _, fee := range fees {
+		i := 0
+		if fee >= 1 {
+			i = int(math.Floor(math.Log2(fee))) + 1
+		}
+		if i >= len(buckets) {
+			i = len(buckets) - 1
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// json api
+func (st *BidStats) Describe() map[string]interface{} {
+	distribution := make([]interface{}, len(st.Distribution))
+	for i, b := range st.Distribution {
+		distribution[i] = map[string]interface{}{
+			"min":   b.MinMei,
+			"max":   b.MaxMei,
+			"count": b.Count,
+		}
+	}
+	miners := make([]interface{}, len(st.Miners))
+	for i, m := range st.Miners {
+		miners[i] = map[string]interface{}{
+			"address": m.Address.ToReadable(),
+			"count":   m.Count,
+		}
+	}
+	return map[string]interface{}{
+		"start_number":           st.StartNumber,
+		"end_number":             st.EndNumber,
+		"count":                  len(st.Fees),
+		"total_fee":              st.TotalFee.ToMeiString(),
+		"total_burned":           st.TotalBurned.ToMeiString(),
+		"min_fee":                st.MinFee,
+		"max_fee":                st.MaxFee,
+		"mean_fee":               st.MeanFee,
+		"median_fee":             st.MedianFee,
+		"moving_average_window":  st.Window,
+		"moving_average":         st.RecentAverage(),
+		"average_bid_burn_price": st.LastAverageBidBurnPrice,
+		"distribution":           distribution,
+		"miners":                 miners,
+	}
+}
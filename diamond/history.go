@@ -0,0 +1,96 @@
+package diamond
+
+import (
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+)
+
+/**
+ * 钻石溯源查询
+ * 创建记录来自 BlockStore 的 DiamondSmelt ，之后的转账、抵押、赎回来自链状态的流转历史
+ * 流转历史从启用高度开始记录，启用之前的钻石以一条所属快照开头
+ */
+
+// 溯源记录
+type Provenance struct {
+	Smelt   *stores.DiamondSmelt
+	Records []*stores.DiamondHistoryRecord // 创建之后的流转，按区块顺序
+	Owner   fields.Address                 // 链状态中的所属地址，可为空
+}
+
+// 当前持有地址（抵押中为抵押人）：以链状态为准，没有时按流转历史推断，没有流转时为矿工地址
+func (p *Provenance) LastAddress() fields.Address {
+	if len(p.Owner) > 0 {
+		return p.Owner
+	}
+	for i := len(p.Records) - 1; i >= 0; i-- {
+		to := p.Records[i].ToAddress
+		if uint16(p.Records[i].ActionKind) == 19 || to.Equal(stores.DiamondHistorySystemLendingAddress) {
+			continue // 抵押不改变所属
+		}
+		return to
+	}
+	return p.Smelt.MinerAddress
+}
+
+// 按字面量查询
+func ReadProvenance(state interfaces.ChainStateOperation, name fields.DiamondName) (*Provenance, error) {
+	smelt, e := state.BlockStore().ReadDiamond(name)
+	if e != nil {
+		return nil, e
+	}
+	return newProvenance(state, smelt, name)
+}
+
+// 按序号查询
+func ReadProvenanceByNumber(state interfaces.ChainStateOperation, number fields.DiamondNumber) (*Provenance, error) {
+	smelt, e := state.BlockStore().ReadDiamondByNumber(uint32(number))
+	if e != nil {
+		return nil, e
+	}
+	return newProvenance(state, smelt, number)
+}
+
+func newProvenance(state interfaces.ChainStateOperation, smelt *stores.DiamondSmelt, key interface{}) (*Provenance, error) {
+	if smelt == nil {
+		return nil, errs.New(errs.CodeNotFound, "Diamond <%v> not exist.", key)
+	}
+	p := &Provenance{
+		Smelt:   smelt,
+		Records: make([]*stores.DiamondHistoryRecord, 0),
+	}
+	records, _, e := state.DiamondHistoryList(smelt.Diamond, 0, 0)
+	if e != nil {
+		return nil, e
+	}
+	if records != nil {
+		p.Records = records
+	}
+	if dia := state.Diamond(smelt.Diamond); dia != nil {
+		p.Owner = dia.Address
+	}
+	return p, nil
+}
+
+// json api
+func (p *Provenance) Describe() map[string]interface{} {
+	records := make([]interface{}, 0, len(p.Records)+1)
+	// 创建
+	records = append(records, map[string]interface{}{
+		"height": uint64(p.Smelt.ContainBlockHeight),
+		"block":  p.Smelt.ContainBlockHash.ToHex(),
+		"kind":   4,
+		"to":     p.Smelt.MinerAddress.ToReadable(),
+	})
+	for _, r := range p.Records {
+		records = append(records, r.Describe())
+	}
+	return map[string]interface{}{
+		"diamond": string(p.Smelt.Diamond),
+		"number":  uint32(p.Smelt.Number),
+		"address": p.LastAddress().ToReadable(),
+		"history": records,
+	}
+}
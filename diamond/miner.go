@@ -0,0 +1,172 @@
+package diamond
+
+import (
+	"encoding/binary"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/x16rs"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+/**
+ * 钻石 CPU 挖矿
+ * 多个 goroutine 按步长分别搜索 nonce ，第 i 个 worker 尝试 start+i, start+i+workers, ...
+ * nonce 为 8 字节大端序整数
+ */
+
+// 挖矿任务
+type MiningTask struct {
+	Number        uint32         // 要挖的钻石序号
+	PrevHash      fields.Hash    // 包含上一枚钻石的区块哈希
+	Address       fields.Address // 奖励地址
+	CustomMessage fields.Bytes32 // 自定义消息，第 20001 枚开始有效
+}
+
+// 由上一枚钻石创建挖矿任务
+func NewMiningTask(prev *PrevDiamond, address fields.Address, msg fields.Bytes32) *MiningTask {
+	task := &MiningTask{
+		Number:        1,
+		PrevHash:      make([]byte, 32),
+		Address:       address,
+		CustomMessage: msg,
+	}
+	if prev != nil && prev.Number > 0 {
+		task.Number = prev.Number + 1
+		task.PrevHash = prev.ContainBlockHash
+	}
+	if task.Number <= actions.DiamondCreateCustomMessageAboveNumber {
+		task.CustomMessage = nil // 消息不参与计算
+	} else if len(task.CustomMessage) != 32 {
+		task.CustomMessage = make([]byte, 32)
+	}
+	return task
+}
+
+// 用 nonce 构造 action
+func (t *MiningTask) NewAction(nonce fields.Bytes8, diamond string) *actions.Action_4_DiamondCreate {
+	act := &actions.Action_4_DiamondCreate{
+		Diamond:       fields.DiamondName(diamond),
+		Number:        fields.DiamondNumber(t.Number),
+		PrevHash:      t.PrevHash,
+		Nonce:         nonce,
+		Address:       t.Address,
+		CustomMessage: t.CustomMessage,
+	}
+	if act.CustomMessage == nil {
+		act.CustomMessage = make([]byte, 32)
+	}
+	return act
+}
+
+// 挖矿结果
+type MiningResult struct {
+	Nonce       fields.Bytes8
+	Diamond     string
+	DiamondHash []byte
+	Action      *actions.Action_4_DiamondCreate
+}
+
+// nonce 搜索器
+type NonceSearcher struct {
+	Task    *MiningTask
+	Workers int // 0 为 CPU 核数
+
+	hashCount uint64
+}
+
+func NewNonceSearcher(task *MiningTask, workers int) *NonceSearcher {
+	return &NonceSearcher{
+		Task:    task,
+		Workers: workers,
+	}
+}
+
+// 已计算的哈希次数
+func (s *NonceSearcher) HashCount() uint64 {
+	return atomic.LoadUint64(&s.hashCount)
+}
+
+// 尝试一个 nonce
+func (s *NonceSearcher) tryNonce(nonce fields.Bytes8, msg []byte) *MiningResult {
+	t := s.Task
+	atomic.AddUint64(&s.hashCount, 1)
+	reshash, resstr := x16rs.Diamond(t.Number, t.PrevHash, nonce, t.Address, msg)
+	diamond, isdia := x16rs.IsDiamondHashResultString(resstr)
+	if !isdia {
+		return nil
+	}
+	if !x16rs.CheckDiamondDifficulty(t.Number, reshash) {
+		return nil
+	}
+	return &MiningResult{
+		Nonce:       nonce,
+		Diamond:     diamond,
+		DiamondHash: reshash,
+		Action:      t.NewAction(nonce, diamond),
+	}
+}
+
+// 搜索 nonce
+// startNonce 起始值，maxAttempts 最多尝试次数（0 为不限制），stop 关闭时停止搜索
+// 未找到时返回 CodeNotFound 错误
+func (s *NonceSearcher) Search(startNonce uint64, maxAttempts uint64, stop <-chan struct{}) (*MiningResult, error) {
+	if s.Task == nil || s.Task.Number == 0 {
+		return nil, errs.New(errs.CodeInvalidParameter, "Mining task number cannot be zero.")
+	}
+	if e := CheckCustomMessage(s.Task.Number, s.Task.CustomMessage); e != nil {
+		return nil, e
+	}
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	msg := s.Task.NewAction(nil, "").GetRealCustomMessage()
+
+	var (
+		found    *MiningResult
+		foundMux sync.Mutex
+		done     = make(chan struct{})
+		doneOnce sync.Once
+		wg       sync.WaitGroup
+	)
+	finish := func() {
+		doneOnce.Do(func() { close(done) })
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(offset uint64) {
+			defer wg.Done()
+			for i := offset; maxAttempts == 0 || i < maxAttempts; i += uint64(workers) {
+				select {
+				case <-done:
+					return
+				case <-stop:
+					finish()
+					return
+				default:
+				}
+				nonce := make([]byte, 8)
+				binary.BigEndian.PutUint64(nonce, startNonce+i)
+				res := s.tryNonce(nonce, msg)
+				if res != nil {
+					foundMux.Lock()
+					if found == nil {
+						found = res
+					}
+					foundMux.Unlock()
+					finish()
+					return
+				}
+			}
+		}(uint64(w))
+	}
+	wg.Wait()
+	finish()
+	if found == nil {
+		return nil, errs.New(errs.CodeNotFound, "Diamond <%d> nonce not found after %d attempts.", s.Task.Number, s.HashCount())
+	}
+	return found, nil
+}
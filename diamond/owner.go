@@ -0,0 +1,33 @@
+package diamond
+
+import (
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+)
+
+/**
+ * 地址持有钻石索引的读取
+ * 索引在启用高度之后由钻石余额函数维护，启用区块已全量补建，见 actions.DoActivateDiamondIndex
+ * 读取时用 Balance.Diamond 校验索引数量，不一致说明状态有误，返回错误而不是残缺的列表
+ */
+
+// 分页读取地址持有的钻石，返回本页和总数
+func ReadOwnerList(state interfaces.ChainStateOperation, addr fields.Address, offset, limit uint32) ([]fields.DiamondName, uint32, error) {
+	if !actions.IsDiamondIndexActive(state.GetPendingBlockHeight()) {
+		return nil, 0, errs.New(errs.CodeNotEnabled, "Diamond owner index not active before height %d.", actions.DiamondIndexActiveBlockHeight)
+	}
+	var hold uint32 = 0
+	if bls := state.Balance(addr); bls != nil {
+		hold = uint32(bls.Diamond)
+	}
+	list, total, e := state.DiamondOwnerList(addr, offset, limit)
+	if e != nil {
+		return nil, 0, e
+	}
+	if total != hold {
+		return nil, 0, errs.New(errs.CodeStatusConflict, "Diamond owner index count %d not match balance %d.", total, hold).WithAddress(addr)
+	}
+	return list, total, nil
+}
@@ -0,0 +1,160 @@
+package diamond
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/x16rs"
+	"strings"
+)
+
+/**
+ * 钻石挖矿离线检查
+ * 不依赖链状态，执行 Action_4_DiamondCreate.WriteinChainState 中的全部计算检查
+ * 矿工提交交易之前即可知道是否会被节点拒绝
+ * 钻石是否已存在、本区块是否已包含钻石，需要链状态，不在此检查
+ */
+
+// 提交钻石的区块高度必须为 5 的倍数
+const DiamondContainBlockHeightMultiple uint64 = 5
+
+// 上一枚钻石信息
+type PrevDiamond struct {
+	Number           uint32      // 上一枚钻石序号，0 表示还没有钻石
+	ContainBlockHash fields.Hash // 包含上一枚钻石的区块哈希
+}
+
+// 由已确认的钻石创建
+func NewPrevDiamondBySmelt(smelt *stores.DiamondSmelt) *PrevDiamond {
+	if smelt == nil {
+		return &PrevDiamond{}
+	}
+	return &PrevDiamond{
+		Number:           uint32(smelt.Number),
+		ContainBlockHash: smelt.ContainBlockHash,
+	}
+}
+
+// 检查结果
+type CheckResult struct {
+	DiamondHash []byte // x16rs 钻石哈希
+	DiamondStr  string // x16rs 结果字符串
+	Diamond     string // 钻石字面量
+}
+
+// 区块高度是否可以包含钻石
+func IsDiamondContainBlockHeight(height uint64) bool {
+	return height%DiamondContainBlockHeightMultiple == 0
+}
+
+// 大于等于 height 的下一个可以包含钻石的区块高度
+func NextDiamondContainBlockHeight(height uint64) uint64 {
+	if IsDiamondContainBlockHeight(height) {
+		return height
+	}
+	return height + DiamondContainBlockHeightMultiple - height%DiamondContainBlockHeightMultiple
+}
+
+// 检查自定义消息规则
+// 第 20001 枚开始消息参与哈希计算，之前的钻石消息必须为空（否则会被忽略，序列化后也不包含）
+func CheckCustomMessage(number uint32, msg fields.Bytes32) error {
+	if number > actions.DiamondCreateCustomMessageAboveNumber {
+		if len(msg) != 32 {
+			return errs.New(errs.CodeInvalidParameter, "Diamond <%d> custom message size must be 32 but got %d.", number, len(msg))
+		}
+		return nil
+	}
+	if len(msg) > 0 && !bytes.Equal(msg, make([]byte, len(msg))) {
+		return errs.New(errs.CodeInvalidParameter, "Diamond <%d> not support custom message, need number above %d.",
+			number, actions.DiamondCreateCustomMessageAboveNumber)
+	}
+	return nil
+}
+
+// 检查挖矿计算结果
+func CheckDiamondMining(act *actions.Action_4_DiamondCreate) (*CheckResult, error) {
+	number := uint32(act.Number)
+	if number == 0 {
+		return nil, errs.New(errs.CodeInvalidParameter, "Diamond number cannot be zero.")
+	}
+	if e := CheckCustomMessage(number, act.CustomMessage); e != nil {
+		return nil, e
+	}
+	// 计算钻石哈希
+	diamondResHash, diamondStr := x16rs.Diamond(number, act.PrevHash, act.Nonce, act.Address, act.GetRealCustomMessage())
+	diamondstrval, isdia := x16rs.IsDiamondHashResultString(diamondStr)
+	if !isdia {
+		return nil, errs.New(errs.CodeInvalidParameter, "String <%s> is not diamond.", diamondStr)
+	}
+	if strings.Compare(diamondstrval, string(act.Diamond)) != 0 {
+		return nil, errs.New(errs.CodeInvalidParameter, "Diamond need <%s> but got <%s>", act.Diamond, diamondstrval)
+	}
+	// 检查钻石难度值
+	if !x16rs.CheckDiamondDifficulty(number, diamondResHash) {
+		return nil, errs.New(errs.CodeInvalidParameter, "Diamond difficulty not meet the requirements.")
+	}
+	return &CheckResult{
+		DiamondHash: diamondResHash,
+		DiamondStr:  diamondStr,
+		Diamond:     diamondstrval,
+	}, nil
+}
+
+// 检查与上一枚钻石的衔接
+func CheckDiamondPrev(act *actions.Action_4_DiamondCreate, prev *PrevDiamond) error {
+	if prev == nil || prev.Number == 0 {
+		if uint32(act.Number) != 1 {
+			return errs.New(errs.CodeInvalidParameter, "Diamond number must be <1> but got <%d>.", act.Number)
+		}
+		return nil
+	}
+	if !act.PrevHash.Equal(prev.ContainBlockHash) {
+		return errs.New(errs.CodeInvalidParameter, "Diamond prev hash must be <%s> but got <%s>.",
+			hex.EncodeToString(prev.ContainBlockHash), hex.EncodeToString(act.PrevHash))
+	}
+	if prev.Number+1 != uint32(act.Number) {
+		return errs.New(errs.CodeInvalidParameter, "Diamond number must be <%d> but got <%d>.", prev.Number+1, act.Number)
+	}
+	return nil
+}
+
+// 提交前完整检查
+// pendingHeight 为预计包含的区块高度，为 0 时不检查高度
+func CheckDiamondCreate(act *actions.Action_4_DiamondCreate, prev *PrevDiamond, pendingHeight uint64) (*CheckResult, error) {
+	if act == nil {
+		return nil, errs.New(errs.CodeInvalidParameter, "Diamond create action cannot be nil.")
+	}
+	e := CheckDiamondPrev(act, prev)
+	if e != nil {
+		return nil, e
+	}
+	res, e := CheckDiamondMining(act)
+	if e != nil {
+		return nil, e
+	}
+	if pendingHeight > 0 && !IsDiamondContainBlockHeight(pendingHeight) {
+		return res, errs.New(errs.CodeRetryLater, "Diamond must be in block height multiple of 5.").WithHeight(pendingHeight)
+	}
+	return res, nil
+}
+
+// 检查钻石交易：只能包含唯一一个 action
+func CheckDiamondCreateTransaction(tx interfaces.Transaction, prev *PrevDiamond, pendingHeight uint64) (*CheckResult, error) {
+	acts := tx.GetActions()
+	if len(acts) != 1 {
+		return nil, errs.New(errs.CodeInvalidParameter, "Diamond create tx need only one action but got %d actions.", len(acts))
+	}
+	act, ok := acts[0].(*actions.Action_4_DiamondCreate)
+	if !ok {
+		return nil, errs.New(errs.CodeInvalidParameter, "Action kind must be 4 but got %d.", acts[0].Kind())
+	}
+	res, e := CheckDiamondCreate(act, prev, pendingHeight)
+	if e != nil {
+		return res, errs.From(e).WithTxHash(tx.Hash())
+	}
+	return res, nil
+}
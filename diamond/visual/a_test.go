@@ -0,0 +1,149 @@
+package visual
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"strings"
+	"testing"
+)
+
+// 按 actions.CalculateVisualGeneByDiamondStuffHash 的规则由字面量后 6 位和基因哈希构造
+// 与共识生成函数的对照测试见 Test_visual_gene_decode_consensus
+func buildTestGene(diamondstr string, vgenehash []byte) fields.Bytes10 {
+	literal := "WTYUIAHXVMEKBSZN"
+	nibbles := make([]byte, 18)
+	for i := 0; i < 6; i++ {
+		nibbles[i] = byte(strings.IndexByte(literal, diamondstr[10+i]))
+	}
+	for i := 0; i < 11; i++ {
+		nibbles[6+i] = vgenehash[20+i] % 16
+	}
+	gene := make([]byte, 10)
+	gene[0] = vgenehash[31]
+	for i := 0; i < 9; i++ {
+		gene[1+i] = nibbles[i*2]<<4 | nibbles[i*2+1]
+	}
+	return gene
+}
+
+func Test_decode_visual_gene(t *testing.T) {
+
+	gene := buildTestGene("WTYUIAHXVMWTYUIA", fields.CalculateHash([]byte("diamond-visual-1")))
+	traits, e := DecodeVisualGene(gene)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for i := 0; i < 6; i++ {
+		if traits.ColorIndexes[i] != uint8(i) {
+			t.Fatalf("color index %d need %d but got %d", i, i, traits.ColorIndexes[i])
+		}
+	}
+	if traits.Shape != gene[0]%16 || traits.Pattern != (gene[0]>>4)%4 {
+		t.Fatal("shape or pattern error")
+	}
+	fmt.Println(hex.EncodeToString(gene), traits.Describe())
+
+	// 长度错误
+	_, e = DecodeVisualGene(fields.Bytes10([]byte{1, 2, 3}))
+	if e == nil {
+		t.Fatal("need size error")
+	}
+}
+
+// 可视化基因解码与共识生成规则一致：由 actions.CalculateVisualGeneByDiamondStuffHash 生成，
+// 经 DiamondSmelt 储存读出后再解码，覆盖第 40000 枚前后两种基因哈希
+func Test_visual_gene_decode_consensus(t *testing.T) {
+
+	literal := "WTYUIAHXVMEKBSZN"
+	blkhash := fields.CalculateHash([]byte("contain block"))
+	for _, c := range []struct {
+		number  uint32
+		diamond string
+	}{
+		{1, "0000000000NHMYYM"},
+		{39999, "0000000000WTYUIA"},
+		{40000, "0000000000HXVMEK"},
+		{40001, "0000000000BSZNAU"},
+		{123456, "0000000000KZMIYX"},
+	} {
+		stuffhx := fields.CalculateHash([]byte(c.diamond))
+		gene, e := actions.CalculateVisualGeneByDiamondStuffHash(c.number, stuffhx, c.diamond, blkhash)
+		if e != nil {
+			t.Fatal(e)
+		}
+		smelt := &stores.DiamondSmelt{
+			Diamond:              fields.DiamondName(c.diamond[10:]),
+			ContainBlockHash:     blkhash,
+			PrevContainBlockHash: blkhash,
+			MinerAddress:         fields.Address(make([]byte, 21)),
+			ApproxFeeOffer:       *fields.NewAmountSmall(1, 248),
+			Nonce:                fields.Bytes8(make([]byte, 8)),
+			CustomMessage:        fields.Bytes32(make([]byte, 32)),
+			VisualGene:           gene,
+		}
+		body, _ := smelt.Serialize()
+		stored := &stores.DiamondSmelt{}
+		if _, e = stored.Parse(body, 0); e != nil {
+			t.Fatal(e)
+		}
+		traits, e := DecodeVisualGene(stored.VisualGene)
+		if e != nil {
+			t.Fatal(e)
+		}
+		vgenehash := []byte(stuffhx)
+		if c.number > actions.DiamondResourceHashAndContainBlockHashDecideVisualGeneAboveNumber {
+			vgenehash = fields.CalculateHash(append(append([]byte{}, stuffhx...), blkhash...))
+		}
+		for i := 0; i < 6; i++ {
+			if int(traits.ColorIndexes[i]) != strings.IndexByte(literal, c.diamond[10+i]) {
+				t.Fatalf("diamond %s color %d not match literal", c.diamond, i)
+			}
+		}
+		for i := 0; i < 11; i++ {
+			if traits.ColorIndexes[6+i] != vgenehash[20+i]%16 {
+				t.Fatalf("diamond %s color %d not match gene hash", c.diamond, 6+i)
+			}
+		}
+		if traits.Shape != vgenehash[31]%16 || traits.Pattern != (vgenehash[31]>>4)%4 {
+			t.Fatalf("diamond %s shape or pattern not match gene hash", c.diamond)
+		}
+		fmt.Println(c.number, c.diamond[10:], hex.EncodeToString(gene), traits.ShapeName, traits.PatternName, traits.MainColor)
+	}
+}
+
+func Test_render_svg(t *testing.T) {
+
+	// 输出固定，修改渲染规则会导致摘要变化
+	genes := map[string]string{
+		"000123456789abcdef00": "e18c490024fbb27e", // round solid
+		"1bfedcba987654321000": "a7f69c3f965bd506", // baguette gradient
+		"2755555555555555550":  "",                 // invalid
+		"a2c3c3c3c3c3c3c3c300": "0365a21217427ac9", // princess sparkle
+		"3f00000000000000ff00": "622d742b1502b747", // octagon frosted
+	}
+	for gx, want := range genes {
+		gene, _ := hex.DecodeString(gx)
+		svg1, e := RenderSVG(gene, 120)
+		if len(gene) != VisualGeneSize {
+			if e == nil {
+				t.Fatal("need size error")
+			}
+			continue
+		}
+		if e != nil {
+			t.Fatal(e)
+		}
+		svg2, _ := RenderSVG(gene, 120)
+		if svg1 != svg2 || !strings.HasPrefix(svg1, "<svg") || !strings.HasSuffix(svg1, "</svg>") {
+			t.Fatal("svg not deterministic")
+		}
+		digest := sha256.Sum256([]byte(svg1))
+		if hex.EncodeToString(digest[:8]) != want {
+			t.Fatalf("gene %s svg digest need %s but got %s", gx, want, hex.EncodeToString(digest[:8]))
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package visual
+
+import (
+	"fmt"
+	"github.com/hacash/core/fields"
+)
+
+/**
+ * 钻石可视化基因解码
+ * VisualGene 共 10 字节，由 actions.CalculateVisualGeneByDiamondStuffHash 生成：
+ *   第 1 字节：形状选择器，低 4 位为形状，高 4 位为纹理
+ *   后 9 字节：18 个半字节颜色选择器
+ *     前 6 个来自钻石字面量最后 6 位（WTYUIAHXVMEKBSZN 对应 0~F）
+ *     中间 11 个来自基因哈希
+ *     最后 1 个固定为 0 （补齐位，不使用）
+ */
+
+const (
+	VisualGeneSize   = 10
+	ColorSlotCount   = 17 // 有效颜色选择器数量
+	ShapeKindCount   = 16
+	PatternKindCount = 4
+)
+
+// 16 色调色板，颜色选择器的值即为下标
+var DiamondPalette = [16]string{
+	"#F0F8FF", // 0 冰白
+	"#E6E6FA", // 1 薰衣草
+	"#B0E0E6", // 2 浅蓝
+	"#87CEEB", // 3 天蓝
+	"#4682B4", // 4 钢蓝
+	"#1E90FF", // 5 宝蓝
+	"#00CED1", // 6 青绿
+	"#3CB371", // 7 翠绿
+	"#9ACD32", // 8 黄绿
+	"#FFD700", // 9 金黄
+	"#FFA500", // A 橙
+	"#FF7F50", // B 珊瑚
+	"#DC143C", // C 红宝石
+	"#FF69B4", // D 粉
+	"#9370DB", // E 紫
+	"#2F4F4F", // F 墨黑
+}
+
+// 纹理
+const (
+	PatternSolid    uint8 = 0 // 纯色
+	PatternGradient uint8 = 1 // 渐变
+	PatternSparkle  uint8 = 2 // 闪光
+	PatternFrosted  uint8 = 3 // 磨砂
+)
+
+var patternNames = [PatternKindCount]string{"solid", "gradient", "sparkle", "frosted"}
+
+// 解码后的外观特征
+type Traits struct {
+	Shape        uint8
+	ShapeName    string
+	Pattern      uint8
+	PatternName  string
+	ColorIndexes [ColorSlotCount]uint8 // 调色板下标
+	Colors       [ColorSlotCount]string
+	MainColor    string // 出现次数最多的颜色，相同次数取靠前的
+}
+
+// 解码可视化基因
+func DecodeVisualGene(gene fields.Bytes10) (*Traits, error) {
+	if len(gene) != VisualGeneSize {
+		return nil, fmt.Errorf("visual gene size must be %d but got %d", VisualGeneSize, len(gene))
+	}
+	selector := gene[0]
+	t := &Traits{
+		Shape:   selector % ShapeKindCount,
+		Pattern: (selector >> 4) % PatternKindCount,
+	}
+	t.ShapeName = diamondShapes[t.Shape].Name
+	t.PatternName = patternNames[t.Pattern]
+	// 颜色选择器
+	var counts [16]int
+	for i := 0; i < ColorSlotCount; i++ {
+		b := gene[1+i/2]
+		var x uint8
+		if i%2 == 0 {
+			x = b >> 4
+		} else {
+			x = b & 0x0f
+		}
+		t.ColorIndexes[i] = x
+		t.Colors[i] = DiamondPalette[x]
+		counts[x]++
+	}
+	maincolor := t.ColorIndexes[0]
+	for i := 1; i < ColorSlotCount; i++ {
+		x := t.ColorIndexes[i]
+		if counts[x] > counts[maincolor] {
+			maincolor = x
+		}
+	}
+	t.MainColor = DiamondPalette[maincolor]
+	return t, nil
+}
+
+// json api
+func (t *Traits) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"shape":        t.Shape,
+		"shape_name":   t.ShapeName,
+		"pattern":      t.Pattern,
+		"pattern_name": t.PatternName,
+		"colors":       t.Colors[:],
+		"main_color":   t.MainColor,
+	}
+}
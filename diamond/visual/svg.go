@@ -0,0 +1,153 @@
+package visual
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hacash/core/fields"
+)
+
+/**
+ * 钻石 SVG 渲染
+ * 画布 240x240 ，侧视图：冠部（台面到腰线）和亭部（腰线到底尖）
+ * 冠部和亭部各分为 k 段，冠部 2k 个三角面，亭部 k 个面，共 3k 个刻面
+ * 第 i 个刻面使用第 i%17 个颜色选择器
+ * 全部坐标为整数，保证不同实现输出的 SVG 字节完全一致
+ */
+
+const (
+	svgCanvasSize = 240
+	svgCenterX    = 120
+)
+
+// 形状参数
+type diamondShape struct {
+	Name       string
+	Width      int // 腰线宽度
+	TableWidth int // 台面宽度
+	Top        int // 台面高度位置
+	Girdle     int // 腰线高度位置
+	Bottom     int // 底部高度位置
+	Culet      int // 底部平面宽度，0 为尖底
+	Segments   int // 分段数 k
+}
+
+var diamondShapes = [ShapeKindCount]diamondShape{
+	{"round", 200, 100, 50, 100, 210, 0, 4},
+	{"oval", 180, 90, 40, 100, 215, 0, 4},
+	{"princess", 200, 160, 40, 90, 200, 40, 4},
+	{"cushion", 190, 120, 45, 95, 205, 20, 5},
+	{"emerald", 180, 140, 50, 90, 190, 80, 3},
+	{"pear", 160, 70, 30, 110, 220, 0, 3},
+	{"marquise", 210, 60, 60, 110, 190, 0, 5},
+	{"heart", 200, 80, 45, 90, 215, 0, 6},
+	{"trillion", 200, 40, 50, 80, 215, 0, 3},
+	{"radiant", 200, 130, 45, 100, 205, 30, 5},
+	{"asscher", 170, 110, 35, 95, 205, 60, 4},
+	{"baguette", 160, 140, 60, 100, 180, 120, 3},
+	{"kite", 140, 40, 20, 120, 225, 0, 3},
+	{"shield", 190, 150, 40, 80, 210, 10, 4},
+	{"hexagon", 200, 100, 30, 120, 210, 100, 4},
+	{"octagon", 190, 110, 35, 90, 205, 80, 6},
+}
+
+type svgPoint struct {
+	X, Y int
+}
+
+// 把一条水平线段等分为 k 段，返回 k+1 个点
+func splitLine(width int, y int, k int) []svgPoint {
+	left := svgCenterX - width/2
+	pts := make([]svgPoint, k+1)
+	for i := 0; i <= k; i++ {
+		pts[i] = svgPoint{left + width*i/k, y}
+	}
+	return pts
+}
+
+func writePolygon(buf *bytes.Buffer, pts []svgPoint, attrs string) {
+	buf.WriteString(`<polygon points="`)
+	for i, p := range pts {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(fmt.Sprintf("%d,%d", p.X, p.Y))
+	}
+	buf.WriteString(`" ` + attrs + `/>`)
+}
+
+// 渲染可视化基因为 SVG
+func RenderSVG(gene fields.Bytes10, size int) (string, error) {
+	t, e := DecodeVisualGene(gene)
+	if e != nil {
+		return "", e
+	}
+	return t.SVG(size), nil
+}
+
+// 渲染 SVG ，size 为输出宽高像素
+func (t *Traits) SVG(size int) string {
+	if size <= 0 {
+		size = svgCanvasSize
+	}
+	shape := diamondShapes[t.Shape]
+	k := shape.Segments
+	tops := splitLine(shape.TableWidth, shape.Top, k)
+	girdles := splitLine(shape.Width, shape.Girdle, k)
+	culets := splitLine(shape.Culet, shape.Bottom, k)
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d">`,
+		svgCanvasSize, svgCanvasSize, size, size))
+	if t.Pattern == PatternGradient {
+		buf.WriteString(`<defs><linearGradient id="g" x1="0" y1="0" x2="0" y2="1">` +
+			`<stop offset="0" stop-color="#FFFFFF" stop-opacity="0.6"/>` +
+			`<stop offset="1" stop-color="#FFFFFF" stop-opacity="0"/></linearGradient></defs>`)
+	}
+	facetattr := `stroke="#FFFFFF" stroke-width="1"`
+	if t.Pattern == PatternFrosted {
+		facetattr += ` fill-opacity="0.8"`
+	}
+	// 刻面
+	n := 0
+	facet := func(pts []svgPoint) {
+		color := t.Colors[n%ColorSlotCount]
+		writePolygon(buf, pts, fmt.Sprintf(`fill="%s" %s`, color, facetattr))
+		n++
+	}
+	// 冠部
+	for i := 0; i < k; i++ {
+		facet([]svgPoint{tops[i], tops[i+1], girdles[i+1]})
+		facet([]svgPoint{tops[i], girdles[i+1], girdles[i]})
+	}
+	// 亭部
+	for i := 0; i < k; i++ {
+		if shape.Culet == 0 {
+			facet([]svgPoint{girdles[i], girdles[i+1], culets[0]})
+		} else {
+			facet([]svgPoint{girdles[i], girdles[i+1], culets[i+1], culets[i]})
+		}
+	}
+	// 纹理覆盖
+	outline := []svgPoint{tops[0], tops[k], girdles[k], culets[k], culets[0], girdles[0]}
+	switch t.Pattern {
+	case PatternGradient:
+		writePolygon(buf, outline, `fill="url(#g)"`)
+	case PatternFrosted:
+		writePolygon(buf, outline, `fill="#FFFFFF" fill-opacity="0.15"`)
+	case PatternSparkle:
+		// 三个四角星，位置由颜色选择器决定
+		for i := 0; i < 3; i++ {
+			cx := girdles[0].X + (shape.Width*int(t.ColorIndexes[i*2]))/16
+			cy := shape.Top + ((shape.Girdle-shape.Top)*int(t.ColorIndexes[i*2+1]))/16
+			r := 4 + int(t.ColorIndexes[i+6]%4)
+			star := []svgPoint{
+				{cx, cy - r*2}, {cx + r/2, cy - r/2}, {cx + r*2, cy}, {cx + r/2, cy + r/2},
+				{cx, cy + r*2}, {cx - r/2, cy + r/2}, {cx - r*2, cy}, {cx - r/2, cy - r/2},
+			}
+			writePolygon(buf, star, `fill="#FFFFFF" fill-opacity="0.9"`)
+		}
+	}
+	writePolygon(buf, outline, `fill="none" stroke="#333333" stroke-width="2"`)
+	buf.WriteString(`</svg>`)
+	return buf.String()
+}
@@ -0,0 +1,74 @@
+package errs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 可读地址，errs 不依赖 fields
+type testAddress string
+
+func (a testAddress) ToReadable() string { return string(a) }
+
+// 与 fields.Address 一样以字节切片为底层
+type testBytesAddress []byte
+
+func (a testBytesAddress) ToReadable() string { return fmt.Sprintf("addr%x", []byte(a)) }
+
+func Test_error_code_and_context(t *testing.T) {
+
+	addr := testAddress("1MzNY1oA3kfgYi75zquj3SRUPYztzXHzK9")
+
+	e1 := New(CodeInsufficientBalance, "balance %s not enough.", "ㄜ1:248").WithAddress(addr).WithHeight(100)
+	var err error = e1
+	if CodeOf(err) != CodeInsufficientBalance || !Is(err, CodeInsufficientBalance) {
+		t.Fatal("code error")
+	}
+	if !strings.Contains(err.Error(), "1MzNY1oA3kfgYi75zquj3SRUPYztzXHzK9") || !strings.Contains(err.Error(), "height: 100") {
+		t.Fatal(err.Error())
+	}
+
+	// 外层附加上下文不覆盖已有的，且不修改原错误
+	e2 := From(fmt.Errorf("wrap: %w", err)).WithHeight(200).WithTxHash([]byte{1, 2, 3})
+	if e2.Code != CodeInsufficientBalance || e2.Height != 100 || e1.TxHash != nil {
+		t.Fatal("from error")
+	}
+	fmt.Println(e2.Error())
+
+	// 空地址忽略，之后仍可附加
+	e4 := New(CodeNotFound, "not find.").WithAddress(testBytesAddress(nil))
+	if e4.Address != "" || e4.WithAddress(testBytesAddress{1}).Address != "addr01" {
+		t.Fatal("empty address error")
+	}
+
+	// 未分类错误
+	e3 := FromOr(CodeMalformed, fmt.Errorf("buf too short"))
+	if e3.Code != CodeMalformed || From(nil) != nil {
+		t.Fatal("from or error")
+	}
+}
+
+func Test_retry_later_compatible(t *testing.T) {
+
+	e1 := New(CodeRetryLater, "Diamond must be in block height multiple of 5.").WithHeight(7)
+	if !strings.HasPrefix(e1.Error(), BackToPoolMark) || !IsRetryLater(e1) {
+		t.Fatal(e1.Error())
+	}
+	// 旧格式字符串
+	if !IsRetryLater(fmt.Errorf("{BACKTOPOOL} old style")) || IsRetryLater(fmt.Errorf("other")) {
+		t.Fatal("retry later compatible error")
+	}
+}
+
+func Test_localize(t *testing.T) {
+
+	e1 := New(CodeBadSignature, "verify signature fail.")
+	if Localize(e1, LangZH) != "签名缺失或错误" || Localize(e1, "fr") != "Signature missing or invalid" {
+		t.Fatal("localize error")
+	}
+	if Localize(fmt.Errorf("x"), LangEN) != Messages[LangEN][CodeUnknown] {
+		t.Fatal("localize unknown error")
+	}
+	fmt.Println(e1.Describe())
+}
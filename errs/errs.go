@@ -0,0 +1,230 @@
+package errs
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/**
+ * 共识错误分类
+ * 交易、区块、action 的校验错误带稳定的错误码，交易池、钱包、RPC 可以按错误码处理，而不是匹配错误字符串
+ * 错误码数值一经发布不可修改，只能新增
+ */
+
+type Code uint16
+
+const (
+	CodeUnknown             Code = 0   // 未分类
+	CodeMalformed           Code = 100 // 数据格式错误、解析失败
+	CodeInvalidParameter    Code = 101 // 参数不合法
+	CodeUnsupportedKind     Code = 102 // 未知的交易、action 或区块类型
+	CodeBadSignature        Code = 200 // 签名错误或缺失
+	CodeNotPermitted        Code = 201 // 无权操作
+	CodeInsufficientBalance Code = 300 // HAC 余额不足
+	CodeInsufficientSAT     Code = 301 // 比特币余额不足
+	CodeInsufficientDiamond Code = 302 // 钻石余额不足
+	CodeNotFound            Code = 400 // 对象不存在
+	CodeAlreadyExists       Code = 401 // 对象已存在
+	CodeStatusConflict      Code = 402 // 对象状态不允许此操作
+	CodeExpired             Code = 403 // 已过期
+	CodeRetryLater          Code = 500 // 暂不满足条件，扔回交易池等待下个区块再次处理
+	CodeNotEnabled          Code = 501 // 功能尚未在主网启用
+)
+
+// 兼容旧的字符串匹配方式
+const BackToPoolMark = "{BACKTOPOOL}"
+
+var codeNames = map[Code]string{
+	CodeUnknown:             "unknown",
+	CodeMalformed:           "malformed",
+	CodeInvalidParameter:    "invalid_parameter",
+	CodeUnsupportedKind:     "unsupported_kind",
+	CodeBadSignature:        "bad_signature",
+	CodeNotPermitted:        "not_permitted",
+	CodeInsufficientBalance: "insufficient_balance",
+	CodeInsufficientSAT:     "insufficient_satoshi",
+	CodeInsufficientDiamond: "insufficient_diamond",
+	CodeNotFound:            "not_found",
+	CodeAlreadyExists:       "already_exists",
+	CodeStatusConflict:      "status_conflict",
+	CodeExpired:             "expired",
+	CodeRetryLater:          "retry_later",
+	CodeNotEnabled:          "not_enabled",
+}
+
+func (c Code) String() string {
+	if n, ok := codeNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("code_%d", uint16(c))
+}
+
+// 共识错误
+type Error struct {
+	Code    Code
+	Message string
+	Address string // 相关地址（可读格式），可为空
+	ID      []byte // 相关对象 ID （钻石、通道、借贷等），可为空
+	Height  uint64 // 相关区块高度，0 为空
+	TxHash  []byte // 所属交易哈希，可为空
+	cause   error
+}
+
+// 创建错误
+func New(code Code, format string, args ...interface{}) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// 包装已有错误，保留原错误信息
+func Wrap(code Code, err error) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{
+		Code:    code,
+		Message: err.Error(),
+		cause:   err,
+	}
+}
+
+// 转换为共识错误：已是共识错误则复制一份（保留错误码），否则包装为 CodeUnknown
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var ce *Error
+	if errors.As(err, &ce) {
+		cp := *ce
+		return &cp
+	}
+	return Wrap(CodeUnknown, err)
+}
+
+// 同 From ，但未分类的错误使用给定错误码
+func FromOr(code Code, err error) *Error {
+	ce := From(err)
+	if ce != nil && ce.Code == CodeUnknown {
+		ce.Code = code
+	}
+	return ce
+}
+
+// 可读地址，由 fields.Address 实现
+// errs 不依赖 fields ，以便 fields 的解析错误也可以使用错误码
+type Readable interface {
+	ToReadable() string
+}
+
+// 附加上下文，已有的值不覆盖，空地址忽略
+func (e *Error) WithAddress(addr Readable) *Error {
+	if e.Address != "" || addr == nil {
+		return e
+	}
+	if v := reflect.ValueOf(addr); v.Kind() == reflect.Slice && v.Len() == 0 {
+		return e
+	}
+	e.Address = addr.ToReadable()
+	return e
+}
+
+func (e *Error) WithID(id []byte) *Error {
+	if e.ID == nil {
+		e.ID = id
+	}
+	return e
+}
+
+func (e *Error) WithHeight(height uint64) *Error {
+	if e.Height == 0 {
+		e.Height = height
+	}
+	return e
+}
+
+func (e *Error) WithTxHash(hx []byte) *Error {
+	if e.TxHash == nil {
+		e.TxHash = hx
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if e.Code == CodeRetryLater && !strings.HasPrefix(msg, BackToPoolMark) {
+		msg = BackToPoolMark + " " + msg
+	}
+	ctx := make([]string, 0, 4)
+	if e.Address != "" {
+		ctx = append(ctx, "address: "+e.Address)
+	}
+	if e.ID != nil {
+		ctx = append(ctx, "id: "+hex.EncodeToString(e.ID))
+	}
+	if e.Height > 0 {
+		ctx = append(ctx, fmt.Sprintf("height: %d", e.Height))
+	}
+	if e.TxHash != nil {
+		ctx = append(ctx, "tx: "+hex.EncodeToString(e.TxHash))
+	}
+	if len(ctx) > 0 {
+		msg += " (" + strings.Join(ctx, ", ") + ")"
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// 取错误码，非共识错误返回 CodeUnknown
+func CodeOf(err error) Code {
+	var ce *Error
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return CodeUnknown
+}
+
+// 是否为指定错误码
+func Is(err error, code Code) bool {
+	return err != nil && CodeOf(err) == code
+}
+
+// 是否应扔回交易池等待下个区块再次处理
+func IsRetryLater(err error) bool {
+	if err == nil {
+		return false
+	}
+	if CodeOf(err) == CodeRetryLater {
+		return true
+	}
+	return strings.Contains(err.Error(), BackToPoolMark) // 兼容旧格式
+}
+
+// json api
+func (e *Error) Describe() map[string]interface{} {
+	data := map[string]interface{}{
+		"code":    uint16(e.Code),
+		"name":    e.Code.String(),
+		"message": e.Message,
+	}
+	if e.Address != "" {
+		data["address"] = e.Address
+	}
+	if e.ID != nil {
+		data["id"] = hex.EncodeToString(e.ID)
+	}
+	if e.Height > 0 {
+		data["height"] = e.Height
+	}
+	if e.TxHash != nil {
+		data["tx_hash"] = hex.EncodeToString(e.TxHash)
+	}
+	return data
+}
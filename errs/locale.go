@@ -0,0 +1,64 @@
+package errs
+
+/**
+ * 错误码的本地化提示
+ * 上层可以按需增加语言或覆盖文本
+ */
+
+const (
+	LangEN = "en"
+	LangZH = "zh"
+)
+
+var Messages = map[string]map[Code]string{
+	LangEN: {
+		CodeUnknown:             "Transaction check failed",
+		CodeMalformed:           "Data format error",
+		CodeInvalidParameter:    "Invalid parameter",
+		CodeUnsupportedKind:     "Unsupported type",
+		CodeBadSignature:        "Signature missing or invalid",
+		CodeNotPermitted:        "Operation not permitted",
+		CodeInsufficientBalance: "Insufficient HAC balance",
+		CodeInsufficientSAT:     "Insufficient BTC balance",
+		CodeInsufficientDiamond: "Insufficient diamonds",
+		CodeNotFound:            "Object not found",
+		CodeAlreadyExists:       "Object already exists",
+		CodeStatusConflict:      "Object status does not allow this operation",
+		CodeExpired:             "Expired",
+		CodeRetryLater:          "Not valid yet, retry in a later block",
+		CodeNotEnabled:          "Feature not enabled on mainnet yet",
+	},
+	LangZH: {
+		CodeUnknown:             "交易检查失败",
+		CodeMalformed:           "数据格式错误",
+		CodeInvalidParameter:    "参数错误",
+		CodeUnsupportedKind:     "不支持的类型",
+		CodeBadSignature:        "签名缺失或错误",
+		CodeNotPermitted:        "无权操作",
+		CodeInsufficientBalance: "HAC 余额不足",
+		CodeInsufficientSAT:     "比特币余额不足",
+		CodeInsufficientDiamond: "钻石余额不足",
+		CodeNotFound:            "对象不存在",
+		CodeAlreadyExists:       "对象已存在",
+		CodeStatusConflict:      "对象状态不允许此操作",
+		CodeExpired:             "已过期",
+		CodeRetryLater:          "暂不满足条件，请在后续区块重试",
+		CodeNotEnabled:          "功能尚未在主网启用",
+	},
+}
+
+// 本地化的错误提示，未知语言使用英文
+func Localize(err error, lang string) string {
+	if err == nil {
+		return ""
+	}
+	msgs, ok := Messages[lang]
+	if !ok {
+		msgs = Messages[LangEN]
+	}
+	code := CodeOf(err)
+	if m, ok := msgs[code]; ok {
+		return m
+	}
+	return msgs[CodeUnknown]
+}
@@ -8,7 +8,8 @@ import (
 )
 
 const (
-	AddressSize = 21
+	AddressSize       = 21
+	AddressMaxVersion = 2 // 地址版本号最大值
 )
 
 type AddressReadable = TrimString34
@@ -24,7 +25,7 @@ func CheckReadableAddress(readable string) (*Address, error) {
 		return nil, fmt.Errorf("Address format error")
 	}
 	version := uint8(hashhex[0])
-	if version > 2 {
+	if version > AddressMaxVersion {
 		return nil, fmt.Errorf("Address version error")
 	}
 	addr := Address(hashhex)
@@ -47,6 +48,11 @@ func (this Address) IsValid() bool {
 	return true
 }
 
+// 版本号是否合法
+func (this Address) IsStandardVersion() bool {
+	return this.IsValid() && this[0] <= AddressMaxVersion
+}
+
 // check equal
 func (this Address) Equal(tar Address) bool {
 	return bytes.Compare(this, tar) == 0
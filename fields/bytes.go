@@ -6,6 +6,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+
+	"github.com/hacash/core/errs"
 )
 
 var EmptyZeroBytes32 = bytes.Repeat([]byte{0}, 32)
@@ -156,7 +158,7 @@ func bytesParse(elm interface{}, buf []byte, seek uint32, maxlen uint32) (uint32
 	//fmt.Println(seek+maxlen)
 	//fmt.Println("----------")
 	if seek+maxlen > uint32(len(buf)) {
-		return 0, fmt.Errorf("[bytesParse] seek out of buf len.")
+		return 0, errs.New(errs.CodeMalformed, "[bytesParse] seek out of buf len.")
 	}
 	var nnnold = buf[seek : seek+maxlen]
 	var addrbytes = make([]byte, len(nnnold))
@@ -6,3 +6,5 @@ const (
 
 type DiamondName = Bytes6
 type DiamondNumber = VarUint3
+
+type DiamondListingId = Bytes16
@@ -3,6 +3,7 @@ package fields
 import (
 	"bytes"
 	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/x16rs"
 	"strings"
 )
@@ -40,7 +41,7 @@ func (elm DiamondListMaxLen200) Serialize() ([]byte, error) {
 func (elm *DiamondListMaxLen200) Parse(buf []byte, seek uint32) (uint32, error) {
 	var e error = nil
 	if seek >= uint32(len(buf)) {
-		return 0, fmt.Errorf("[DiamondListMaxLen200.Parse] seek out of buf len.")
+		return 0, errs.New(errs.CodeMalformed, "[DiamondListMaxLen200.Parse] seek out of buf len.")
 	}
 	elm.Count = VarUint1(0)
 	seek, e = elm.Count.Parse(buf, seek)
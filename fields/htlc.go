@@ -0,0 +1,3 @@
+package fields
+
+type HtlcId = Bytes16
@@ -1,6 +1,6 @@
 package fields
 
-import "fmt"
+import "github.com/hacash/core/errs"
 
 type ExtendMessageMaxLen255 struct {
 	Count   VarUint1
@@ -20,15 +20,15 @@ func (e ExtendMessageMaxLen255) Serialize() ([]byte, error) {
 
 func (e *ExtendMessageMaxLen255) Parse(buf []byte, seek uint32) (uint32, error) {
 	if seek >= uint32(len(buf)) {
-		return 0, fmt.Errorf("[ExtendMessageMaxLen255.Parse] seek out of buf len.")
+		return 0, errs.New(errs.CodeMalformed, "[ExtendMessageMaxLen255.Parse] seek out of buf len.")
 	}
 	e.Count = VarUint1(buf[int(seek)])
 	seek++
 	start := seek
 	end := start + uint32(e.Count)
-	if len(buf) < int(e.Count)-1 {
-		return 0, fmt.Errorf("buf is too short.")
+	if uint32(len(buf)) < end {
+		return 0, errs.New(errs.CodeMalformed, "buf is too short.")
 	}
-	e.Message = buf[start:end]
+	e.Message = append([]byte{}, buf[start:end]...)
 	return end, nil
 }
@@ -2,7 +2,8 @@ package fields
 
 import (
 	"bytes"
-	"fmt"
+
+	"github.com/hacash/core/errs"
 )
 
 // 可选地址
@@ -41,7 +42,7 @@ func (elm OptionalAddress) Serialize() ([]byte, error) {
 func (elm *OptionalAddress) Parse(buf []byte, seek uint32) (uint32, error) {
 	var e error = nil
 	if seek >= uint32(len(buf)) {
-		return 0, fmt.Errorf("[OptionalAddress.Parse] seek out of buf len.")
+		return 0, errs.New(errs.CodeMalformed, "[OptionalAddress.Parse] seek out of buf len.")
 	}
 	seek, e = elm.Exist.Parse(buf, seek)
 	if e != nil {
@@ -44,12 +44,23 @@ type ChainStateOperation interface {
 	Balance(fields.Address) *stores.Balance
 	//Satoshi(fields.Address) *stores.Satoshi
 	Lockbls(fields.LockblsId) *stores.Lockbls
+	LockblsVesting(fields.LockblsId) *stores.LockblsVesting // 归属锁仓扩展，独立的储存空间
 	Channel(fields.ChannelId) *stores.Channel
 	Diamond(fields.DiamondName) *stores.Diamond
 	DiamondSystemLending(fields.DiamondSyslendId) *stores.DiamondSystemLending
 	BitcoinSystemLending(fields.BitcoinSyslendId) *stores.BitcoinSystemLending
 	UserLending(fields.UserLendingId) *stores.UserLending
 	Chaswap(fields.HashHalfChecker) *stores.Chaswap
+	Htlc(fields.HtlcId) *stores.Htlc
+	DiamondListing(fields.DiamondListingId) *stores.DiamondListing
+	Escrow(fields.EscrowId) *stores.Escrow
+	// 地址持有的钻石，按 stores.DiamondOwnerKey 前缀扫描分页读取，返回本页和总数
+	// 对外查询请使用 diamond.ReadOwnerList ，会检查启用高度和数量
+	DiamondOwnerList(addr fields.Address, offset, limit uint32) ([]fields.DiamondName, uint32, error)
+	// 钻石流转历史，按 stores.DiamondHistoryKey 分页读取，返回本页和总数，limit 为 0 时返回 offset 之后的全部
+	DiamondHistoryList(name fields.DiamondName, offset, limit uint32) ([]*stores.DiamondHistoryRecord, uint32, error)
+	DiamondMetadata(fields.DiamondName) *stores.DiamondMetadata                      // 钻石附加元数据
+	DiamondMetadataArchive(fields.DiamondName, uint32) *stores.DiamondMetadataRecord // 写满后移出的旧版本
 
 	// operate
 
@@ -63,6 +74,9 @@ type ChainStateOperation interface {
 	LockblsUpdate(fields.LockblsId, *stores.Lockbls) error // 更新：释放（取出部分任意可取额度）
 	LockblsDelete(fields.LockblsId) error                  // 释放完毕后自动删除
 
+	LockblsVestingSet(fields.LockblsId, *stores.LockblsVesting) error // 归属锁仓扩展
+	LockblsVestingDel(fields.LockblsId) error
+
 	ChannelCreate(fields.ChannelId, *stores.Channel) error
 	ChannelUpdate(fields.ChannelId, *stores.Channel) error
 	ChannelDelete(fields.ChannelId) error
@@ -86,6 +100,29 @@ type ChainStateOperation interface {
 	ChaswapUpdate(fields.HashHalfChecker, *stores.Chaswap) error
 	ChaswapDelete(fields.HashHalfChecker) error
 
+	HtlcCreate(fields.HtlcId, *stores.Htlc) error // 创建哈希时间锁
+	HtlcUpdate(fields.HtlcId, *stores.Htlc) error // 更新：领取或退回
+	HtlcDelete(fields.HtlcId) error
+
+	DiamondListingCreate(fields.DiamondListingId, *stores.DiamondListing) error // 钻石挂单
+	DiamondListingUpdate(fields.DiamondListingId, *stores.DiamondListing) error // 成交或撤单
+	DiamondListingDelete(fields.DiamondListingId) error
+
+	EscrowCreate(fields.EscrowId, *stores.Escrow) error // 创建担保交易
+	EscrowUpdate(fields.EscrowId, *stores.Escrow) error // 更新：放款或退款
+	EscrowDelete(fields.EscrowId) error
+
+	DiamondOwnerAdd(fields.Address, fields.DiamondName) error // 地址持有钻石索引，每个钻石一条记录
+	DiamondOwnerDel(fields.Address, fields.DiamondName) error
+
+	DiamondHistoryPush(fields.DiamondName, *stores.DiamondHistoryRecord) error // 钻石流转历史，尾部追加一条
+	DiamondHistoryPop(fields.DiamondName) error                                // 移除尾部一条
+
+	DiamondMetadataSet(fields.DiamondName, *stores.DiamondMetadata) error // 钻石附加元数据
+	DiamondMetadataDel(fields.DiamondName) error
+	DiamondMetadataArchiveSet(fields.DiamondName, *stores.DiamondMetadataRecord) error // 按版本号保存移出的旧版本
+	DiamondMetadataArchiveDel(fields.DiamondName, uint32) error
+
 	// movebtc
 	SaveMoveBTCBelongTxHash(trsno uint32, txhash []byte) error
 	ReadMoveBTCTxHashByNumber(trsno uint32) ([]byte, error)
@@ -1,5 +1,10 @@
 package interfaces
 
+import (
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+)
+
 type ChainState interface {
 	ChainStateOperation
 
@@ -8,3 +13,16 @@ type ChainState interface {
 	Destory() // 销毁，包括删除所有文件储存
 
 }
+
+// 遍历全部借贷合约，回调返回 false 则停止遍历
+// 可选实现，不属于 ChainState ，使用方按类型断言检查
+type LendingStateIterator interface {
+	IterateDiamondSystemLending(func(fields.DiamondSyslendId, *stores.DiamondSystemLending) bool) error
+	IterateBitcoinSystemLending(func(fields.BitcoinSyslendId, *stores.BitcoinSystemLending) bool) error
+	IterateUserLending(func(fields.UserLendingId, *stores.UserLending) bool) error
+}
+
+// 遍历全部钻石，回调返回 false 则停止遍历，同样为可选实现
+type DiamondStateIterator interface {
+	IterateDiamond(func(fields.DiamondName, *stores.Diamond) bool) error
+}
@@ -0,0 +1,138 @@
+package lending
+
+import (
+	"fmt"
+	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"testing"
+)
+
+func Test_diamond_lending_stages(t *testing.T) {
+
+	mainaddr, _ := fields.CheckReadableAddress("1MzNY1oA3kfgYi75zquj3SRUPYztzXHzK9")
+
+	obj := stores.NewDiamondSystemLending(*mainaddr)
+	obj.CreateBlockHeight = 1
+	obj.BorrowPeriod = 4
+	obj.LoanTotalAmountMei = 100
+
+	pst := NewDiamondSystemLendingPosition([]byte("12345678901234"), obj)
+	for _, s := range pst.Stages {
+		fmt.Println(s.Stage, s.StartHeight, s.EndHeight, s.Redeemers)
+	}
+
+	// 阶段划分必须与 coinbase 的计算一致
+	dslbpbn := DiamondSystemLendingPeriodBlockNumber()
+	for h := uint64(1); h < 20*dslbpbn; h += dslbpbn / 2 {
+		stage, amt, _ := coinbase.CalculationDiamondSystemLendingRedeemAmount(
+			*mainaddr, *mainaddr, 4, 1, 100, int64(dslbpbn), int64(h))
+		span := pst.StageAt(h)
+		if uint8(span.Stage) != stage {
+			t.Errorf("height %d stage %d != %d", h, span.Stage, stage)
+		}
+		fmt.Println(h, span.Stage, amt.ToFinString())
+	}
+
+	curve, e := pst.RedeemCurve(1, 20*dslbpbn, dslbpbn)
+	if e != nil {
+		t.Error(e)
+	}
+	for _, p := range curve {
+		fmt.Println(p.Height, p.Stage, p.Amount.ToMeiString())
+	}
+}
+
+func Test_user_lending_redeemers(t *testing.T) {
+
+	mortgagor, _ := fields.CheckReadableAddress("1MzNY1oA3kfgYi75zquj3SRUPYztzXHzK9")
+	lender, _ := fields.CheckReadableAddress("1AVRuFXNFi3rdMrPH4hdqSgFrEBnWisWaS")
+	other, _ := fields.CheckReadableAddress("1EDUeK8NAjrgYhgDFv9NJecn8dNyJJsu3y")
+
+	obj := &stores.UserLending{
+		IsPublicRedeemable:     fields.CreateBool(false),
+		IsRedemptionOvertime:   fields.CreateBool(true),
+		CreateBlockHeight:      100,
+		ExpireBlockHeight:      200,
+		MortgagorAddress:       *mortgagor,
+		LenderAddress:          *lender,
+		AgreedRedemptionAmount: *fields.NewAmountSmall(12, 248),
+	}
+	pst := NewUserLendingPosition([]byte("12345678901234567"), obj)
+
+	for _, h := range []uint64{150, 200, 201} {
+		fmt.Println(h, pst.CanRedeem(*mortgagor, h), pst.CanRedeem(*lender, h), pst.CanRedeem(*other, h))
+	}
+	if pst.CanRedeem(*lender, 200) || !pst.CanRedeem(*lender, 201) || pst.CanRedeem(*other, 201) || !pst.CanRedeem(*mortgagor, 201) {
+		t.Error("user lending redeemers error")
+	}
+	amt, _ := pst.RedeemAmountForAddressAt(*lender, 201)
+	fmt.Println(amt.ToFinString())
+}
+
+type testLendingIterator struct {
+	interfaces.ChainStateOperation
+	diamonds map[string]*stores.DiamondSystemLending
+}
+
+func (it *testLendingIterator) IterateDiamondSystemLending(fn func(fields.DiamondSyslendId, *stores.DiamondSystemLending) bool) error {
+	for k, v := range it.diamonds {
+		if !fn(fields.DiamondSyslendId(k), v) {
+			break
+		}
+	}
+	return nil
+}
+
+func (it *testLendingIterator) IterateBitcoinSystemLending(fn func(fields.BitcoinSyslendId, *stores.BitcoinSystemLending) bool) error {
+	return nil
+}
+
+func (it *testLendingIterator) IterateUserLending(fn func(fields.UserLendingId, *stores.UserLending) bool) error {
+	return nil
+}
+
+type testBalanceOnlyState struct {
+	interfaces.ChainStateOperation
+}
+
+func Test_scan_liquidation_without_iterator(t *testing.T) {
+
+	// 未实现遍历的链状态
+	if _, e := ScanLiquidationOpportunities(&testBalanceOnlyState{}, 2); !errs.Is(e, errs.CodeUnsupportedKind) {
+		t.Fatal("chain state without iterator must be error")
+	}
+}
+
+func Test_scan_liquidation(t *testing.T) {
+
+	mainaddr, _ := fields.CheckReadableAddress("1MzNY1oA3kfgYi75zquj3SRUPYztzXHzK9")
+
+	obj := stores.NewDiamondSystemLending(*mainaddr)
+	obj.CreateBlockHeight = 1
+	obj.BorrowPeriod = 1
+	obj.LoanTotalAmountMei = 100
+	obj.MortgageDiamondList.Diamonds = []fields.DiamondName{fields.DiamondName("AAABBB")}
+	obj.MortgageDiamondList.Count = 1
+
+	it := &testLendingIterator{diamonds: map[string]*stores.DiamondSystemLending{"12345678901234": obj}}
+
+	dslbpbn := DiamondSystemLendingPeriodBlockNumber()
+	for _, h := range []uint64{2, dslbpbn + 2, dslbpbn*2 + 2} {
+		list, e := ScanLiquidationOpportunities(it, h)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if h == 2 && len(list) != 0 {
+			t.Fatalf("private stage cannot be liquidated")
+		}
+		if h > 2 && len(list) != 1 {
+			t.Fatalf("height %d must have one opportunity", h)
+		}
+		for _, v := range list {
+			fmt.Println(h, v.Stage.Stage, v.RedeemAmount.ToFinString(), v.CollateralDiamondCount, v.RedeemMeiPerDiamond)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package offer
+
+import (
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/fields"
+	"testing"
+)
+
+func Test_offer_complete(t *testing.T) {
+
+	lender := account.CreateAccountByPassword("lender")
+	mortgagor := account.CreateAccountByPassword("mortgagor")
+
+	ofr := &UserLendingOffer{
+		LenderAddress:            lender.Address,
+		MortgagorAddress:         fields.NewEmptyOptionalAddress(),
+		LoanTotalAmount:          *fields.NewAmountSmall(10, 248),
+		AgreedRedemptionAmount:   *fields.NewAmountSmall(11, 248),
+		PreBurningInterestAmount: *fields.NewAmountSmall(1, 247),
+		IsRedemptionOvertime:     fields.CreateBool(false),
+		IsPublicRedeemable:       fields.CreateBool(true),
+		AgreedExpireBlockHeight:  5000,
+		OfferExpireBlockHeight:   1000,
+		MinMortgageDiamondCount:  1,
+		Nonce:                    []byte("12345678"),
+	}
+	e := ofr.FillLenderSign(lender)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// 序列化往返
+	body, _ := ofr.Serialize()
+	ofr2 := &UserLendingOffer{}
+	_, e = ofr2.Parse(body, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = ofr2.VerifySignature()
+	if e != nil {
+		t.Fatal(e)
+	}
+	fmt.Println(ofr2.Describe())
+
+	// 确定性 ID
+	id1 := ofr.DeriveLendingId(mortgagor.Address)
+	id2 := ofr2.DeriveLendingId(mortgagor.Address)
+	if id1.ToHex() != id2.ToHex() || id1[0] == 0 || id1[len(id1)-1] == 0 {
+		t.Fatal("derive lending id error")
+	}
+
+	// 抵押物不足
+	_, e = ofr2.CompleteAction(mortgagor.Address, fields.DiamondListMaxLen200{}, 0)
+	if e == nil {
+		t.Fatal("collateral must not enough")
+	}
+
+	dias := fields.DiamondListMaxLen200{Count: 1, Diamonds: []fields.DiamondName{fields.DiamondName("AAABBB")}}
+	tx, e := ofr2.CompleteTransaction(mortgagor, dias, 0, fields.NewAmountSmall(1, 244))
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = ofr.CheckCompletedTransaction(tx)
+	if e != nil {
+		t.Fatal(e)
+	}
+	fmt.Println(id1.ToHex())
+}
@@ -0,0 +1,436 @@
+package offer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/hacash/core/account"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+	"github.com/hacash/core/sys"
+	"github.com/hacash/core/transactions"
+)
+
+/**
+ * 用户间借贷链下报价单
+ *
+ * 流程：
+ * 1. 放款人填写借贷条款（借出金额、约定赎回金额即利息、到期高度、可接受的抵押物）并签名，发布到借贷撮合板
+ * 2. 借款人选择报价单，填入自己的抵押物，生成 Action_19 交易并作为主地址（支付手续费）签名
+ * 3. 放款人（或其托管的自动签名程序）检查交易与报价单条款一致后补充签名，广播上链
+ *
+ * 借贷合约ID由报价单哈希和借款人地址确定性派生，所以同一报价单对同一借款人只能成交一次
+ */
+
+type UserLendingOffer struct {
+	LenderAddress    fields.Address         // 放款人地址
+	MortgagorAddress fields.OptionalAddress // 指定借款人，为空则任何人可以接受
+
+	LoanTotalAmount          fields.Amount // 借出HAC额度
+	AgreedRedemptionAmount   fields.Amount // 约定的赎回金额（包含利息）
+	PreBurningInterestAmount fields.Amount // 预先销毁的利息，由放款人支付，必须大于等于借出金额的 1%
+
+	IsRedemptionOvertime    fields.Bool        // 是否超期仍可赎回（自动展期）
+	IsPublicRedeemable      fields.Bool        // 到期后是否公共可赎回
+	AgreedExpireBlockHeight fields.BlockHeight // 约定到期的区块高度
+	OfferExpireBlockHeight  fields.BlockHeight // 报价单有效期，超过此高度不能再成交
+
+	// 可接受的抵押物：钻石数量和比特币数量都必须达到最低要求
+	MinMortgageDiamondCount fields.VarUint1 // 最少抵押钻石数量
+	MinMortgageSatoshi      fields.Satoshi  // 最少抵押比特币数量 单位：SAT
+
+	Nonce fields.Bytes8 // 随机数，区分同样条款的不同报价单
+
+	LenderSign fields.Sign // 放款人签名
+}
+
+func (elm *UserLendingOffer) Size() uint32 {
+	return elm.LenderAddress.Size() +
+		elm.MortgagorAddress.Size() +
+		elm.LoanTotalAmount.Size() +
+		elm.AgreedRedemptionAmount.Size() +
+		elm.PreBurningInterestAmount.Size() +
+		elm.IsRedemptionOvertime.Size() +
+		elm.IsPublicRedeemable.Size() +
+		elm.AgreedExpireBlockHeight.Size() +
+		elm.OfferExpireBlockHeight.Size() +
+		elm.MinMortgageDiamondCount.Size() +
+		elm.MinMortgageSatoshi.Size() +
+		elm.Nonce.Size() +
+		elm.LenderSign.Size()
+}
+
+func (elm *UserLendingOffer) SerializeForSign() ([]byte, error) {
+	var buffer bytes.Buffer
+	var bt []byte
+	bt, _ = elm.LenderAddress.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.MortgagorAddress.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.LoanTotalAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.AgreedRedemptionAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.PreBurningInterestAmount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.IsRedemptionOvertime.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.IsPublicRedeemable.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.AgreedExpireBlockHeight.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.OfferExpireBlockHeight.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.MinMortgageDiamondCount.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.MinMortgageSatoshi.Serialize()
+	buffer.Write(bt)
+	bt, _ = elm.Nonce.Serialize()
+	buffer.Write(bt)
+	return buffer.Bytes(), nil
+}
+
+func (elm *UserLendingOffer) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var bt []byte
+	bt, _ = elm.SerializeForSign() // 签名部分数据体
+	buffer.Write(bt)
+	bt, _ = elm.LenderSign.Serialize()
+	buffer.Write(bt)
+	return buffer.Bytes(), nil
+}
+
+func (elm *UserLendingOffer) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = elm.LenderAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.MortgagorAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LoanTotalAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.AgreedRedemptionAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.PreBurningInterestAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.IsRedemptionOvertime.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.IsPublicRedeemable.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.AgreedExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.OfferExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.MinMortgageDiamondCount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.MinMortgageSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Nonce.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LenderSign.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+// 报价单哈希，即签名数据
+func (elm *UserLendingOffer) SignStuffHash() fields.Hash {
+	var conbt, _ = elm.SerializeForSign()
+	return fields.CalculateHash(conbt)
+}
+
+// 放款人签名
+func (elm *UserLendingOffer) FillLenderSign(acc *account.Account) error {
+	if elm.LenderAddress.NotEqual(fields.Address(acc.Address)) {
+		return fmt.Errorf("Account %s is not the lender %s.", fields.Address(acc.Address).ToReadable(), elm.LenderAddress.ToReadable())
+	}
+	sig, e := acc.Private.Sign(elm.SignStuffHash())
+	if e != nil {
+		return e
+	}
+	elm.LenderSign = fields.Sign{
+		PublicKey: acc.PublicKey,
+		Signature: sig.Serialize64(),
+	}
+	return nil
+}
+
+// 检查放款人签名
+func (elm *UserLendingOffer) VerifySignature() error {
+	if len(elm.LenderSign.PublicKey) != 33 || len(elm.LenderSign.Signature) != 64 {
+		return fmt.Errorf("Lender sign format error.")
+	}
+	if elm.LenderSign.GetAddress().NotEqual(elm.LenderAddress) {
+		return fmt.Errorf("Lender sign public key not match address %s.", elm.LenderAddress.ToReadable())
+	}
+	ok, _ := account.CheckSignByHash32(elm.SignStuffHash(), elm.LenderSign.PublicKey, elm.LenderSign.Signature)
+	if !ok {
+		return fmt.Errorf("Lender %s verify signature fail.", elm.LenderAddress.ToReadable())
+	}
+	return nil
+}
+
+// 检查条款本身，与 Action_19 的规则一致
+func (elm *UserLendingOffer) CheckTerms() error {
+	if !elm.LenderAddress.IsValid() {
+		return fmt.Errorf("Lender address is invalid.")
+	}
+	if elm.MortgagorAddress.Exist.Check() && elm.MortgagorAddress.Addr.Equal(elm.LenderAddress) {
+		return fmt.Errorf("Cannot lending to myself.")
+	}
+	for _, amt := range []*fields.Amount{&elm.LoanTotalAmount, &elm.AgreedRedemptionAmount, &elm.PreBurningInterestAmount} {
+		if len(amt.Numeral) > 4 {
+			return fmt.Errorf("Amount <%s> byte length is too long.", amt.ToFinString())
+		}
+		if !amt.IsPositive() {
+			return fmt.Errorf("Amount <%s> must be positive.", amt.ToFinString())
+		}
+	}
+	mustBurnDesk := elm.LoanTotalAmount.Copy()
+	if mustBurnDesk.Unit > 2 {
+		mustBurnDesk.Unit -= 2 // 1%
+	}
+	if elm.PreBurningInterestAmount.LessThan(mustBurnDesk) {
+		return fmt.Errorf("PreBurningInterestAmount <%s> can not less than <%s>", elm.PreBurningInterestAmount.ToFinString(), mustBurnDesk.ToFinString())
+	}
+	if elm.MinMortgageDiamondCount > 200 {
+		return fmt.Errorf("Diamonds quantity cannot over 200")
+	}
+	if elm.MinMortgageDiamondCount == 0 && elm.MinMortgageSatoshi == 0 {
+		return fmt.Errorf("Min mortgage diamond and bitcoin cannot be empty at the same time")
+	}
+	if elm.OfferExpireBlockHeight >= elm.AgreedExpireBlockHeight {
+		return fmt.Errorf("Offer expire block height %d must less than agreed expire block height %d.", elm.OfferExpireBlockHeight, elm.AgreedExpireBlockHeight)
+	}
+	return nil
+}
+
+// 确定性派生借贷合约ID：hash(报价单哈希 + 借款人地址) 的前 17 位，首尾不能为零
+func (elm *UserLendingOffer) DeriveLendingId(mortgagor fields.Address) fields.UserLendingId {
+	var stuff bytes.Buffer
+	stuff.Write(elm.SignStuffHash())
+	stuff.Write(mortgagor)
+	hx := fields.CalculateHash(stuff.Bytes())
+	lid := make([]byte, stores.UserLendingIdLength)
+	copy(lid, hx[0:stores.UserLendingIdLength])
+	if lid[0] == 0 {
+		lid[0] = 1
+	}
+	if lid[stores.UserLendingIdLength-1] == 0 {
+		lid[stores.UserLendingIdLength-1] = 1
+	}
+	return lid
+}
+
+// 检查借款人的抵押物是否满足报价单
+func (elm *UserLendingOffer) checkCollateral(mortgagor fields.Address, diamonds *fields.DiamondListMaxLen200, satoshi fields.Satoshi) error {
+	if mortgagor.Equal(elm.LenderAddress) {
+		return fmt.Errorf("Cannot lending to myself.")
+	}
+	if elm.MortgagorAddress.Exist.Check() && elm.MortgagorAddress.Addr.NotEqual(mortgagor) {
+		return fmt.Errorf("Offer only for mortgagor %s.", elm.MortgagorAddress.Addr.ToReadable())
+	}
+	dianum := len(diamonds.Diamonds)
+	if int(diamonds.Count) != dianum {
+		return fmt.Errorf("Diamonds quantity error")
+	}
+	if dianum > 200 {
+		return fmt.Errorf("Diamonds quantity cannot over 200")
+	}
+	if dianum < int(elm.MinMortgageDiamondCount) {
+		return fmt.Errorf("Mortgage diamonds need at least %d but got %d.", elm.MinMortgageDiamondCount, dianum)
+	}
+	if satoshi < elm.MinMortgageSatoshi {
+		return fmt.Errorf("Mortgage satoshi need at least %d but got %d.", elm.MinMortgageSatoshi, satoshi)
+	}
+	return nil
+}
+
+// 借款人接受报价单，生成借贷 action
+func (elm *UserLendingOffer) CompleteAction(mortgagor fields.Address, diamonds fields.DiamondListMaxLen200, satoshi fields.Satoshi) (*actions.Action_19_UsersLendingCreate, error) {
+	e := elm.CheckTerms()
+	if e != nil {
+		return nil, e
+	}
+	e = elm.checkCollateral(mortgagor, &diamonds, satoshi)
+	if e != nil {
+		return nil, e
+	}
+	mtgbtc := fields.NewEmptySatoshiVariation()
+	if satoshi > 0 {
+		mtgbtc = satoshi.GetSatoshiVariation()
+	}
+	act := &actions.Action_19_UsersLendingCreate{
+		LendingID:                elm.DeriveLendingId(mortgagor),
+		IsRedemptionOvertime:     elm.IsRedemptionOvertime,
+		IsPublicRedeemable:       elm.IsPublicRedeemable,
+		AgreedExpireBlockHeight:  elm.AgreedExpireBlockHeight,
+		MortgagorAddress:         mortgagor,
+		LenderAddress:            elm.LenderAddress,
+		MortgageBitcoin:          mtgbtc,
+		MortgageDiamondList:      diamonds,
+		LoanTotalAmount:          elm.LoanTotalAmount,
+		AgreedRedemptionAmount:   elm.AgreedRedemptionAmount,
+		PreBurningInterestAmount: elm.PreBurningInterestAmount,
+	}
+	return act, nil
+}
+
+// 借款人接受报价单，生成交易并以主地址签名，之后交给放款人补充签名
+func (elm *UserLendingOffer) CompleteTransaction(mortgagor *account.Account, diamonds fields.DiamondListMaxLen200, satoshi fields.Satoshi, fee *fields.Amount) (*transactions.Transaction_2_Simple, error) {
+	mtgaddr := fields.Address(mortgagor.Address)
+	act, e := elm.CompleteAction(mtgaddr, diamonds, satoshi)
+	if e != nil {
+		return nil, e
+	}
+	tx, e := transactions.NewEmptyTransaction_2_Simple(mtgaddr)
+	if e != nil {
+		return nil, e
+	}
+	tx.Fee = *fee
+	e = tx.AppendAction(act)
+	if e != nil {
+		return nil, e
+	}
+	e = tx.FillTargetSign(mortgagor)
+	if e != nil {
+		return nil, e
+	}
+	return tx, nil
+}
+
+// 放款人检查借款人提交的交易是否完全符合报价单
+func (elm *UserLendingOffer) CheckCompletedTransaction(tx interfaces.Transaction) error {
+	acts := tx.GetActions()
+	if len(acts) != 1 {
+		return fmt.Errorf("Transaction must contain only one action.")
+	}
+	act, ok := acts[0].(*actions.Action_19_UsersLendingCreate)
+	if !ok {
+		return fmt.Errorf("Transaction action is not Action_19_UsersLendingCreate.")
+	}
+	if tx.GetAddress().NotEqual(act.MortgagorAddress) {
+		return fmt.Errorf("Transaction main address must be the mortgagor %s.", act.MortgagorAddress.ToReadable())
+	}
+	var satoshi fields.Satoshi = 0
+	if act.MortgageBitcoin.NotEmpty.Check() {
+		satoshi = act.MortgageBitcoin.ValueSAT
+	}
+	// 按条款重新生成并逐字节比较
+	must, e := elm.CompleteAction(act.MortgagorAddress, act.MortgageDiamondList, satoshi)
+	if e != nil {
+		return e
+	}
+	b1, _ := act.Serialize()
+	b2, _ := must.Serialize()
+	if bytes.Compare(b1, b2) != 0 {
+		return fmt.Errorf("Transaction lending action not match the offer.")
+	}
+	// 借款人签名
+	ok, e = tx.VerifyTargetSigns([]fields.Address{act.MortgagorAddress})
+	if !ok || e != nil {
+		return fmt.Errorf("Mortgagor %s signature verify fail.", act.MortgagorAddress.ToReadable())
+	}
+	return nil
+}
+
+// 对照当前链上状态检查报价单和借款人的抵押物能否成交
+func (elm *UserLendingOffer) CheckWithChainState(state interfaces.ChainStateOperation, mortgagor fields.Address, diamonds fields.DiamondListMaxLen200, satoshi fields.Satoshi) error {
+	e := elm.VerifySignature()
+	if e != nil {
+		return e
+	}
+	act, e := elm.CompleteAction(mortgagor, diamonds, satoshi)
+	if e != nil {
+		return e
+	}
+	paddingHeight := state.GetPendingBlockHeight()
+	if paddingHeight > uint64(elm.OfferExpireBlockHeight) {
+		return fmt.Errorf("Offer expired at height %d.", elm.OfferExpireBlockHeight)
+	}
+	effectiveExpireBlockHeight := paddingHeight + 288
+	if sys.TestDebugLocalDevelopmentMark {
+		effectiveExpireBlockHeight = paddingHeight + 10 // 测试环境10个区块
+	}
+	if uint64(elm.AgreedExpireBlockHeight) < effectiveExpireBlockHeight {
+		return fmt.Errorf("AgreedExpireBlockHeight %d is too short, must over than %d.", elm.AgreedExpireBlockHeight, effectiveExpireBlockHeight)
+	}
+	// 合约ID
+	if state.UserLending(act.LendingID) != nil {
+		return fmt.Errorf("User Lending <%s> already exist.", act.LendingID.ToHex())
+	}
+	// 放款人余额：借出金额 + 销毁利息
+	need, e := elm.LoanTotalAmount.Add(&elm.PreBurningInterestAmount)
+	if e != nil {
+		return e
+	}
+	lenderbls := state.Balance(elm.LenderAddress)
+	if lenderbls == nil || lenderbls.Hacash.LessThan(need) {
+		return fmt.Errorf("Lender %s balance not enough, need %s.", elm.LenderAddress.ToReadable(), need.ToFinString())
+	}
+	// 借款人比特币
+	if satoshi > 0 {
+		mtgbls := state.Balance(mortgagor)
+		if mtgbls == nil || mtgbls.Satoshi < satoshi {
+			return fmt.Errorf("Mortgagor %s satoshi not enough, need %d.", mortgagor.ToReadable(), satoshi)
+		}
+	}
+	// 借款人钻石
+	for _, diamond := range diamonds.Diamonds {
+		diaitem := state.Diamond(diamond)
+		if diaitem == nil {
+			return fmt.Errorf("Diamond <%s> not find.", string(diamond))
+		}
+		if diaitem.Address.NotEqual(mortgagor) {
+			return fmt.Errorf("Diamond <%s> not belong to address '%s'", string(diamond), mortgagor.ToReadable())
+		}
+		if diaitem.Status != stores.DiamondStatusNormal {
+			return fmt.Errorf("Diamond <%s> has been mortgaged.", string(diamond))
+		}
+	}
+	return nil
+}
+
+// json api
+func (elm *UserLendingOffer) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"offer_hash":                 hex.EncodeToString(elm.SignStuffHash()),
+		"lender":                     elm.LenderAddress.ToReadable(),
+		"mortgagor":                  elm.MortgagorAddress.ShowReadableOrEmpty(),
+		"loan_amount":                elm.LoanTotalAmount.ToFinString(),
+		"redemption_amount":          elm.AgreedRedemptionAmount.ToFinString(),
+		"pre_burning_interest":       elm.PreBurningInterestAmount.ToFinString(),
+		"is_redemption_overtime":     elm.IsRedemptionOvertime.Check(),
+		"is_public_redeemable":       elm.IsPublicRedeemable.Check(),
+		"agreed_expire_block_height": uint64(elm.AgreedExpireBlockHeight),
+		"offer_expire_block_height":  uint64(elm.OfferExpireBlockHeight),
+		"min_mortgage_diamond":       int(elm.MinMortgageDiamondCount),
+		"min_mortgage_satoshi":       uint64(elm.MinMortgageSatoshi),
+	}
+}
@@ -0,0 +1,28 @@
+package lending
+
+import (
+	"github.com/hacash/core/sys"
+)
+
+const (
+	// 钻石系统借贷周期区块数量 10000区块约 35天
+	DiamondSystemLendingBorrowPeriodBlockNumber uint64 = 10000
+	// 比特币系统借贷赎回期阶段区块数 十万个区块约一年
+	BitcoinSystemLendingRansomBlockNumberBase uint64 = 100000
+)
+
+// 钻石系统借贷一个借款周期的区块数
+func DiamondSystemLendingPeriodBlockNumber() uint64 {
+	if sys.TestDebugLocalDevelopmentMark {
+		return 10 // 测试时使用 10 个区块为一个周期
+	}
+	return DiamondSystemLendingBorrowPeriodBlockNumber
+}
+
+// 比特币系统借贷每个赎回阶段的区块数
+func BitcoinSystemLendingRansomBlockNumber() uint64 {
+	if sys.TestDebugLocalDevelopmentMark {
+		return 10 // 测试环境 10 个区块为周期
+	}
+	return BitcoinSystemLendingRansomBlockNumberBase
+}
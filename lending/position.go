@@ -0,0 +1,338 @@
+package lending
+
+import (
+	"fmt"
+	"github.com/hacash/core/coinbase"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"sort"
+	"strings"
+)
+
+/**
+ * 借贷仓位查询
+ * 对钻石系统借贷、比特币系统借贷、用户间借贷统一计算：
+ * 私有赎回期、公共赎回期、荷兰拍卖期的区块边界，赎回金额随高度的曲线，以及每个阶段谁可以赎回
+ */
+
+type LendingKind uint8
+
+const (
+	LendingKindDiamondSystem LendingKind = 1 // 钻石系统借贷
+	LendingKindBitcoinSystem LendingKind = 2 // 比特币系统借贷
+	LendingKindUser          LendingKind = 3 // 用户间借贷
+)
+
+func (k LendingKind) String() string {
+	switch k {
+	case LendingKindDiamondSystem:
+		return "diamond_system"
+	case LendingKindBitcoinSystem:
+		return "bitcoin_system"
+	case LendingKindUser:
+		return "user"
+	}
+	return "unknown"
+}
+
+// 赎回阶段，编号与 coinbase 中的计算保持一致
+type RedeemStage uint8
+
+const (
+	RedeemStagePrivate      RedeemStage = 1 // 私有赎回期
+	RedeemStagePublic       RedeemStage = 2 // 公共赎回期
+	RedeemStageAuction      RedeemStage = 3 // 荷兰拍卖期
+	RedeemStageAuctionFloor RedeemStage = 4 // 拍卖已降到最低（钻石借贷）
+)
+
+func (s RedeemStage) String() string {
+	switch s {
+	case RedeemStagePrivate:
+		return "private"
+	case RedeemStagePublic:
+		return "public"
+	case RedeemStageAuction:
+		return "auction"
+	case RedeemStageAuctionFloor:
+		return "auction_floor"
+	}
+	return "unknown"
+}
+
+// 可以赎回的角色，按位组合
+type Redeemer uint8
+
+const (
+	RedeemerMortgagor Redeemer = 1 << 0 // 抵押人
+	RedeemerLender    Redeemer = 1 << 1 // 放款人（用户间借贷，到期后扣押抵押品）
+	RedeemerPublic    Redeemer = 1 << 2 // 任何第三方
+)
+
+func (r Redeemer) Has(t Redeemer) bool {
+	return r&t == t
+}
+
+func (r Redeemer) String() string {
+	names := []string{}
+	if r.Has(RedeemerMortgagor) {
+		names = append(names, "mortgagor")
+	}
+	if r.Has(RedeemerLender) {
+		names = append(names, "lender")
+	}
+	if r.Has(RedeemerPublic) {
+		names = append(names, "public")
+	}
+	return strings.Join(names, ",")
+}
+
+// 一个赎回阶段
+type StageSpan struct {
+	Stage       RedeemStage
+	StartHeight uint64   // 开始高度（包含）
+	EndHeight   uint64   // 结束高度（包含），为 0 表示没有结束
+	Redeemers   Redeemer // 此阶段可以赎回的角色
+}
+
+func (s *StageSpan) Contains(height uint64) bool {
+	return height >= s.StartHeight && (s.EndHeight == 0 || height <= s.EndHeight)
+}
+
+// 赎回曲线上的一个点
+type CurvePoint struct {
+	Height uint64
+	Stage  RedeemStage
+	Amount *fields.Amount
+}
+
+// 借贷仓位
+type Position struct {
+	Kind      LendingKind
+	LendingId []byte
+
+	IsRansomed        bool
+	CreateBlockHeight uint64
+
+	MortgagorAddress fields.Address
+	LenderAddress    fields.Address // 系统借贷为 nil
+
+	CollateralDiamonds []fields.DiamondName
+	CollateralSatoshi  fields.Satoshi
+	LoanAmount         *fields.Amount
+
+	Stages []*StageSpan
+
+	// 抵押人或第三方赎回所需金额
+	amountAt func(height uint64) (*fields.Amount, error)
+}
+
+// 钻石系统借贷
+func NewDiamondSystemLendingPosition(id fields.DiamondSyslendId, obj *stores.DiamondSystemLending) *Position {
+	period := uint64(obj.BorrowPeriod)
+	dslbpbn := DiamondSystemLendingPeriodBlockNumber()
+	create := uint64(obj.CreateBlockHeight)
+	base := period * dslbpbn
+	privateHeight := create + base
+	publicHeight := privateHeight + base
+	// 每个周期减扣 0.5% 利息，最多减扣 2 倍借款周期
+	floorHeight := publicHeight + (period*2+1)*dslbpbn
+	pst := &Position{
+		Kind:               LendingKindDiamondSystem,
+		LendingId:          id,
+		IsRansomed:         obj.IsRansomed.Check(),
+		CreateBlockHeight:  create,
+		MortgagorAddress:   obj.MainAddress,
+		CollateralDiamonds: obj.MortgageDiamondList.Diamonds,
+		LoanAmount:         fields.NewAmountByUnit248(int64(obj.LoanTotalAmountMei)),
+		Stages: []*StageSpan{
+			{RedeemStagePrivate, create, privateHeight, RedeemerMortgagor},
+			{RedeemStagePublic, privateHeight + 1, publicHeight, RedeemerMortgagor | RedeemerPublic},
+			{RedeemStageAuction, publicHeight + 1, floorHeight - 1, RedeemerMortgagor | RedeemerPublic},
+			{RedeemStageAuctionFloor, floorHeight, 0, RedeemerMortgagor | RedeemerPublic},
+		},
+	}
+	pst.amountAt = func(height uint64) (*fields.Amount, error) {
+		_, amt, e := coinbase.CalculationDiamondSystemLendingRedeemAmount(
+			obj.MainAddress, obj.MainAddress,
+			int64(obj.BorrowPeriod), int64(obj.CreateBlockHeight),
+			int64(obj.LoanTotalAmountMei),
+			int64(dslbpbn), int64(height))
+		return amt, e
+	}
+	return pst
+}
+
+// 比特币系统借贷
+func NewBitcoinSystemLendingPosition(id fields.BitcoinSyslendId, obj *stores.BitcoinSystemLending) *Position {
+	base := BitcoinSystemLendingRansomBlockNumber()
+	create := uint64(obj.CreateBlockHeight)
+	privateHeight := create + base
+	publicHeight := privateHeight + base
+	loan := obj.LoanTotalAmount
+	pst := &Position{
+		Kind:               LendingKindBitcoinSystem,
+		LendingId:          id,
+		IsRansomed:         obj.IsRansomed.Check(),
+		CreateBlockHeight:  create,
+		MortgagorAddress:   obj.MainAddress,
+		CollateralDiamonds: []fields.DiamondName{},
+		CollateralSatoshi:  fields.Satoshi(uint64(obj.MortgageBitcoinPortion) * 100 * 10000), // 一份 = 0.01 BTC
+		LoanAmount:         &loan,
+		Stages: []*StageSpan{
+			{RedeemStagePrivate, create, privateHeight, RedeemerMortgagor},
+			{RedeemStagePublic, privateHeight + 1, publicHeight, RedeemerMortgagor | RedeemerPublic},
+			// 用十个阶段的区块数把赎回金额降低到 0
+			{RedeemStageAuction, publicHeight + 1, 0, RedeemerMortgagor | RedeemerPublic},
+		},
+	}
+	pst.amountAt = func(height uint64) (*fields.Amount, error) {
+		_, amt, e := coinbase.CalculationBitcoinSystemLendingRedeemAmount(
+			obj.MainAddress, obj.MainAddress, &loan,
+			base, create, height)
+		return amt, e
+	}
+	return pst
+}
+
+// 用户间借贷
+func NewUserLendingPosition(id fields.UserLendingId, obj *stores.UserLending) *Position {
+	create := uint64(obj.CreateBlockHeight)
+	expire := uint64(obj.ExpireBlockHeight)
+	// 到期后：放款人可以扣押；开启公共赎回则第三方可以赎回；
+	// 开启自动展期或公共赎回，则抵押人仍可赎回
+	afterExpire := RedeemerLender
+	if obj.IsPublicRedeemable.Check() {
+		afterExpire |= RedeemerPublic
+	}
+	if obj.IsPublicRedeemable.Check() || obj.IsRedemptionOvertime.Check() {
+		afterExpire |= RedeemerMortgagor
+	}
+	stage2 := RedeemStagePublic
+	if obj.IsPublicRedeemable.Is(false) {
+		stage2 = RedeemStagePrivate // 没有公共赎回期，到期后仍只有当事人
+	}
+	agreed := obj.AgreedRedemptionAmount
+	var satoshi fields.Satoshi = 0
+	if obj.MortgageBitcoin.NotEmpty.Check() {
+		satoshi = obj.MortgageBitcoin.ValueSAT
+	}
+	pst := &Position{
+		Kind:               LendingKindUser,
+		LendingId:          id,
+		IsRansomed:         obj.IsRansomed.Check(),
+		CreateBlockHeight:  create,
+		MortgagorAddress:   obj.MortgagorAddress,
+		LenderAddress:      obj.LenderAddress,
+		CollateralDiamonds: obj.MortgageDiamondList.Diamonds,
+		CollateralSatoshi:  satoshi,
+		LoanAmount:         &obj.LoanTotalAmount,
+		Stages: []*StageSpan{
+			{RedeemStagePrivate, create, expire, RedeemerMortgagor},
+			{stage2, expire + 1, 0, afterExpire},
+		},
+	}
+	pst.amountAt = func(height uint64) (*fields.Amount, error) {
+		return &agreed, nil // 没有拍卖，始终为约定赎回金额
+	}
+	return pst
+}
+
+// 指定高度所处的阶段
+func (p *Position) StageAt(height uint64) *StageSpan {
+	for _, s := range p.Stages {
+		if s.Contains(height) {
+			return s
+		}
+	}
+	return nil
+}
+
+// 指定高度抵押人或第三方赎回所需金额
+func (p *Position) RedeemAmountAt(height uint64) (*fields.Amount, error) {
+	if p.StageAt(height) == nil {
+		return nil, fmt.Errorf("height %d is before lending create height %d", height, p.CreateBlockHeight)
+	}
+	return p.amountAt(height)
+}
+
+// 地址在指定高度的角色
+func (p *Position) roleOf(addr fields.Address) Redeemer {
+	if addr.Equal(p.MortgagorAddress) {
+		return RedeemerMortgagor
+	}
+	if p.LenderAddress != nil && addr.Equal(p.LenderAddress) {
+		return RedeemerLender
+	}
+	return RedeemerPublic
+}
+
+// 地址在指定高度是否可以赎回
+func (p *Position) CanRedeem(addr fields.Address, height uint64) bool {
+	if p.IsRansomed {
+		return false
+	}
+	stage := p.StageAt(height)
+	if stage == nil {
+		return false
+	}
+	return stage.Redeemers.Has(p.roleOf(addr))
+}
+
+// 地址在指定高度赎回所需金额，不能赎回则返回错误
+// 用户间借贷的放款人到期后扣押抵押品，金额为零
+func (p *Position) RedeemAmountForAddressAt(addr fields.Address, height uint64) (*fields.Amount, error) {
+	if p.IsRansomed {
+		return nil, fmt.Errorf("lending has been redeemed")
+	}
+	if !p.CanRedeem(addr, height) {
+		return nil, fmt.Errorf("address %s cannot redeem at height %d", addr.ToReadable(), height)
+	}
+	if p.roleOf(addr) == RedeemerLender {
+		return fields.NewEmptyAmount(), nil
+	}
+	return p.amountAt(height)
+}
+
+// 赎回金额曲线，从 start 到 end 每隔 step 个区块一个点，并包含每个阶段的开始高度
+func (p *Position) RedeemCurve(start, end, step uint64) ([]*CurvePoint, error) {
+	if step == 0 {
+		return nil, fmt.Errorf("step cannot be zero")
+	}
+	if end < start {
+		return nil, fmt.Errorf("end height %d less than start height %d", end, start)
+	}
+	if (end-start)/step > 10000 {
+		return nil, fmt.Errorf("curve points cannot over 10000")
+	}
+	heights := []uint64{}
+	for h := start; h <= end; h += step {
+		heights = append(heights, h)
+		if h+step < h {
+			break // overflow
+		}
+	}
+	// 补充阶段边界
+	for _, s := range p.Stages {
+		if s.StartHeight > start && s.StartHeight <= end && (s.StartHeight-start)%step != 0 {
+			heights = append(heights, s.StartHeight)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	points := make([]*CurvePoint, 0, len(heights))
+	for _, h := range heights {
+		stage := p.StageAt(h)
+		if stage == nil {
+			continue
+		}
+		amt, e := p.amountAt(h)
+		if e != nil {
+			return nil, e
+		}
+		points = append(points, &CurvePoint{
+			Height: h,
+			Stage:  stage.Stage,
+			Amount: amt,
+		})
+	}
+	return points, nil
+}
@@ -0,0 +1,94 @@
+package lending
+
+import (
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/stores"
+)
+
+// 遍历全部借贷仓位（三种借贷），回调返回 false 则停止遍历
+// 链状态需要实现 interfaces.LendingStateIterator
+func IteratePositions(chainstate interfaces.ChainStateOperation, fn func(*Position) bool) error {
+	state, ok := chainstate.(interfaces.LendingStateIterator)
+	if !ok {
+		return errs.New(errs.CodeUnsupportedKind, "Chain state not support lending iterate.")
+	}
+	goon := true
+	e1 := state.IterateDiamondSystemLending(func(id fields.DiamondSyslendId, obj *stores.DiamondSystemLending) bool {
+		goon = fn(NewDiamondSystemLendingPosition(id, obj))
+		return goon
+	})
+	if e1 != nil || !goon {
+		return e1
+	}
+	e2 := state.IterateBitcoinSystemLending(func(id fields.BitcoinSyslendId, obj *stores.BitcoinSystemLending) bool {
+		goon = fn(NewBitcoinSystemLendingPosition(id, obj))
+		return goon
+	})
+	if e2 != nil || !goon {
+		return e2
+	}
+	return state.IterateUserLending(func(id fields.UserLendingId, obj *stores.UserLending) bool {
+		return fn(NewUserLendingPosition(id, obj))
+	})
+}
+
+// 可清算（第三方可赎回）的仓位
+type Opportunity struct {
+	Position     *Position
+	Stage        *StageSpan
+	Height       uint64
+	RedeemAmount *fields.Amount // 当前赎回所需 HAC
+
+	CollateralDiamondCount int
+	CollateralSatoshi      fields.Satoshi
+
+	// 单一抵押物时的单价，混合抵押时为 0
+	RedeemMeiPerDiamond float64 // 每枚钻石的赎回价格（枚）
+	RedeemMeiPerBitcoin float64 // 每个比特币的赎回价格（枚）
+}
+
+// 扫描在指定高度已经进入公共赎回期或荷兰拍卖期、第三方可以赎回的仓位
+func ScanLiquidationOpportunities(state interfaces.ChainStateOperation, height uint64) ([]*Opportunity, error) {
+	results := []*Opportunity{}
+	var scanerr error = nil
+	e := IteratePositions(state, func(pst *Position) bool {
+		if pst.IsRansomed {
+			return true
+		}
+		stage := pst.StageAt(height)
+		if stage == nil || stage.Stage == RedeemStagePrivate || !stage.Redeemers.Has(RedeemerPublic) {
+			return true
+		}
+		amt, e := pst.RedeemAmountAt(height)
+		if e != nil {
+			scanerr = e
+			return false
+		}
+		opt := &Opportunity{
+			Position:               pst,
+			Stage:                  stage,
+			Height:                 height,
+			RedeemAmount:           amt,
+			CollateralDiamondCount: len(pst.CollateralDiamonds),
+			CollateralSatoshi:      pst.CollateralSatoshi,
+		}
+		redeemMei := amt.ToMei()
+		if opt.CollateralDiamondCount > 0 && opt.CollateralSatoshi == 0 {
+			opt.RedeemMeiPerDiamond = redeemMei / float64(opt.CollateralDiamondCount)
+		}
+		if opt.CollateralDiamondCount == 0 && opt.CollateralSatoshi > 0 {
+			opt.RedeemMeiPerBitcoin = redeemMei / (float64(opt.CollateralSatoshi) / 100000000)
+		}
+		results = append(results, opt)
+		return true
+	})
+	if e != nil {
+		return nil, e
+	}
+	if scanerr != nil {
+		return nil, scanerr
+	}
+	return results, nil
+}
@@ -0,0 +1,244 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/hacash/core/fields"
+	"math"
+	"testing"
+)
+
+// 旧版本的 float64 储存格式
+func legacyTotalSupplyBytes(values map[uint8]float64) []byte {
+	buf := bytes.NewBuffer([]byte{uint8(typeSizeMax)})
+	for i := 0; i < typeSizeMax; i++ {
+		var btstore = make([]byte, 8)
+		binary.BigEndian.PutUint64(btstore, math.Float64bits(values[uint8(i)]))
+		buf.Write(btstore)
+	}
+	return buf.Bytes()
+}
+
+// 类型化接口的 float64 累加值必须与旧版本逐位一致
+func Test_total_supply_legacy_float(t *testing.T) {
+
+	fees := []string{"ㄜ1:244", "ㄜ3:243", "ㄜ7777:240", "ㄜ12345678:238", "ㄜ9:247", "ㄜ333:241"}
+	ttsp := NewTotalSupplyStoreData()
+	var legacy float64 = 0
+	for i := 0; i < 3000; i++ {
+		amt, _ := fields.NewAmountFromFinString(fees[i%len(fees)])
+		ttsp.DoAddAmount(TotalSupplyStoreTypeOfBurningFee, amt)
+		legacy += amt.ToMei() // 旧版本的累加方式
+	}
+	fmt.Println(ttsp.Get(TotalSupplyStoreTypeOfBurningFee), legacy)
+	if math.Float64bits(ttsp.Get(TotalSupplyStoreTypeOfBurningFee)) != math.Float64bits(legacy) {
+		t.Fatal("burning fee float must equal legacy accumulation")
+	}
+	exact, e := ttsp.GetAmount(TotalSupplyStoreTypeOfBurningFee)
+	if e != nil {
+		t.Fatal(e)
+	}
+	fmt.Println(exact.ToFinString())
+
+	// 序列化后不变
+	body, _ := ttsp.Serialize()
+	ttsp2 := NewTotalSupplyStoreData()
+	_, e = ttsp2.Parse(body, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	exact2, _ := ttsp2.GetAmount(TotalSupplyStoreTypeOfBurningFee)
+	if ttsp2.Version() != TotalSupplyStoreVersionExactFloat || exact2.NotEqual(exact) ||
+		math.Float64bits(ttsp2.Get(TotalSupplyStoreTypeOfBurningFee)) != math.Float64bits(legacy) {
+		t.Fatal("total supply round trip error")
+	}
+}
+
+// 旧格式迁移
+func Test_total_supply_migration(t *testing.T) {
+
+	legacy := legacyTotalSupplyBytes(map[uint8]float64{
+		TotalSupplyStoreTypeOfDiamond:          1234,
+		TotalSupplyStoreTypeOfBlockReward:      13456789.5,
+		TotalSupplyStoreTypeOfBurningFee:       0.1 + 0.2, // 浮点误差必须原样保留
+		TotalSupplyStoreTypeOfChannelOfOpening: 7,
+	})
+	ttsp := NewTotalSupplyStoreData()
+	seek, e := ttsp.Parse(legacy, 0)
+	if e != nil || int(seek) != len(legacy) || ttsp.Version() != TotalSupplyStoreVersionLegacyFloat {
+		t.Fatal("parse legacy total supply error", e)
+	}
+	if ttsp.GetCount(TotalSupplyStoreTypeOfDiamond) != 1234 || ttsp.Get(TotalSupplyStoreTypeOfBurningFee) != 0.1+0.2 {
+		t.Fatal("legacy value error")
+	}
+	reward, _ := ttsp.GetAmount(TotalSupplyStoreTypeOfBlockReward)
+	fee, _ := ttsp.GetAmount(TotalSupplyStoreTypeOfBurningFee)
+	fmt.Println(reward.ToFinString(), fee.ToFinString())
+	if reward.ToFinString() != "ㄜ134,567,895:247" || fee.ToFinString() != "ㄜ3:247" {
+		t.Fatal("legacy exact value error")
+	}
+
+	// 迁移为新格式，再次读取
+	body, e := MigrateTotalSupplyFromLegacyFloat(legacy)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if body[0] != totalSupplyStoreLayoutMark || body[1] != TotalSupplyStoreVersionExactFloat {
+		t.Fatal("migrate layout error")
+	}
+	ttsp2 := NewTotalSupplyStoreData()
+	seek, e = ttsp2.Parse(body, 0)
+	if e != nil || int(seek) != len(body) {
+		t.Fatal("parse migrated total supply error", e)
+	}
+	for ty := uint8(0); ty <= uint8(typeSizeValid); ty++ {
+		if math.Float64bits(ttsp.Get(ty)) != math.Float64bits(ttsp2.Get(ty)) {
+			t.Fatalf("migrated float value %d changed", ty)
+		}
+	}
+	fee2, _ := ttsp2.GetAmount(TotalSupplyStoreTypeOfBurningFee)
+	if fee2.NotEqual(fee) || ttsp2.GetCount(TotalSupplyStoreTypeOfChannelOfOpening) != 7 {
+		t.Fatal("migrated exact value changed")
+	}
+	body2, _ := ttsp2.Serialize()
+	if !bytes.Equal(body, body2) {
+		t.Fatal("serialize must be stable")
+	}
+
+	// 精确格式（版本 1）只保存了精确值
+	v1 := []byte{totalSupplyStoreLayoutMark, TotalSupplyStoreVersionExact, uint8(typeSizeValid + 1)}
+	for ty := uint8(0); ty <= uint8(typeSizeValid); ty++ {
+		if IsTotalSupplyCountType(ty) {
+			v1 = append(v1, 0, 0, 0, 0, 0, 0, 0, 3)
+		} else {
+			amtbts, _ := fields.NewAmountSmall(5, 247).Serialize()
+			v1 = append(v1, amtbts...)
+		}
+	}
+	ttsp3 := NewTotalSupplyStoreData()
+	seek, e = ttsp3.Parse(v1, 0)
+	if e != nil || int(seek) != len(v1) || ttsp3.Version() != TotalSupplyStoreVersionExact {
+		t.Fatal("parse exact total supply error", e)
+	}
+	if ttsp3.Get(TotalSupplyStoreTypeOfDiamond) != 3 || ttsp3.Get(TotalSupplyStoreTypeOfBurningFee) != 0.5 {
+		t.Fatal("exact total supply value error")
+	}
+}
+
+// 锁仓存储格式：归属扩展单独保存，锁仓记录与原格式一致
+func Test_lockbls_store_format(t *testing.T) {
+
+	addr := fields.Address(bytes.Repeat([]byte{255}, 21)) // 非标准版本号的主地址
+	lock := NewEmptyLockbls(addr)
+	lock.EffectBlockHeight = 100
+	lock.LinearBlockNumber = 288
+	lock.TotalLockAmount = *fields.NewAmountByUnit248(10)
+	lock.LinearReleaseAmount = *fields.NewAmountByUnit248(1)
+	lock.BalanceAmount = *fields.NewAmountByUnit248(10)
+	plain, _ := lock.Serialize()
+
+	// 归属扩展不参与序列化
+	lock.IsVesting.Set(true)
+	lock.Vesting.CliffBlockHeight = 200
+	lock.Vesting.EndBlockHeight = 1000
+	vest, _ := lock.Serialize()
+	if !bytes.Equal(plain, vest) || uint32(len(plain)) != lock.Size() {
+		t.Fatal("vesting must not change lockbls format")
+	}
+	lock2 := &Lockbls{}
+	seek, e := lock2.Parse(append(append([]byte{}, plain...), 1, 2, 3), 0)
+	if e != nil || lock2.IsVesting.Check() || int(seek) != len(plain) || lock2.MasterAddress.NotEqual(addr) {
+		t.Fatal("lockbls parse error", e)
+	}
+
+	// 归属扩展
+	vbts, _ := lock.Vesting.Serialize()
+	vesting := &LockblsVesting{}
+	seek, e = vesting.Parse(vbts, 0)
+	if e != nil || int(seek) != len(vbts) || vesting.EndBlockHeight != 1000 || vesting.IsRevoked() {
+		t.Fatal("vesting parse error", e)
+	}
+	fmt.Println(len(plain), len(vbts))
+}
+
+// 持有索引的键：同一地址前缀连续，按字面量字节序排列
+func Test_diamond_owner_key(t *testing.T) {
+
+	addr1 := fields.Address(bytes.Repeat([]byte{1}, 21))
+	addr2 := fields.Address(bytes.Repeat([]byte{2}, 21))
+	k1 := DiamondOwnerKey(addr1, []byte("ZZZZZZ"))
+	k2 := DiamondOwnerKey(addr2, []byte("AAAAAA"))
+	k3 := DiamondOwnerKey(addr1, []byte("NHMYYM"))
+	if len(k1) != DiamondOwnerKeySize || !bytes.HasPrefix(k1, addr1) {
+		t.Fatal("owner key error")
+	}
+	if bytes.Compare(k3, k1) >= 0 || bytes.Compare(k1, k2) >= 0 {
+		t.Fatal("owner key order error")
+	}
+	addr, name, e := ParseDiamondOwnerKey(k3)
+	if e != nil || addr.NotEqual(addr1) || string(name) != "NHMYYM" {
+		t.Fatal("parse owner key error", e)
+	}
+	if _, _, e = ParseDiamondOwnerKey(k3[1:]); e == nil {
+		t.Fatal("owner key size must be error")
+	}
+}
+
+// 流转历史记录：每条单独一个键，回退时按交易和类型识别
+func Test_diamond_history_record(t *testing.T) {
+
+	tx1 := fields.Hash(bytes.Repeat([]byte{1}, 32))
+	tx2 := fields.Hash(bytes.Repeat([]byte{2}, 32))
+	addr := fields.Address(bytes.Repeat([]byte{3}, 21))
+	rcd := &DiamondHistoryRecord{BlockHeight: 15, TxHash: tx2, ActionKind: 15, FromAddress: addr, ToAddress: DiamondHistorySystemLendingAddress}
+	bts, _ := rcd.Serialize()
+	rcd2 := &DiamondHistoryRecord{}
+	if seek, e := rcd2.Parse(bts, 0); e != nil || int(seek) != len(bts) || uint32(len(bts)) != rcd.Size() {
+		t.Fatal("serialize error", e)
+	}
+	if !rcd2.IsOf(tx2, 15) || rcd2.IsOf(tx1, 15) || rcd2.IsOf(tx2, 16) {
+		t.Fatal("record match error")
+	}
+	// 同一钻石的记录按序号顺序排列
+	k1 := DiamondHistoryKey([]byte("WTYUIA"), 1)
+	k2 := DiamondHistoryKey([]byte("WTYUIA"), 256)
+	if len(k1) != DiamondHistoryKeySize || bytes.Compare(k1, k2) >= 0 || !bytes.HasPrefix(k2, []byte("WTYUIA")) {
+		t.Fatal("history key error")
+	}
+}
+
+// 元数据版本：写满后移出最早的版本，回退时放回
+func Test_diamond_metadata_versions(t *testing.T) {
+
+	owner := fields.Address(bytes.Repeat([]byte{1}, 21))
+	meta := NewDiamondMetadata()
+	for v := 1; v <= DiamondMetadataMaxVersions+1; v++ {
+		oldest := meta.EvictOldest()
+		if (v <= DiamondMetadataMaxVersions) != (oldest == nil) {
+			t.Fatal("evict error", v)
+		}
+		meta.Append(&DiamondMetadataRecord{Version: fields.VarUint4(v), Owner: owner, DataType: DiamondMetadataTypeText})
+	}
+	bts, _ := meta.Serialize()
+	meta2 := NewDiamondMetadata()
+	if _, e := meta2.Parse(bts, 0); e != nil || meta2.CurrentVersion() != DiamondMetadataMaxVersions+1 || uint32(len(bts)) != meta.Size() {
+		t.Fatal("metadata parse error", e)
+	}
+	// 只能回退最新版本
+	if meta2.DropLast(DiamondMetadataMaxVersions) || !meta2.DropLast(DiamondMetadataMaxVersions+1) {
+		t.Fatal("metadata drop error")
+	}
+	if meta2.EvictedVersion() != 1 {
+		t.Fatal("evicted version error")
+	}
+	meta2.RestoreOldest(&DiamondMetadataRecord{Version: 1, Owner: owner})
+	if meta2.Records[0].Version != 1 || int(meta2.Count) != DiamondMetadataMaxVersions || meta2.EvictedVersion() != 0 {
+		t.Fatal("metadata restore error")
+	}
+	// 不足上限时没有移出的版本
+	meta2.DropLast(DiamondMetadataMaxVersions)
+	if meta2.EvictedVersion() != 0 {
+		t.Fatal("evicted version must be zero")
+	}
+}
@@ -13,10 +13,12 @@ const (
 	DiamondStatusNormal           fields.VarUint1 = 0
 	DiamondStatusLendingSystem    fields.VarUint1 = 1
 	DiamondStatusLendingOtherUser fields.VarUint1 = 2
+	DiamondStatusHtlcLocked       fields.VarUint1 = 3
+	DiamondStatusListing          fields.VarUint1 = 4
 )
 
 type Diamond struct {
-	Status  fields.VarUint1 // 状态 0. 正常可用可转账  1. 抵押给系统  2. 抵押给其他用户
+	Status  fields.VarUint1 // 状态 0. 正常可用可转账  1. 抵押给系统  2. 抵押给其他用户  3. 哈希时间锁定中  4. 挂单出售中
 	Address fields.Address
 }
 
@@ -0,0 +1,150 @@
+package stores
+
+import (
+	"bytes"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	DiamondListingIdLength = 16
+)
+
+const (
+	DiamondListingStatusOpen      fields.VarUint1 = 0 // 挂单中
+	DiamondListingStatusSold      fields.VarUint1 = 1 // 已成交
+	DiamondListingStatusCancelled fields.VarUint1 = 2 // 已撤单
+)
+
+// 钻石挂单出售
+type DiamondListing struct {
+	Status fields.VarUint1 // 状态 0.挂单中 1.已成交 2.已撤单
+
+	CreateBlockHeight fields.BlockHeight // 挂单时的区块高度
+	ExpireBlockHeight fields.BlockHeight // 到期区块高度，到期后不能成交
+
+	SellerAddress     fields.Address         // 卖方
+	DesignatedBuyer   fields.OptionalAddress // 指定买方，可选
+	AskPrice          fields.Amount          // 要价 HAC
+	ListedDiamondList fields.DiamondListMaxLen200
+
+	// 如已经成交或撤单则写入数据
+	CloseBlockHeight fields.BlockHeight // 成交或撤单时的区块高度
+	// 成交时写入
+	BuyerAddress fields.Address
+}
+
+func (elm *DiamondListing) IsClosed() bool {
+	return elm.Status != DiamondListingStatusOpen
+}
+
+func (elm *DiamondListing) Size() uint32 {
+	sz := elm.Status.Size() +
+		elm.CreateBlockHeight.Size() +
+		elm.ExpireBlockHeight.Size() +
+		elm.SellerAddress.Size() +
+		elm.DesignatedBuyer.Size() +
+		elm.AskPrice.Size() +
+		elm.ListedDiamondList.Size()
+	if elm.IsClosed() {
+		sz += elm.CloseBlockHeight.Size()
+	}
+	if elm.Status == DiamondListingStatusSold {
+		sz += elm.BuyerAddress.Size()
+	}
+	return sz
+}
+
+func (elm *DiamondListing) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Status.Serialize()
+	var b2, _ = elm.CreateBlockHeight.Serialize()
+	var b3, _ = elm.ExpireBlockHeight.Serialize()
+	var b4, _ = elm.SellerAddress.Serialize()
+	var b5, _ = elm.DesignatedBuyer.Serialize()
+	var b6, _ = elm.AskPrice.Serialize()
+	var b7, e = elm.ListedDiamondList.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	if elm.IsClosed() {
+		var b8, _ = elm.CloseBlockHeight.Serialize()
+		buffer.Write(b8)
+	}
+	if elm.Status == DiamondListingStatusSold {
+		var b9, _ = elm.BuyerAddress.Serialize()
+		buffer.Write(b9)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *DiamondListing) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.Status.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CreateBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ExpireBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.DesignatedBuyer.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.AskPrice.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ListedDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if elm.IsClosed() {
+		seek, e = elm.CloseBlockHeight.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	if elm.Status == DiamondListingStatusSold {
+		seek, e = elm.BuyerAddress.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+// 设置成交状态
+func (elm *DiamondListing) SetSoldStatus(height uint64, buyer fields.Address) {
+	elm.Status = DiamondListingStatusSold
+	elm.CloseBlockHeight = fields.BlockHeight(height)
+	elm.BuyerAddress = buyer
+}
+
+// 设置撤单状态
+func (elm *DiamondListing) SetCancelledStatus(height uint64) {
+	elm.Status = DiamondListingStatusCancelled
+	elm.CloseBlockHeight = fields.BlockHeight(height)
+}
+
+// 回退为挂单状态
+func (elm *DiamondListing) DropClosedStatus() {
+	elm.Status = DiamondListingStatusOpen
+	elm.CloseBlockHeight = 0
+	elm.BuyerAddress = nil
+}
@@ -0,0 +1,108 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hacash/core/fields"
+)
+
+/**
+ * 钻石流转历史
+ * 记录转账、挂单成交、哈希时间锁定领取，以及抵押、赎回、部分还款释放，按区块顺序追加，回退时从尾部移除
+ * 每条记录单独一个键：钻石字面量 + 序号（大端 uint32），追加和回退只写一条记录，与历史长度无关
+ * 创建记录不在此保存，由 BlockStore 的 DiamondSmelt 提供
+ * 启用高度之前的流转没有记录，启用区块为当时存在的每枚钻石写入一条所属快照（ActionKind 为 0）
+ *
+ * 借贷类记录的地址统一规则：
+ *   抵押 From 为抵押人，To 为对手方（系统借贷为占位地址，用户借贷为出借人）
+ *   赎回、释放 From 为对手方，To 为实际取回钻石的地址
+ */
+
+// 系统借贷合约的占位地址：抵押给系统时 To 为此地址，赎回时 From 为此地址
+var DiamondHistorySystemLendingAddress = fields.Address(make([]byte, fields.AddressSize))
+
+const (
+	DiamondHistoryKindSnapshot uint16 = 0 // 启用时的所属快照
+
+	DiamondHistoryKeySize = fields.DiamondNameSize + 4
+)
+
+// 第 seq 条记录的键，序号从 0 开始
+func DiamondHistoryKey(diamond fields.DiamondName, seq uint32) []byte {
+	key := make([]byte, DiamondHistoryKeySize)
+	copy(key, diamond)
+	binary.BigEndian.PutUint32(key[fields.DiamondNameSize:], seq)
+	return key
+}
+
+type DiamondHistoryRecord struct {
+	BlockHeight fields.BlockHeight
+	TxHash      fields.Hash
+	ActionKind  fields.VarUint2
+	FromAddress fields.Address
+	ToAddress   fields.Address
+}
+
+func (this *DiamondHistoryRecord) Size() uint32 {
+	return this.BlockHeight.Size() +
+		this.TxHash.Size() +
+		this.ActionKind.Size() +
+		this.FromAddress.Size() +
+		this.ToAddress.Size()
+}
+
+func (this *DiamondHistoryRecord) Serialize() ([]byte, error) {
+	var buffer = new(bytes.Buffer)
+	b1, _ := this.BlockHeight.Serialize()
+	b2, _ := this.TxHash.Serialize()
+	b3, _ := this.ActionKind.Serialize()
+	b4, _ := this.FromAddress.Serialize()
+	b5, _ := this.ToAddress.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	return buffer.Bytes(), nil
+}
+
+func (this *DiamondHistoryRecord) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = this.BlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.TxHash.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.ActionKind.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.FromAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.ToAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+// json api
+func (this *DiamondHistoryRecord) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"height": uint64(this.BlockHeight),
+		"tx":     this.TxHash.ToHex(),
+		"kind":   uint16(this.ActionKind),
+		"from":   this.FromAddress.ToReadable(),
+		"to":     this.ToAddress.ToReadable(),
+	}
+}
+
+// 是否为该交易和 action 类型写入的记录，回退时检查
+func (this *DiamondHistoryRecord) IsOf(txhash fields.Hash, kind uint16) bool {
+	return uint16(this.ActionKind) == kind && this.TxHash.Equal(txhash)
+}
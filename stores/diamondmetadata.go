@@ -0,0 +1,199 @@
+package stores
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/hacash/core/fields"
+)
+
+/**
+ * 钻石附加元数据（铭文）
+ * 由持有人写入，每次写入版本号加一，最新版本在最后
+ * 整条记录每次都会完整读写，所以最多保留最近 DiamondMetadataMaxVersions 个版本
+ * 写满后再写入时移出最早的版本，移出的记录按 DiamondMetadataArchiveKey 单独保存，回退时放回
+ * 钻石转移后元数据随钻石保留，新的持有人可以继续更新
+ */
+
+const (
+	DiamondMetadataTypeClear       fields.VarUint1 = 0 // 清除，内容为空
+	DiamondMetadataTypeURI         fields.VarUint1 = 1 // URI
+	DiamondMetadataTypeContentHash fields.VarUint1 = 2 // 内容哈希，32 字节
+	DiamondMetadataTypeText        fields.VarUint1 = 3 // 短文本
+
+	DiamondMetadataMaxVersions = 8 // 最多保留的版本数
+
+	DiamondMetadataArchiveKeySize = fields.DiamondNameSize + 4
+)
+
+// 移出的旧版本的键：钻石字面量 + 版本号（大端 uint32）
+func DiamondMetadataArchiveKey(diamond fields.DiamondName, version uint32) []byte {
+	key := make([]byte, DiamondMetadataArchiveKeySize)
+	copy(key, diamond)
+	binary.BigEndian.PutUint32(key[fields.DiamondNameSize:], version)
+	return key
+}
+
+type DiamondMetadataRecord struct {
+	Version     fields.VarUint4
+	BlockHeight fields.BlockHeight
+	Owner       fields.Address // 写入时的持有人
+	DataType    fields.VarUint1
+	Data        fields.ExtendMessageMaxLen255
+}
+
+func (this *DiamondMetadataRecord) Size() uint32 {
+	return this.Version.Size() +
+		this.BlockHeight.Size() +
+		this.Owner.Size() +
+		this.DataType.Size() +
+		this.Data.Size()
+}
+
+func (this *DiamondMetadataRecord) Serialize() ([]byte, error) {
+	var buffer = new(bytes.Buffer)
+	b1, _ := this.Version.Serialize()
+	b2, _ := this.BlockHeight.Serialize()
+	b3, _ := this.Owner.Serialize()
+	b4, _ := this.DataType.Serialize()
+	b5, _ := this.Data.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	return buffer.Bytes(), nil
+}
+
+func (this *DiamondMetadataRecord) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = this.Version.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.BlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.Owner.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.DataType.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.Data.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	return seek, nil
+}
+
+////////////////////////////////////////
+
+type DiamondMetadata struct {
+	Count   fields.VarUint2
+	Records []*DiamondMetadataRecord
+}
+
+func NewDiamondMetadata() *DiamondMetadata {
+	return &DiamondMetadata{
+		Count:   0,
+		Records: make([]*DiamondMetadataRecord, 0),
+	}
+}
+
+func (this *DiamondMetadata) Size() uint32 {
+	size := this.Count.Size()
+	for _, v := range this.Records {
+		size += v.Size()
+	}
+	return size
+}
+
+func (this *DiamondMetadata) Serialize() ([]byte, error) {
+	var buffer = new(bytes.Buffer)
+	b1, _ := this.Count.Serialize()
+	buffer.Write(b1)
+	for _, v := range this.Records {
+		b2, _ := v.Serialize()
+		buffer.Write(b2)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (this *DiamondMetadata) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = this.Count.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	this.Records = make([]*DiamondMetadataRecord, int(this.Count))
+	for i := 0; i < int(this.Count); i++ {
+		this.Records[i] = &DiamondMetadataRecord{}
+		seek, e = this.Records[i].Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+// 当前版本号，0 表示还没有写入
+func (this *DiamondMetadata) CurrentVersion() uint32 {
+	n := len(this.Records)
+	if n == 0 {
+		return 0
+	}
+	return uint32(this.Records[n-1].Version)
+}
+
+// 最新记录，没有时为 nil
+func (this *DiamondMetadata) Latest() *DiamondMetadataRecord {
+	n := len(this.Records)
+	if n == 0 {
+		return nil
+	}
+	return this.Records[n-1]
+}
+
+// 追加新版本
+func (this *DiamondMetadata) Append(rcd *DiamondMetadataRecord) {
+	this.Records = append(this.Records, rcd)
+	this.Count = fields.VarUint2(len(this.Records))
+}
+
+// 写满时移出最早的版本并返回，未写满时返回 nil
+func (this *DiamondMetadata) EvictOldest() *DiamondMetadataRecord {
+	if len(this.Records) < DiamondMetadataMaxVersions {
+		return nil
+	}
+	oldest := this.Records[0]
+	this.Records = this.Records[1:]
+	this.Count = fields.VarUint2(len(this.Records))
+	return oldest
+}
+
+// 回退之后需要放回的旧版本号：移除最新版本后剩余 DiamondMetadataMaxVersions-1 个且最早的不是第一版，没有时返回 0
+func (this *DiamondMetadata) EvictedVersion() uint32 {
+	if len(this.Records) != DiamondMetadataMaxVersions-1 || this.Records[0].Version <= 1 {
+		return 0
+	}
+	return uint32(this.Records[0].Version) - 1
+}
+
+// 回退时放回最早的版本
+func (this *DiamondMetadata) RestoreOldest(rcd *DiamondMetadataRecord) {
+	this.Records = append([]*DiamondMetadataRecord{rcd}, this.Records...)
+	this.Count = fields.VarUint2(len(this.Records))
+}
+
+// 回退：移除指定的最新版本，返回是否移除
+func (this *DiamondMetadata) DropLast(version uint32) bool {
+	if this.CurrentVersion() != version || version == 0 {
+		return false
+	}
+	this.Records = this.Records[:len(this.Records)-1]
+	this.Count = fields.VarUint2(len(this.Records))
+	return true
+}
@@ -0,0 +1,36 @@
+package stores
+
+import (
+	"github.com/hacash/core/errs"
+	"github.com/hacash/core/fields"
+)
+
+/**
+ * 地址持有钻石索引
+ * 每个（地址，钻石）单独一条记录，键为 地址 + 钻石字面量，值为空
+ * 按地址前缀顺序扫描即得到按字面量字节序排列的持有列表，分页结果稳定
+ * 加入或移除只写对应的记录，开销与本次转移的数量成正比，与地址持有总量无关
+ * 与 Balance.Diamond 数量保持一致：抵押、哈希时间锁定中的钻石不在索引内，赎回、退回后重新加入
+ * 启用高度与启用区块的全量补建见 actions.DiamondIndexActiveBlockHeight
+ */
+
+const (
+	DiamondOwnerKeySize = fields.AddressSize + fields.DiamondNameSize
+)
+
+// 记录的键
+func DiamondOwnerKey(addr fields.Address, diamond fields.DiamondName) []byte {
+	key := make([]byte, 0, DiamondOwnerKeySize)
+	key = append(key, addr...)
+	return append(key, diamond...)
+}
+
+// 从键中解出地址和钻石字面量
+func ParseDiamondOwnerKey(key []byte) (fields.Address, fields.DiamondName, error) {
+	if len(key) != DiamondOwnerKeySize {
+		return nil, nil, errs.New(errs.CodeMalformed, "Diamond owner key size error.")
+	}
+	addr := fields.Address(append([]byte{}, key[:fields.AddressSize]...))
+	diamond := fields.DiamondName(append([]byte{}, key[fields.AddressSize:]...))
+	return addr, diamond, nil
+}
@@ -0,0 +1,137 @@
+package stores
+
+import (
+	"bytes"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	EscrowIdLength = 16
+)
+
+const (
+	EscrowStatusLocked   fields.VarUint1 = 0 // 锁定中
+	EscrowStatusReleased fields.VarUint1 = 1 // 已放款给卖方
+	EscrowStatusRefunded fields.VarUint1 = 2 // 已退款给买方
+)
+
+// 三方担保交易（买方、卖方、仲裁人任意两方授权）
+type Escrow struct {
+	Status fields.VarUint1 // 状态 0.锁定中 1.已放款 2.已退款
+
+	CreateBlockHeight  fields.BlockHeight // 创建时的区块高度
+	TimeoutBlockHeight fields.BlockHeight // 超时自动退款高度，为 0 表示不设超时
+
+	BuyerAddress   fields.Address // 买方（付款方，退款收款方）
+	SellerAddress  fields.Address // 卖方（放款收款方）
+	ArbiterAddress fields.Address // 仲裁人
+
+	LockAmount fields.Amount // 锁定的 HAC
+
+	// 如已经放款或退款则写入数据
+	CloseBlockHeight fields.BlockHeight
+}
+
+func (elm *Escrow) IsClosed() bool {
+	return elm.Status != EscrowStatusLocked
+}
+
+// 是否已经超时可以自动退款
+func (elm *Escrow) IsTimeout(height uint64) bool {
+	return elm.TimeoutBlockHeight > 0 && height >= uint64(elm.TimeoutBlockHeight)
+}
+
+// 是否为参与方之一
+func (elm *Escrow) IsParticipant(addr fields.Address) bool {
+	return addr.Equal(elm.BuyerAddress) ||
+		addr.Equal(elm.SellerAddress) ||
+		addr.Equal(elm.ArbiterAddress)
+}
+
+func (elm *Escrow) Size() uint32 {
+	sz := elm.Status.Size() +
+		elm.CreateBlockHeight.Size() +
+		elm.TimeoutBlockHeight.Size() +
+		elm.BuyerAddress.Size() +
+		elm.SellerAddress.Size() +
+		elm.ArbiterAddress.Size() +
+		elm.LockAmount.Size()
+	if elm.IsClosed() {
+		sz += elm.CloseBlockHeight.Size()
+	}
+	return sz
+}
+
+func (elm *Escrow) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Status.Serialize()
+	var b2, _ = elm.CreateBlockHeight.Serialize()
+	var b3, _ = elm.TimeoutBlockHeight.Serialize()
+	var b4, _ = elm.BuyerAddress.Serialize()
+	var b5, _ = elm.SellerAddress.Serialize()
+	var b6, _ = elm.ArbiterAddress.Serialize()
+	var b7, _ = elm.LockAmount.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	if elm.IsClosed() {
+		var b8, _ = elm.CloseBlockHeight.Serialize()
+		buffer.Write(b8)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *Escrow) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.Status.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CreateBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TimeoutBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.BuyerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SellerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ArbiterAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if elm.IsClosed() {
+		seek, e = elm.CloseBlockHeight.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+// 设置关闭状态（放款或退款）
+func (elm *Escrow) SetClosedStatus(status fields.VarUint1, height uint64) {
+	elm.Status = status
+	elm.CloseBlockHeight = fields.BlockHeight(height)
+}
+
+// 回退为锁定状态
+func (elm *Escrow) DropClosedStatus() {
+	elm.Status = EscrowStatusLocked
+	elm.CloseBlockHeight = 0
+}
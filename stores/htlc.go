@@ -0,0 +1,199 @@
+package stores
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/hacash/core/crypto/sha3"
+	"github.com/hacash/core/fields"
+)
+
+const (
+	HtlcIdLength = 16
+)
+
+const (
+	HtlcHashTypeSha256 fields.VarUint1 = 0 // sha256(preimage)，与比特币等链的 OP_SHA256 兼容
+	HtlcHashTypeSha3   fields.VarUint1 = 1 // sha3-256(preimage)，与 Hacash 哈希算法一致
+)
+
+const (
+	HtlcStatusLocked   fields.VarUint1 = 0 // 锁定中
+	HtlcStatusClaimed  fields.VarUint1 = 1 // 收款方已凭原像领取
+	HtlcStatusRefunded fields.VarUint1 = 2 // 超时后已退回给发送方
+)
+
+// 哈希时间锁合约
+type Htlc struct {
+	Status fields.VarUint1 // 状态 0.锁定中 1.已领取 2.已退回
+
+	CreateBlockHeight  fields.BlockHeight // 创建时的区块高度
+	TimeoutBlockHeight fields.BlockHeight // 超时区块高度，达到后只能退回
+
+	SenderAddress   fields.Address // 发送方（超时后退回）
+	ReceiverAddress fields.Address // 收款方（凭原像领取）
+
+	HashType fields.VarUint1 // 哈希算法
+	Hashlock fields.Hash     // 哈希锁
+
+	LockAmount      fields.Amount               // 锁定的 HAC
+	LockSatoshi     fields.SatoshiVariation     // 锁定的 SAT
+	LockDiamondList fields.DiamondListMaxLen200 // 锁定的钻石
+
+	// 如已经领取或退回则写入数据
+	CloseBlockHeight fields.BlockHeight // 领取或退回时的区块高度
+	// 领取时公开原像，供其它链上的对手方读取
+	Preimage fields.Bytes32
+}
+
+func (elm *Htlc) IsClosed() bool {
+	return elm.Status != HtlcStatusLocked
+}
+
+func (elm *Htlc) Size() uint32 {
+	sz := elm.Status.Size() +
+		elm.CreateBlockHeight.Size() +
+		elm.TimeoutBlockHeight.Size() +
+		elm.SenderAddress.Size() +
+		elm.ReceiverAddress.Size() +
+		elm.HashType.Size() +
+		elm.Hashlock.Size() +
+		elm.LockAmount.Size() +
+		elm.LockSatoshi.Size() +
+		elm.LockDiamondList.Size()
+	if elm.IsClosed() {
+		sz += elm.CloseBlockHeight.Size()
+	}
+	if elm.Status == HtlcStatusClaimed {
+		sz += elm.Preimage.Size()
+	}
+	return sz
+}
+
+func (elm *Htlc) Serialize() ([]byte, error) {
+	var buffer bytes.Buffer
+	var b1, _ = elm.Status.Serialize()
+	var b2, _ = elm.CreateBlockHeight.Serialize()
+	var b3, _ = elm.TimeoutBlockHeight.Serialize()
+	var b4, _ = elm.SenderAddress.Serialize()
+	var b5, _ = elm.ReceiverAddress.Serialize()
+	var b6, _ = elm.HashType.Serialize()
+	var b7, _ = elm.Hashlock.Serialize()
+	var b8, _ = elm.LockAmount.Serialize()
+	var b9, _ = elm.LockSatoshi.Serialize()
+	var b10, e = elm.LockDiamondList.Serialize()
+	if e != nil {
+		return nil, e
+	}
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	buffer.Write(b5)
+	buffer.Write(b6)
+	buffer.Write(b7)
+	buffer.Write(b8)
+	buffer.Write(b9)
+	buffer.Write(b10)
+	if elm.IsClosed() {
+		var b11, _ = elm.CloseBlockHeight.Serialize()
+		buffer.Write(b11)
+	}
+	if elm.Status == HtlcStatusClaimed {
+		var b12, _ = elm.Preimage.Serialize()
+		buffer.Write(b12)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (elm *Htlc) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error = nil
+	seek, e = elm.Status.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.CreateBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.TimeoutBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.SenderAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.ReceiverAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.HashType.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.Hashlock.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockAmount.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockSatoshi.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = elm.LockDiamondList.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if elm.IsClosed() {
+		seek, e = elm.CloseBlockHeight.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	if elm.Status == HtlcStatusClaimed {
+		seek, e = elm.Preimage.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
+}
+
+// 检查原像是否与哈希锁匹配
+func (elm *Htlc) CheckPreimage(preimage []byte) bool {
+	var hx []byte
+	switch elm.HashType {
+	case HtlcHashTypeSha256:
+		h := sha256.Sum256(preimage)
+		hx = h[:]
+	case HtlcHashTypeSha3:
+		h := sha3.Sum256(preimage)
+		hx = h[:]
+	default:
+		return false
+	}
+	return bytes.Compare(hx, elm.Hashlock) == 0
+}
+
+// 设置领取状态
+func (elm *Htlc) SetClaimedStatus(height uint64, preimage fields.Bytes32) {
+	elm.Status = HtlcStatusClaimed
+	elm.CloseBlockHeight = fields.BlockHeight(height)
+	elm.Preimage = preimage
+}
+
+// 设置退回状态
+func (elm *Htlc) SetRefundedStatus(height uint64) {
+	elm.Status = HtlcStatusRefunded
+	elm.CloseBlockHeight = fields.BlockHeight(height)
+}
+
+// 回退为锁定状态
+func (elm *Htlc) DropClosedStatus() {
+	elm.Status = HtlcStatusLocked
+	elm.CloseBlockHeight = 0
+	elm.Preimage = nil
+}
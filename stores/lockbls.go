@@ -2,11 +2,15 @@ package stores
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/hacash/core/fields"
+	"math/big"
 )
 
 const (
 	LockblsIdLength = 18
+
+	LockblsScheduleMaxSteps = 10000 // 释放计划最多展开的步数
 )
 
 type Lockbls struct {
@@ -16,11 +20,87 @@ type Lockbls struct {
 	TotalLockAmount     fields.Amount      // 总共存入额度
 	LinearReleaseAmount fields.Amount      // 每次释放额度
 	BalanceAmount       fields.Amount      // 有效余额（每次可以取出可取额度之内的任意数额）
+
+	// 归属锁仓（vesting）扩展，不参与序列化
+	// 扩展单独保存在 LockblsVesting 储存空间，读取锁仓时一并载入，普通锁仓的存储数据保持不变
+	IsVesting fields.Bool
+	Vesting   LockblsVesting
+}
+
+// 归属锁仓：悬崖期之前不能提取，之后按步进线性归属，到最终高度全部归属
+type LockblsVesting struct {
+	CliffBlockHeight fields.BlockHeight     // 悬崖高度，之前归属额度为零
+	EndBlockHeight   fields.BlockHeight     // 最终释放高度，之后全部归属
+	RevokerAddress   fields.OptionalAddress // 可撤销地址（付款方），可选
+
+	// 撤销后写入
+	RevokedBlockHeight fields.BlockHeight // 撤销时的区块高度，为 0 表示未撤销
+	RevokedAmount      fields.Amount      // 撤销收回的未归属额度
+}
+
+func (this *LockblsVesting) IsRevoked() bool {
+	return this.RevokedBlockHeight > 0
+}
+
+func (this *LockblsVesting) Size() uint32 {
+	size := this.CliffBlockHeight.Size() +
+		this.EndBlockHeight.Size() +
+		this.RevokerAddress.Size() +
+		this.RevokedBlockHeight.Size()
+	if this.IsRevoked() {
+		size += this.RevokedAmount.Size()
+	}
+	return size
+}
+
+func (this *LockblsVesting) Serialize() ([]byte, error) {
+	var buffer = new(bytes.Buffer)
+	b1, _ := this.CliffBlockHeight.Serialize()
+	b2, _ := this.EndBlockHeight.Serialize()
+	b3, _ := this.RevokerAddress.Serialize()
+	b4, _ := this.RevokedBlockHeight.Serialize()
+	buffer.Write(b1)
+	buffer.Write(b2)
+	buffer.Write(b3)
+	buffer.Write(b4)
+	if this.IsRevoked() {
+		b5, _ := this.RevokedAmount.Serialize()
+		buffer.Write(b5)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (this *LockblsVesting) Parse(buf []byte, seek uint32) (uint32, error) {
+	var e error
+	seek, e = this.CliffBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.EndBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.RevokerAddress.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	seek, e = this.RevokedBlockHeight.Parse(buf, seek)
+	if e != nil {
+		return 0, e
+	}
+	if this.IsRevoked() {
+		seek, e = this.RevokedAmount.Parse(buf, seek)
+		if e != nil {
+			return 0, e
+		}
+	}
+	return seek, nil
 }
 
 func NewEmptyLockbls(addr fields.Address) *Lockbls {
 	return &Lockbls{
 		MasterAddress: addr[:],
+		IsVesting:     fields.CreateBool(false),
 	}
 }
 
@@ -78,3 +158,161 @@ func (this *Lockbls) Parse(buf []byte, seek uint32) (uint32, error) {
 	}
 	return seek, nil
 }
+
+// 是否为比特币单向转移创建的锁仓，ID 第一位为 0
+func IsLockblsIdOfBtcMove(id fields.LockblsId) bool {
+	return len(id) == LockblsIdLength && id[0] == 0
+}
+
+// 释放计划中的一步
+type LockblsReleaseStep struct {
+	BlockHeight      uint64         // 解锁区块高度
+	CumulativeAmount *fields.Amount // 到此高度累计解锁额度
+}
+
+// 已经提取的额度
+func (this *Lockbls) ExtractedAmount() (*fields.Amount, error) {
+	return this.TotalLockAmount.Sub(&this.BalanceAmount)
+}
+
+// 到指定高度为止累计解锁额度（包含已经提取的部分），不超过锁仓总额
+func (this *Lockbls) UnlockedAt(height uint64) (*fields.Amount, error) {
+	unlocked := this.unlockedValueAt(height)
+	return fields.NewAmountByBigInt(unlocked)
+}
+
+func (this *Lockbls) unlockedValueAt(height uint64) *big.Int {
+	if height < uint64(this.EffectBlockHeight) || this.LinearBlockNumber == 0 {
+		return big.NewInt(0)
+	}
+	if this.IsVesting.Check() {
+		return this.vestedValueAt(height)
+	}
+	// rlsnum == 可提取次数
+	rlsnum := (height - uint64(this.EffectBlockHeight)) / uint64(this.LinearBlockNumber)
+	unlocked := new(big.Int).Mul(this.LinearReleaseAmount.GetValue(), new(big.Int).SetUint64(rlsnum))
+	total := this.TotalLockAmount.GetValue()
+	if unlocked.Cmp(total) == 1 {
+		unlocked = total
+	}
+	return unlocked
+}
+
+// 归属锁仓到指定高度的归属额度
+// 撤销之后 TotalLockAmount 只剩下已归属部分，全部可以提取
+func (this *Lockbls) vestedValueAt(height uint64) *big.Int {
+	total := this.TotalLockAmount.GetValue()
+	if this.Vesting.IsRevoked() {
+		if height >= uint64(this.Vesting.RevokedBlockHeight) {
+			return total
+		}
+		// 撤销之前按原始总额计算
+		total = new(big.Int).Add(total, this.Vesting.RevokedAmount.GetValue())
+	}
+	if height < uint64(this.Vesting.CliffBlockHeight) {
+		return big.NewInt(0)
+	}
+	if height >= uint64(this.Vesting.EndBlockHeight) {
+		return this.TotalLockAmount.GetValue()
+	}
+	// 按步进线性归属 total * 已过步进区块数 / 总区块数
+	duration := uint64(this.Vesting.EndBlockHeight) - uint64(this.EffectBlockHeight)
+	elapsed := (height - uint64(this.EffectBlockHeight)) / uint64(this.LinearBlockNumber) * uint64(this.LinearBlockNumber)
+	vested := new(big.Int).Mul(total, new(big.Int).SetUint64(elapsed))
+	vested = vested.Div(vested, new(big.Int).SetUint64(duration))
+	if maxv := this.TotalLockAmount.GetValue(); vested.Cmp(maxv) == 1 {
+		vested = maxv
+	}
+	return vested
+}
+
+// 撤销时未归属、可以收回的额度
+// 已撤销的归属锁仓不能再次撤销
+func (this *Lockbls) UnvestedAt(height uint64) (*fields.Amount, error) {
+	unvested := new(big.Int).Sub(this.TotalLockAmount.GetValue(), this.unlockedValueAt(height))
+	if unvested.Sign() < 0 {
+		unvested = big.NewInt(0)
+	}
+	return fields.NewAmountByBigInt(unvested)
+}
+
+// 指定高度时当前可以提取的额度 = 累计解锁 - 已经提取，不超过锁仓余额
+func (this *Lockbls) ReleasableAt(height uint64) (*fields.Amount, error) {
+	extracted := new(big.Int).Sub(this.TotalLockAmount.GetValue(), this.BalanceAmount.GetValue())
+	releasable := new(big.Int).Sub(this.unlockedValueAt(height), extracted)
+	if releasable.Sign() < 0 {
+		releasable = big.NewInt(0)
+	}
+	balance := this.BalanceAmount.GetValue()
+	if releasable.Cmp(balance) == 1 {
+		releasable = balance
+	}
+	return fields.NewAmountByBigInt(releasable)
+}
+
+// 完整释放计划：每一个解锁高度和到该高度累计解锁的额度
+func (this *Lockbls) FullSchedule() ([]*LockblsReleaseStep, error) {
+	if this.IsVesting.Check() {
+		return this.vestingSchedule()
+	}
+	if this.LinearBlockNumber == 0 || !this.LinearReleaseAmount.IsPositive() {
+		return nil, fmt.Errorf("Lockbls linear release config error.")
+	}
+	total := this.TotalLockAmount.GetValue()
+	step := this.LinearReleaseAmount.GetValue()
+	// 步数向上取整
+	stepnum := new(big.Int).Add(total, new(big.Int).Sub(step, big.NewInt(1)))
+	stepnum = stepnum.Div(stepnum, step)
+	if !stepnum.IsUint64() || stepnum.Uint64() > LockblsScheduleMaxSteps {
+		return nil, fmt.Errorf("Lockbls release steps cannot over %d.", LockblsScheduleMaxSteps)
+	}
+	num := stepnum.Uint64()
+	heights := make([]uint64, 0, num)
+	for i := uint64(1); i <= num; i++ {
+		heights = append(heights, uint64(this.EffectBlockHeight)+i*uint64(this.LinearBlockNumber))
+	}
+	return this.scheduleByHeights(heights)
+}
+
+// 归属锁仓的释放计划：悬崖高度、之后每个步进高度、最终高度（或撤销高度）
+func (this *Lockbls) vestingSchedule() ([]*LockblsReleaseStep, error) {
+	if this.LinearBlockNumber == 0 {
+		return nil, fmt.Errorf("Lockbls linear release config error.")
+	}
+	lbn := uint64(this.LinearBlockNumber)
+	effect := uint64(this.EffectBlockHeight)
+	cliff := uint64(this.Vesting.CliffBlockHeight)
+	end := uint64(this.Vesting.EndBlockHeight)
+	if this.Vesting.IsRevoked() && uint64(this.Vesting.RevokedBlockHeight) < end {
+		end = uint64(this.Vesting.RevokedBlockHeight)
+	}
+	if (end-effect)/lbn+2 > LockblsScheduleMaxSteps {
+		return nil, fmt.Errorf("Lockbls release steps cannot over %d.", LockblsScheduleMaxSteps)
+	}
+	heights := []uint64{}
+	if cliff > effect && cliff < end {
+		heights = append(heights, cliff)
+	}
+	for h := effect + lbn; h < end; h += lbn {
+		if h > cliff {
+			heights = append(heights, h)
+		}
+	}
+	heights = append(heights, end)
+	return this.scheduleByHeights(heights)
+}
+
+func (this *Lockbls) scheduleByHeights(heights []uint64) ([]*LockblsReleaseStep, error) {
+	schedule := make([]*LockblsReleaseStep, 0, len(heights))
+	for _, height := range heights {
+		amt, e := fields.NewAmountByBigInt(this.unlockedValueAt(height))
+		if e != nil {
+			return nil, e
+		}
+		schedule = append(schedule, &LockblsReleaseStep{
+			BlockHeight:      height,
+			CumulativeAmount: amt,
+		})
+	}
+	return schedule, nil
+}
@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"github.com/hacash/core/fields"
 	"math"
+	"math/big"
 )
 
 const (
 	typeSizeMax   int = 32
-	typeSizeValid int = 19 // 当前可用的
+	typeSizeValid int = 24 // 当前可用的
 	// 钻石
 	TotalSupplyStoreTypeOfDiamond uint8 = 0 // 已挖掘出的钻石数量
 	// BTC
@@ -39,69 +40,234 @@ const (
 	TotalSupplyStoreTypeOfUsersLendingCumulationBitcoin                  uint8 = 17 // 用户间借贷比特币数量流水累计（单位：枚）
 	TotalSupplyStoreTypeOfUsersLendingCumulationHacAmount                uint8 = 18 // 用户间借贷HAC借出额流水累计（借出累计而非归还累计）
 	TotalSupplyStoreTypeOfUsersLendingBurningOnePercentInterestHacAmount uint8 = 19 // 用户间借贷系统销毁的1%利息统计
+	// 归属锁仓
+	TotalSupplyStoreTypeOfLocatedHACInVestingLockbls  uint8 = 20 // 当前锁定在归属锁仓内的HAC数量
+	TotalSupplyStoreTypeOfVestingLockblsRevokedAmount uint8 = 21 // 归属锁仓撤销收回的HAC累计
+	// 用户间借贷部分还款和展期
+	TotalSupplyStoreTypeOfUsersLendingPartialRepayHacAmount uint8 = 22 // 用户间借贷部分还款HAC流水累计
+	TotalSupplyStoreTypeOfUsersLendingExtendCount           uint8 = 23 // 用户间借贷展期次数累计
+	// 普通线性锁仓
+	TotalSupplyStoreTypeOfLocatedHACInLockbls uint8 = 24 // 当前锁定在普通线性锁仓内的HAC数量（不含比特币转移和归属锁仓）
 	// TotalSupplyStoreTypeOfUsersLendingLendersInterestHacAmountCumulation uint8 = ... // 用户间借贷贷出方赚取的利息流水累计
 
 )
 
+/**
+ * 统计数据精确储存
+ * 旧版本以 float64 保存全部统计值，累计数百万个区块后精度会漂移
+ * 新版本：HAC 类统计保存为精确的 fields.Amount ，数量类统计保存为整数
+ * 序列化带版本号，读取旧的 float64 格式时自动迁移，再次保存即为新格式
+ *
+ * 共识兼容：钻石平均竞价（AverageBidBurnPrice）等共识计算读取 Get() 的 float64 值，
+ * 必须与旧版本节点逐位一致，所以同时保留与旧版本完全相同的 float64 累加值（每次加减 amt.ToMei()），
+ * Get() 返回该值，精确值只用于类型化接口和统计报告
+ */
+
+const (
+	totalSupplyStoreLayoutMark         uint8 = 0 // 旧格式首字节为数量（32），新格式首字节为 0
+	TotalSupplyStoreVersionLegacyFloat uint8 = 0 // 旧的 float64 格式
+	TotalSupplyStoreVersionExact       uint8 = 1 // 精确格式（只有精确值）
+	TotalSupplyStoreVersionExactFloat  uint8 = 2 // 精确格式，并保存旧版本的 float64 累加值
+)
+
+// 数量类统计（整数），其余为 HAC 数额（单位：枚）
+var totalSupplyCountTypes = map[uint8]bool{
+	TotalSupplyStoreTypeOfDiamond:                                         true,
+	TotalSupplyStoreTypeOfTransferBitcoin:                                 true,
+	TotalSupplyStoreTypeOfLocatedSATInChannel:                             true,
+	TotalSupplyStoreTypeOfChannelOfOpening:                                true,
+	TotalSupplyStoreTypeOfSystemLendingDiamondCurrentMortgageCount:        true,
+	TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCurrentMortgageCount: true,
+	TotalSupplyStoreTypeOfUsersLendingCumulationDiamond:                   true,
+	TotalSupplyStoreTypeOfUsersLendingCumulationBitcoin:                   true,
+	TotalSupplyStoreTypeOfUsersLendingExtendCount:                         true,
+}
+
+// 是否为数量类统计
+func IsTotalSupplyCountType(ty uint8) bool {
+	return totalSupplyCountTypes[ty]
+}
+
+// 1 枚 = 10^248
+var totalSupplyMeiUnit = new(big.Int).Exp(big.NewInt(10), big.NewInt(248), nil)
+
 type TotalSupply struct {
-	changeMark []bool
-	dataBytes  []float64 //fields.Bytes8
+	changeMark  []bool
+	dataValues  []*big.Int // 数量类为整数；HAC 类为 fields.Amount.GetValue() 的数值
+	floatValues []float64  // 与旧版本相同方式累加的 float64 值（HAC 类单位为枚）
+
+	version uint8 // 读取时的储存格式版本
 }
 
 func NewTotalSupplyStoreData() *TotalSupply {
-	return &TotalSupply{
-		changeMark: make([]bool, typeSizeMax),
-		dataBytes:  make([]float64, typeSizeMax), // fields.Bytes8
+	t := &TotalSupply{
+		changeMark:  make([]bool, typeSizeMax),
+		dataValues:  make([]*big.Int, typeSizeMax),
+		floatValues: make([]float64, typeSizeMax),
+		version:     TotalSupplyStoreVersionExactFloat,
 	}
+	for i := 0; i < typeSizeMax; i++ {
+		t.dataValues[i] = big.NewInt(0)
+	}
+	return t
 }
 
-func (t *TotalSupply) Get(ty uint8) float64 {
+func checkTotalSupplyType(ty uint8) {
 	if ty > uint8(typeSizeValid) {
 		panic("type error")
 	}
-	// check mark
+}
+
+func checkTotalSupplyKind(ty uint8, iscount bool) {
+	checkTotalSupplyType(ty)
+	if IsTotalSupplyCountType(ty) != iscount {
+		panic(fmt.Sprintf("total supply type %d kind error", ty))
+	}
+}
+
+// 读取时的储存格式版本
+func (t *TotalSupply) Version() uint8 {
+	return t.version
+}
+
+// 精确值
+func (t *TotalSupply) getValue(ty uint8) *big.Int {
 	if t.changeMark[ty] {
-		return t.dataBytes[ty]
+		return new(big.Int).Set(t.dataValues[ty])
+	}
+	return big.NewInt(0)
+}
+
+func (t *TotalSupply) getFloat(ty uint8) float64 {
+	if t.changeMark[ty] {
+		return t.floatValues[ty]
 	}
-	// none
 	return 0
 }
 
+func (t *TotalSupply) setValue(ty uint8, value *big.Int, fvalue float64) {
+	t.changeMark[ty] = true
+	t.dataValues[ty] = new(big.Int).Set(value)
+	t.floatValues[ty] = fvalue
+}
+
+/******** 类型化接口 ********/
+
+// 读取 HAC 类统计
+func (t *TotalSupply) GetAmount(ty uint8) (*fields.Amount, error) {
+	checkTotalSupplyKind(ty, false)
+	amt, e := fields.NewAmountByBigInt(t.getValue(ty))
+	if e != nil {
+		return nil, fmt.Errorf("total supply type %d amount error: %s", ty, e.Error())
+	}
+	return amt, nil
+}
+
+// 设置 HAC 类统计
+func (t *TotalSupply) SetAmount(ty uint8, amt *fields.Amount) {
+	checkTotalSupplyKind(ty, false)
+	t.setValue(ty, amt.GetValue(), amt.ToMei())
+}
+
+// 增加 HAC 类统计
+func (t *TotalSupply) DoAddAmount(ty uint8, amt *fields.Amount) {
+	checkTotalSupplyKind(ty, false)
+	t.setValue(ty, new(big.Int).Add(t.getValue(ty), amt.GetValue()), t.getFloat(ty)+amt.ToMei())
+}
+
+// 减少 HAC 类统计
+func (t *TotalSupply) DoSubAmount(ty uint8, amt *fields.Amount) {
+	checkTotalSupplyKind(ty, false)
+	t.setValue(ty, new(big.Int).Sub(t.getValue(ty), amt.GetValue()), t.getFloat(ty)-amt.ToMei())
+}
+
+// 增加 HAC 类统计，float64 累加值按旧版本的计算结果 fvalue 增加
+func (t *TotalSupply) DoAddAmountWithFloat(ty uint8, amt *fields.Amount, fvalue float64) {
+	checkTotalSupplyKind(ty, false)
+	t.setValue(ty, new(big.Int).Add(t.getValue(ty), amt.GetValue()), t.getFloat(ty)+fvalue)
+}
+
+// 减少 HAC 类统计，float64 累加值按旧版本的计算结果 fvalue 减少
+func (t *TotalSupply) DoSubAmountWithFloat(ty uint8, amt *fields.Amount, fvalue float64) {
+	checkTotalSupplyKind(ty, false)
+	t.setValue(ty, new(big.Int).Sub(t.getValue(ty), amt.GetValue()), t.getFloat(ty)-fvalue)
+}
+
+// 读取数量类统计
+func (t *TotalSupply) GetCount(ty uint8) int64 {
+	checkTotalSupplyKind(ty, true)
+	return t.getValue(ty).Int64()
+}
+
+// 设置数量类统计
+func (t *TotalSupply) SetCount(ty uint8, num int64) {
+	checkTotalSupplyKind(ty, true)
+	t.setValue(ty, big.NewInt(num), float64(num))
+}
+
+// 增加数量类统计
+func (t *TotalSupply) DoAddCount(ty uint8, num uint64) int64 {
+	checkTotalSupplyKind(ty, true)
+	t.setValue(ty, new(big.Int).Add(t.getValue(ty), new(big.Int).SetUint64(num)), t.getFloat(ty)+float64(num))
+	return t.GetCount(ty)
+}
+
+// 减少数量类统计
+func (t *TotalSupply) DoSubCount(ty uint8, num uint64) int64 {
+	checkTotalSupplyKind(ty, true)
+	t.setValue(ty, new(big.Int).Sub(t.getValue(ty), new(big.Int).SetUint64(num)), t.getFloat(ty)-float64(num))
+	return t.GetCount(ty)
+}
+
+/******** 兼容旧接口（float64） ********/
+
+// 与旧版本相同方式累加的 float64 值，HAC 类单位为枚，共识计算使用此值
+func (t *TotalSupply) Get(ty uint8) float64 {
+	checkTotalSupplyType(ty)
+	return t.getFloat(ty)
+}
+
+// 精确值转换为 float64 ，HAC 类单位为枚
+func (t *TotalSupply) exactToFloat(ty uint8) float64 {
+	value := t.getValue(ty)
+	if IsTotalSupplyCountType(ty) {
+		return float64(value.Int64())
+	}
+	mei := new(big.Float).Quo(new(big.Float).SetInt(value), new(big.Float).SetInt(totalSupplyMeiUnit))
+	f, _ := mei.Float64()
+	return f
+}
+
+// float64 转换为精确值：数量类四舍五入，HAC 类保留到单位 240
+func totalSupplyValueFromFloat(ty uint8, value float64) *big.Int {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return big.NewInt(0)
+	}
+	if IsTotalSupplyCountType(ty) {
+		return big.NewInt(int64(math.Round(value)))
+	}
+	yi, _ := new(big.Float).SetFloat64(math.Round(value * 10000 * 10000)).Int(nil)
+	return yi.Mul(yi, new(big.Int).Exp(big.NewInt(10), big.NewInt(240), nil))
+}
+
 // 设置
 func (t *TotalSupply) Set(ty uint8, value float64) {
-	if ty > uint8(typeSizeValid) {
-		panic("type error")
-	}
-	// check mark
-	t.changeMark[ty] = true
-	// 保存
-	t.dataBytes[ty] = value
+	checkTotalSupplyType(ty)
+	t.setValue(ty, totalSupplyValueFromFloat(ty, value), value)
 }
 
 // 增加
 func (t *TotalSupply) DoAdd(ty uint8, value float64) float64 {
-	if ty > uint8(typeSizeValid) {
-		panic("type error")
-	}
-	// check mark
-	t.changeMark[ty] = true
-	vbase := t.Get(ty)
-	newv := vbase + value
-	t.Set(ty, newv)
-	return newv
+	checkTotalSupplyType(ty)
+	t.setValue(ty, new(big.Int).Add(t.getValue(ty), totalSupplyValueFromFloat(ty, value)), t.getFloat(ty)+value)
+	return t.Get(ty)
 }
 
 // 减少
 func (t *TotalSupply) DoSub(ty uint8, value float64) float64 {
-	if ty > uint8(typeSizeValid) {
-		panic("type error")
-	}
-	// check mark
-	t.changeMark[ty] = true
-	vbase := t.Get(ty)
-	newv := vbase - value
-	t.Set(ty, newv)
-	return newv
+	checkTotalSupplyType(ty)
+	t.setValue(ty, new(big.Int).Sub(t.getValue(ty), totalSupplyValueFromFloat(ty, value)), t.getFloat(ty)-value)
+	return t.Get(ty)
 }
 
 // 覆盖保存
@@ -109,7 +275,8 @@ func (t *TotalSupply) CoverCopySave(src *TotalSupply) {
 	for i := 0; i < typeSizeMax; i++ {
 		if src.changeMark[i] {
 			t.changeMark[i] = true
-			t.dataBytes[i] = src.dataBytes[i]
+			t.dataValues[i] = new(big.Int).Set(src.dataValues[i])
+			t.floatValues[i] = src.floatValues[i]
 		}
 	}
 }
@@ -117,40 +284,219 @@ func (t *TotalSupply) CoverCopySave(src *TotalSupply) {
 // 拷贝复制
 func (t *TotalSupply) Clone() *TotalSupply {
 	changeMark := []bool{}
-	dataBytes := []float64{}
 	changeMark = append(changeMark, t.changeMark...)
-	dataBytes = append(dataBytes, t.dataBytes...)
+	dataValues := make([]*big.Int, len(t.dataValues))
+	for i, v := range t.dataValues {
+		dataValues[i] = new(big.Int).Set(v)
+	}
+	floatValues := []float64{}
+	floatValues = append(floatValues, t.floatValues...)
 	return &TotalSupply{
-		changeMark: changeMark,
-		dataBytes:  dataBytes,
+		changeMark:  changeMark,
+		dataValues:  dataValues,
+		floatValues: floatValues,
+		version:     t.version,
 	}
 }
 
-// 序列化
+// 序列化，总是保存为最新的精确格式
 func (t *TotalSupply) Serialize() ([]byte, error) {
-	buf := bytes.NewBuffer([]byte{uint8(typeSizeMax)}) // 长度
-	for i := 0; i < typeSizeMax; i++ {
-		intbits := math.Float64bits(t.dataBytes[i])
-		var btstore = fields.Bytes8{0, 0, 0, 0, 0, 0, 0, 0}
-		binary.BigEndian.PutUint64(btstore, intbits)
-		buf.Write(btstore)
+	itemnum := typeSizeValid + 1
+	buf := bytes.NewBuffer([]byte{totalSupplyStoreLayoutMark, TotalSupplyStoreVersionExactFloat, uint8(itemnum)})
+	for i := 0; i < itemnum; i++ {
+		ty := uint8(i)
+		value := t.getValue(ty)
+		var fltstore = fields.Bytes8{0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(fltstore, math.Float64bits(t.getFloat(ty)))
+		buf.Write(fltstore)
+		if IsTotalSupplyCountType(ty) {
+			var btstore = fields.Bytes8{0, 0, 0, 0, 0, 0, 0, 0}
+			binary.BigEndian.PutUint64(btstore, uint64(value.Int64()))
+			buf.Write(btstore)
+		} else {
+			amt, e := fields.NewAmountByBigInt(value)
+			if e != nil {
+				return nil, e
+			}
+			amtbts, e := amt.Serialize()
+			if e != nil {
+				return nil, e
+			}
+			buf.Write(amtbts)
+		}
 	}
 	return buf.Bytes(), nil
 }
 
-// 反序列化
+// 反序列化，兼容旧的 float64 格式（自动迁移）
 func (t *TotalSupply) Parse(buf []byte, seek uint32) (uint32, error) {
 	if int(seek)+1 > len(buf) {
 		return 0, fmt.Errorf("buf too short")
 	}
+	if t.dataValues == nil {
+		*t = *NewTotalSupplyStoreData()
+	}
+	if buf[seek] != totalSupplyStoreLayoutMark {
+		return t.parseLegacyFloat(buf, seek)
+	}
+	if int(seek)+3 > len(buf) {
+		return 0, fmt.Errorf("buf too short")
+	}
+	version := buf[seek+1]
+	if version != TotalSupplyStoreVersionExact && version != TotalSupplyStoreVersionExactFloat {
+		return 0, fmt.Errorf("total supply store version %d not support", version)
+	}
+	itemnum := int(buf[seek+2])
+	if itemnum > typeSizeMax {
+		return 0, fmt.Errorf("total supply item number %d overflow", itemnum)
+	}
+	seek += 3
+	t.changeMark = make([]bool, typeSizeMax)
+	for i := 0; i < itemnum; i++ {
+		ty := uint8(i)
+		var fvalue float64
+		if version == TotalSupplyStoreVersionExactFloat {
+			if int(seek)+8 > len(buf) {
+				return 0, fmt.Errorf("buf too short")
+			}
+			fvalue = math.Float64frombits(binary.BigEndian.Uint64(buf[seek : seek+8]))
+			seek += 8
+		}
+		var value *big.Int
+		if IsTotalSupplyCountType(ty) {
+			if int(seek)+8 > len(buf) {
+				return 0, fmt.Errorf("buf too short")
+			}
+			num := int64(binary.BigEndian.Uint64(buf[seek : seek+8]))
+			value = big.NewInt(num)
+			seek += 8
+		} else {
+			var amt fields.Amount
+			var e error
+			seek, e = amt.Parse(buf, seek)
+			if e != nil {
+				return 0, e
+			}
+			value = amt.GetValue()
+		}
+		t.setValue(ty, value, fvalue)
+		if version == TotalSupplyStoreVersionExact {
+			t.floatValues[ty] = t.exactToFloat(ty) // 没有保存 float64 值
+		}
+	}
+	t.version = version
+	return seek, nil
+}
+
+// 读取旧的 float64 格式并迁移为精确值
+func (t *TotalSupply) parseLegacyFloat(buf []byte, seek uint32) (uint32, error) {
 	tysize := int(buf[seek])
-	t.changeMark = make([]bool, tysize)
+	if tysize > typeSizeMax {
+		return 0, fmt.Errorf("total supply item number %d overflow", tysize)
+	}
+	if int(seek)+1+tysize*8 > len(buf) {
+		return 0, fmt.Errorf("buf too short")
+	}
+	t.changeMark = make([]bool, typeSizeMax)
 	seek += 1
 	for i := 0; i < tysize; i++ {
-		t.changeMark[i] = true
 		intbts := binary.BigEndian.Uint64(buf[seek : seek+8])
-		t.dataBytes[i] = math.Float64frombits(intbts)
+		fvalue := math.Float64frombits(intbts)
+		t.setValue(uint8(i), totalSupplyValueFromFloat(uint8(i), fvalue), fvalue) // 保留原 float64 值
 		seek += 8
 	}
+	t.version = TotalSupplyStoreVersionLegacyFloat
 	return seek, nil
 }
+
+// 把旧的 float64 格式数据迁移为精确格式
+func MigrateTotalSupplyFromLegacyFloat(buf []byte) ([]byte, error) {
+	t := NewTotalSupplyStoreData()
+	_, e := t.Parse(buf, 0)
+	if e != nil {
+		return nil, e
+	}
+	return t.Serialize()
+}
+
+/******** 类型化统计汇总 ********/
+
+type TotalSupplySummary struct {
+	// 钻石与比特币
+	MinedDiamond       int64 // 已挖掘出的钻石数量
+	TransferredBitcoin int64 // 已成功转移过来的 BTC 枚数
+	// 流通数量
+	BlockReward                    *fields.Amount // 区块奖励HAC累计
+	ChannelInterest                *fields.Amount // 通道利息HAC累计
+	BitcoinTransferUnlockSuccessed *fields.Amount // 比特币转移增发成功解锁的HAC累计
+	// 通道
+	LocatedHACInChannel *fields.Amount // 当前锁定在通道内的HAC
+	LocatedSATInChannel int64          // 当前锁定在通道内的SAT
+	ChannelOfOpening    int64          // 当前开启的通道数量
+	// 销毁
+	BurningFee *fields.Amount // 手续费燃烧销毁HAC累计
+	// 钻石系统借贷
+	SystemLendingDiamondCurrentMortgageCount      int64
+	SystemLendingDiamondCumulationLoanHacAmount   *fields.Amount
+	SystemLendingDiamondCumulationRansomHacAmount *fields.Amount
+	// 比特币系统借贷
+	SystemLendingBitcoinPortionCurrentMortgageCount      int64
+	SystemLendingBitcoinPortionBurningInterestHacAmount  *fields.Amount
+	SystemLendingBitcoinPortionCumulationLoanHacAmount   *fields.Amount
+	SystemLendingBitcoinPortionCumulationRansomHacAmount *fields.Amount
+	// 用户间借贷
+	UsersLendingCumulationDiamond                  int64
+	UsersLendingCumulationBitcoin                  int64 // 单位：SAT
+	UsersLendingCumulationHacAmount                *fields.Amount
+	UsersLendingBurningOnePercentInterestHacAmount *fields.Amount
+	UsersLendingPartialRepayHacAmount              *fields.Amount
+	UsersLendingExtendCount                        int64
+	// 归属锁仓
+	LocatedHACInVestingLockbls  *fields.Amount
+	VestingLockblsRevokedAmount *fields.Amount
+	// 普通线性锁仓
+	LocatedHACInLockbls *fields.Amount
+}
+
+// 类型化的统计汇总
+func (t *TotalSupply) Summary() (*TotalSupplySummary, error) {
+	var err error
+	amount := func(ty uint8) *fields.Amount {
+		amt, e := t.GetAmount(ty)
+		if e != nil && err == nil {
+			err = e
+		}
+		return amt
+	}
+	sm := &TotalSupplySummary{
+		MinedDiamond:                             t.GetCount(TotalSupplyStoreTypeOfDiamond),
+		TransferredBitcoin:                       t.GetCount(TotalSupplyStoreTypeOfTransferBitcoin),
+		BlockReward:                              amount(TotalSupplyStoreTypeOfBlockReward),
+		ChannelInterest:                          amount(TotalSupplyStoreTypeOfChannelInterest),
+		BitcoinTransferUnlockSuccessed:           amount(TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed),
+		LocatedHACInChannel:                      amount(TotalSupplyStoreTypeOfLocatedHACInChannel),
+		LocatedSATInChannel:                      t.GetCount(TotalSupplyStoreTypeOfLocatedSATInChannel),
+		ChannelOfOpening:                         t.GetCount(TotalSupplyStoreTypeOfChannelOfOpening),
+		BurningFee:                               amount(TotalSupplyStoreTypeOfBurningFee),
+		SystemLendingDiamondCurrentMortgageCount: t.GetCount(TotalSupplyStoreTypeOfSystemLendingDiamondCurrentMortgageCount),
+		SystemLendingDiamondCumulationLoanHacAmount:          amount(TotalSupplyStoreTypeOfSystemLendingDiamondCumulationLoanHacAmount),
+		SystemLendingDiamondCumulationRansomHacAmount:        amount(TotalSupplyStoreTypeOfSystemLendingDiamondCumulationRansomHacAmount),
+		SystemLendingBitcoinPortionCurrentMortgageCount:      t.GetCount(TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCurrentMortgageCount),
+		SystemLendingBitcoinPortionBurningInterestHacAmount:  amount(TotalSupplyStoreTypeOfSystemLendingBitcoinPortionBurningInterestHacAmount),
+		SystemLendingBitcoinPortionCumulationLoanHacAmount:   amount(TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCumulationLoanHacAmount),
+		SystemLendingBitcoinPortionCumulationRansomHacAmount: amount(TotalSupplyStoreTypeOfSystemLendingBitcoinPortionCumulationRansomHacAmount),
+		UsersLendingCumulationDiamond:                        t.GetCount(TotalSupplyStoreTypeOfUsersLendingCumulationDiamond),
+		UsersLendingCumulationBitcoin:                        t.GetCount(TotalSupplyStoreTypeOfUsersLendingCumulationBitcoin),
+		UsersLendingCumulationHacAmount:                      amount(TotalSupplyStoreTypeOfUsersLendingCumulationHacAmount),
+		UsersLendingBurningOnePercentInterestHacAmount:       amount(TotalSupplyStoreTypeOfUsersLendingBurningOnePercentInterestHacAmount),
+		UsersLendingPartialRepayHacAmount:                    amount(TotalSupplyStoreTypeOfUsersLendingPartialRepayHacAmount),
+		UsersLendingExtendCount:                              t.GetCount(TotalSupplyStoreTypeOfUsersLendingExtendCount),
+		LocatedHACInVestingLockbls:                           amount(TotalSupplyStoreTypeOfLocatedHACInVestingLockbls),
+		VestingLockblsRevokedAmount:                          amount(TotalSupplyStoreTypeOfVestingLockblsRevokedAmount),
+		LocatedHACInLockbls:                                  amount(TotalSupplyStoreTypeOfLocatedHACInLockbls),
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
@@ -0,0 +1,87 @@
+package supply
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"testing"
+)
+
+func Test_btc_move_reward_total(t *testing.T) {
+
+	// 逐枚累加与分层计算一致
+	var sum int64 = 0
+	for i := int64(1); i <= 5000; i++ {
+		one := actions.MoveBtcCoinRewardTotalByCount(i) - actions.MoveBtcCoinRewardTotalByCount(i-1)
+		sum += one
+		if i == 1 && one != 1048576 {
+			t.Fatalf("first btc reward %d", one)
+		}
+	}
+	if sum != actions.MoveBtcCoinRewardTotalByCount(5000) {
+		t.Fatal("btc move reward total error")
+	}
+	fmt.Println(actions.MoveBtcCoinRewardTotalByCount(1), actions.MoveBtcCoinRewardTotalByCount(3), actions.MoveBtcCoinRewardTotalByCount(2097151))
+}
+
+func Test_supply_report(t *testing.T) {
+
+	amt := func(s string) *fields.Amount {
+		a, _ := fields.NewAmountFromStringUnsafe(s)
+		return a
+	}
+
+	ttsp := stores.NewTotalSupplyStoreData()
+	ttsp.DoAddCount(stores.TotalSupplyStoreTypeOfDiamond, 40000)
+	ttsp.DoAddCount(stores.TotalSupplyStoreTypeOfTransferBitcoin, 3)
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfBlockReward, amt("1000000"))
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfChannelInterest, amt("12.5"))
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfBitcoinTransferUnlockSuccessed, amt("1048576"))
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfSystemLendingDiamondCumulationLoanHacAmount, amt("1000"))
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfSystemLendingDiamondCumulationRansomHacAmount, amt("400"))
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfBurningFee, amt("ㄜ200000000001:240"))
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfUsersLendingBurningOnePercentInterestHacAmount, amt("3"))
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfLocatedHACInChannel, amt("5000"))
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfLocatedHACInVestingLockbls, amt("100"))
+
+	r, e := NewReport(ttsp, 500000)
+	if e != nil {
+		t.Fatal(e)
+	}
+	// 1000000 + 12.5 + 1048576 + 1000 = 2049588.5
+	if s := AmountToMeiString(r.Issued); s != "2049588.5" {
+		t.Fatalf("issued %s", s)
+	}
+	// 2000.00000001 + 3 + 400 = 2403.00000001
+	if s := AmountToMeiString(r.Burned); s != "2403.00000001" {
+		t.Fatalf("burned %s", s)
+	}
+	if s := AmountToMeiString(r.Circulating); s != "2042085.49999999" {
+		t.Fatalf("circulating %s", s)
+	}
+	// 3 枚 BTC 应增发 1048576 + 524288 * 2
+	if s := AmountToMeiString(r.BtcMoveLocked); s != "1048576" {
+		t.Fatalf("btc move locked %s", s)
+	}
+
+	// 普通线性锁仓同样计入锁定
+	ttsp.DoAddAmount(stores.TotalSupplyStoreTypeOfLocatedHACInLockbls, amt("20"))
+	r2, e := NewReport(ttsp, 500000)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if s := AmountToMeiString(r2.Locked); s != "5120" {
+		t.Fatalf("locked %s", s)
+	}
+	if s := AmountToMeiString(r2.Circulating); s != "2042065.49999999" {
+		t.Fatalf("circulating with lockbls %s", s)
+	}
+
+	jsonbts, e := json.Marshal(r)
+	if e != nil {
+		t.Fatal(e)
+	}
+	fmt.Println(string(jsonbts))
+}
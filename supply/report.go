@@ -0,0 +1,240 @@
+package supply
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hacash/core/actions"
+	"github.com/hacash/core/fields"
+	"github.com/hacash/core/stores"
+	"math/big"
+	"strings"
+)
+
+/**
+ * 流通量与发行量报告
+ * 由 stores.TotalSupply 统计数据和当前区块高度推导，供浏览器、交易所等直接使用
+ *
+ * 计算公式（单位：枚 HAC）：
+ *
+ *   已发行 Issued = 区块奖励 BlockReward
+ *                 + 通道利息 ChannelInterest
+ *                 + 比特币转移已解锁 BtcMoveUnlocked
+ *                 + 系统借贷累计借出 SystemLendingLoan （钻石 + 比特币）
+ *
+ *   已销毁 Burned = 手续费销毁 BurnedFee （含钻石竞价 90% 销毁）
+ *                 + 借贷利息销毁 BurnedLendingInterest （比特币系统借贷预付利息 + 用户间借贷 1% 利息）
+ *                 + 系统借贷赎回销毁 BurnedSystemLendingRansom （钻石 + 比特币）
+ *
+ *   总量 TotalSupply = Issued - Burned
+ *   锁定 Locked = 通道锁定 LockedInChannel + 普通线性锁仓 LockedInLockbls + 归属锁仓 LockedInVestingLockbls
+ *   （比特币转移的锁仓尚未计入 Issued ，不在 Locked 内；普通线性锁仓统计从创世区块开始累加，已同步的节点需要重建数据）
+ *   流通量 Circulating = TotalSupply - Locked
+ *
+ *   比特币转移应增发 BtcMoveTotal = 按转移枚数计算的增发总量
+ *   比特币转移待解锁 BtcMoveLocked = BtcMoveTotal - BtcMoveUnlocked （不计入 TotalSupply）
+ *
+ * 所有数额精确到 8 位小数（单位 240），多余部分舍去
+ */
+
+const (
+	reportAmountUnit = 240 // 报告数额精度：1 枚 = 10^8 个最小单位
+)
+
+// 供应量报告
+type Report struct {
+	BlockHeight uint64
+
+	// 钻石与比特币
+	MinedDiamond       int64 // 已挖出钻石数量
+	TransferredBitcoin int64 // 已转移的 BTC 枚数
+
+	// 发行
+	MinedReward       *fields.Amount // 区块奖励累计
+	ChannelInterest   *fields.Amount // 通道利息累计
+	BtcMoveUnlocked   *fields.Amount // 比特币转移增发已解锁
+	SystemLendingLoan *fields.Amount // 系统借贷累计借出
+	Issued            *fields.Amount
+
+	// 销毁
+	BurnedFee                 *fields.Amount // 手续费销毁
+	BurnedLendingInterest     *fields.Amount // 借贷利息销毁
+	BurnedSystemLendingRansom *fields.Amount // 系统借贷赎回销毁
+	Burned                    *fields.Amount
+
+	// 锁定
+	LockedInChannel        *fields.Amount
+	LockedInLockbls        *fields.Amount
+	LockedInVestingLockbls *fields.Amount
+	Locked                 *fields.Amount
+	LockedSATInChannel     int64
+
+	// 比特币转移
+	BtcMoveTotal  *fields.Amount // 应增发总量
+	BtcMoveLocked *fields.Amount // 尚未解锁
+
+	// 总量与流通量
+	TotalSupply *fields.Amount
+	Circulating *fields.Amount
+}
+
+// 由统计数据生成报告
+func NewReport(ttsp *stores.TotalSupply, blockHeight uint64) (*Report, error) {
+	if ttsp == nil {
+		return nil, fmt.Errorf("total supply cannot be nil")
+	}
+	sm, e := ttsp.Summary()
+	if e != nil {
+		return nil, e
+	}
+	r := &Report{
+		BlockHeight:        blockHeight,
+		MinedDiamond:       sm.MinedDiamond,
+		TransferredBitcoin: sm.TransferredBitcoin,
+		LockedSATInChannel: sm.LocatedSATInChannel,
+	}
+	// 发行
+	r.MinedReward = truncateAmount(sm.BlockReward)
+	r.ChannelInterest = truncateAmount(sm.ChannelInterest)
+	r.BtcMoveUnlocked = truncateAmount(sm.BitcoinTransferUnlockSuccessed)
+	r.SystemLendingLoan, e = sumAmounts(
+		sm.SystemLendingDiamondCumulationLoanHacAmount,
+		sm.SystemLendingBitcoinPortionCumulationLoanHacAmount)
+	if e != nil {
+		return nil, e
+	}
+	r.Issued, e = sumAmounts(r.MinedReward, r.ChannelInterest, r.BtcMoveUnlocked, r.SystemLendingLoan)
+	if e != nil {
+		return nil, e
+	}
+	// 销毁
+	r.BurnedFee = truncateAmount(sm.BurningFee)
+	r.BurnedLendingInterest, e = sumAmounts(
+		sm.SystemLendingBitcoinPortionBurningInterestHacAmount,
+		sm.UsersLendingBurningOnePercentInterestHacAmount)
+	if e != nil {
+		return nil, e
+	}
+	r.BurnedSystemLendingRansom, e = sumAmounts(
+		sm.SystemLendingDiamondCumulationRansomHacAmount,
+		sm.SystemLendingBitcoinPortionCumulationRansomHacAmount)
+	if e != nil {
+		return nil, e
+	}
+	r.Burned, e = sumAmounts(r.BurnedFee, r.BurnedLendingInterest, r.BurnedSystemLendingRansom)
+	if e != nil {
+		return nil, e
+	}
+	// 锁定
+	r.LockedInChannel = truncateAmount(sm.LocatedHACInChannel)
+	r.LockedInLockbls = truncateAmount(sm.LocatedHACInLockbls)
+	r.LockedInVestingLockbls = truncateAmount(sm.LocatedHACInVestingLockbls)
+	r.Locked, e = sumAmounts(r.LockedInChannel, r.LockedInLockbls, r.LockedInVestingLockbls)
+	if e != nil {
+		return nil, e
+	}
+	// 比特币转移
+	r.BtcMoveTotal = fields.NewAmountByUnit248(actions.MoveBtcCoinRewardTotalByCount(sm.TransferredBitcoin))
+	r.BtcMoveLocked, e = r.BtcMoveTotal.Sub(r.BtcMoveUnlocked)
+	if e != nil {
+		return nil, e
+	}
+	// 总量与流通量
+	r.TotalSupply, e = r.Issued.Sub(r.Burned)
+	if e != nil {
+		return nil, e
+	}
+	r.Circulating, e = r.TotalSupply.Sub(r.Locked)
+	if e != nil {
+		return nil, e
+	}
+	return r, nil
+}
+
+// 数额截断到 8 位小数
+func truncateAmount(amt *fields.Amount) *fields.Amount {
+	if amt == nil {
+		return fields.NewEmptyAmount()
+	}
+	if int(amt.Unit) >= reportAmountUnit {
+		return amt
+	}
+	base := new(big.Int).Exp(big.NewInt(10), big.NewInt(reportAmountUnit), nil)
+	value := new(big.Int).Quo(amt.GetValue(), base)
+	res, e := fields.NewAmountByBigIntWithUnit(value, reportAmountUnit)
+	if e != nil {
+		return fields.NewEmptyAmount()
+	}
+	return res
+}
+
+// 累加多个数额
+func sumAmounts(amts ...*fields.Amount) (*fields.Amount, error) {
+	total := fields.NewEmptyAmount()
+	for _, amt := range amts {
+		var e error
+		total, e = total.Add(truncateAmount(amt))
+		if e != nil {
+			return nil, e
+		}
+	}
+	return total, nil
+}
+
+// 精确转换为枚的十进制字符串，保留 8 位小数，去掉末尾的 0
+func AmountToMeiString(amt *fields.Amount) string {
+	value := truncateAmount(amt).GetValue()
+	base := new(big.Int).Exp(big.NewInt(10), big.NewInt(reportAmountUnit), nil)
+	value = new(big.Int).Quo(value, base)
+	sign := ""
+	if value.Sign() < 0 {
+		sign = "-"
+		value = value.Neg(value)
+	}
+	intpart, decpart := new(big.Int).QuoRem(value, big.NewInt(100000000), new(big.Int))
+	decstr := strings.TrimRight(fmt.Sprintf("%08d", decpart.Int64()), "0")
+	if decstr == "" {
+		return sign + intpart.String()
+	}
+	return sign + intpart.String() + "." + decstr
+}
+
+// json api
+func (r *Report) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"block_height":        r.BlockHeight,
+		"mined_diamond":       r.MinedDiamond,
+		"transferred_bitcoin": r.TransferredBitcoin,
+		"issued": map[string]interface{}{
+			"mined_reward":        AmountToMeiString(r.MinedReward),
+			"channel_interest":    AmountToMeiString(r.ChannelInterest),
+			"btc_move_unlocked":   AmountToMeiString(r.BtcMoveUnlocked),
+			"system_lending_loan": AmountToMeiString(r.SystemLendingLoan),
+			"total":               AmountToMeiString(r.Issued),
+		},
+		"burned": map[string]interface{}{
+			"fee":                   AmountToMeiString(r.BurnedFee),
+			"lending_interest":      AmountToMeiString(r.BurnedLendingInterest),
+			"system_lending_ransom": AmountToMeiString(r.BurnedSystemLendingRansom),
+			"total":                 AmountToMeiString(r.Burned),
+		},
+		"locked": map[string]interface{}{
+			"channel":         AmountToMeiString(r.LockedInChannel),
+			"lockbls":         AmountToMeiString(r.LockedInLockbls),
+			"vesting_lockbls": AmountToMeiString(r.LockedInVestingLockbls),
+			"total":           AmountToMeiString(r.Locked),
+			"channel_sat":     r.LockedSATInChannel,
+		},
+		"btc_move": map[string]interface{}{
+			"total":    AmountToMeiString(r.BtcMoveTotal),
+			"unlocked": AmountToMeiString(r.BtcMoveUnlocked),
+			"locked":   AmountToMeiString(r.BtcMoveLocked),
+		},
+		"total_supply": AmountToMeiString(r.TotalSupply),
+		"circulating":  AmountToMeiString(r.Circulating),
+	}
+}
+
+// json 输出
+func (r *Report) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Describe())
+}
@@ -3,9 +3,9 @@ package transactions
 import (
 	"bytes"
 	"encoding/hex"
-	"fmt"
 	"github.com/hacash/core/account"
 	"github.com/hacash/core/actions"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
 	"github.com/hacash/core/stores"
@@ -184,7 +184,7 @@ func (trs *Transaction_0_Coinbase) Parse(buf []byte, seek uint32) (uint32, error
 			trs.Witnesses = make([]fields.Sign, lenwc)
 			for i := 0; i < lenwc; i++ {
 				if seek >= uint32(len(buf)) {
-					return 0, fmt.Errorf("seek out of buf len.")
+					return 0, errs.New(errs.CodeMalformed, "seek out of buf len.")
 				}
 				trs.WitnessSigs[i] = buf[seek]
 				seek++
@@ -192,7 +192,7 @@ func (trs *Transaction_0_Coinbase) Parse(buf []byte, seek uint32) (uint32, error
 			for i := 0; i < lenwc; i++ {
 				var sign fields.Sign
 				if seek >= uint32(len(buf)) {
-					return 0, fmt.Errorf("seek out of buf len.")
+					return 0, errs.New(errs.CodeMalformed, "seek out of buf len.")
 				}
 				seek, e = sign.Parse(buf, seek)
 				if e != nil {
@@ -311,7 +311,7 @@ func (trs *Transaction_0_Coinbase) WriteinChainState(state interfaces.ChainState
 	if e2 != nil {
 		return e2
 	}
-	totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfBlockReward, trs.Reward.ToMei())
+	totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfBlockReward, &trs.Reward)
 	// feeBurning
 	if trs.TotalFeeMinerReceived.NotEqual(&trs.TotalFeeUserPayed) {
 		// 有销毁
@@ -319,7 +319,7 @@ func (trs *Transaction_0_Coinbase) WriteinChainState(state interfaces.ChainState
 		if e != nil {
 			return e
 		}
-		totalsupply.DoAdd(stores.TotalSupplyStoreTypeOfBurningFee, burnamt.ToMei())
+		totalsupply.DoAddAmount(stores.TotalSupplyStoreTypeOfBurningFee, burnamt)
 	}
 	// update total supply
 	e3 := state.UpdateSetTotalSupply(totalsupply)
@@ -343,7 +343,7 @@ func (trs *Transaction_0_Coinbase) RecoverChainState(state interfaces.ChainState
 	if e2 != nil {
 		return e2
 	}
-	totalsupply.DoSub(stores.TotalSupplyStoreTypeOfBlockReward, trs.Reward.ToMei())
+	totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfBlockReward, &trs.Reward)
 	// feeBurning
 	if trs.TotalFeeMinerReceived.NotEqual(&trs.TotalFeeUserPayed) {
 		// 有销毁
@@ -351,7 +351,7 @@ func (trs *Transaction_0_Coinbase) RecoverChainState(state interfaces.ChainState
 		if e != nil {
 			return e
 		}
-		totalsupply.DoSub(stores.TotalSupplyStoreTypeOfBurningFee, burnamt.ToMei())
+		totalsupply.DoSubAmount(stores.TotalSupplyStoreTypeOfBurningFee, burnamt)
 	}
 	// update total supply
 	e3 := state.UpdateSetTotalSupply(totalsupply)
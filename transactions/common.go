@@ -22,6 +22,18 @@ func CheckoutAction_4_DiamondCreateFromTx(tx interfaces.Transaction) *actions.Ac
 	return nil
 }
 
+// 取出交易备注的action
+func CheckoutAction_29_TransactionMemoFromTx(tx interfaces.Transaction) *actions.Action_29_TransactionMemo {
+
+	for _, act := range tx.GetActions() {
+		if memoact, ok := act.(*actions.Action_29_TransactionMemo); ok {
+			return memoact
+		}
+	}
+
+	return nil
+}
+
 // 创建一笔普通转账交易
 func CreateOneTxOfSimpleTransfer(payacc *account.Account, toaddr fields.Address,
 	amount *fields.Amount, fee *fields.Amount, timestamp int64) *Transaction_2_Simple {
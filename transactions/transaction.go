@@ -1,7 +1,7 @@
 package transactions
 
 import (
-	"fmt"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/interfaces"
 )
 
@@ -18,12 +18,12 @@ func NewTransactionByType(ty uint8) (interfaces.Transaction, error) {
 		return new(Transaction_2_Simple), nil
 		////////////////////     END      ////////////////////
 	}
-	return nil, fmt.Errorf("Cannot find Transaction type of " + string(ty))
+	return nil, errs.New(errs.CodeUnsupportedKind, "Cannot find Transaction type of %d", ty)
 }
 
 func ParseTransaction(buf []byte, seek uint32) (interfaces.Transaction, uint32, error) {
 	if seek >= uint32(len(buf)) {
-		return nil, 0, fmt.Errorf("buf length over range")
+		return nil, 0, errs.New(errs.CodeMalformed, "buf length over range")
 	}
 	ty := uint8(buf[seek])
 	var trx, e1 = NewTransactionByType(ty)
@@ -31,5 +31,8 @@ func ParseTransaction(buf []byte, seek uint32) (interfaces.Transaction, uint32,
 		return nil, 0, e1
 	}
 	var mv, err = trx.Parse(buf, seek+1)
-	return trx, mv, err
+	if err != nil {
+		return trx, mv, errs.FromOr(errs.CodeMalformed, err)
+	}
+	return trx, mv, nil
 }
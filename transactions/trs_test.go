@@ -6,6 +6,7 @@ import (
 	"github.com/hacash/core/account"
 	"github.com/hacash/core/actions"
 	"github.com/hacash/core/fields"
+	"github.com/hacash/core/sys"
 	"testing"
 	"time"
 )
@@ -143,3 +144,77 @@ func Test_coinbaseCopy(t *testing.T) {
 	fmt.Println(clonetrs.Serialize())
 
 }
+
+// 带备注的转账交易
+func Test_transfer_memo(t *testing.T) {
+
+	payacc := account.CreateAccountByPassword("123456")
+	toacc := account.CreateAccountByPassword("qwerty")
+
+	tx, _ := NewEmptyTransaction_2_Simple(payacc.Address)
+	tx.Fee = *fields.NewAmountSmall(1, 246)
+	tx.Timestamp = 1618839281
+	tx.AppendAction(actions.NewAction_1_SimpleToTransfer(toacc.Address, fields.NewAmountSmall(5, 248)))
+
+	// 加密备注
+	memo, e := actions.NewAction_29_TransactionMemoByEncrypt(toacc.PublicKey, []byte("deposit tag 88650102"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	tx.AppendAction(memo)
+	tx.FillNeedSigns(map[string][]byte{string(payacc.Address): payacc.PrivateKey}, nil)
+
+	// 解析并解密
+	txbody, _ := tx.Serialize()
+	tx2, _, e := ParseTransaction(txbody, 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	memo2 := CheckoutAction_29_TransactionMemoFromTx(tx2)
+	if memo2 == nil {
+		t.Fatal("memo not find")
+	}
+	plain, e := memo2.DecryptMemo(toacc.PrivateKey)
+	if e != nil || string(plain) != "deposit tag 88650102" {
+		t.Fatal("decrypt memo fail", e)
+	}
+	fmt.Println("tx size:", tx.Size(), "fee purity:", tx.FeePurity(), memo2.Describe())
+
+	// 主网启用之前为空操作
+	tx.SetMessage("ABC123")
+	if len(tx.GetActions()) != 2 || string(tx.GetMessage()) != "" {
+		t.Fatal("set message must be no-op before enabled")
+	}
+	sys.TestDebugLocalDevelopmentMark = true
+	defer func() { sys.TestDebugLocalDevelopmentMark = false }()
+
+	// 明文备注替换已有的备注
+	tx.SetMessage("ABC123")
+	fmt.Println("message:", tx.GetMessage(), "actions:", len(tx.GetActions()))
+	if len(tx.GetActions()) != 2 || string(tx.GetMessage()) != "ABC123" {
+		t.Fatal("set message must replace memo")
+	}
+	tx.SetMessage("XYZ")
+	memonum := 0
+	for _, act := range tx.GetActions() {
+		if _, ok := act.(*actions.Action_29_TransactionMemo); ok {
+			memonum++
+		}
+	}
+	if memonum != 1 || string(tx.GetMessage()) != "XYZ" {
+		t.Fatal("memo must not be duplicated")
+	}
+	// 空内容不添加备注
+	tx3, _ := NewEmptyTransaction_2_Simple(payacc.Address)
+	tx3.SetMessage("")
+	if len(tx3.GetActions()) != 0 {
+		t.Fatal("empty message must not append memo")
+	}
+	// 超过 16 字节的备注完整返回
+	longmsg := "order 20261019-0001 paid by wire"
+	tx.SetMessage(fields.TrimString16(longmsg))
+	if string(tx.GetMessage()) != longmsg || tx.GetMemo() != longmsg {
+		t.Fatal("message must not be trimmed")
+	}
+
+}
@@ -2,14 +2,15 @@ package transactions
 
 import (
 	"bytes"
-	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/hacash/core/account"
 	"github.com/hacash/core/actions"
+	"github.com/hacash/core/errs"
 	"github.com/hacash/core/fields"
 	"github.com/hacash/core/interfaces"
+	"github.com/hacash/core/sys"
 )
 
 type Transaction_2_Simple struct {
@@ -33,7 +34,7 @@ type Transaction_2_Simple struct {
 
 func NewEmptyTransaction_2_Simple(master fields.Address) (*Transaction_2_Simple, error) {
 	if !master.IsValid() {
-		return nil, fmt.Errorf("Master Address is InValid ")
+		return nil, errs.New(errs.CodeInvalidParameter, "Master Address is InValid ")
 	}
 	timeUnix := time.Now().Unix()
 	return &Transaction_2_Simple{
@@ -239,7 +240,7 @@ func (trs *Transaction_2_Simple) HashFresh() fields.Hash {
 
 func (trs *Transaction_2_Simple) AppendAction(action interfaces.Action) error {
 	if trs.ActionCount >= 65530 {
-		return fmt.Errorf("Actions too much")
+		return errs.New(errs.CodeInvalidParameter, "Actions too much")
 	}
 	if trs.Actions == nil {
 		trs.ActionCount = 0 // 初始化
@@ -254,7 +255,7 @@ func (trs *Transaction_2_Simple) AppendAction(action interfaces.Action) error {
 // 从 actions 拿出需要签名的地址
 func (trs *Transaction_2_Simple) RequestSignAddresses(reqs []fields.Address, dropfeeaddr bool) ([]fields.Address, error) {
 	if !trs.MainAddress.IsValid() {
-		return nil, fmt.Errorf("Master Address is InValid ")
+		return nil, errs.New(errs.CodeInvalidParameter, "Master Address is InValid ")
 	}
 	requests := make([]fields.Address, 0, 32)
 	// 另外新加的需要验证的
@@ -351,11 +352,11 @@ func (trs *Transaction_2_Simple) addOneSign(hash []byte, addrPrivates map[string
 	// 判断私钥是否存在
 	privitebytes, has := addrPrivates[string(address)]
 	if !has {
-		return fmt.Errorf("Private Key '" + account.Base58CheckEncode(address) + "' necessary")
+		return errs.New(errs.CodeNotFound, "Private Key '%s' necessary", account.Base58CheckEncode(address))
 	}
 	privite, e1 := account.GetAccountByPriviteKey(privitebytes)
 	if e1 != nil {
-		return fmt.Errorf("Private Key '" + account.Base58CheckEncode(address) + "' error")
+		return errs.New(errs.CodeBadSignature, "Private Key '%s' error", account.Base58CheckEncode(address))
 	}
 	// 判断签名是否已经存在，如果存在则去掉重新加入
 	var alreadly = -1
@@ -368,7 +369,7 @@ func (trs *Transaction_2_Simple) addOneSign(hash []byte, addrPrivates map[string
 	// 计算签名
 	signature, e2 := privite.Private.Sign(hash)
 	if e2 != nil {
-		return fmt.Errorf("Private Key '" + account.Base58CheckEncode(address) + "' do sign error")
+		return errs.New(errs.CodeBadSignature, "Private Key '%s' do sign error", account.Base58CheckEncode(address))
 	}
 	sigObjSave := fields.Sign{
 		PublicKey: privite.PublicKey,
@@ -437,12 +438,12 @@ func (trs *Transaction_2_Simple) VerifyAllNeedSigns() (bool, error) {
 	// 验证主签名（包括手续费）
 	ok, e := verifyOneSignature(allSigns, trs.MainAddress, hashWithFee)
 	if e != nil || !ok {
-		return ok, e
+		return ok, errs.FromOr(errs.CodeBadSignature, e).WithTxHash(hashNoFee)
 	}
 	// 验证全部需要验证的签名 // 去掉主地址
 	requests, e := trs.RequestSignAddresses(nil, true)
 	if e != nil {
-		return false, e
+		return false, errs.FromOr(errs.CodeInvalidParameter, e).WithTxHash(hashNoFee)
 	}
 	if requests == nil || len(requests) == 0 {
 		return true, nil // 没有其他需要验证
@@ -451,7 +452,7 @@ func (trs *Transaction_2_Simple) VerifyAllNeedSigns() (bool, error) {
 	for i := 0; i < len(requests); i++ {
 		ok, e := verifyOneSignature(allSigns, requests[i], hashNoFee)
 		if e != nil || !ok {
-			return ok, e
+			return ok, errs.FromOr(errs.CodeBadSignature, e).WithTxHash(hashNoFee)
 		}
 	}
 	// 验证成功
@@ -462,10 +463,17 @@ func verifyOneSignature(allSigns map[string]fields.Sign, address fields.Address,
 
 	main, ok := allSigns[string(address)]
 	if !ok {
-		return false, fmt.Errorf("address %s signature not find!", address.ToReadable())
+		return false, errs.New(errs.CodeBadSignature, "signature not find!").WithAddress(address)
 	}
 	// 检查签名
-	return account.CheckSignByHash32(hash, main.PublicKey, main.Signature)
+	ok, e := account.CheckSignByHash32(hash, main.PublicKey, main.Signature)
+	if e != nil || !ok {
+		if e == nil {
+			return false, errs.New(errs.CodeBadSignature, "verify signature fail.").WithAddress(address)
+		}
+		return false, errs.Wrap(errs.CodeBadSignature, e).WithAddress(address)
+	}
+	return true, nil
 }
 
 // 需要的余额检查
@@ -478,7 +486,7 @@ func (trs *Transaction_2_Simple) WriteinChainState(state interfaces.ChainStateOp
 	// 检查 fee size
 	if state.GetPendingBlockHeight() > 200000 {
 		if trs.Fee.Size() > 2+4 {
-			return fmt.Errorf("BlockHeight more than 20w trs.Fee.Size() must less than 6 bytes.")
+			return errs.New(errs.CodeInvalidParameter, "BlockHeight more than 20w trs.Fee.Size() must less than 6 bytes.").WithTxHash(trs.Hash())
 		}
 	}
 	// actions
@@ -486,11 +494,15 @@ func (trs *Transaction_2_Simple) WriteinChainState(state interfaces.ChainStateOp
 		trs.Actions[i].SetBelongTransaction(trs)
 		e := trs.Actions[i].WriteinChainState(state)
 		if e != nil {
-			return e
+			return errs.From(e).WithTxHash(trs.Hash()).WithHeight(state.GetPendingBlockHeight())
 		}
 	}
 	// 扣除手续费
-	return actions.DoSubBalanceFromChainState(state, trs.MainAddress, trs.Fee)
+	e := actions.DoSubBalanceFromChainState(state, trs.MainAddress, trs.Fee)
+	if e != nil {
+		return errs.From(e).WithTxHash(trs.Hash()).WithHeight(state.GetPendingBlockHeight())
+	}
+	return nil
 }
 
 func (trs *Transaction_2_Simple) RecoverChainState(state interfaces.ChainStateOperation) error {
@@ -558,9 +570,39 @@ func (trs *Transaction_2_Simple) GetTimestamp() uint64 { // 时间戳
 	return uint64(trs.Timestamp)
 }
 
-func (trs *Transaction_2_Simple) SetMessage(fields.TrimString16) {
+// 设置明文备注，已有备注则替换
+// 备注 action 主网启用之前保持原来的空操作，空内容不添加备注
+func (trs *Transaction_2_Simple) SetMessage(msg fields.TrimString16) {
+	if !sys.TestDebugLocalDevelopmentMark {
+		return // 暂未启用
+	}
+	if len(msg) == 0 {
+		return
+	}
+	memo, e := actions.NewAction_29_TransactionMemoByPlaintext(string(msg))
+	if e != nil {
+		return
+	}
+	for i, act := range trs.Actions {
+		if _, ok := act.(*actions.Action_29_TransactionMemo); ok {
+			trs.Actions[i] = memo
+			trs.ClearHash() // 重置哈希缓存
+			return
+		}
+	}
+	trs.AppendAction(memo)
 }
 
+// 返回完整的明文备注（不按 16 字节截断），加密备注需要调用 DecryptMemo
 func (trs *Transaction_2_Simple) GetMessage() fields.TrimString16 {
-	return fields.TrimString16("")
+	return fields.TrimString16(trs.GetMemo())
+}
+
+// 完整的明文备注
+func (trs *Transaction_2_Simple) GetMemo() string {
+	memo := CheckoutAction_29_TransactionMemoFromTx(trs)
+	if memo == nil || memo.MemoType != actions.TransactionMemoTypePlaintext {
+		return ""
+	}
+	return string(memo.Memo.Message)
 }